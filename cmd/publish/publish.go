@@ -0,0 +1,84 @@
+// Package publish provides the command that renders a note subtree as a static website.
+package publish
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ray-d-song/yan/internal/convert"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/spf13/cobra"
+)
+
+// maxAssetSize limits the size of a single image copied into the site
+const maxAssetSize = 10 << 20
+
+var (
+	outDir  string
+	baseURL string
+)
+
+var PublishCmd = &cobra.Command{
+	Use:   "publish <noteID>",
+	Short: "Publish a note and its children as a static website",
+	Long: `Render a note and all of its children into a directory of static HTML
+pages with navigation, search and a sitemap, ready to be hosted anywhere.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			fmt.Printf("Invalid note id: %s\n", args[0])
+			os.Exit(1)
+		}
+
+		if err := publish(cmd.Context(), id); err != nil {
+			fmt.Printf("Error publishing note %d: %v\n", id, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Published note %d to %s\n", id, outDir)
+	},
+}
+
+func init() {
+	PublishCmd.Flags().StringVarP(&outDir, "out", "o", "./site", "Directory to write the site to")
+	PublishCmd.Flags().StringVar(&baseURL, "base-url", "", "Public URL of the site, used in the sitemap")
+}
+
+func publish(ctx context.Context, id int64) error {
+	db, err := infra.NewDB(infra.LoadConfig())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	noteRepo := repo.NewNoteRepo(db)
+	root, err := noteRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("note not found")
+		}
+		return err
+	}
+	if !root.IsNormal() {
+		return errors.New("note is in the trash")
+	}
+
+	descendants, err := noteRepo.GetDescendants(ctx, id, model.NoteStatusNormal)
+	if err != nil {
+		return err
+	}
+
+	tree := model.BuildNoteTree(root, descendants)
+	tree.StripEncrypted()
+
+	return convert.WriteSite(ctx, tree, outDir, convert.SiteOptions{
+		BaseURL:    baseURL,
+		FetchImage: convert.NewImageFetcher(nil, maxAssetSize),
+	})
+}
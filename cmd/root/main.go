@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/ray-d-song/yan/cmd/migrate"
+	"github.com/ray-d-song/yan/cmd/publish"
 	"github.com/ray-d-song/yan/cmd/server"
 	"github.com/spf13/cobra"
 )
@@ -17,6 +18,7 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(server.ServerCmd)
 	rootCmd.AddCommand(migrate.MigrateCmd)
+	rootCmd.AddCommand(publish.PublishCmd)
 }
 
 func main() {
@@ -0,0 +1,94 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type BlockHandler struct {
+	blockService service.BlockService
+}
+
+func NewBlockHandler(blockService service.BlockService) *BlockHandler {
+	return &BlockHandler{
+		blockService: blockService,
+	}
+}
+
+// RegisterRoutes registers the block reference routes
+// Note: Auth middleware should be applied before calling this
+func (h *BlockHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:blockId", h.ResolveBlock)
+}
+
+// RegisterNoteRoutes registers the note outline route
+// Note: Auth middleware should be applied before calling this
+func (h *BlockHandler) RegisterNoteRoutes(g *gin.RouterGroup) {
+	g.GET("/:id/outline", h.GetOutline)
+}
+
+// GetOutline returns the table of contents and the blocks of a note
+// GET /api/v1/notes/:id/outline
+func (h *BlockHandler) GetOutline(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	outline, err := h.blockService.Outline(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrNoteEncrypted {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, outline)
+}
+
+// ResolveBlock returns the block a ((block-id)) reference points at,
+// together with the title of its note
+// GET /api/v1/blocks/:blockId
+func (h *BlockHandler) ResolveBlock(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	block, err := h.blockService.Resolve(c.Request.Context(), c.Param("blockId"), userID)
+	if err != nil {
+		if err == service.ErrBlockNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, block)
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type CalendarHandler struct {
+	calendarService service.CalendarService
+}
+
+func NewCalendarHandler(calendarService service.CalendarService) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: calendarService,
+	}
+}
+
+// RegisterRoutes registers the calendar token routes
+// Note: Auth middleware should be applied before calling this
+func (h *CalendarHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListTokens)
+	g.POST("", h.CreateToken)
+	g.DELETE("/:id", h.RevokeToken)
+}
+
+// RegisterFeedRoutes registers the feed route, which is authenticated by the
+// secret token in its path instead of the session
+func (h *CalendarHandler) RegisterFeedRoutes(g *gin.RouterGroup) {
+	g.GET("/feed/:token", h.GetFeed)
+}
+
+// CreateCalendarTokenRequest represents the create calendar token request payload
+type CreateCalendarTokenRequest struct {
+	Name string `json:"name"`
+}
+
+// ListTokens lists the user's calendar tokens
+// GET /api/v1/calendar/tokens
+func (h *CalendarHandler) ListTokens(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	tokens, err := h.calendarService.ListTokens(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreateToken creates a calendar token. The response holds the secret token
+// and feed URL, which cannot be retrieved again.
+// POST /api/v1/calendar/tokens
+func (h *CalendarHandler) CreateToken(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req CreateCalendarTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	token, err := h.calendarService.CreateToken(c.Request.Context(), userID, req.Name)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCalendarToken) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+// RevokeToken deletes a calendar token, disabling its feed
+// DELETE /api/v1/calendar/tokens/:id
+func (h *CalendarHandler) RevokeToken(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid calendar token id")
+		return
+	}
+
+	if err := h.calendarService.RevokeToken(c.Request.Context(), id, userID); err != nil {
+		if err == service.ErrCalendarTokenNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrCalendarTokenUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GetFeed serves the iCalendar feed of a token
+// GET /api/v1/calendar/feed/:token.ics
+func (h *CalendarHandler) GetFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	feed, err := h.calendarService.Feed(c.Request.Context(), token)
+	if err != nil {
+		if err == service.ErrCalendarTokenNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", feed)
+}
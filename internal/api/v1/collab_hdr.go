@@ -0,0 +1,150 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+const (
+	collabWriteWait    = 10 * time.Second
+	collabPongWait     = 60 * time.Second
+	collabPingInterval = 25 * time.Second
+	collabMaxMessage   = 1 << 20
+)
+
+type CollabHandler struct {
+	collabService service.CollabService
+	// The default origin check rejects cross-site pages, which would
+	// otherwise connect with the user's session cookie
+	upgrader websocket.Upgrader
+}
+
+func NewCollabHandler(collabService service.CollabService) *CollabHandler {
+	return &CollabHandler{
+		collabService: collabService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+		},
+	}
+}
+
+// RegisterRoutes registers the collaborative editing route
+// Note: Auth middleware should be applied before calling this
+func (h *CollabHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:id/collab", h.Collaborate)
+}
+
+// Collaborate upgrades to a WebSocket joining the note's editing session.
+// Messages are JSON documents of service.CollabMessage: the server opens
+// with a sync of the whole document, then relays the update and awareness
+// messages of the other editors.
+// GET /api/v1/notes/:id/collab
+func (h *CollabHandler) Collaborate(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		c.String(http.StatusBadRequest, "websocket upgrade required")
+		return
+	}
+
+	peer, err := h.collabService.Join(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrNoteEncrypted {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if err == service.ErrCollabClosed {
+			c.String(http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has answered with an error already
+		h.collabService.Leave(peer)
+		return
+	}
+	defer conn.Close()
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		h.writeMessages(conn, peer)
+	}()
+
+	conn.SetReadLimit(collabMaxMessage)
+	_ = conn.SetReadDeadline(time.Now().Add(collabPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(collabPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(collabPongWait))
+		h.collabService.Receive(peer, data)
+	}
+
+	h.collabService.Leave(peer)
+	<-written
+}
+
+// writeMessages sends the peer's messages and keep-alive pings until the
+// peer is disconnected
+func (h *CollabHandler) writeMessages(conn *websocket.Conn, peer *service.CollabPeer) {
+	ping := time.NewTicker(collabPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case data, ok := <-peer.Outgoing():
+			_ = conn.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if !ok {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				// Unblock the reader if the peer was dropped by the server
+				conn.Close()
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				conn.Close()
+				return
+			}
+		case <-ping.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type DuplicateHandler struct {
+	duplicateService service.DuplicateService
+	mergeService     service.MergeService
+}
+
+func NewDuplicateHandler(duplicateService service.DuplicateService, mergeService service.MergeService) *DuplicateHandler {
+	return &DuplicateHandler{
+		duplicateService: duplicateService,
+		mergeService:     mergeService,
+	}
+}
+
+// RegisterRoutes registers the duplicate note routes
+// Note: Auth middleware should be applied before calling this
+func (h *DuplicateHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/duplicates", h.ListDuplicates)
+	g.POST("/duplicates/merge", h.MergeDuplicate)
+}
+
+// MergeDuplicateRequest represents the merge duplicate request payload
+type MergeDuplicateRequest struct {
+	TargetID int64 `json:"target_id" binding:"required"`
+	SourceID int64 `json:"source_id" binding:"required"`
+}
+
+// ListDuplicates lists the clusters of near-duplicate notes of the current
+// workspace found by the last detection run
+// GET /api/v1/notes/duplicates
+func (h *DuplicateHandler) ListDuplicates(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	clusters, err := h.duplicateService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, clusters)
+}
+
+// MergeDuplicate merges a duplicate into the note kept, and returns the
+// merged note
+// POST /api/v1/notes/duplicates/merge
+func (h *DuplicateHandler) MergeDuplicate(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req MergeDuplicateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note, err := h.mergeService.Merge(c.Request.Context(), req.TargetID, []int64{req.TargetID, req.SourceID}, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMerge) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrNoteEncrypted {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
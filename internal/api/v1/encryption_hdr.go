@@ -0,0 +1,298 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type EncryptionHandler struct {
+	encryptionService service.EncryptionService
+}
+
+func NewEncryptionHandler(encryptionService service.EncryptionService) *EncryptionHandler {
+	return &EncryptionHandler{
+		encryptionService: encryptionService,
+	}
+}
+
+// RegisterRoutes registers the key envelope routes
+// Note: Auth middleware should be applied before calling this
+func (h *EncryptionHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.GetEnvelope)
+	g.POST("", h.SetupEnvelope)
+	g.PUT("", h.RotateEnvelope)
+	g.POST("/recover", h.RecoverEnvelope)
+}
+
+// RegisterNoteRoutes registers the route encrypting and decrypting notes
+// Note: Auth middleware should be applied before calling this
+func (h *EncryptionHandler) RegisterNoteRoutes(g *gin.RouterGroup) {
+	g.PUT("/:id/encryption", h.SetNoteEncryption)
+}
+
+// KeyEnvelopeRequest represents a key envelope built by the client. Binary
+// values are base64 encoded.
+type KeyEnvelopeRequest struct {
+	Algorithm          string `json:"algorithm"`
+	KDF                string `json:"kdf"`
+	Salt               string `json:"salt"`
+	Iterations         int    `json:"iterations"`
+	MemoryKiB          int    `json:"memory_kib"`
+	Parallelism        int    `json:"parallelism"`
+	WrappedKey         string `json:"wrapped_key"`
+	Proof              string `json:"proof"`
+	RecoveryWrappedKey string `json:"recovery_wrapped_key"`
+	RecoveryProof      string `json:"recovery_proof"`
+}
+
+// RotateKeyEnvelopeRequest represents the rotate and recover key envelope
+// request payload. Proof proves the current passphrase, or the recovery key
+// when recovering.
+type RotateKeyEnvelopeRequest struct {
+	Version  int                  `json:"version" binding:"required"`
+	Proof    string               `json:"proof" binding:"required"`
+	Envelope KeyEnvelopeRequest   `json:"envelope"`
+	Rekey    bool                 `json:"rekey"`
+	Notes    []NoteContentRequest `json:"notes"`
+}
+
+// NoteContentRequest is the new content of one note
+type NoteContentRequest struct {
+	ID      int64  `json:"id" binding:"required"`
+	Content string `json:"content"`
+}
+
+// SetNoteEncryptionRequest represents the set note encryption request
+// payload
+type SetNoteEncryptionRequest struct {
+	Encrypted bool                 `json:"encrypted"`
+	Subtree   bool                 `json:"subtree"`
+	Notes     []NoteContentRequest `json:"notes"`
+}
+
+func (r KeyEnvelopeRequest) input() service.KeyEnvelopeInput {
+	return service.KeyEnvelopeInput{
+		Algorithm:          r.Algorithm,
+		KDF:                r.KDF,
+		Salt:               r.Salt,
+		Iterations:         r.Iterations,
+		MemoryKiB:          r.MemoryKiB,
+		Parallelism:        r.Parallelism,
+		WrappedKey:         r.WrappedKey,
+		Proof:              r.Proof,
+		RecoveryWrappedKey: r.RecoveryWrappedKey,
+		RecoveryProof:      r.RecoveryProof,
+	}
+}
+
+func (r RotateKeyEnvelopeRequest) rotation() service.KeyRotation {
+	return service.KeyRotation{
+		Version:  r.Version,
+		Proof:    r.Proof,
+		Envelope: r.Envelope.input(),
+		Rekey:    r.Rekey,
+		Notes:    noteContents(r.Notes),
+	}
+}
+
+func noteContents(notes []NoteContentRequest) []repo.NoteContent {
+	contents := make([]repo.NoteContent, 0, len(notes))
+	for _, n := range notes {
+		contents = append(contents, repo.NoteContent{ID: n.ID, Content: n.Content})
+	}
+	return contents
+}
+
+// GetEnvelope returns the user's key envelope
+// GET /api/v1/keys
+func (h *EncryptionHandler) GetEnvelope(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	envelope, err := h.encryptionService.GetEnvelope(c.Request.Context(), userID)
+	if err != nil {
+		if err == service.ErrKeyEnvelopeNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, envelope)
+}
+
+// SetupEnvelope stores the user's first key envelope
+// POST /api/v1/keys
+func (h *EncryptionHandler) SetupEnvelope(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req KeyEnvelopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	envelope, err := h.encryptionService.Setup(c.Request.Context(), userID, req.input())
+	if err != nil {
+		if err == service.ErrKeyEnvelopeExists {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidKeyEnvelope) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, envelope)
+}
+
+// RotateEnvelope replaces the key envelope after proving the current
+// passphrase
+// PUT /api/v1/keys
+func (h *EncryptionHandler) RotateEnvelope(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req RotateKeyEnvelopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	envelope, err := h.encryptionService.Rotate(c.Request.Context(), userID, req.rotation())
+	if err != nil {
+		if err == service.ErrKeyEnvelopeNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrKeyProofMismatch {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrKeyEnvelopeOutOfDate {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidKeyEnvelope) || errors.Is(err, service.ErrInvalidNoteEncryption) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, envelope)
+}
+
+// RecoverEnvelope sets a new passphrase after proving the recovery key
+// POST /api/v1/keys/recover
+func (h *EncryptionHandler) RecoverEnvelope(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req RotateKeyEnvelopeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	envelope, err := h.encryptionService.Recover(c.Request.Context(), userID, req.rotation())
+	if err != nil {
+		if err == service.ErrKeyEnvelopeNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrKeyProofMismatch {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrKeyEnvelopeOutOfDate {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidKeyEnvelope) || errors.Is(err, service.ErrInvalidNoteEncryption) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, envelope)
+}
+
+// SetNoteEncryption encrypts or decrypts a note, optionally with its
+// subtree, storing the content the client sends
+// PUT /api/v1/notes/:id/encryption
+func (h *EncryptionHandler) SetNoteEncryption(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	var req SetNoteEncryptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notes, err := h.encryptionService.SetNoteEncryption(c.Request.Context(), id, userID, req.Encrypted, req.Subtree, noteContents(req.Notes))
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrKeyEnvelopeNotFound {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidNoteEncryption) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
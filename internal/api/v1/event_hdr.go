@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+const (
+	// eventKeepAlive is the interval of comments keeping idle streams open
+	// through proxies
+	eventKeepAlive = 25 * time.Second
+	// eventRetryMs is the reconnect delay suggested to clients
+	eventRetryMs = 3000
+)
+
+type EventHandler struct {
+	eventStream *service.EventStream
+}
+
+func NewEventHandler(eventStream *service.EventStream) *EventHandler {
+	return &EventHandler{
+		eventStream: eventStream,
+	}
+}
+
+// RegisterRoutes registers the event stream route
+// Note: Auth middleware should be applied before calling this
+func (h *EventHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.StreamEvents)
+}
+
+// StreamEvents streams the user's note changes as Server-Sent Events. Every
+// event carries its cursor as the event ID; reconnecting with Last-Event-ID
+// (or ?last_event_id= on the first connection) replays what was missed. The
+// stream opens with a stream.ready event, or stream.reset when missed events
+// are gone and the client must reload.
+// GET /api/v1/events
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	cursorStr := c.GetHeader("Last-Event-ID")
+	if cursorStr == "" {
+		cursorStr = c.Query("last_event_id")
+	}
+	var cursor int64
+	if cursorStr != "" {
+		cursor, err = strconv.ParseInt(cursorStr, 10, 64)
+		if err != nil || cursor < 0 {
+			c.String(http.StatusBadRequest, "invalid last event id")
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	sub, err := h.eventStream.Subscribe(ctx, userID, cursor)
+	if err != nil {
+		if err == service.ErrStreamClosed {
+			c.String(http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer h.eventStream.Unsubscribe(sub)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	last := sub.Cursor
+	opening := "stream.ready"
+	if sub.Reset {
+		opening = "stream.reset"
+	}
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatInt(last, 10),
+		Event: opening,
+		Retry: eventRetryMs,
+		Data:  gin.H{"cursor": last},
+	})
+
+	send := func(e *model.StreamEvent) {
+		if e.ID <= last {
+			return
+		}
+		c.Render(-1, sse.Event{
+			Id:    strconv.FormatInt(e.ID, 10),
+			Event: e.Type,
+			Data:  []byte(e.Data),
+		})
+		last = e.ID
+	}
+	for _, e := range sub.Replay {
+		send(e)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events:
+			if !ok {
+				// Dropped or shutting down; the client reconnects and resumes
+				return
+			}
+			send(e)
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			if _, err := c.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type ExportHandler struct {
+	exportService service.ExportService
+}
+
+func NewExportHandler(exportService service.ExportService) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+	}
+}
+
+// RegisterRoutes registers all export routes
+// Note: Auth middleware should be applied before calling this
+func (h *ExportHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:id/export", h.ExportNote)
+}
+
+// ExportNote exports a note and its subtree as a downloadable file
+// GET /api/v1/notes/:id/export?format=opml|epub|docx
+func (h *ExportHandler) ExportNote(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		c.String(http.StatusBadRequest, "format is required")
+		return
+	}
+
+	res, err := h.exportService.Export(c.Request.Context(), id, userID, format)
+	if err != nil {
+		if err == service.ErrUnsupportedFormat {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": res.Filename,
+	}))
+	c.Data(http.StatusOK, res.ContentType, res.Data)
+}
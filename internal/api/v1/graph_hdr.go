@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type GraphHandler struct {
+	graphService service.GraphService
+}
+
+func NewGraphHandler(graphService service.GraphService) *GraphHandler {
+	return &GraphHandler{
+		graphService: graphService,
+	}
+}
+
+// RegisterRoutes registers the knowledge graph routes
+// Note: Auth middleware should be applied before calling this
+func (h *GraphHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.GetGraph)
+}
+
+// GetGraph returns the notes of the current workspace as nodes, with their
+// parent, link, embed and shared tag edges
+// GET /api/v1/graph?root_id=1&depth=2&tag=work&tag=ideas
+func (h *GraphHandler) GetGraph(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	filter := service.GraphFilter{Tags: c.QueryArray("tag")}
+
+	if rootIDStr := c.Query("root_id"); rootIDStr != "" {
+		rootID, err := strconv.ParseInt(rootIDStr, 10, 64)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid root_id")
+			return
+		}
+		filter.RootID = rootID
+	}
+
+	if depthStr := c.Query("depth"); depthStr != "" {
+		depth, err := strconv.Atoi(depthStr)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid depth")
+			return
+		}
+		filter.Depth = depth
+	}
+
+	graph, err := h.graphService.Graph(c.Request.Context(), userID, filter)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidGraphFilter) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
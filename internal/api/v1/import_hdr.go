@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/convert"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+// maxImportSize bounds an import upload, leaving room for the multipart
+// framing around the largest document
+const maxImportSize = convert.MaxDOCXSize + 1<<20
+
+type ImportHandler struct {
+	importService service.ImportService
+}
+
+func NewImportHandler(importService service.ImportService) *ImportHandler {
+	return &ImportHandler{
+		importService: importService,
+	}
+}
+
+// RegisterRoutes registers all import routes
+// Note: Auth middleware should be applied before calling this
+func (h *ImportHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.POST("/import", h.ImportNotes)
+}
+
+// ImportNotes imports an uploaded document as new notes
+// POST /api/v1/notes/import?format=opml|docx&parent_id=123&split=2 (multipart form, field "file")
+func (h *ImportHandler) ImportNotes(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	format := c.Query("format")
+	if format == "" {
+		c.String(http.StatusBadRequest, "format is required")
+		return
+	}
+
+	opts := service.ImportOptions{Format: format}
+	if parentIDStr := c.Query("parent_id"); parentIDStr != "" && parentIDStr != "null" && parentIDStr != "0" {
+		id, err := strconv.ParseInt(parentIDStr, 10, 64)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid parent_id")
+			return
+		}
+		opts.ParentID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	if splitStr := c.Query("split"); splitStr != "" {
+		split, err := strconv.Atoi(splitStr)
+		if err != nil || split < 0 || split > 9 {
+			c.String(http.StatusBadRequest, "invalid split")
+			return
+		}
+		opts.SplitLevel = split
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.String(http.StatusRequestEntityTooLarge, "file is too large")
+			return
+		}
+		c.String(http.StatusBadRequest, "file is required")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	opts.Title = strings.TrimSuffix(fileHeader.Filename, filepath.Ext(fileHeader.Filename))
+
+	notes, err := h.importService.Import(c.Request.Context(), file, opts, userID)
+	if err != nil {
+		if err == service.ErrUnsupportedFormat || err == service.ErrInvalidParentNote ||
+			errors.Is(err, service.ErrInvalidImport) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, notes)
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type LinkHandler struct {
+	linkService service.LinkService
+}
+
+func NewLinkHandler(linkService service.LinkService) *LinkHandler {
+	return &LinkHandler{
+		linkService: linkService,
+	}
+}
+
+// RegisterRoutes registers the note link routes
+// Note: Auth middleware should be applied before calling this
+func (h *LinkHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:id/embedded-by", h.ListEmbeddedBy)
+}
+
+// ListEmbeddedBy lists the notes that embed a note, directly or through
+// other notes, so the impact of editing it can be seen
+// GET /api/v1/notes/:id/embedded-by
+func (h *LinkHandler) ListEmbeddedBy(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	notes, err := h.linkService.EmbeddedBy(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
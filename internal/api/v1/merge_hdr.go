@@ -0,0 +1,140 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type MergeHandler struct {
+	mergeService service.MergeService
+}
+
+func NewMergeHandler(mergeService service.MergeService) *MergeHandler {
+	return &MergeHandler{
+		mergeService: mergeService,
+	}
+}
+
+// RegisterRoutes registers the merge and split routes
+// Note: Auth middleware should be applied before calling this
+func (h *MergeHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.POST("/merge", h.MergeNotes)
+	g.POST("/:id/split", h.SplitNote)
+}
+
+// MergeNotesRequest represents the merge notes request payload. NoteIDs
+// gives the order of the merged content and includes TargetID.
+type MergeNotesRequest struct {
+	TargetID int64   `json:"target_id" binding:"required"`
+	NoteIDs  []int64 `json:"note_ids" binding:"required"`
+}
+
+// SplitNoteRequest represents the split note request payload
+type SplitNoteRequest struct {
+	Level int `json:"level" binding:"required"`
+}
+
+// SplitNoteResponse is the split note and the notes split off it
+type SplitNoteResponse struct {
+	Note     *model.Note   `json:"note"`
+	Children []*model.Note `json:"children"`
+}
+
+// MergeNotes merges notes into one of them in the given order, and returns
+// the merged note
+// POST /api/v1/notes/merge
+func (h *MergeHandler) MergeNotes(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req MergeNotesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note, err := h.mergeService.Merge(c.Request.Context(), req.TargetID, req.NoteIDs, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMerge) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrNoteEncrypted {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+// SplitNote breaks a note into child notes at its headings of a level
+// POST /api/v1/notes/:id/split
+func (h *MergeHandler) SplitNote(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	var req SplitNoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note, children, err := h.mergeService.Split(c.Request.Context(), id, req.Level, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSplit) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrNoteEncrypted {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, SplitNoteResponse{
+		Note:     note,
+		Children: children,
+	})
+}
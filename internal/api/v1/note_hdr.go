@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ray-d-song/yan/internal/infra"
@@ -13,12 +14,20 @@ import (
 )
 
 type NoteHandler struct {
-	noteService service.NoteService
+	noteService     service.NoteService
+	propertyService service.PropertyService
+	suggestService  service.SuggestService
 }
 
-func NewNoteHandler(noteService service.NoteService) *NoteHandler {
+func NewNoteHandler(
+	noteService service.NoteService,
+	propertyService service.PropertyService,
+	suggestService service.SuggestService,
+) *NoteHandler {
 	return &NoteHandler{
-		noteService: noteService,
+		noteService:     noteService,
+		propertyService: propertyService,
+		suggestService:  suggestService,
 	}
 }
 
@@ -26,6 +35,7 @@ func NewNoteHandler(noteService service.NoteService) *NoteHandler {
 // Note: Auth middleware should be applied before calling this
 func (h *NoteHandler) RegisterRoutes(g *gin.RouterGroup) {
 	g.POST("", h.CreateNote)
+	g.GET("/suggest", h.SuggestNotes)
 	g.GET("/:id", h.GetNote)
 	g.GET("", h.ListNotes)
 	g.PUT("/:id", h.UpdateNote)
@@ -146,11 +156,46 @@ func (h *NoteHandler) GetNote(c *gin.Context) {
 		return
 	}
 
+	// Opens rank notes in suggestions; failing to count one is not an error
+	if err := h.suggestService.RecordOpen(c.Request.Context(), note.ID, userID); err != nil {
+		infra.Errorf("failed to record open of note %d: %v", note.ID, err)
+	}
+
 	c.JSON(http.StatusOK, note)
 }
 
-// ListNotes retrieves notes by parent_id or all user notes
-// GET /api/v1/notes?parent_id=123&status=1
+// SuggestNotes offers notes of the current workspace by title, for the link
+// picker and the quick switcher
+// GET /api/v1/notes/suggest?prefix=pro&limit=10
+func (h *NoteHandler) SuggestNotes(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	suggestions, err := h.suggestService.Suggest(c.Request.Context(), userID, c.Query("prefix"), limit)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// ListNotes retrieves notes by parent_id or all user notes, optionally
+// filtered by property values
+// GET /api/v1/notes?parent_id=123&status=1&prop.status=done
 func (h *NoteHandler) ListNotes(c *gin.Context) {
 	userID, err := infra.UserIDFromCtx(c)
 	if err != nil {
@@ -170,19 +215,24 @@ func (h *NoteHandler) ListNotes(c *gin.Context) {
 		return
 	}
 
-	// Get favorites
+	// Property filters are given as prop.<name>=<value>
+	propFilters := make(map[string]string)
+	for key, values := range c.Request.URL.Query() {
+		if name, ok := strings.CutPrefix(key, "prop."); ok && name != "" {
+			propFilters[name] = values[0]
+		}
+	}
+
+	var notes []*model.Note
 	if favoriteStr == "true" || favoriteStr == "1" {
-		notes, err := h.noteService.GetFavorites(c.Request.Context(), userID)
+		// Get favorites
+		notes, err = h.noteService.GetFavorites(c.Request.Context(), userID)
 		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, notes)
-		return
-	}
-
-	// Get notes by parent_id
-	if parentIDStr != "" {
+	} else if parentIDStr != "" {
+		// Get notes by parent_id
 		var parentID sql.NullInt64
 		if parentIDStr == "null" || parentIDStr == "0" {
 			parentID = sql.NullInt64{Valid: false}
@@ -195,7 +245,7 @@ func (h *NoteHandler) ListNotes(c *gin.Context) {
 			parentID = sql.NullInt64{Int64: id, Valid: true}
 		}
 
-		notes, err := h.noteService.GetByParentID(c.Request.Context(), parentID, userID, status)
+		notes, err = h.noteService.GetByParentID(c.Request.Context(), parentID, userID, status)
 		if err != nil {
 			if err == service.ErrInvalidParentNote {
 				c.String(http.StatusBadRequest, err.Error())
@@ -208,12 +258,16 @@ func (h *NoteHandler) ListNotes(c *gin.Context) {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
-		c.JSON(http.StatusOK, notes)
-		return
+	} else {
+		// Get all notes for user
+		notes, err = h.noteService.GetByUserID(c.Request.Context(), userID, status)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
 	}
 
-	// Get all notes for user
-	notes, err := h.noteService.GetByUserID(c.Request.Context(), userID, status)
+	notes, err = h.propertyService.FilterNotes(c.Request.Context(), userID, notes, propFilters)
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
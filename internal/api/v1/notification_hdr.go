@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type NotificationHandler struct {
+	notificationService service.NotificationService
+}
+
+func NewNotificationHandler(notificationService service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: notificationService,
+	}
+}
+
+// RegisterRoutes registers all notification inbox routes
+// Note: Auth middleware should be applied before calling this
+func (h *NotificationHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListNotifications)
+	g.PUT("/read", h.MarkAllRead)
+	g.PUT("/:id/read", h.MarkRead)
+}
+
+// ListNotifications lists the user's inbox, newest first
+// GET /api/v1/notifications?unread=true
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	unreadStr := c.Query("unread")
+	unreadOnly := unreadStr == "true" || unreadStr == "1"
+
+	notifications, err := h.notificationService.List(c.Request.Context(), userID, unreadOnly)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+// MarkRead marks a notification as read
+// PUT /api/v1/notifications/:id/read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid notification id")
+		return
+	}
+
+	if err := h.notificationService.MarkRead(c.Request.Context(), id, userID); err != nil {
+		if err == service.ErrNotificationNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNotificationUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// MarkAllRead marks every notification of the user as read
+// PUT /api/v1/notifications/read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	if err := h.notificationService.MarkAllRead(c.Request.Context(), userID); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
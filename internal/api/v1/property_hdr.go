@@ -0,0 +1,174 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type PropertyHandler struct {
+	propertyService service.PropertyService
+}
+
+func NewPropertyHandler(propertyService service.PropertyService) *PropertyHandler {
+	return &PropertyHandler{
+		propertyService: propertyService,
+	}
+}
+
+// RegisterRoutes registers all note property routes
+// Note: Auth middleware should be applied before calling this
+func (h *PropertyHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:id/properties", h.ListProperties)
+	g.PUT("/:id/properties/:name", h.SetProperty)
+	g.DELETE("/:id/properties/:name", h.DeleteProperty)
+}
+
+// SetPropertyRequest represents the set property request payload. Value may
+// be a string, number or boolean.
+type SetPropertyRequest struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// ListProperties lists the properties of a note
+// GET /api/v1/notes/:id/properties
+func (h *PropertyHandler) ListProperties(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	props, err := h.propertyService.List(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, props)
+}
+
+// SetProperty creates or replaces a property of a note
+// PUT /api/v1/notes/:id/properties/:name
+func (h *PropertyHandler) SetProperty(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	var req SetPropertyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var value string
+	switch v := req.Value.(type) {
+	case string:
+		value = v
+	case float64, bool:
+		value = fmt.Sprint(v)
+	case nil:
+	default:
+		c.String(http.StatusBadRequest, "value must be a string, number or boolean")
+		return
+	}
+
+	prop := &model.NoteProperty{
+		Name:  c.Param("name"),
+		Type:  req.Type,
+		Value: value,
+	}
+
+	if err := h.propertyService.Set(c.Request.Context(), id, userID, prop); err != nil {
+		if errors.Is(err, service.ErrInvalidProperty) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrPropertyInFrontMatter {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, prop)
+}
+
+// DeleteProperty removes a property from a note
+// DELETE /api/v1/notes/:id/properties/:name
+func (h *PropertyHandler) DeleteProperty(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	if err := h.propertyService.Delete(c.Request.Context(), id, userID, c.Param("name")); err != nil {
+		if err == service.ErrPropertyInFrontMatter {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if err == service.ErrPropertyNotFound || err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
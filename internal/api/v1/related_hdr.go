@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type RelatedHandler struct {
+	relatedService service.RelatedService
+}
+
+func NewRelatedHandler(relatedService service.RelatedService) *RelatedHandler {
+	return &RelatedHandler{
+		relatedService: relatedService,
+	}
+}
+
+// RegisterRoutes registers the related note routes
+// Note: Auth middleware should be applied before calling this
+func (h *RelatedHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:id/related", h.ListRelated)
+}
+
+// ListRelated lists the notes of the current workspace most similar to a
+// note, with the terms they share with it
+// GET /api/v1/notes/:id/related?limit=10
+func (h *RelatedHandler) ListRelated(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	related, err := h.relatedService.Related(c.Request.Context(), id, userID, limit)
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, related)
+}
@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type ReminderHandler struct {
+	reminderService service.ReminderService
+}
+
+func NewReminderHandler(reminderService service.ReminderService) *ReminderHandler {
+	return &ReminderHandler{
+		reminderService: reminderService,
+	}
+}
+
+// RegisterRoutes registers all reminder routes
+// Note: Auth middleware should be applied before calling this
+func (h *ReminderHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.POST("", h.CreateReminder)
+	g.GET("", h.ListReminders)
+	g.DELETE("/:id", h.DeleteReminder)
+}
+
+// CreateReminderRequest represents the create reminder request payload
+type CreateReminderRequest struct {
+	NoteID   int64     `json:"note_id" binding:"required"`
+	TaskID   *int64    `json:"task_id"`
+	RemindAt time.Time `json:"remind_at" binding:"required"`
+	Channel  string    `json:"channel" binding:"required"`
+	Target   *string   `json:"target"`
+}
+
+// CreateReminder schedules a reminder for a note or one of its tasks
+// POST /api/v1/reminders
+func (h *ReminderHandler) CreateReminder(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req CreateReminderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reminder := &model.Reminder{
+		UserID:   userID,
+		NoteID:   req.NoteID,
+		RemindAt: req.RemindAt,
+		Channel:  req.Channel,
+	}
+
+	if req.TaskID != nil {
+		reminder.TaskID = model.NullInt64{NullInt64: sql.NullInt64{Int64: *req.TaskID, Valid: true}}
+	}
+
+	if req.Target != nil {
+		reminder.Target = model.NullString{NullString: sql.NullString{String: *req.Target, Valid: true}}
+	}
+
+	if err := h.reminderService.Create(c.Request.Context(), reminder); err != nil {
+		if errors.Is(err, service.ErrInvalidReminder) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// ListReminders lists the user's reminders
+// GET /api/v1/reminders?status=pending&note_id=123
+func (h *ReminderHandler) ListReminders(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var noteID sql.NullInt64
+	if noteIDStr := c.Query("note_id"); noteIDStr != "" {
+		id, err := strconv.ParseInt(noteIDStr, 10, 64)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid note_id")
+			return
+		}
+		noteID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	reminders, err := h.reminderService.List(c.Request.Context(), userID, c.Query("status"), noteID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, reminders)
+}
+
+// DeleteReminder deletes a reminder
+// DELETE /api/v1/reminders/:id
+func (h *ReminderHandler) DeleteReminder(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid reminder id")
+		return
+	}
+
+	if err := h.reminderService.Delete(c.Request.Context(), id, userID); err != nil {
+		if err == service.ErrReminderNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrReminderUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
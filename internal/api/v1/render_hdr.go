@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type RenderHandler struct {
+	renderService service.RenderService
+}
+
+func NewRenderHandler(renderService service.RenderService) *RenderHandler {
+	return &RenderHandler{
+		renderService: renderService,
+	}
+}
+
+// RegisterRoutes registers all render routes
+// Note: Auth middleware should be applied before calling this
+func (h *RenderHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/:id/render", h.RenderNote)
+}
+
+// RenderNote returns the content of a note rendered as sanitized HTML
+// GET /api/v1/notes/:id/render
+func (h *RenderHandler) RenderNote(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	res, err := h.renderService.Render(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrNoteEncrypted {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Let clients revalidate instead of downloading an unchanged render.
+	// updated_at has second resolution, so the content hash tells apart
+	// edits within the same second. Content of other notes and access to
+	// them can change without either, so such renders get no ETag.
+	c.Header("Cache-Control", "private, no-cache")
+	if !res.Dynamic {
+		etag := fmt.Sprintf(`"%d-%d-%x"`, res.NoteID, res.UpdatedAt.UnixNano(), res.ContentHash)
+		c.Header("ETag", etag)
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", res.HTML)
+}
@@ -0,0 +1,224 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type ReplaceHandler struct {
+	replaceService service.ReplaceService
+}
+
+func NewReplaceHandler(replaceService service.ReplaceService) *ReplaceHandler {
+	return &ReplaceHandler{
+		replaceService: replaceService,
+	}
+}
+
+// RegisterRoutes registers the find and replace routes
+// Note: Auth middleware should be applied before calling this
+func (h *ReplaceHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListReplacements)
+	g.POST("", h.ApplyReplacement)
+	g.POST("/preview", h.PreviewReplacement)
+	g.GET("/:id", h.GetReplacement)
+	g.POST("/:id/revert", h.RevertReplacement)
+}
+
+// ReplaceRequest represents the find and replace request payload. Find is
+// literal text unless Regex is set. RootID limits the change to a note and
+// its descendants and Tag to the notes carrying the tag.
+type ReplaceRequest struct {
+	Find          string `json:"find" binding:"required"`
+	Replacement   string `json:"replacement"`
+	Regex         bool   `json:"regex"`
+	CaseSensitive bool   `json:"case_sensitive"`
+	RootID        *int64 `json:"root_id"`
+	Tag           string `json:"tag"`
+}
+
+func (r ReplaceRequest) options() service.ReplaceOptions {
+	opts := service.ReplaceOptions{
+		Find:          r.Find,
+		Replacement:   r.Replacement,
+		IsRegex:       r.Regex,
+		CaseSensitive: r.CaseSensitive,
+		Tag:           r.Tag,
+	}
+	if r.RootID != nil {
+		opts.RootID = sql.NullInt64{Int64: *r.RootID, Valid: true}
+	}
+	return opts
+}
+
+// PreviewReplacement lists the matches a find and replace would change, in
+// context, without changing any note
+// POST /api/v1/replacements/preview
+func (h *ReplaceHandler) PreviewReplacement(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req ReplaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	preview, err := h.replaceService.Preview(c.Request.Context(), req.options(), userID)
+	if err != nil {
+		h.replaceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// ApplyReplacement replaces the matches in every note of the scope at once,
+// and returns the record of the replacement
+// POST /api/v1/replacements
+func (h *ReplaceHandler) ApplyReplacement(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req ReplaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	replacement, err := h.replaceService.Apply(c.Request.Context(), req.options(), userID)
+	if err != nil {
+		if err == service.ErrReplaceConflict {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		h.replaceError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, replacement)
+}
+
+// ListReplacements lists the user's replacements in their current
+// workspace, newest first
+// GET /api/v1/replacements
+func (h *ReplaceHandler) ListReplacements(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	replacements, err := h.replaceService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, replacements)
+}
+
+// GetReplacement gets a replacement by ID
+// GET /api/v1/replacements/:id
+func (h *ReplaceHandler) GetReplacement(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid replacement id")
+		return
+	}
+
+	replacement, err := h.replaceService.Get(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrReplacementNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrReplacementUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, replacement)
+}
+
+// RevertReplacement gives the notes of a replacement back their content
+// from before it. Notes edited since keep theirs and are listed as skipped.
+// POST /api/v1/replacements/:id/revert
+func (h *ReplaceHandler) RevertReplacement(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid replacement id")
+		return
+	}
+
+	result, err := h.replaceService.Revert(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrReplacementNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrReplacementUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrReplacementReverted {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// replaceError writes the response for an error of a preview or an apply
+func (h *ReplaceHandler) replaceError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrInvalidReplace) {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err == service.ErrNoteNotFound {
+		c.String(http.StatusNotFound, err.Error())
+		return
+	}
+	if err == service.ErrNoteUnauthorized {
+		c.String(http.StatusForbidden, err.Error())
+		return
+	}
+	c.String(http.StatusInternalServerError, err.Error())
+}
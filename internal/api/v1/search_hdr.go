@@ -0,0 +1,291 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/search"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type SearchHandler struct {
+	searchService service.SearchService
+}
+
+func NewSearchHandler(searchService service.SearchService) *SearchHandler {
+	return &SearchHandler{
+		searchService: searchService,
+	}
+}
+
+// RegisterRoutes registers the search route
+// Note: Auth middleware should be applied before calling this
+func (h *SearchHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.Search)
+}
+
+// RegisterSavedRoutes registers the saved search routes
+// Note: Auth middleware should be applied before calling this
+func (h *SearchHandler) RegisterSavedRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListSavedSearches)
+	g.POST("", h.CreateSavedSearch)
+	g.GET("/:id", h.GetSavedSearch)
+	g.PUT("/:id", h.UpdateSavedSearch)
+	g.DELETE("/:id", h.DeleteSavedSearch)
+	g.GET("/:id/notes", h.RunSavedSearch)
+}
+
+// SavedSearchRequest represents the create and update saved search request
+// payload
+type SavedSearchRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Query    string `json:"query" binding:"required"`
+	Position int    `json:"position"`
+}
+
+// Search finds notes of the current workspace. A malformed query is answered
+// with a JSON error pointing at the offending token.
+// GET /api/v1/search?q=tag:work -draft
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	notes, err := h.searchService.Search(c.Request.Context(), userID, c.Query("q"))
+	if err != nil {
+		var parseErr *search.ParseError
+		if errors.As(err, &parseErr) {
+			c.JSON(http.StatusBadRequest, parseErr)
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// ListSavedSearches lists the user's saved searches of the current workspace
+// GET /api/v1/saved-searches
+func (h *SearchHandler) ListSavedSearches(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	searches, err := h.searchService.ListSaved(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, searches)
+}
+
+// CreateSavedSearch saves a search in the current workspace
+// POST /api/v1/saved-searches
+func (h *SearchHandler) CreateSavedSearch(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	saved := &model.SavedSearch{
+		UserID:   userID,
+		Name:     req.Name,
+		Query:    req.Query,
+		Position: req.Position,
+	}
+	if err := h.searchService.CreateSaved(c.Request.Context(), saved); err != nil {
+		var parseErr *search.ParseError
+		if errors.As(err, &parseErr) {
+			c.JSON(http.StatusBadRequest, parseErr)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidSavedSearch) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, saved)
+}
+
+// GetSavedSearch gets a saved search by ID
+// GET /api/v1/saved-searches/:id
+func (h *SearchHandler) GetSavedSearch(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid saved search id")
+		return
+	}
+
+	saved, err := h.searchService.GetSaved(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrSavedSearchNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrSavedSearchUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// UpdateSavedSearch renames, moves or changes the query of a saved search
+// PUT /api/v1/saved-searches/:id
+func (h *SearchHandler) UpdateSavedSearch(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid saved search id")
+		return
+	}
+
+	var req SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	saved := &model.SavedSearch{
+		ID:       id,
+		Name:     req.Name,
+		Query:    req.Query,
+		Position: req.Position,
+	}
+	if err := h.searchService.UpdateSaved(c.Request.Context(), saved, userID); err != nil {
+		var parseErr *search.ParseError
+		if errors.As(err, &parseErr) {
+			c.JSON(http.StatusBadRequest, parseErr)
+			return
+		}
+		if errors.Is(err, service.ErrInvalidSavedSearch) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrSavedSearchNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrSavedSearchUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, saved)
+}
+
+// DeleteSavedSearch deletes a saved search
+// DELETE /api/v1/saved-searches/:id
+func (h *SearchHandler) DeleteSavedSearch(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid saved search id")
+		return
+	}
+
+	if err := h.searchService.DeleteSaved(c.Request.Context(), id, userID); err != nil {
+		if err == service.ErrSavedSearchNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrSavedSearchUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// RunSavedSearch lists the notes a saved search matches now
+// GET /api/v1/saved-searches/:id/notes
+func (h *SearchHandler) RunSavedSearch(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid saved search id")
+		return
+	}
+
+	notes, err := h.searchService.RunSaved(c.Request.Context(), id, userID)
+	if err != nil {
+		var parseErr *search.ParseError
+		if errors.As(err, &parseErr) {
+			c.JSON(http.StatusBadRequest, parseErr)
+			return
+		}
+		if err == service.ErrSavedSearchNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrSavedSearchUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
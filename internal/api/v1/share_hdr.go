@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type ShareHandler struct {
+	shareService service.ShareService
+}
+
+func NewShareHandler(shareService service.ShareService) *ShareHandler {
+	return &ShareHandler{
+		shareService: shareService,
+	}
+}
+
+// RegisterRoutes registers the note sharing routes
+// Note: Auth middleware should be applied before calling this
+func (h *ShareHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("/shared", h.ListSharedWithMe)
+	g.GET("/:id/permissions", h.ListPermissions)
+	g.PUT("/:id/permissions", h.GrantPermission)
+	g.DELETE("/:id/permissions/:userId", h.RevokePermission)
+}
+
+// GrantPermissionRequest represents the grant permission request payload
+type GrantPermissionRequest struct {
+	User string `json:"user" binding:"required"` // email or username
+	Role string `json:"role" binding:"required"`
+}
+
+// ListSharedWithMe lists the notes other users shared with the user
+// GET /api/v1/notes/shared
+func (h *ShareHandler) ListSharedWithMe(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	notes, err := h.shareService.SharedWithMe(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// ListPermissions lists who the note is shared with
+// GET /api/v1/notes/:id/permissions
+func (h *ShareHandler) ListPermissions(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	permissions, err := h.shareService.List(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// GrantPermission shares the note and its subtree with another user, or
+// changes their role
+// PUT /api/v1/notes/:id/permissions
+func (h *ShareHandler) GrantPermission(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	var req GrantPermissionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	permission, err := h.shareService.Grant(c.Request.Context(), id, userID, req.User, req.Role)
+	if err != nil {
+		if err == service.ErrNoteNotFound || err == service.ErrUserNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidShare) || err == service.ErrUsernameAmbiguous {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, permission)
+}
+
+// RevokePermission stops sharing the note with a user. Users may also
+// revoke their own access.
+// DELETE /api/v1/notes/:id/permissions/:userId
+func (h *ShareHandler) RevokePermission(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid note id")
+		return
+	}
+
+	granteeIDStr := c.Param("userId")
+	granteeID, err := strconv.ParseInt(granteeIDStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := h.shareService.Revoke(c.Request.Context(), id, userID, granteeID); err != nil {
+		if err == service.ErrNoteNotFound || err == service.ErrShareNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,44 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type TagHandler struct {
+	tagService service.TagService
+}
+
+func NewTagHandler(tagService service.TagService) *TagHandler {
+	return &TagHandler{
+		tagService: tagService,
+	}
+}
+
+// RegisterRoutes registers the tag routes
+// Note: Auth middleware should be applied before calling this
+func (h *TagHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListTags)
+}
+
+// ListTags lists the tags of the current workspace with their note counts
+// GET /api/v1/tags
+func (h *TagHandler) ListTags(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	tags, err := h.tagService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type TaskHandler struct {
+	taskService service.TaskService
+}
+
+func NewTaskHandler(taskService service.TaskService) *TaskHandler {
+	return &TaskHandler{
+		taskService: taskService,
+	}
+}
+
+// RegisterRoutes registers all task routes
+// Note: Auth middleware should be applied before calling this
+func (h *TaskHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListTasks)
+	g.PUT("/:id", h.UpdateTask)
+}
+
+// UpdateTaskRequest represents the update task request payload. Without done
+// the task is toggled.
+type UpdateTaskRequest struct {
+	Done *bool `json:"done"`
+}
+
+// ListTasks lists the tasks of all notes, open ones by default
+// GET /api/v1/tasks?status=open|done|all&due_before=2026-01-31&due_after=2026-01-01&parent_id=123
+func (h *TaskHandler) ListTasks(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	filter := repo.TaskFilter{UserID: userID}
+
+	switch c.DefaultQuery("status", "open") {
+	case "open":
+		done := model.TaskOpen
+		filter.Done = &done
+	case "done":
+		done := model.TaskDone
+		filter.Done = &done
+	case "all":
+	default:
+		c.String(http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	for param, bound := range map[string]*string{"due_before": &filter.DueBefore, "due_after": &filter.DueAfter} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(markdown.DueDateLayout, value); err != nil {
+			c.String(http.StatusBadRequest, "invalid "+param)
+			return
+		}
+		*bound = value
+	}
+
+	if parentIDStr := c.Query("parent_id"); parentIDStr != "" {
+		id, err := strconv.ParseInt(parentIDStr, 10, 64)
+		if err != nil {
+			c.String(http.StatusBadRequest, "invalid parent_id")
+			return
+		}
+		filter.ParentID = sql.NullInt64{Int64: id, Valid: true}
+	}
+
+	tasks, err := h.taskService.List(c.Request.Context(), filter)
+	if err != nil {
+		if err == service.ErrInvalidParentNote {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+// UpdateTask checks or unchecks a task, rewriting its checkbox in the note
+// PUT /api/v1/tasks/:id
+func (h *TaskHandler) UpdateTask(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	var req UpdateTaskRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var task *model.Task
+	if req.Done == nil {
+		task, err = h.taskService.GetByID(c.Request.Context(), id, userID)
+		if err == nil {
+			task, err = h.taskService.SetDone(c.Request.Context(), id, userID, !task.IsDone())
+		}
+	} else {
+		task, err = h.taskService.SetDone(c.Request.Context(), id, userID, *req.Done)
+	}
+	if err != nil {
+		if err == service.ErrTaskNotFound || err == service.ErrNoteNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrTaskUnauthorized || err == service.ErrNoteUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrTaskOutOfDate {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
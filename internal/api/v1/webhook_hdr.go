@@ -0,0 +1,354 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(webhookService service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// RegisterRoutes registers all webhook routes
+// Note: Auth middleware should be applied before calling this
+func (h *WebhookHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListWebhooks)
+	g.POST("", h.CreateWebhook)
+	g.GET("/:id", h.GetWebhook)
+	g.PUT("/:id", h.UpdateWebhook)
+	g.DELETE("/:id", h.DeleteWebhook)
+	g.POST("/:id/secret", h.RotateSecret)
+	g.GET("/:id/deliveries", h.ListDeliveries)
+	g.POST("/:id/deliveries/:deliveryId/redeliver", h.Redeliver)
+}
+
+// CreateWebhookRequest represents the create webhook request payload. Without
+// events the webhook receives every note event.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+}
+
+// UpdateWebhookRequest represents the update webhook request payload
+type UpdateWebhookRequest struct {
+	URL      *string   `json:"url"`
+	Events   *[]string `json:"events"`
+	IsActive *bool     `json:"is_active"`
+}
+
+// ListWebhooks lists the user's webhooks
+// GET /api/v1/webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	webhooks, err := h.webhookService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// CreateWebhook registers a webhook. The response holds the signing secret,
+// which is not shown again.
+// POST /api/v1/webhooks
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := h.webhookService.Create(c.Request.Context(), &model.Webhook{
+		UserID: userID,
+		URL:    req.URL,
+		Events: req.Events,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhook) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// GetWebhook gets a webhook by ID
+// GET /api/v1/webhooks/:id
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	webhook, err := h.webhookService.GetByID(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrWebhookNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWebhookUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook changes the URL or events of a webhook, or disables and
+// enables it. Enabling clears the failure record of a webhook that was
+// disabled for failing.
+// PUT /api/v1/webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	webhook, err := h.webhookService.GetByID(ctx, id, userID)
+	if err != nil {
+		if err == service.ErrWebhookNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWebhookUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if req.URL != nil || req.Events != nil {
+		if req.URL != nil {
+			webhook.URL = *req.URL
+		}
+		if req.Events != nil {
+			webhook.Events = *req.Events
+		}
+		if err := h.webhookService.Update(ctx, webhook, userID); err != nil {
+			if errors.Is(err, service.ErrInvalidWebhook) {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	if req.IsActive != nil {
+		if err := h.webhookService.SetActive(ctx, id, userID, *req.IsActive); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	webhook, err = h.webhookService.GetByID(ctx, id, userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+// DELETE /api/v1/webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	if err := h.webhookService.Delete(c.Request.Context(), id, userID); err != nil {
+		if err == service.ErrWebhookNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWebhookUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// RotateSecret replaces the signing secret of a webhook and returns the new
+// one
+// POST /api/v1/webhooks/:id/secret
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	webhook, err := h.webhookService.RotateSecret(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrWebhookNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWebhookUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// ListDeliveries lists the latest deliveries of a webhook, newest first
+// GET /api/v1/webhooks/:id/deliveries
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrWebhookNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWebhookUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver sends the payload of a past delivery again
+// POST /api/v1/webhooks/:id/deliveries/:deliveryId/redeliver
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(c.Request.Context(), id, deliveryID, userID)
+	if err != nil {
+		if err == service.ErrWebhookNotFound || err == service.ErrWebhookDeliveryNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWebhookUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrWebhookDisabled {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
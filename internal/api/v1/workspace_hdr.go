@@ -0,0 +1,622 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
+)
+
+type WorkspaceHandler struct {
+	workspaceService service.WorkspaceService
+}
+
+func NewWorkspaceHandler(workspaceService service.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: workspaceService,
+	}
+}
+
+// RegisterRoutes registers all workspace-related routes
+// Note: Auth middleware should be applied before calling this
+func (h *WorkspaceHandler) RegisterRoutes(g *gin.RouterGroup) {
+	g.GET("", h.ListWorkspaces)
+	g.POST("", h.CreateWorkspace)
+	g.GET("/current", h.GetCurrentWorkspace)
+	g.GET("/invitations", h.ListMyInvitations)
+	g.POST("/invitations/:invitationId/accept", h.AcceptInvitation)
+	g.POST("/invitations/:invitationId/decline", h.DeclineInvitation)
+	g.GET("/:id", h.GetWorkspace)
+	g.PUT("/:id", h.RenameWorkspace)
+	g.DELETE("/:id", h.DeleteWorkspace)
+	g.POST("/:id/switch", h.SwitchWorkspace)
+	g.GET("/:id/members", h.ListMembers)
+	g.PUT("/:id/members/:userId", h.UpdateMember)
+	g.DELETE("/:id/members/:userId", h.RemoveMember)
+	g.GET("/:id/invitations", h.ListInvitations)
+	g.POST("/:id/invitations", h.Invite)
+	g.DELETE("/:id/invitations/:invitationId", h.CancelInvitation)
+}
+
+// WorkspaceRequest represents the create and rename workspace request payload
+type WorkspaceRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// UpdateMemberRequest represents the update member request payload
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// InviteRequest represents the invite request payload
+type InviteRequest struct {
+	User string `json:"user" binding:"required"` // email or username
+	Role string `json:"role" binding:"required"`
+}
+
+// ListWorkspaces lists the user's workspaces, marking the current one
+// GET /api/v1/workspaces
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	workspaces, err := h.workspaceService.List(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, workspaces)
+}
+
+// CreateWorkspace creates a workspace owned by the user
+// POST /api/v1/workspaces
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	var req WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	workspace, err := h.workspaceService.Create(c.Request.Context(), userID, req.Name)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWorkspace) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, workspace)
+}
+
+// GetCurrentWorkspace returns the workspace the user works in
+// GET /api/v1/workspaces/current
+func (h *WorkspaceHandler) GetCurrentWorkspace(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	workspace, err := h.workspaceService.Current(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+// ListMyInvitations lists the user's pending invitations
+// GET /api/v1/workspaces/invitations
+func (h *WorkspaceHandler) ListMyInvitations(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	invitations, err := h.workspaceService.ListMyInvitations(c.Request.Context(), userID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// AcceptInvitation joins the workspace of an invitation
+// POST /api/v1/workspaces/invitations/:invitationId/accept
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	invitationIDStr := c.Param("invitationId")
+	invitationID, err := strconv.ParseInt(invitationIDStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid invitation id")
+		return
+	}
+
+	workspace, err := h.workspaceService.AcceptInvitation(c.Request.Context(), invitationID, userID)
+	if err != nil {
+		if err == service.ErrInvitationNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+// DeclineInvitation declines an invitation
+// POST /api/v1/workspaces/invitations/:invitationId/decline
+func (h *WorkspaceHandler) DeclineInvitation(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	invitationIDStr := c.Param("invitationId")
+	invitationID, err := strconv.ParseInt(invitationIDStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid invitation id")
+		return
+	}
+
+	if err := h.workspaceService.DeclineInvitation(c.Request.Context(), invitationID, userID); err != nil {
+		if err == service.ErrInvitationNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GetWorkspace retrieves a workspace of the user
+// GET /api/v1/workspaces/:id
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	workspace, err := h.workspaceService.GetByID(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+// RenameWorkspace renames a workspace
+// PUT /api/v1/workspaces/:id
+func (h *WorkspaceHandler) RenameWorkspace(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	var req WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.workspaceService.Rename(c.Request.Context(), id, userID, req.Name); err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidWorkspace) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// DeleteWorkspace deletes an empty workspace
+// DELETE /api/v1/workspaces/:id
+func (h *WorkspaceHandler) DeleteWorkspace(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	if err := h.workspaceService.Delete(c.Request.Context(), id, userID); err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidWorkspace) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceNotEmpty {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// SwitchWorkspace makes a workspace the user's current one
+// POST /api/v1/workspaces/:id/switch
+func (h *WorkspaceHandler) SwitchWorkspace(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	workspace, err := h.workspaceService.Switch(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, workspace)
+}
+
+// ListMembers lists the members of a workspace
+// GET /api/v1/workspaces/:id/members
+func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// UpdateMember changes the role of a member
+// PUT /api/v1/workspaces/:id/members/:userId
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	memberIDStr := c.Param("userId")
+	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.workspaceService.UpdateMemberRole(c.Request.Context(), id, userID, memberID, req.Role); err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrMemberNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidWorkspace) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// RemoveMember removes a member from a workspace. Members may remove
+// themselves to leave it.
+// DELETE /api/v1/workspaces/:id/members/:userId
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	memberIDStr := c.Param("userId")
+	memberID, err := strconv.ParseInt(memberIDStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(c.Request.Context(), id, userID, memberID); err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrMemberNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidWorkspace) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// ListInvitations lists the pending invitations of a workspace
+// GET /api/v1/workspaces/:id/invitations
+func (h *WorkspaceHandler) ListInvitations(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	invitations, err := h.workspaceService.ListInvitations(c.Request.Context(), id, userID)
+	if err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// Invite invites a user, by email or username, to a workspace
+// POST /api/v1/workspaces/:id/invitations
+func (h *WorkspaceHandler) Invite(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	var req InviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invitation, err := h.workspaceService.Invite(c.Request.Context(), id, userID, req.User, req.Role)
+	if err != nil {
+		if err == service.ErrWorkspaceNotFound || err == service.ErrUserNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		if err == service.ErrAlreadyMember {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrInvalidWorkspace) || err == service.ErrUsernameAmbiguous {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// CancelInvitation cancels a pending invitation of a workspace
+// DELETE /api/v1/workspaces/:id/invitations/:invitationId
+func (h *WorkspaceHandler) CancelInvitation(c *gin.Context) {
+	userID, err := infra.UserIDFromCtx(c)
+	if err != nil {
+		c.String(http.StatusUnauthorized, err.Error())
+		c.Abort()
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid workspace id")
+		return
+	}
+
+	invitationIDStr := c.Param("invitationId")
+	invitationID, err := strconv.ParseInt(invitationIDStr, 10, 64)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid invitation id")
+		return
+	}
+
+	if err := h.workspaceService.CancelInvitation(c.Request.Context(), id, userID, invitationID); err != nil {
+		if err == service.ErrWorkspaceNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrInvitationNotFound {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		if err == service.ErrWorkspaceUnauthorized {
+			c.String(http.StatusForbidden, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
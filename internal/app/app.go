@@ -6,6 +6,7 @@ import (
 	v1 "github.com/ray-d-song/yan/internal/api/v1"
 	"github.com/ray-d-song/yan/internal/infra"
 	"github.com/ray-d-song/yan/internal/mdw"
+	"github.com/ray-d-song/yan/internal/notify"
 	"github.com/ray-d-song/yan/internal/repo"
 	"github.com/ray-d-song/yan/internal/service"
 	"go.uber.org/fx"
@@ -28,27 +29,142 @@ func New() *fx.App {
 			// repo
 			repo.NewUserRepo,
 			repo.NewNoteRepo,
+			repo.NewPropertyRepo,
+			repo.NewTaskRepo,
+			repo.NewReminderRepo,
+			repo.NewNotificationRepo,
+			repo.NewCalendarTokenRepo,
+			repo.NewWebhookRepo,
+			repo.NewWebhookDeliveryRepo,
+			repo.NewStreamEventRepo,
+			repo.NewNotePermissionRepo,
+			repo.NewWorkspaceRepo,
+			repo.NewWorkspaceInvitationRepo,
+			repo.NewKeyEnvelopeRepo,
+			repo.NewNoteBlockRepo,
+			repo.NewNoteLinkRepo,
+			repo.NewNoteTagRepo,
+			repo.NewGraphRepo,
+			repo.NewSearchRepo,
+			repo.NewSavedSearchRepo,
+			repo.NewNoteOpenRepo,
+			repo.NewNoteTermRepo,
+			repo.NewNoteDuplicateRepo,
+			repo.NewReplacementRepo,
+
+			// notifier
+			asNotifier(notify.NewSMTPNotifier),
+			asNotifier(notify.NewWebhookNotifier),
+			asNotifier(notify.NewInboxNotifier),
 
 			// service
+			service.NewNoteEvents,
 			service.NewUserService,
+			service.NewWorkspaceService,
 			service.NewNoteService,
+			service.NewImportService,
+			service.NewBlockService,
+			service.NewLinkService,
+			service.NewTagService,
+			service.NewGraphService,
+			service.NewSearchService,
+			service.NewSuggestService,
+			service.NewRelatedService,
+			service.NewDuplicateService,
+			service.NewDuplicateDetector,
+			service.NewMergeService,
+			service.NewReplaceService,
+			service.NewExportService,
+			service.NewRenderService,
+			service.NewPropertyService,
+			service.NewTaskService,
+			service.NewReminderService,
+			service.NewNotificationService,
+			service.NewCalendarService,
+			service.NewWebhookDispatcher,
+			service.NewWebhookService,
+			service.NewEventStream,
+			service.NewCollabService,
+			service.NewShareService,
+			service.NewEncryptionService,
+			fx.Annotate(
+				service.NewReminderScheduler,
+				fx.ParamTags(``, ``, ``, ``, ``, `group:"notifiers"`),
+			),
 
 			// handler
 			v1.NewUserHandler,
 			v1.NewNoteHandler,
+			v1.NewExportHandler,
+			v1.NewImportHandler,
+			v1.NewRenderHandler,
+			v1.NewPropertyHandler,
+			v1.NewTaskHandler,
+			v1.NewReminderHandler,
+			v1.NewNotificationHandler,
+			v1.NewCalendarHandler,
+			v1.NewWebhookHandler,
+			v1.NewEventHandler,
+			v1.NewCollabHandler,
+			v1.NewShareHandler,
+			v1.NewWorkspaceHandler,
+			v1.NewEncryptionHandler,
+			v1.NewBlockHandler,
+			v1.NewLinkHandler,
+			v1.NewTagHandler,
+			v1.NewGraphHandler,
+			v1.NewSearchHandler,
+			v1.NewRelatedHandler,
+			v1.NewDuplicateHandler,
+			v1.NewMergeHandler,
+			v1.NewReplaceHandler,
 		),
 		fx.Invoke(
 			RegisterLifecycle,
+			RegisterScheduler,
+			RegisterWebhookDispatcher,
+			RegisterEventStream,
+			RegisterCollab,
+			RegisterSearchIndex,
+			RegisterDuplicateDetector,
 			RegisterRoutes,
 		),
 	)
 }
 
+// asNotifier adds a notifier constructor to the group of delivery channels
+func asNotifier(constructor any) any {
+	return fx.Annotate(constructor, fx.ResultTags(`group:"notifiers"`))
+}
+
 // RegisterRoutes registers all application routes with appropriate middleware
 func RegisterRoutes(
 	apiV1 *gin.RouterGroup,
 	userHandler *v1.UserHandler,
 	noteHandler *v1.NoteHandler,
+	exportHandler *v1.ExportHandler,
+	importHandler *v1.ImportHandler,
+	renderHandler *v1.RenderHandler,
+	propertyHandler *v1.PropertyHandler,
+	taskHandler *v1.TaskHandler,
+	reminderHandler *v1.ReminderHandler,
+	notificationHandler *v1.NotificationHandler,
+	calendarHandler *v1.CalendarHandler,
+	webhookHandler *v1.WebhookHandler,
+	eventHandler *v1.EventHandler,
+	collabHandler *v1.CollabHandler,
+	shareHandler *v1.ShareHandler,
+	workspaceHandler *v1.WorkspaceHandler,
+	encryptionHandler *v1.EncryptionHandler,
+	blockHandler *v1.BlockHandler,
+	linkHandler *v1.LinkHandler,
+	tagHandler *v1.TagHandler,
+	graphHandler *v1.GraphHandler,
+	searchHandler *v1.SearchHandler,
+	relatedHandler *v1.RelatedHandler,
+	duplicateHandler *v1.DuplicateHandler,
+	mergeHandler *v1.MergeHandler,
+	replaceHandler *v1.ReplaceHandler,
 	store *infra.DBStore,
 	userService service.UserService,
 ) {
@@ -62,4 +178,86 @@ func RegisterRoutes(
 	notesGroup := apiV1.Group("/notes")
 	notesGroup.Use(authMiddleware)
 	noteHandler.RegisterRoutes(notesGroup)
+	exportHandler.RegisterRoutes(notesGroup)
+	importHandler.RegisterRoutes(notesGroup)
+	renderHandler.RegisterRoutes(notesGroup)
+	propertyHandler.RegisterRoutes(notesGroup)
+	collabHandler.RegisterRoutes(notesGroup)
+	shareHandler.RegisterRoutes(notesGroup)
+	encryptionHandler.RegisterNoteRoutes(notesGroup)
+	blockHandler.RegisterNoteRoutes(notesGroup)
+	linkHandler.RegisterRoutes(notesGroup)
+	relatedHandler.RegisterRoutes(notesGroup)
+	duplicateHandler.RegisterRoutes(notesGroup)
+	mergeHandler.RegisterRoutes(notesGroup)
+
+	// Register find and replace routes with auth protection
+	replacementsGroup := apiV1.Group("/replacements")
+	replacementsGroup.Use(authMiddleware)
+	replaceHandler.RegisterRoutes(replacementsGroup)
+
+	// Register workspace routes with auth protection
+	workspacesGroup := apiV1.Group("/workspaces")
+	workspacesGroup.Use(authMiddleware)
+	workspaceHandler.RegisterRoutes(workspacesGroup)
+
+	// Register encryption key routes with auth protection
+	keysGroup := apiV1.Group("/keys")
+	keysGroup.Use(authMiddleware)
+	encryptionHandler.RegisterRoutes(keysGroup)
+
+	// Register block routes with auth protection
+	blocksGroup := apiV1.Group("/blocks")
+	blocksGroup.Use(authMiddleware)
+	blockHandler.RegisterRoutes(blocksGroup)
+
+	// Register tag routes with auth protection
+	tagsGroup := apiV1.Group("/tags")
+	tagsGroup.Use(authMiddleware)
+	tagHandler.RegisterRoutes(tagsGroup)
+
+	// Register knowledge graph routes with auth protection
+	graphGroup := apiV1.Group("/graph")
+	graphGroup.Use(authMiddleware)
+	graphHandler.RegisterRoutes(graphGroup)
+
+	// Register search routes with auth protection
+	searchGroup := apiV1.Group("/search")
+	searchGroup.Use(authMiddleware)
+	searchHandler.RegisterRoutes(searchGroup)
+	savedSearchesGroup := apiV1.Group("/saved-searches")
+	savedSearchesGroup.Use(authMiddleware)
+	searchHandler.RegisterSavedRoutes(savedSearchesGroup)
+
+	// Register task routes with auth protection
+	tasksGroup := apiV1.Group("/tasks")
+	tasksGroup.Use(authMiddleware)
+	taskHandler.RegisterRoutes(tasksGroup)
+
+	// Register reminder routes with auth protection
+	remindersGroup := apiV1.Group("/reminders")
+	remindersGroup.Use(authMiddleware)
+	reminderHandler.RegisterRoutes(remindersGroup)
+
+	// Register notification routes with auth protection
+	notificationsGroup := apiV1.Group("/notifications")
+	notificationsGroup.Use(authMiddleware)
+	notificationHandler.RegisterRoutes(notificationsGroup)
+
+	// Register calendar routes; the feed is authenticated by its token
+	calendarGroup := apiV1.Group("/calendar")
+	calendarHandler.RegisterFeedRoutes(calendarGroup)
+	calendarTokensGroup := calendarGroup.Group("/tokens")
+	calendarTokensGroup.Use(authMiddleware)
+	calendarHandler.RegisterRoutes(calendarTokensGroup)
+
+	// Register webhook routes with auth protection
+	webhooksGroup := apiV1.Group("/webhooks")
+	webhooksGroup.Use(authMiddleware)
+	webhookHandler.RegisterRoutes(webhooksGroup)
+
+	// Register event stream routes with auth protection
+	eventsGroup := apiV1.Group("/events")
+	eventsGroup.Use(authMiddleware)
+	eventHandler.RegisterRoutes(eventsGroup)
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/service"
 	"go.uber.org/fx"
 	"go.uber.org/zap"
 )
@@ -37,3 +38,104 @@ func RegisterLifecycle(lc fx.Lifecycle, engine *gin.Engine, db *sqlx.DB, logger
 
 	return srv
 }
+
+// RegisterScheduler runs the reminder scheduler while the app is up. It must
+// be invoked after RegisterLifecycle so that migrations run first.
+func RegisterScheduler(lc fx.Lifecycle, scheduler *service.ReminderScheduler) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			scheduler.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return scheduler.Stop(ctx)
+		},
+	})
+}
+
+// RegisterWebhookDispatcher sends webhook deliveries while the app is up. Like
+// the scheduler it must be invoked after RegisterLifecycle.
+func RegisterWebhookDispatcher(lc fx.Lifecycle, dispatcher *service.WebhookDispatcher) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			dispatcher.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return dispatcher.Stop(ctx)
+		},
+	})
+}
+
+// RegisterEventStream ends open event streams on shutdown. Hooks stop in
+// reverse order, so this runs before the HTTP server waits for connections
+// to finish.
+func RegisterEventStream(lc fx.Lifecycle, stream *service.EventStream) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			stream.Close()
+			return nil
+		},
+	})
+}
+
+// RegisterCollab saves and closes the collaborative editing sessions on
+// shutdown
+func RegisterCollab(lc fx.Lifecycle, collab service.CollabService) {
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return collab.Close(ctx)
+		},
+	})
+}
+
+// RegisterSearchIndex indexes the notes missing from the full-text index and
+// the term vectors in the background, as after a reindex this takes longer
+// than the app may take to start. Searches miss the notes not yet indexed
+// meanwhile. Like the scheduler it must be invoked after RegisterLifecycle,
+// which migrates the database.
+func RegisterSearchIndex(lc fx.Lifecycle, searchService service.SearchService, relatedService service.RelatedService) {
+	var cancel context.CancelFunc
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				defer close(done)
+				// Errors of a cancelled run are only those of the shutdown
+				if err := searchService.IndexMissing(ctx); err != nil && ctx.Err() == nil {
+					infra.Errorf("failed to index notes for search: %v", err)
+				}
+				if err := relatedService.IndexMissing(ctx); err != nil && ctx.Err() == nil {
+					infra.Errorf("failed to compute term vectors of notes: %v", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
+
+// RegisterDuplicateDetector looks for near-duplicate notes while the app is
+// up. It must be invoked after RegisterLifecycle so that migrations run
+// first.
+func RegisterDuplicateDetector(lc fx.Lifecycle, detector *service.DuplicateDetector) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			detector.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return detector.Stop(ctx)
+		},
+	})
+}
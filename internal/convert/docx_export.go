@@ -0,0 +1,614 @@
+package convert
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+// DOCXOptions carries the document metadata that is not part of the note tree
+type DOCXOptions struct {
+	Author string
+	// FetchImage loads referenced images so they can be embedded. Images
+	// that cannot be loaded are replaced by their alt text.
+	FetchImage ImageFetcher
+}
+
+const (
+	// docxBulletNumID is the numbering instance shared by all bullet lists
+	docxBulletNumID = 1
+	// docxMaxImageWidth is 6 inches in EMU, the usable width of a Letter/A4 page
+	docxMaxImageWidth = 6 * 914400
+	docxEMUPerPixel   = 9525
+)
+
+type docxRel struct {
+	ID       string
+	Type     string
+	Target   string
+	External bool
+}
+
+type docxMedia struct {
+	Name   string
+	RelID  string
+	Data   []byte
+	Width  int
+	Height int
+}
+
+type docxWriter struct {
+	ctx   context.Context
+	opts  DOCXOptions
+	body  bytes.Buffer
+	rels  []docxRel
+	media []*docxMedia
+	// imageBySrc caches fetched images; a nil entry marks a failed fetch
+	imageBySrc map[string]*docxMedia
+	drawings   int
+	// orderedStarts holds the start value of every ordered list, which
+	// gets its own numbering instance so that numbering restarts
+	orderedStarts []int
+}
+
+type docxRunProps struct {
+	bold, italic, strike, code, link, superscript bool
+}
+
+type docxBlockCtx struct {
+	headingOffset int
+	quote         bool
+	// numID and level are set for the first paragraph of a list item
+	numID, level int
+	// indent is the list nesting of paragraphs that continue a list item
+	indent int
+}
+
+// EncodeDOCX renders the tree rooted at root as a Word document. Note titles
+// become headings by depth and the headings inside each note are shifted
+// below them.
+func EncodeDOCX(ctx context.Context, root *model.NoteNode, opts DOCXOptions) ([]byte, error) {
+	w := &docxWriter{
+		ctx:        ctx,
+		opts:       opts,
+		imageBySrc: map[string]*docxMedia{},
+	}
+	w.addRel("http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles", "styles.xml", false)
+	w.addRel("http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering", "numbering.xml", false)
+
+	root.Walk(func(node *model.NoteNode, depth int) {
+		level := min(depth+1, 9)
+		w.paragraph(fmt.Sprintf("Heading%d", level), docxBlockCtx{}, func(buf *bytes.Buffer) {
+			w.run(buf, node.Title, docxRunProps{})
+		})
+
+		src := []byte(node.Content)
+		doc := markdown.Parse(src)
+		w.blocks(doc, src, docxBlockCtx{headingOffset: level})
+	})
+
+	return w.pack(root.Title)
+}
+
+func (w *docxWriter) addRel(relType, target string, external bool) string {
+	id := fmt.Sprintf("rId%d", len(w.rels)+1)
+	w.rels = append(w.rels, docxRel{ID: id, Type: relType, Target: target, External: external})
+	return id
+}
+
+func (w *docxWriter) blocks(parent ast.Node, src []byte, bc docxBlockCtx) {
+	first := true
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		cur := bc
+		if !first {
+			// Only the first block of a list item carries the number
+			cur.numID = 0
+		}
+		first = false
+		w.block(n, src, cur)
+	}
+}
+
+func (w *docxWriter) block(n ast.Node, src []byte, bc docxBlockCtx) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		style := fmt.Sprintf("Heading%d", min(n.Level+bc.headingOffset, 9))
+		w.paragraph(style, bc, func(buf *bytes.Buffer) {
+			w.inlines(buf, n, src, docxRunProps{})
+		})
+	case *ast.Paragraph, *ast.TextBlock:
+		style := ""
+		if bc.quote {
+			style = "Quote"
+		}
+		w.paragraph(style, bc, func(buf *bytes.Buffer) {
+			w.inlines(buf, n, src, docxRunProps{})
+		})
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			text := strings.TrimRight(string(line.Value(src)), "\r\n")
+			w.paragraph("Code", bc, func(buf *bytes.Buffer) {
+				w.run(buf, text, docxRunProps{})
+			})
+			bc.numID = 0
+		}
+	case *ast.Blockquote:
+		bc.quote = true
+		w.blocks(n, src, bc)
+	case *ast.List:
+		numID := docxBulletNumID
+		if n.IsOrdered() {
+			w.orderedStarts = append(w.orderedStarts, n.Start)
+			numID = docxBulletNumID + len(w.orderedStarts)
+		}
+		level := bc.indent
+		if bc.numID != 0 {
+			// A list that opens a list item is nested in it
+			level = bc.level + 1
+		}
+		for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+			w.blocks(item, src, docxBlockCtx{
+				headingOffset: bc.headingOffset,
+				quote:         bc.quote,
+				numID:         numID,
+				level:         min(level, 8),
+				indent:        level + 1,
+			})
+		}
+	case *ast.ThematicBreak:
+		w.body.WriteString(`<w:p><w:pPr><w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="auto"/></w:pBdr></w:pPr></w:p>`)
+	case *east.Table:
+		w.table(n, src)
+	case *east.FootnoteList:
+		for fn := n.FirstChild(); fn != nil; fn = fn.NextSibling() {
+			footnote, ok := fn.(*east.Footnote)
+			if !ok {
+				continue
+			}
+			prefix := fmt.Sprintf("[%d] ", footnote.Index)
+			for c := footnote.FirstChild(); c != nil; c = c.NextSibling() {
+				w.paragraph("FootnoteText", bc, func(buf *bytes.Buffer) {
+					w.run(buf, prefix, docxRunProps{})
+					w.inlines(buf, c, src, docxRunProps{})
+				})
+				prefix = ""
+			}
+		}
+	case *ast.HTMLBlock:
+		// Raw HTML has no Word equivalent
+	default:
+		w.blocks(n, src, bc)
+	}
+}
+
+// paragraph writes a w:p with the given style. The list number of bc is
+// attached and consumed by the first paragraph only.
+func (w *docxWriter) paragraph(style string, bc docxBlockCtx, content func(buf *bytes.Buffer)) {
+	w.body.WriteString("<w:p><w:pPr>")
+	if style != "" {
+		fmt.Fprintf(&w.body, `<w:pStyle w:val="%s"/>`, style)
+	}
+	if bc.numID != 0 {
+		fmt.Fprintf(&w.body, `<w:numPr><w:ilvl w:val="%d"/><w:numId w:val="%d"/></w:numPr>`, bc.level, bc.numID)
+	} else if bc.indent > 0 {
+		fmt.Fprintf(&w.body, `<w:ind w:left="%d"/>`, 720*bc.indent)
+	}
+	w.body.WriteString("</w:pPr>")
+	content(&w.body)
+	w.body.WriteString("</w:p>")
+}
+
+func (w *docxWriter) table(t *east.Table, src []byte) {
+	cols := len(t.Alignments)
+	w.body.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="5000" w:type="pct"/></w:tblPr><w:tblGrid>`)
+	for i := 0; i < cols; i++ {
+		w.body.WriteString(`<w:gridCol/>`)
+	}
+	w.body.WriteString(`</w:tblGrid>`)
+
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		_, header := row.(*east.TableHeader)
+		w.body.WriteString("<w:tr>")
+		if header {
+			w.body.WriteString(`<w:trPr><w:tblHeader/></w:trPr>`)
+		}
+		i := 0
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			w.body.WriteString(`<w:tc><w:tcPr><w:tcW w:w="0" w:type="auto"/></w:tcPr><w:p>`)
+			if i < cols {
+				switch t.Alignments[i] {
+				case east.AlignCenter:
+					w.body.WriteString(`<w:pPr><w:jc w:val="center"/></w:pPr>`)
+				case east.AlignRight:
+					w.body.WriteString(`<w:pPr><w:jc w:val="right"/></w:pPr>`)
+				}
+			}
+			w.inlines(&w.body, cell, src, docxRunProps{bold: header})
+			w.body.WriteString(`</w:p></w:tc>`)
+			i++
+		}
+		w.body.WriteString("</w:tr>")
+	}
+	w.body.WriteString("</w:tbl>")
+	// Word needs a paragraph between adjacent tables
+	w.body.WriteString("<w:p/>")
+}
+
+func (w *docxWriter) inlines(buf *bytes.Buffer, parent ast.Node, src []byte, rp docxRunProps) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		switch n := n.(type) {
+		case *ast.Text:
+			w.run(buf, string(n.Segment.Value(src)), rp)
+			if n.HardLineBreak() {
+				buf.WriteString("<w:r><w:br/></w:r>")
+			} else if n.SoftLineBreak() {
+				w.run(buf, " ", rp)
+			}
+		case *ast.String:
+			w.run(buf, string(n.Value), rp)
+		case *ast.Emphasis:
+			inner := rp
+			if n.Level >= 2 {
+				inner.bold = true
+			} else {
+				inner.italic = true
+			}
+			w.inlines(buf, n, src, inner)
+		case *east.Strikethrough:
+			inner := rp
+			inner.strike = true
+			w.inlines(buf, n, src, inner)
+		case *ast.CodeSpan:
+			inner := rp
+			inner.code = true
+			w.run(buf, nodeText(n, src), inner)
+		case *ast.Link:
+			w.hyperlink(buf, string(n.Destination), rp, func(inner docxRunProps) {
+				w.inlines(buf, n, src, inner)
+			})
+		case *ast.AutoLink:
+			url := string(n.URL(src))
+			dest := url
+			if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(dest, "mailto:") {
+				dest = "mailto:" + dest
+			}
+			w.hyperlink(buf, dest, rp, func(inner docxRunProps) {
+				w.run(buf, url, inner)
+			})
+		case *ast.Image:
+			if !w.image(buf, string(n.Destination)) {
+				w.run(buf, nodeText(n, src), rp)
+			}
+		case *markdown.WikiLink:
+			w.run(buf, n.DisplayText(), rp)
+		case *east.TaskCheckBox:
+			if n.IsChecked {
+				w.run(buf, "☒ ", rp)
+			} else {
+				w.run(buf, "☐ ", rp)
+			}
+		case *east.FootnoteLink:
+			inner := rp
+			inner.superscript = true
+			w.run(buf, fmt.Sprintf("[%d]", n.Index), inner)
+		case *ast.RawHTML, *east.FootnoteBacklink:
+			// Not representable in Word
+		default:
+			w.inlines(buf, n, src, rp)
+		}
+	}
+}
+
+func (w *docxWriter) hyperlink(buf *bytes.Buffer, dest string, rp docxRunProps, content func(inner docxRunProps)) {
+	if rp.link || dest == "" || strings.HasPrefix(dest, "#") {
+		content(rp)
+		return
+	}
+	relID := w.addRel("http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink", dest, true)
+	fmt.Fprintf(buf, `<w:hyperlink r:id="%s">`, relID)
+	inner := rp
+	inner.link = true
+	content(inner)
+	buf.WriteString("</w:hyperlink>")
+}
+
+func (w *docxWriter) run(buf *bytes.Buffer, text string, rp docxRunProps) {
+	if text == "" {
+		return
+	}
+	buf.WriteString("<w:r><w:rPr>")
+	switch {
+	case rp.code:
+		buf.WriteString(`<w:rStyle w:val="CodeChar"/>`)
+	case rp.link:
+		buf.WriteString(`<w:rStyle w:val="Hyperlink"/>`)
+	}
+	if rp.bold {
+		buf.WriteString("<w:b/>")
+	}
+	if rp.italic {
+		buf.WriteString("<w:i/>")
+	}
+	if rp.strike {
+		buf.WriteString("<w:strike/>")
+	}
+	if rp.superscript {
+		buf.WriteString(`<w:vertAlign w:val="superscript"/>`)
+	}
+	buf.WriteString("</w:rPr>")
+
+	// Tabs must be written as elements, everything else is preserved text
+	for i, part := range strings.Split(text, "\t") {
+		if i > 0 {
+			buf.WriteString("<w:tab/>")
+		}
+		if part != "" {
+			fmt.Fprintf(buf, `<w:t xml:space="preserve">%s</w:t>`, xmlEscape(part))
+		}
+	}
+	buf.WriteString("</w:r>")
+}
+
+// image writes an inline picture and reports whether the image could be
+// embedded
+func (w *docxWriter) image(buf *bytes.Buffer, src string) bool {
+	m, seen := w.imageBySrc[src]
+	if !seen {
+		m = w.loadImage(src)
+		w.imageBySrc[src] = m
+	}
+	if m == nil {
+		return false
+	}
+
+	cx, cy := m.Width*docxEMUPerPixel, m.Height*docxEMUPerPixel
+	if cx > docxMaxImageWidth {
+		cy = cy * docxMaxImageWidth / cx
+		cx = docxMaxImageWidth
+	}
+	w.drawings++
+	id := w.drawings
+	fmt.Fprintf(buf, `<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%d" cy="%d"/><wp:docPr id="%d" name="%s"/>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main">`+
+		`<a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:nvPicPr><pic:cNvPr id="%d" name="%s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`,
+		cx, cy, id, m.Name, id, m.Name, m.RelID, cx, cy)
+	return true
+}
+
+func (w *docxWriter) loadImage(src string) *docxMedia {
+	if w.opts.FetchImage == nil {
+		return nil
+	}
+	img, err := w.opts.FetchImage(w.ctx, src)
+	if err != nil {
+		return nil
+	}
+	// Only raster formats that Word renders without a fallback
+	switch img.MediaType {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil || cfg.Width == 0 || cfg.Height == 0 {
+		return nil
+	}
+
+	m := &docxMedia{
+		Name:   fmt.Sprintf("image%d%s", len(w.media)+1, img.Ext()),
+		Data:   img.Data,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+	}
+	m.RelID = w.addRel("http://schemas.openxmlformats.org/officeDocument/2006/relationships/image", "media/"+m.Name, false)
+	w.media = append(w.media, m)
+	return m
+}
+
+func (w *docxWriter) pack(title string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	data := map[string]any{
+		"Title":         title,
+		"Author":        w.opts.Author,
+		"Created":       time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Body":          w.body.String(),
+		"Rels":          w.rels,
+		"OrderedStarts": w.orderedStarts,
+		"BulletNumID":   docxBulletNumID,
+	}
+	files := []struct {
+		name string
+		tmpl *template.Template
+	}{
+		{"[Content_Types].xml", docxContentTypesTmpl},
+		{"_rels/.rels", docxRootRelsTmpl},
+		{"docProps/core.xml", docxCoreTmpl},
+		{"word/document.xml", docxDocumentTmpl},
+		{"word/_rels/document.xml.rels", docxDocumentRelsTmpl},
+		{"word/styles.xml", docxStylesTmpl},
+		{"word/numbering.xml", docxNumberingTmpl},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.tmpl.Execute(fw, data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range w.media {
+		fw, err := zw.Create("word/media/" + m.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(m.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// nodeText returns the plain text below n
+func nodeText(n ast.Node, src []byte) string {
+	var sb strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch c := c.(type) {
+		case *ast.Text:
+			sb.Write(c.Segment.Value(src))
+		case *ast.String:
+			sb.Write(c.Value)
+		case *markdown.WikiLink:
+			sb.WriteString(c.DisplayText())
+		}
+		return ast.WalkContinue, nil
+	})
+	return sb.String()
+}
+
+// xmlEscape escapes text for XML content and drops characters XML forbids
+func xmlEscape(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
+
+var docxFuncs = template.FuncMap{
+	"esc": xmlEscape,
+	"add": func(a, b int) int { return a + b },
+	"levels": func() []int {
+		return []int{0, 1, 2, 3, 4, 5, 6, 7, 8}
+	},
+	"indent": func(level int) int { return 720 * (level + 1) },
+	"bullet": func(level int) string {
+		return []string{"•", "◦", "▪"}[level%3]
+	},
+}
+
+var docxContentTypesTmpl = template.Must(template.New("types").Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+  <Default Extension="xml" ContentType="application/xml"/>
+  <Default Extension="png" ContentType="image/png"/>
+  <Default Extension="jpg" ContentType="image/jpeg"/>
+  <Default Extension="gif" ContentType="image/gif"/>
+  <Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+  <Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+  <Override PartName="/word/numbering.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"/>
+  <Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>
+`))
+
+var docxRootRelsTmpl = template.Must(template.New("rels").Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+</Relationships>
+`))
+
+var docxCoreTmpl = template.Must(template.New("core").Funcs(docxFuncs).Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <dc:title>{{esc .Title}}</dc:title>
+  <dc:creator>{{esc .Author}}</dc:creator>
+  <dcterms:created xsi:type="dcterms:W3CDTF">{{.Created}}</dcterms:created>
+</cp:coreProperties>
+`))
+
+var docxDocumentTmpl = template.Must(template.New("document").Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">
+<w:body>{{.Body}}<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr></w:body>
+</w:document>
+`))
+
+var docxDocumentRelsTmpl = template.Must(template.New("docrels").Funcs(docxFuncs).Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  {{- range .Rels}}
+  <Relationship Id="{{.ID}}" Type="{{.Type}}" Target="{{esc .Target}}"{{if .External}} TargetMode="External"{{end}}/>
+  {{- end}}
+</Relationships>
+`))
+
+var docxNumberingTmpl = template.Must(template.New("numbering").Funcs(docxFuncs).Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:abstractNum w:abstractNumId="0">
+    <w:multiLevelType w:val="hybridMultilevel"/>
+    {{- range levels}}
+    <w:lvl w:ilvl="{{.}}"><w:start w:val="1"/><w:numFmt w:val="bullet"/><w:lvlText w:val="{{bullet .}}"/><w:lvlJc w:val="left"/><w:pPr><w:ind w:left="{{indent .}}" w:hanging="360"/></w:pPr></w:lvl>
+    {{- end}}
+  </w:abstractNum>
+  <w:abstractNum w:abstractNumId="1">
+    <w:multiLevelType w:val="hybridMultilevel"/>
+    {{- range levels}}
+    <w:lvl w:ilvl="{{.}}"><w:start w:val="1"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%{{add . 1}}."/><w:lvlJc w:val="left"/><w:pPr><w:ind w:left="{{indent .}}" w:hanging="360"/></w:pPr></w:lvl>
+    {{- end}}
+  </w:abstractNum>
+  <w:num w:numId="{{.BulletNumID}}"><w:abstractNumId w:val="0"/></w:num>
+  {{- $base := .BulletNumID}}
+  {{- range $i, $start := .OrderedStarts}}
+  <w:num w:numId="{{add $base (add $i 1)}}"><w:abstractNumId w:val="1"/>
+    {{- range levels}}<w:lvlOverride w:ilvl="{{.}}"><w:startOverride w:val="{{$start}}"/></w:lvlOverride>{{end}}</w:num>
+  {{- end}}
+</w:numbering>
+`))
+
+var docxStylesTmpl = template.Must(template.New("styles").Parse(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:docDefaults>
+    <w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:eastAsia="Microsoft YaHei" w:cs="Calibri"/><w:sz w:val="22"/><w:szCs w:val="22"/></w:rPr></w:rPrDefault>
+    <w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="276" w:lineRule="auto"/></w:pPr></w:pPrDefault>
+  </w:docDefaults>
+  <w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>
+  <w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:rPr><w:sz w:val="56"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:spacing w:before="360" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:sz w:val="36"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading2"><w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:outlineLvl w:val="1"/></w:pPr><w:rPr><w:b/><w:sz w:val="32"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading3"><w:name w:val="heading 3"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:spacing w:before="240" w:after="80"/><w:outlineLvl w:val="2"/></w:pPr><w:rPr><w:b/><w:sz w:val="28"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading4"><w:name w:val="heading 4"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:outlineLvl w:val="3"/></w:pPr><w:rPr><w:b/><w:sz w:val="24"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading5"><w:name w:val="heading 5"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:outlineLvl w:val="4"/></w:pPr><w:rPr><w:b/><w:i/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading6"><w:name w:val="heading 6"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:outlineLvl w:val="5"/></w:pPr><w:rPr><w:i/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading7"><w:name w:val="heading 7"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:outlineLvl w:val="6"/></w:pPr><w:rPr><w:i/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading8"><w:name w:val="heading 8"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:outlineLvl w:val="7"/></w:pPr><w:rPr><w:i/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Heading9"><w:name w:val="heading 9"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:keepNext/><w:outlineLvl w:val="8"/></w:pPr><w:rPr><w:i/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/><w:pPr><w:ind w:left="720"/><w:pBdr><w:left w:val="single" w:sz="12" w:space="8" w:color="BFBFBF"/></w:pBdr></w:pPr><w:rPr><w:i/><w:color w:val="595959"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="Code"><w:name w:val="Code"/><w:basedOn w:val="Normal"/><w:qFormat/><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/><w:shd w:val="clear" w:color="auto" w:fill="F2F2F2"/></w:pPr><w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="20"/></w:rPr></w:style>
+  <w:style w:type="paragraph" w:styleId="FootnoteText"><w:name w:val="footnote text"/><w:basedOn w:val="Normal"/><w:rPr><w:sz w:val="18"/></w:rPr></w:style>
+  <w:style w:type="character" w:styleId="CodeChar"><w:name w:val="Code Char"/><w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:shd w:val="clear" w:color="auto" w:fill="F2F2F2"/></w:rPr></w:style>
+  <w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/><w:rPr><w:color w:val="0563C1"/><w:u w:val="single"/></w:rPr></w:style>
+  <w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:left w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:bottom w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:right w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideH w:val="single" w:sz="4" w:space="0" w:color="auto"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="auto"/></w:tblBorders></w:tblPr></w:style>
+</w:styles>
+`))
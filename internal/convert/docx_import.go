@@ -0,0 +1,662 @@
+package convert
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+var ErrInvalidDOCX = errors.New("invalid docx document")
+
+const (
+	// MaxDOCXSize bounds the size of an imported document
+	MaxDOCXSize = 32 << 20
+	// maxDOCXEntrySize bounds the unpacked size of one part of a document,
+	// and maxDOCXUnpacked that of all the parts read
+	maxDOCXEntrySize = 64 << 20
+	maxDOCXUnpacked  = 256 << 20
+)
+
+// DOCXImportOptions controls how a Word document becomes notes
+type DOCXImportOptions struct {
+	// Title is used for the top note when the document has no title
+	Title string
+	// SplitLevel turns every heading up to this level into a child note,
+	// nested by heading level. 0 keeps the whole document in one note.
+	SplitLevel int
+}
+
+// xmlNode is a generic element tree, which keeps the order of mixed
+// children that struct based decoding would lose
+type xmlNode struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Children []*xmlNode
+	Text     string
+}
+
+func (n *xmlNode) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func (n *xmlNode) child(local string) *xmlNode {
+	for _, c := range n.Children {
+		if c.Name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// find returns the first descendant with the given local name
+func (n *xmlNode) find(local string) *xmlNode {
+	for _, c := range n.Children {
+		if c.Name.Local == local {
+			return c
+		}
+		if found := c.find(local); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func parseXMLTree(r io.Reader) (*xmlNode, error) {
+	dec := xml.NewDecoder(r)
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{Name: t.Name, Attrs: t.Attr}
+			top.Children = append(top.Children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			top.Text += string(t)
+		}
+	}
+	if len(root.Children) == 0 {
+		return nil, errors.New("empty xml document")
+	}
+	return root.Children[0], nil
+}
+
+type docxReader struct {
+	files map[string]*zip.File
+	// styles maps style IDs to their lower-case names
+	styles map[string]string
+	// numFormats maps numId and level to the numbering format
+	numFormats map[string]map[string]string
+	rels       map[string]string
+	imageCount int
+	// unpacked is the unpacked size of the parts opened so far
+	unpacked uint64
+}
+
+// docxBlock is one converted paragraph or table
+type docxBlock struct {
+	markdown     string
+	headingLevel int
+	headingText  string
+	listItem     bool
+	code         bool
+}
+
+// DecodeDOCX converts a Word document into a single unsaved note, or a note
+// with children when opts.SplitLevel is set.
+func DecodeDOCX(data []byte, opts DOCXImportOptions) ([]*model.NoteNode, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDOCX, err)
+	}
+
+	d := &docxReader{
+		files:      map[string]*zip.File{},
+		styles:     map[string]string{},
+		numFormats: map[string]map[string]string{},
+		rels:       map[string]string{},
+	}
+	for _, f := range zr.File {
+		d.files[f.Name] = f
+	}
+
+	doc, err := d.parse("word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDOCX, err)
+	}
+	d.loadStyles()
+	d.loadNumbering()
+	d.loadRels()
+
+	body := doc.child("body")
+	if body == nil {
+		return nil, fmt.Errorf("%w: missing body", ErrInvalidDOCX)
+	}
+
+	title := d.coreTitle()
+	var blocks []docxBlock
+	d.bodyBlocks(body, &blocks)
+
+	// A leading Title paragraph names the note instead of being content
+	if len(blocks) > 0 && blocks[0].headingLevel == -1 {
+		if title == "" {
+			title = blocks[0].headingText
+		}
+		blocks = blocks[1:]
+	}
+	if title == "" {
+		title = opts.Title
+	}
+	if title == "" {
+		title = "Untitled"
+	}
+	// Documents exported by Yan repeat the title as their first heading
+	if len(blocks) > 0 && blocks[0].headingLevel > 0 && blocks[0].headingText == title {
+		blocks = blocks[1:]
+	}
+
+	root := &model.NoteNode{Note: &model.Note{Title: title, Status: model.NoteStatusNormal}}
+	// stack[i] is the open note for heading level i, stack[0] the root
+	stack := []*model.NoteNode{root}
+	levels := []int{0}
+	contents := map[*model.NoteNode][]docxBlock{}
+	for _, b := range blocks {
+		if opts.SplitLevel > 0 && b.headingLevel > 0 && b.headingLevel <= opts.SplitLevel {
+			for len(levels) > 1 && levels[len(levels)-1] >= b.headingLevel {
+				stack, levels = stack[:len(stack)-1], levels[:len(levels)-1]
+			}
+			parent := stack[len(stack)-1]
+			child := &model.NoteNode{Note: &model.Note{
+				Title:    b.headingText,
+				Position: len(parent.Children),
+				Status:   model.NoteStatusNormal,
+			}}
+			parent.Children = append(parent.Children, child)
+			stack, levels = append(stack, child), append(levels, b.headingLevel)
+			continue
+		}
+		cur := stack[len(stack)-1]
+		contents[cur] = append(contents[cur], b)
+	}
+
+	root.Walk(func(node *model.NoteNode, _ int) {
+		node.Content = joinDOCXBlocks(contents[node])
+	})
+
+	return []*model.NoteNode{root}, nil
+}
+
+func (d *docxReader) parse(name string) (*xmlNode, error) {
+	f, ok := d.files[name]
+	if !ok {
+		return nil, fmt.Errorf("missing %s", name)
+	}
+	data, err := d.read(f)
+	if err != nil {
+		return nil, err
+	}
+	return parseXMLTree(bytes.NewReader(data))
+}
+
+// read unpacks a part of the document. Parts claiming or turning out to be
+// larger than maxDOCXEntrySize, or beyond maxDOCXUnpacked in all, are
+// refused, so a small archive cannot unpack into a huge one.
+func (d *docxReader) read(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxDOCXEntrySize || d.unpacked+f.UncompressedSize64 > maxDOCXUnpacked {
+		return nil, fmt.Errorf("%s is too large", f.Name)
+	}
+	d.unpacked += f.UncompressedSize64
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxDOCXEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDOCXEntrySize {
+		return nil, fmt.Errorf("%s is too large", f.Name)
+	}
+	return data, nil
+}
+
+func (d *docxReader) loadStyles() {
+	styles, err := d.parse("word/styles.xml")
+	if err != nil {
+		return
+	}
+	for _, s := range styles.Children {
+		if s.Name.Local != "style" {
+			continue
+		}
+		if name := s.child("name"); name != nil {
+			d.styles[s.attr("styleId")] = strings.ToLower(name.attr("val"))
+		}
+	}
+}
+
+func (d *docxReader) loadNumbering() {
+	numbering, err := d.parse("word/numbering.xml")
+	if err != nil {
+		return
+	}
+	abstracts := map[string]map[string]string{}
+	for _, a := range numbering.Children {
+		if a.Name.Local != "abstractNum" {
+			continue
+		}
+		formats := map[string]string{}
+		for _, lvl := range a.Children {
+			if lvl.Name.Local == "lvl" {
+				if f := lvl.child("numFmt"); f != nil {
+					formats[lvl.attr("ilvl")] = f.attr("val")
+				}
+			}
+		}
+		abstracts[a.attr("abstractNumId")] = formats
+	}
+	for _, n := range numbering.Children {
+		if n.Name.Local != "num" {
+			continue
+		}
+		if a := n.child("abstractNumId"); a != nil {
+			d.numFormats[n.attr("numId")] = abstracts[a.attr("val")]
+		}
+	}
+}
+
+func (d *docxReader) loadRels() {
+	rels, err := d.parse("word/_rels/document.xml.rels")
+	if err != nil {
+		return
+	}
+	for _, r := range rels.Children {
+		d.rels[r.attr("Id")] = r.attr("Target")
+	}
+}
+
+func (d *docxReader) coreTitle() string {
+	core, err := d.parse("docProps/core.xml")
+	if err != nil {
+		return ""
+	}
+	if t := core.child("title"); t != nil {
+		return strings.TrimSpace(t.Text)
+	}
+	return ""
+}
+
+var headingStyleRe = regexp.MustCompile(`^heading\s*([1-9])$`)
+
+func (d *docxReader) bodyBlocks(parent *xmlNode, blocks *[]docxBlock) {
+	for _, n := range parent.Children {
+		switch n.Name.Local {
+		case "p":
+			if b, ok := d.paragraph(n); ok {
+				*blocks = append(*blocks, b)
+			}
+		case "tbl":
+			*blocks = append(*blocks, docxBlock{markdown: d.table(n)})
+		case "sdt", "sdtContent", "customXml", "ins":
+			d.bodyBlocks(n, blocks)
+		}
+	}
+}
+
+func (d *docxReader) paragraph(p *xmlNode) (docxBlock, bool) {
+	style := ""
+	var numPr *xmlNode
+	if pPr := p.child("pPr"); pPr != nil {
+		if ps := pPr.child("pStyle"); ps != nil {
+			style = d.styles[ps.attr("val")]
+			if style == "" {
+				style = strings.ToLower(ps.attr("val"))
+			}
+		}
+		numPr = pPr.child("numPr")
+	}
+
+	if strings.Contains(style, "code") || strings.Contains(style, "preformatted") || strings.Contains(style, "source") {
+		return docxBlock{markdown: d.plainText(p), code: true}, true
+	}
+
+	text := strings.TrimSpace(d.inlines(p))
+	if text == "" && p.find("drawing") == nil {
+		return docxBlock{}, false
+	}
+
+	if style == "title" {
+		return docxBlock{markdown: "# " + text, headingLevel: -1, headingText: d.plainText(p)}, true
+	}
+	if m := headingStyleRe.FindStringSubmatch(style); m != nil {
+		level, _ := strconv.Atoi(m[1])
+		return docxBlock{
+			markdown:     strings.Repeat("#", min(level, 6)) + " " + text,
+			headingLevel: level,
+			headingText:  strings.TrimSpace(d.plainText(p)),
+		}, true
+	}
+	if strings.Contains(style, "quote") {
+		return docxBlock{markdown: "> " + text}, true
+	}
+	if numPr != nil {
+		ilvl, numID := "0", ""
+		if l := numPr.child("ilvl"); l != nil {
+			ilvl = l.attr("val")
+		}
+		if id := numPr.child("numId"); id != nil {
+			numID = id.attr("val")
+		}
+		depth, _ := strconv.Atoi(ilvl)
+		marker := "-"
+		if f := d.numFormats[numID][ilvl]; f != "" && f != "bullet" && f != "none" {
+			marker = "1."
+		}
+		return docxBlock{markdown: strings.Repeat("  ", depth) + marker + " " + text, listItem: true}, true
+	}
+
+	return docxBlock{markdown: text}, true
+}
+
+// docxSpan is a run of text with uniform formatting
+type docxSpan struct {
+	text                       string
+	bold, italic, strike, code bool
+	link                       string
+	raw                        bool
+}
+
+// inlines converts the runs of a paragraph into markdown
+func (d *docxReader) inlines(p *xmlNode) string {
+	var spans []docxSpan
+	d.collectSpans(p, "", &spans)
+
+	var sb strings.Builder
+	for i := 0; i < len(spans); {
+		// Merge neighbours with the same formatting so that markers are not
+		// repeated for every run Word happened to split the text into
+		j := i + 1
+		text := spans[i].text
+		for j < len(spans) && sameFormat(spans[i], spans[j]) && !spans[i].raw && !spans[j].raw {
+			text += spans[j].text
+			j++
+		}
+		sb.WriteString(formatSpan(spans[i], text))
+		i = j
+	}
+	return sb.String()
+}
+
+func sameFormat(a, b docxSpan) bool {
+	return a.bold == b.bold && a.italic == b.italic && a.strike == b.strike && a.code == b.code && a.link == b.link
+}
+
+func formatSpan(s docxSpan, text string) string {
+	if s.raw {
+		return text
+	}
+	if s.code {
+		text = "`" + text + "`"
+	} else {
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" {
+			return text
+		}
+		lead := text[:strings.Index(text, trimmed)]
+		trail := text[len(lead)+len(trimmed):]
+		inner := escapeMarkdown(trimmed)
+		if s.strike {
+			inner = "~~" + inner + "~~"
+		}
+		if s.italic {
+			inner = "*" + inner + "*"
+		}
+		if s.bold {
+			inner = "**" + inner + "**"
+		}
+		text = lead + inner + trail
+	}
+	if s.link != "" {
+		text = "[" + text + "](" + s.link + ")"
+	}
+	return text
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`,
+)
+
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+func (d *docxReader) collectSpans(n *xmlNode, link string, spans *[]docxSpan) {
+	for _, c := range n.Children {
+		switch c.Name.Local {
+		case "r":
+			d.runSpans(c, link, spans)
+		case "hyperlink":
+			target := link
+			if id := c.attr("id"); id != "" {
+				target = d.rels[id]
+			}
+			d.collectSpans(c, target, spans)
+		case "ins", "smartTag", "fldSimple", "sdt", "sdtContent":
+			d.collectSpans(c, link, spans)
+		}
+	}
+}
+
+func (d *docxReader) runSpans(r *xmlNode, link string, spans *[]docxSpan) {
+	base := docxSpan{link: link}
+	if rPr := r.child("rPr"); rPr != nil {
+		base.bold = isOn(rPr.child("b"))
+		base.italic = isOn(rPr.child("i"))
+		base.strike = isOn(rPr.child("strike"))
+		if rs := rPr.child("rStyle"); rs != nil {
+			name := d.styles[rs.attr("val")]
+			if name == "" {
+				name = strings.ToLower(rs.attr("val"))
+			}
+			base.code = strings.Contains(name, "code")
+		}
+	}
+
+	for _, c := range r.Children {
+		span := base
+		switch c.Name.Local {
+		case "t":
+			span.text = c.Text
+		case "tab":
+			span.text = "\t"
+		case "br", "cr":
+			span.text, span.raw = "  \n", true
+		case "drawing", "pict":
+			img := d.image(c)
+			if img == "" {
+				continue
+			}
+			span.text, span.raw = img, true
+		default:
+			continue
+		}
+		*spans = append(*spans, span)
+	}
+}
+
+func isOn(n *xmlNode) bool {
+	if n == nil {
+		return false
+	}
+	v := n.attr("val")
+	return v == "" || v == "1" || v == "true" || v == "on"
+}
+
+// image inlines a picture as a data URI, since notes have no attachment store
+func (d *docxReader) image(n *xmlNode) string {
+	blip := n.find("blip")
+	if blip == nil {
+		return ""
+	}
+	target, ok := d.rels[blip.attr("embed")]
+	if !ok {
+		return ""
+	}
+	f, ok := d.files[path.Join("word", target)]
+	if !ok {
+		return ""
+	}
+	data, err := d.read(f)
+	if err != nil {
+		return ""
+	}
+
+	mediaType := mime.TypeByExtension(path.Ext(target))
+	if mediaType == "" {
+		return ""
+	}
+	d.imageCount++
+	alt := fmt.Sprintf("image%d", d.imageCount)
+	if docPr := n.find("docPr"); docPr != nil && docPr.attr("descr") != "" {
+		alt = docPr.attr("descr")
+	}
+	return fmt.Sprintf("![%s](data:%s;base64,%s)", escapeMarkdown(alt), mediaType, base64.StdEncoding.EncodeToString(data))
+}
+
+func (d *docxReader) plainText(n *xmlNode) string {
+	var sb strings.Builder
+	var walk func(n *xmlNode)
+	walk = func(n *xmlNode) {
+		switch n.Name.Local {
+		case "t":
+			sb.WriteString(n.Text)
+			return
+		case "tab":
+			sb.WriteString("\t")
+			return
+		case "br", "cr":
+			sb.WriteString("\n")
+			return
+		case "del", "pPr", "rPr":
+			return
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// table converts a Word table into a GFM table, using the first row as header
+func (d *docxReader) table(tbl *xmlNode) string {
+	var rows [][]string
+	cols := 0
+	for _, tr := range tbl.Children {
+		if tr.Name.Local != "tr" {
+			continue
+		}
+		var cells []string
+		for _, tc := range tr.Children {
+			if tc.Name.Local != "tc" {
+				continue
+			}
+			var parts []string
+			for _, p := range tc.Children {
+				if p.Name.Local == "p" {
+					if text := strings.TrimSpace(d.inlines(p)); text != "" {
+						parts = append(parts, text)
+					}
+				}
+			}
+			cell := strings.Join(parts, " ")
+			cell = strings.ReplaceAll(strings.ReplaceAll(cell, "\n", " "), "|", `\|`)
+			cells = append(cells, cell)
+		}
+		cols = max(cols, len(cells))
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 || cols == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		sb.WriteString("|")
+		for i := 0; i < cols; i++ {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(rows[0])
+	sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// joinDOCXBlocks joins converted blocks, keeping list items and code lines
+// together and wrapping code lines in fences
+func joinDOCXBlocks(blocks []docxBlock) string {
+	var sb strings.Builder
+	for i, b := range blocks {
+		if b.markdown == "" && !b.code {
+			continue
+		}
+		prevCode := i > 0 && blocks[i-1].code
+		nextCode := i+1 < len(blocks) && blocks[i+1].code
+		if sb.Len() > 0 {
+			switch {
+			case b.code && prevCode:
+				sb.WriteString("\n")
+			case b.listItem && i > 0 && blocks[i-1].listItem:
+				sb.WriteString("\n")
+			default:
+				sb.WriteString("\n\n")
+			}
+		}
+		if b.code && !prevCode {
+			sb.WriteString("```\n")
+		}
+		sb.WriteString(b.markdown)
+		if b.code && !nextCode {
+			sb.WriteString("\n```")
+		}
+	}
+	return sb.String()
+}
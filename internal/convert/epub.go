@@ -0,0 +1,278 @@
+package convert
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+// EPUBOptions carries the book metadata that is not part of the note tree
+type EPUBOptions struct {
+	Author   string
+	Language string
+	// FetchImage loads referenced images so they can be embedded. Images
+	// are left as remote references when it is nil or fails.
+	FetchImage ImageFetcher
+}
+
+type epubChapter struct {
+	ID      string
+	Href    string
+	Title   string
+	Body    string
+	Remote  bool
+	Entries []*epubChapter
+}
+
+type epubImage struct {
+	ID        string
+	Href      string
+	MediaType string
+	Data      []byte
+}
+
+type epubBook struct {
+	Identifier string
+	Title      string
+	Author     string
+	Language   string
+	Modified   string
+	Chapters   []*epubChapter
+	Toc        []*epubChapter
+	Images     []*epubImage
+}
+
+type epubFile struct {
+	name string
+	tmpl *template.Template
+	data any
+}
+
+// EncodeEPUB renders the tree rooted at root as an EPUB 3 book. Every note
+// becomes a chapter in tree order and the navigation document mirrors the
+// hierarchy.
+func EncodeEPUB(ctx context.Context, root *model.NoteNode, opts EPUBOptions) ([]byte, error) {
+	if opts.Language == "" {
+		opts.Language = "en"
+	}
+
+	book := &epubBook{
+		Identifier: "urn:uuid:" + newUUID(),
+		Title:      root.Title,
+		Author:     opts.Author,
+		Language:   opts.Language,
+		Modified:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+
+	images := map[string]*epubImage{}
+	embed := func(src string) (string, bool) {
+		if img, ok := images[src]; ok {
+			return img.Href, true
+		}
+		if opts.FetchImage == nil {
+			return src, false
+		}
+		fetched, err := opts.FetchImage(ctx, src)
+		if err != nil {
+			return src, false
+		}
+		img := &epubImage{
+			ID:        fmt.Sprintf("img-%d", len(book.Images)+1),
+			MediaType: fetched.MediaType,
+			Data:      fetched.Data,
+		}
+		img.Href = "images/" + img.ID + fetched.Ext()
+		images[src] = img
+		book.Images = append(book.Images, img)
+		return img.Href, true
+	}
+
+	var renderErr error
+	var build func(node *model.NoteNode) *epubChapter
+	build = func(node *model.NoteNode) *epubChapter {
+		ch := &epubChapter{
+			ID:    fmt.Sprintf("chapter-%d", len(book.Chapters)+1),
+			Title: node.Title,
+		}
+		ch.Href = ch.ID + ".xhtml"
+		book.Chapters = append(book.Chapters, ch)
+
+		body, err := markdown.Render([]byte(node.Content),
+			markdown.WithXHTML(),
+			markdown.WithImageRewriter(func(dest string) string {
+				href, ok := embed(dest)
+				if !ok && (strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://")) {
+					ch.Remote = true
+				}
+				return href
+			}),
+		)
+		if err != nil && renderErr == nil {
+			renderErr = err
+		}
+		ch.Body = string(body)
+
+		for _, child := range node.Children {
+			ch.Entries = append(ch.Entries, build(child))
+		}
+		return ch
+	}
+	book.Toc = []*epubChapter{build(root)}
+	if renderErr != nil {
+		return nil, renderErr
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	// The mimetype entry must come first and must not be compressed
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte("application/epub+zip")); err != nil {
+		return nil, err
+	}
+
+	files := []epubFile{
+		{"META-INF/container.xml", epubContainerTmpl, book},
+		{"OEBPS/content.opf", epubPackageTmpl, book},
+		{"OEBPS/nav.xhtml", epubNavTmpl, book},
+		{"OEBPS/style.css", epubStyleTmpl, book},
+	}
+	for _, ch := range book.Chapters {
+		files = append(files, epubFile{"OEBPS/" + ch.Href, epubChapterTmpl, map[string]any{"Book": book, "Chapter": ch}})
+	}
+
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.tmpl.Execute(w, f.data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, img := range book.Images {
+		w, err := zw.Create("OEBPS/" + img.Href)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(img.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+var epubFuncs = template.FuncMap{
+	"esc": html.EscapeString,
+}
+
+var epubContainerTmpl = template.Must(template.New("container").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`))
+
+var epubPackageTmpl = template.Must(template.New("package").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{esc .Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.Identifier}}</dc:identifier>
+    <dc:title>{{esc .Title}}</dc:title>
+    <dc:language>{{esc .Language}}</dc:language>
+    {{- if .Author}}
+    <dc:creator>{{esc .Author}}</dc:creator>
+    {{- end}}
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    {{- range .Chapters}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="application/xhtml+xml"{{if .Remote}} properties="remote-resources"{{end}}/>
+    {{- end}}
+    {{- range .Images}}
+    <item id="{{.ID}}" href="{{.Href}}" media-type="{{.MediaType}}"/>
+    {{- end}}
+  </manifest>
+  <spine>
+    {{- range .Chapters}}
+    <itemref idref="{{.ID}}"/>
+    {{- end}}
+  </spine>
+</package>
+`))
+
+var epubNavTmpl = template.Must(template.New("nav").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{esc .Language}}" lang="{{esc .Language}}">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{esc .Title}}</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{esc .Title}}</h1>
+    {{- template "entries" .Toc}}
+  </nav>
+</body>
+</html>
+{{define "entries"}}
+<ol>
+  {{- range .}}
+  <li><a href="{{.Href}}">{{esc .Title}}</a>{{if .Entries}}{{template "entries" .Entries}}{{end}}</li>
+  {{- end}}
+</ol>
+{{- end}}
+`))
+
+var epubChapterTmpl = template.Must(template.New("chapter").Funcs(epubFuncs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{esc .Book.Language}}" lang="{{esc .Book.Language}}">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{esc .Chapter.Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+<section epub:type="chapter">
+<h1>{{esc .Chapter.Title}}</h1>
+{{.Chapter.Body}}
+</section>
+</body>
+</html>
+`))
+
+var epubStyleTmpl = template.Must(template.New("style").Parse(`body { font-family: serif; line-height: 1.5; }
+pre { white-space: pre-wrap; font-size: 0.9em; }
+code { font-family: monospace; }
+img { max-width: 100%; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 0.2em 0.4em; }
+`))
@@ -0,0 +1,95 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ICalEvent is a calendar entry. Entries with AllDay set last the whole day
+// of Start in floating time; others happen at Start.
+type ICalEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Start       time.Time
+	AllDay      bool
+	// Updated is the last modification, used as DTSTAMP
+	Updated time.Time
+}
+
+// EncodeICalendar renders events as an RFC 5545 calendar named name
+func EncodeICalendar(name string, events []ICalEvent) []byte {
+	var buf bytes.Buffer
+	w := &icalWriter{buf: &buf}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:-//Yan//Yan Notes//EN")
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + icalText(name))
+
+	for _, e := range events {
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + icalText(e.UID))
+		stamp := e.Updated
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+		w.line("DTSTAMP:" + stamp.UTC().Format("20060102T150405Z"))
+		if e.AllDay {
+			w.line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+			w.line("DTEND;VALUE=DATE:" + e.Start.AddDate(0, 0, 1).Format("20060102"))
+		} else {
+			w.line("DTSTART:" + e.Start.UTC().Format("20060102T150405Z"))
+		}
+		w.line("SUMMARY:" + icalText(e.Summary))
+		if e.Description != "" {
+			w.line("DESCRIPTION:" + icalText(e.Description))
+		}
+		if e.URL != "" {
+			w.line("URL:" + e.URL)
+		}
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// icalText escapes a TEXT property value
+func icalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(s)
+}
+
+type icalWriter struct {
+	buf *bytes.Buffer
+}
+
+// line writes a content line, folded at 75 octets without splitting UTF-8
+// sequences
+func (w *icalWriter) line(s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.buf.WriteString(s[:cut])
+		w.buf.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = 74
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
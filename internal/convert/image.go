@@ -0,0 +1,117 @@
+package convert
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/safehttp"
+)
+
+var ErrImageNotEmbeddable = errors.New("image cannot be embedded")
+
+// Image is an image referenced by a note, loaded so it can be embedded
+type Image struct {
+	Data      []byte
+	MediaType string
+}
+
+// Ext returns the file extension matching the image media type
+func (img *Image) Ext() string {
+	switch img.MediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/svg+xml":
+		return ".svg"
+	case "image/webp":
+		return ".webp"
+	}
+	return ""
+}
+
+// ImageFetcher loads the image behind a markdown image destination
+type ImageFetcher func(ctx context.Context, src string) (*Image, error)
+
+// NewImageFetcher returns a fetcher that decodes data: URIs and downloads
+// http(s) images with the given client, refusing bodies above maxBytes.
+// Without a client, images are only downloaded from public addresses.
+func NewImageFetcher(client *http.Client, maxBytes int64) ImageFetcher {
+	if client == nil {
+		client = safehttp.NewClient(15 * time.Second)
+	}
+
+	return func(ctx context.Context, src string) (*Image, error) {
+		if strings.HasPrefix(src, "data:") {
+			return decodeDataURI(src)
+		}
+		if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+			return nil, ErrImageNotEmbeddable
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetch image %s: status %d", src, resp.StatusCode)
+		}
+
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > maxBytes {
+			return nil, fmt.Errorf("fetch image %s: larger than %d bytes", src, maxBytes)
+		}
+
+		img := &Image{Data: data, MediaType: imageMediaType(resp.Header.Get("Content-Type"), src, data)}
+		if img.Ext() == "" {
+			return nil, ErrImageNotEmbeddable
+		}
+		return img, nil
+	}
+}
+
+func decodeDataURI(src string) (*Image, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(src, "data:"), ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return nil, ErrImageNotEmbeddable
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	img := &Image{Data: data, MediaType: strings.TrimSuffix(header, ";base64")}
+	if img.Ext() == "" {
+		return nil, ErrImageNotEmbeddable
+	}
+	return img, nil
+}
+
+func imageMediaType(contentType, src string, data []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "image/") {
+		return mediaType
+	}
+	if byExt := mime.TypeByExtension(path.Ext(strings.SplitN(src, "?", 2)[0])); strings.HasPrefix(byExt, "image/") {
+		mediaType, _, _ := mime.ParseMediaType(byExt)
+		return mediaType
+	}
+	return http.DetectContentType(data)
+}
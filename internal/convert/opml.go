@@ -0,0 +1,125 @@
+// Package convert translates note trees to and from external document formats.
+package convert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+var ErrInvalidOPML = errors.New("invalid opml document")
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []*opmlOutline `xml:"outline"`
+}
+
+// opmlOutline maps a note onto an OPML outline element. text and _note are
+// the attributes understood by most outliners; icon and isFavorite are Yan
+// extensions so that a round trip keeps them.
+type opmlOutline struct {
+	Text       string         `xml:"text,attr"`
+	Title      string         `xml:"title,attr,omitempty"`
+	Note       string         `xml:"_note,attr,omitempty"`
+	Icon       string         `xml:"icon,attr,omitempty"`
+	IsFavorite string         `xml:"isFavorite,attr,omitempty"`
+	Outlines   []*opmlOutline `xml:"outline"`
+}
+
+// EncodeOPML renders the tree rooted at root as an OPML 2.0 document.
+func EncodeOPML(root *model.NoteNode) ([]byte, error) {
+	doc := opmlDocument{
+		Version: "2.0",
+		Head: opmlHead{
+			Title:       root.Title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Body: opmlBody{Outlines: []*opmlOutline{toOutline(root)}},
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+func toOutline(node *model.NoteNode) *opmlOutline {
+	o := &opmlOutline{
+		Text: node.Title,
+		Note: node.Content,
+	}
+	if node.Icon.Valid {
+		o.Icon = node.Icon.String
+	}
+	if node.IsFavorited() {
+		o.IsFavorite = "true"
+	}
+	for _, child := range node.Children {
+		o.Outlines = append(o.Outlines, toOutline(child))
+	}
+	return o
+}
+
+// DecodeOPML parses an OPML document into unsaved note trees, one per
+// top-level outline. Positions follow the document order.
+func DecodeOPML(r io.Reader) ([]*model.NoteNode, error) {
+	var doc opmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOPML, err)
+	}
+	if len(doc.Body.Outlines) == 0 {
+		return nil, fmt.Errorf("%w: no outlines", ErrInvalidOPML)
+	}
+
+	return fromOutlines(doc.Body.Outlines), nil
+}
+
+func fromOutlines(outlines []*opmlOutline) []*model.NoteNode {
+	nodes := make([]*model.NoteNode, 0, len(outlines))
+	for i, o := range outlines {
+		title := o.Text
+		if title == "" {
+			title = o.Title
+		}
+		note := &model.Note{
+			Title:    strings.TrimSpace(title),
+			Content:  o.Note,
+			Position: i,
+			Status:   model.NoteStatusNormal,
+		}
+		if o.Icon != "" {
+			note.Icon.String, note.Icon.Valid = o.Icon, true
+		}
+		if o.IsFavorite == "true" || o.IsFavorite == "1" {
+			note.IsFavorite = model.NoteFavoriteYes
+		}
+		nodes = append(nodes, &model.NoteNode{
+			Note:     note,
+			Children: fromOutlines(o.Outlines),
+		})
+	}
+	return nodes
+}
@@ -0,0 +1,391 @@
+package convert
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+// SiteOptions configures a published static site
+type SiteOptions struct {
+	// BaseURL is the public URL the site is served from. It is used for
+	// absolute URLs in the sitemap; without it the sitemap lists
+	// relative paths.
+	BaseURL string
+	// FetchImage loads referenced images so they can be copied into the
+	// site. Images are left as remote references when it is nil or fails.
+	FetchImage ImageFetcher
+}
+
+type sitePage struct {
+	Node        *model.NoteNode
+	Href        string
+	Title       string
+	Body        template.HTML
+	Parent      *sitePage
+	Children    []*sitePage
+	Prev, Next  *sitePage
+	Breadcrumbs []*sitePage
+	// PlainText feeds the search index
+	PlainText string
+}
+
+type siteSearchEntry struct {
+	Title string `json:"title"`
+	Href  string `json:"href"`
+	Path  string `json:"path"`
+	Text  string `json:"text"`
+}
+
+// WriteSite renders the tree rooted at root into a self-contained static
+// website in dir: one HTML page per note with navigation built from the
+// hierarchy, copied assets, a search index and a sitemap.
+func WriteSite(ctx context.Context, root *model.NoteNode, dir string, opts SiteOptions) error {
+	if err := os.MkdirAll(filepath.Join(dir, "assets"), 0755); err != nil {
+		return err
+	}
+
+	// Lay out the pages first so that links between them can be resolved
+	var pages []*sitePage
+	byID := map[int64]*sitePage{}
+	byTitle := map[string]*sitePage{}
+	var layout func(node *model.NoteNode, parent *sitePage) *sitePage
+	layout = func(node *model.NoteNode, parent *sitePage) *sitePage {
+		page := &sitePage{Node: node, Title: node.Title, Parent: parent}
+		if parent == nil {
+			page.Href = "index.html"
+		} else {
+			page.Href = fmt.Sprintf("%d-%s.html", node.ID, pageSlug(node.Title))
+			page.Breadcrumbs = append(append([]*sitePage{}, parent.Breadcrumbs...), parent)
+		}
+		if len(pages) > 0 {
+			page.Prev = pages[len(pages)-1]
+			page.Prev.Next = page
+		}
+		pages = append(pages, page)
+		byID[node.ID] = page
+		if _, ok := byTitle[strings.ToLower(node.Title)]; !ok {
+			byTitle[strings.ToLower(node.Title)] = page
+		}
+		for _, child := range node.Children {
+			page.Children = append(page.Children, layout(child, page))
+		}
+		return page
+	}
+	home := layout(root, nil)
+
+	assets := map[string]string{}
+	copyAsset := func(src string) string {
+		if href, ok := assets[src]; ok {
+			return href
+		}
+		if opts.FetchImage == nil {
+			return src
+		}
+		img, err := opts.FetchImage(ctx, src)
+		if err != nil {
+			return src
+		}
+		href := fmt.Sprintf("assets/img-%d%s", len(assets)+1, img.Ext())
+		if err := os.WriteFile(filepath.Join(dir, href), img.Data, 0644); err != nil {
+			return src
+		}
+		assets[src] = href
+		return href
+	}
+
+	for _, page := range pages {
+		src := []byte(page.Node.Content)
+		body, err := markdown.Render(src,
+			markdown.WithImageRewriter(copyAsset),
+			markdown.WithLinkRewriter(func(dest string) string {
+				id, ok := markdown.NoteLinkID(dest)
+				if !ok {
+					return dest
+				}
+				if target, ok := byID[id]; ok {
+					return target.Href
+				}
+				return dest
+			}),
+			markdown.WithWikiLinkResolver(func(target string) (string, bool) {
+				title, heading, _ := strings.Cut(target, "#")
+				page, ok := byTitle[strings.ToLower(strings.TrimSpace(title))]
+				if !ok {
+					return "", false
+				}
+				if heading != "" {
+					return page.Href + "#" + markdown.Slug(heading), true
+				}
+				return page.Href, true
+			}),
+		)
+		if err != nil {
+			return err
+		}
+		page.Body = template.HTML(body)
+		page.PlainText = markdown.PlainText(src)
+	}
+
+	for _, page := range pages {
+		f, err := os.Create(filepath.Join(dir, page.Href))
+		if err != nil {
+			return err
+		}
+		err = sitePageTmpl.Execute(f, map[string]any{"Home": home, "Page": page})
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "assets", "style.css"), []byte(siteStyle+markdown.HighlightCSS()), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "assets", "search.js"), []byte(siteSearchScript), 0644); err != nil {
+		return err
+	}
+
+	index := make([]siteSearchEntry, 0, len(pages))
+	for _, page := range pages {
+		path := make([]string, 0, len(page.Breadcrumbs))
+		for _, crumb := range page.Breadcrumbs {
+			path = append(path, crumb.Title)
+		}
+		index = append(index, siteSearchEntry{
+			Title: page.Title,
+			Href:  page.Href,
+			Path:  strings.Join(path, " / "),
+			Text:  page.PlainText,
+		})
+	}
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "search-index.json"), indexData, 0644); err != nil {
+		return err
+	}
+
+	return writeSitemap(filepath.Join(dir, "sitemap.xml"), pages, opts.BaseURL)
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func writeSitemap(path string, pages []*sitePage, baseURL string) error {
+	set := sitemapURLSet{XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	base := strings.TrimSuffix(baseURL, "/")
+	for _, page := range pages {
+		loc := page.Href
+		if base != "" {
+			loc = base + "/" + page.Href
+		}
+		u := sitemapURL{Loc: loc}
+		if !page.Node.UpdatedAt.IsZero() {
+			u.LastMod = page.Node.UpdatedAt.UTC().Format("2006-01-02")
+		}
+		set.URLs = append(set.URLs, u)
+	}
+
+	data, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
+
+// pageSlug names the file of a published page
+func pageSlug(title string) string {
+	if slug := markdown.Slug(title); slug != "" {
+		return slug
+	}
+	return "page"
+}
+
+var sitePageTmpl = template.Must(template.New("page").Funcs(template.FuncMap{
+	"dict": func(kv ...any) map[string]any {
+		m := make(map[string]any, len(kv)/2)
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[kv[i].(string)] = kv[i+1]
+		}
+		return m
+	},
+	"list": func(items ...*sitePage) []*sitePage {
+		return items
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Page.Title}}{{if ne .Page .Home}} · {{.Home.Title}}{{end}}</title>
+  <link rel="stylesheet" href="assets/style.css">
+</head>
+<body>
+<header>
+  <a class="site-title" href="index.html">{{.Home.Title}}</a>
+  <div class="search">
+    <input id="search" type="search" placeholder="Search" autocomplete="off">
+    <ul id="search-results"></ul>
+  </div>
+</header>
+<div class="layout">
+  <nav class="sidebar">
+    {{template "tree" (dict "Pages" (list .Home) "Current" .Page)}}
+  </nav>
+  <main>
+    {{- if .Page.Breadcrumbs}}
+    <ol class="breadcrumbs">
+      {{- range .Page.Breadcrumbs}}
+      <li><a href="{{.Href}}">{{.Title}}</a></li>
+      {{- end}}
+    </ol>
+    {{- end}}
+    <article>
+      <h1>{{if .Page.Node.Icon.Valid}}{{.Page.Node.Icon.String}} {{end}}{{.Page.Title}}</h1>
+      {{.Page.Body}}
+    </article>
+    {{- if .Page.Children}}
+    <section class="children">
+      <h2>Pages</h2>
+      <ul>
+        {{- range .Page.Children}}
+        <li><a href="{{.Href}}">{{.Title}}</a></li>
+        {{- end}}
+      </ul>
+    </section>
+    {{- end}}
+    <footer class="pager">
+      {{- if .Page.Prev}}<a class="prev" href="{{.Page.Prev.Href}}">← {{.Page.Prev.Title}}</a>{{end}}
+      {{- if .Page.Next}}<a class="next" href="{{.Page.Next.Href}}">{{.Page.Next.Title}} →</a>{{end}}
+    </footer>
+  </main>
+</div>
+<script src="assets/search.js"></script>
+</body>
+</html>
+{{define "tree"}}
+<ul>
+  {{- $current := .Current}}
+  {{- range .Pages}}
+  <li{{if eq . $current}} class="current"{{end}}><a href="{{.Href}}">{{.Title}}</a>
+    {{- if .Children}}{{template "tree" (dict "Pages" .Children "Current" $current)}}{{end}}
+  </li>
+  {{- end}}
+</ul>
+{{- end}}
+`))
+
+const siteStyle = `*, *::before, *::after { box-sizing: border-box; }
+body { margin: 0; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; line-height: 1.6; color: #1f2328; }
+a { color: #0969da; text-decoration: none; }
+a:hover { text-decoration: underline; }
+header { display: flex; align-items: center; justify-content: space-between; padding: 0.75rem 1.5rem; border-bottom: 1px solid #d0d7de; }
+.site-title { font-weight: 600; font-size: 1.1rem; color: inherit; }
+.search { position: relative; }
+.search input { padding: 0.35rem 0.6rem; border: 1px solid #d0d7de; border-radius: 6px; width: 16rem; }
+#search-results { position: absolute; right: 0; z-index: 10; margin: 0.25rem 0 0; padding: 0; list-style: none; width: 24rem; max-height: 60vh; overflow: auto; background: #fff; border: 1px solid #d0d7de; border-radius: 6px; }
+#search-results:empty { display: none; }
+#search-results li { padding: 0.5rem 0.75rem; border-bottom: 1px solid #eaeef2; }
+#search-results small { display: block; color: #656d76; }
+.layout { display: flex; }
+.sidebar { width: 18rem; flex-shrink: 0; padding: 1rem; border-right: 1px solid #d0d7de; min-height: calc(100vh - 3.5rem); }
+.sidebar ul { list-style: none; margin: 0; padding-left: 0.9rem; }
+.sidebar > ul { padding-left: 0; }
+.sidebar li.current > a { font-weight: 600; color: inherit; }
+main { flex: 1; min-width: 0; max-width: 52rem; padding: 1.5rem 2.5rem; }
+.breadcrumbs { display: flex; flex-wrap: wrap; list-style: none; padding: 0; margin: 0 0 1rem; color: #656d76; font-size: 0.9rem; }
+.breadcrumbs li + li::before { content: "/"; padding: 0 0.4rem; }
+pre { background: #f6f8fa; padding: 0.8rem 1rem; border-radius: 6px; overflow: auto; }
+code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: 0.9em; }
+img { max-width: 100%; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #d0d7de; padding: 0.3rem 0.6rem; }
+blockquote { margin: 0; padding-left: 1rem; border-left: 4px solid #d0d7de; color: #656d76; }
+.wikilink-missing { color: #656d76; }
+.pager { display: flex; justify-content: space-between; margin-top: 3rem; padding-top: 1rem; border-top: 1px solid #d0d7de; }
+.pager .next { margin-left: auto; }
+@media (max-width: 768px) { .sidebar { display: none; } main { padding: 1rem; } .search input { width: 10rem; } }
+`
+
+const siteSearchScript = `(function () {
+  var input = document.getElementById('search');
+  var results = document.getElementById('search-results');
+  var index = null;
+
+  function load() {
+    if (index) return Promise.resolve(index);
+    return fetch('search-index.json').then(function (r) { return r.json(); }).then(function (data) {
+      index = data;
+      return index;
+    });
+  }
+
+  function render(entries) {
+    results.innerHTML = '';
+    entries.slice(0, 20).forEach(function (e) {
+      var li = document.createElement('li');
+      var a = document.createElement('a');
+      a.href = e.href;
+      a.textContent = e.title;
+      li.appendChild(a);
+      if (e.path) {
+        var small = document.createElement('small');
+        small.textContent = e.path;
+        li.appendChild(small);
+      }
+      results.appendChild(li);
+    });
+  }
+
+  input.addEventListener('input', function () {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    if (terms.length === 0) {
+      render([]);
+      return;
+    }
+    load().then(function (entries) {
+      var scored = [];
+      entries.forEach(function (e) {
+        var title = e.title.toLowerCase();
+        var text = e.text.toLowerCase();
+        var score = 0;
+        for (var i = 0; i < terms.length; i++) {
+          var inTitle = title.indexOf(terms[i]) !== -1;
+          if (!inTitle && text.indexOf(terms[i]) === -1) return;
+          score += inTitle ? 10 : 1;
+        }
+        scored.push({ e: e, score: score });
+      });
+      scored.sort(function (a, b) { return b.score - a.score; });
+      render(scored.map(function (s) { return s.e; }));
+    });
+  });
+
+  input.addEventListener('keydown', function (ev) {
+    if (ev.key === 'Escape') {
+      input.value = '';
+      render([]);
+    }
+  });
+})();
+`
@@ -0,0 +1,218 @@
+// Package crdt implements a replicated text document that concurrent editors
+// can change independently and merge without conflicts.
+//
+// The document is an RGA (replicated growable array) of single characters.
+// Every character has a unique ID made of the ID of the client that typed it
+// and a Lamport clock, and remembers the character it was typed after (its
+// origin). Deleted characters stay in the document as tombstones so that
+// later inserts can still refer to them. Replicas that apply the same
+// operations, in any causal order, end up with the same text.
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnknownOrigin means an operation refers to a character the document
+	// has not seen; the sender is out of sync
+	ErrUnknownOrigin = errors.New("unknown origin")
+	ErrInvalidOp     = errors.New("invalid operation")
+)
+
+// SeedClient is the client ID of the characters a document starts with
+const SeedClient = "seed"
+
+const (
+	OpInsert = "insert"
+	OpDelete = "delete"
+)
+
+// ID identifies a character. IDs are ordered by clock, then client.
+type ID struct {
+	Client string `json:"client"`
+	Clock  int64  `json:"clock"`
+}
+
+// IsZero reports whether id is the zero ID, which stands for the start of
+// the document
+func (id ID) IsZero() bool {
+	return id.Client == "" && id.Clock == 0
+}
+
+func (id ID) less(other ID) bool {
+	if id.Clock != other.Clock {
+		return id.Clock < other.Clock
+	}
+	return id.Client < other.Client
+}
+
+func (id ID) String() string {
+	return fmt.Sprintf("%s:%d", id.Client, id.Clock)
+}
+
+// Item is a character of the document
+type Item struct {
+	ID      ID     `json:"id"`
+	Origin  ID     `json:"origin"`
+	Char    string `json:"char"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Op is a change to a document.
+//
+// An insert puts Text after the character Origin, or at the start when
+// Origin is zero. Its characters get the IDs {ID.Client, ID.Clock + i}, and
+// each one after the first has the previous one as origin. ID.Clock must be
+// greater than every clock the inserting client has seen.
+//
+// A delete removes the characters IDs.
+type Op struct {
+	Type   string `json:"type"`
+	ID     ID     `json:"id,omitempty"`
+	Origin ID     `json:"origin,omitempty"`
+	Text   string `json:"text,omitempty"`
+	IDs    []ID   `json:"ids,omitempty"`
+}
+
+// Text is a replicated text document. It is not safe for concurrent use.
+type Text struct {
+	items []*Item
+	index map[ID]*Item
+	clock int64
+}
+
+// NewText returns a document holding s, typed by SeedClient
+func NewText(s string) *Text {
+	t := &Text{index: make(map[ID]*Item)}
+	if s != "" {
+		// Seeding cannot fail: the seed client starts from an empty document
+		_ = t.Apply(Op{Type: OpInsert, ID: ID{Client: SeedClient, Clock: 1}, Text: s})
+	}
+	return t
+}
+
+// Load rebuilds a document from the items of another replica
+func Load(items []Item) (*Text, error) {
+	t := &Text{index: make(map[ID]*Item, len(items))}
+	for i := range items {
+		item := items[i]
+		if _, ok := t.index[item.ID]; ok || item.ID.IsZero() {
+			return nil, fmt.Errorf("%w: duplicate item %s", ErrInvalidOp, item.ID)
+		}
+		t.items = append(t.items, &item)
+		t.index[item.ID] = &item
+		t.clock = max(t.clock, item.ID.Clock)
+	}
+	return t, nil
+}
+
+// String returns the visible text
+func (t *Text) String() string {
+	var sb strings.Builder
+	for _, item := range t.items {
+		if !item.Deleted {
+			sb.WriteString(item.Char)
+		}
+	}
+	return sb.String()
+}
+
+// Clock returns the highest clock in the document. Clients insert with
+// clocks above it.
+func (t *Text) Clock() int64 {
+	return t.clock
+}
+
+// Items returns a copy of every item, tombstones included, in document order
+func (t *Text) Items() []Item {
+	items := make([]Item, len(t.items))
+	for i, item := range t.items {
+		items[i] = *item
+	}
+	return items
+}
+
+// Apply integrates op. Applying an operation again has no effect.
+func (t *Text) Apply(op Op) error {
+	switch op.Type {
+	case OpInsert:
+		return t.insert(op)
+	case OpDelete:
+		for _, id := range op.IDs {
+			if item, ok := t.index[id]; ok {
+				item.Deleted = true
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidOp, op.Type)
+	}
+}
+
+// Validate checks op against the document without applying it
+func (t *Text) Validate(op Op) error {
+	switch op.Type {
+	case OpInsert:
+		if op.ID.Client == "" || op.Text == "" || !utf8.ValidString(op.Text) {
+			return fmt.Errorf("%w: insert needs a client and valid text", ErrInvalidOp)
+		}
+		if !op.Origin.IsZero() {
+			if _, ok := t.index[op.Origin]; !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownOrigin, op.Origin)
+			}
+		}
+		// Lamport order: an insert happens after the character it follows
+		if op.ID.Clock <= op.Origin.Clock {
+			return fmt.Errorf("%w: clock %d is not after origin %s", ErrInvalidOp, op.ID.Clock, op.Origin)
+		}
+		return nil
+	case OpDelete:
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidOp, op.Type)
+	}
+}
+
+func (t *Text) insert(op Op) error {
+	if err := t.Validate(op); err != nil {
+		return err
+	}
+
+	origin := op.Origin
+	id := op.ID
+	for _, r := range op.Text {
+		if _, ok := t.index[id]; !ok {
+			t.integrate(&Item{ID: id, Origin: origin, Char: string(r)})
+		}
+		origin = id
+		id.Clock++
+	}
+	return nil
+}
+
+// integrate places item after its origin, past the concurrent inserts at the
+// same place with higher IDs and everything typed after those
+func (t *Text) integrate(item *Item) {
+	pos := 0
+	if !item.Origin.IsZero() {
+		for i, other := range t.items {
+			if other.ID == item.Origin {
+				pos = i + 1
+				break
+			}
+		}
+	}
+	for pos < len(t.items) && item.ID.less(t.items[pos].ID) {
+		pos++
+	}
+
+	t.items = append(t.items, nil)
+	copy(t.items[pos+1:], t.items[pos:])
+	t.items[pos] = item
+	t.index[item.ID] = item
+	t.clock = max(t.clock, item.ID.Clock)
+}
@@ -0,0 +1,5 @@
+-- Migration: note_property_table
+-- Created at: 2026-10-18 22:05:12
+-- Description: Create note_properties table
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_properties;
@@ -0,0 +1,20 @@
+-- Migration: note_property_table
+-- Created at: 2026-10-18 22:05:12
+-- Description: Create note_properties table
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS note_properties (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  note_id INTEGER NOT NULL,
+  name TEXT NOT NULL COLLATE NOCASE,
+  type TEXT NOT NULL, -- text, number, date, select, url, checkbox
+  value TEXT NOT NULL COLLATE NOCASE, -- canonical text form of the value
+  value_number REAL, -- set for number properties
+  source TEXT NOT NULL DEFAULT 'api', -- api or frontmatter
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  UNIQUE (note_id, name)
+);
+
+-- Indexes for filtering notes by property value
+CREATE INDEX IF NOT EXISTS idx_note_properties_name_value ON note_properties(name, value);
+CREATE INDEX IF NOT EXISTS idx_note_properties_name_number ON note_properties(name, value_number);
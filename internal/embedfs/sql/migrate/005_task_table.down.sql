@@ -0,0 +1,5 @@
+-- Migration: task_table
+-- Created at: 2026-10-18 22:31:47
+-- Description: Create tasks table
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS tasks;
@@ -0,0 +1,21 @@
+-- Migration: task_table
+-- Created at: 2026-10-18 22:31:47
+-- Description: Create tasks table
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS tasks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  note_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  line INTEGER NOT NULL, -- 1-based line of the checkbox in the note content
+  text TEXT NOT NULL,
+  due_date TEXT, -- YYYY-MM-DD from @due(...)
+  done INTEGER NOT NULL DEFAULT 0, -- 1 done, 0 open
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for listing a note's tasks
+CREATE INDEX IF NOT EXISTS idx_tasks_note_id ON tasks(note_id);
+
+-- Index for listing open tasks by due date
+CREATE INDEX IF NOT EXISTS idx_tasks_user_done_due ON tasks(user_id, done, due_date);
@@ -0,0 +1,5 @@
+-- Migration: reminder_table
+-- Created at: 2026-10-18 22:58:03
+-- Description: Create reminders table
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS reminders;
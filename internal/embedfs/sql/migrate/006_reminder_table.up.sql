@@ -0,0 +1,29 @@
+-- Migration: reminder_table
+-- Created at: 2026-10-18 22:58:03
+-- Description: Create reminders table
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS reminders (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  note_id INTEGER NOT NULL,
+  task_id INTEGER, -- set when the reminder is for a task of the note
+  remind_at TIMESTAMP NOT NULL,
+  channel TEXT NOT NULL, -- email, webhook or inbox
+  target TEXT, -- webhook url
+  status TEXT NOT NULL DEFAULT 'pending', -- pending, sending, sent, failed, cancelled
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL, -- next delivery attempt, or lease expiry while sending
+  last_error TEXT,
+  sent_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for the scheduler picking due reminders
+CREATE INDEX IF NOT EXISTS idx_reminders_status_next_attempt ON reminders(status, next_attempt_at);
+
+-- Index for listing a user's reminders
+CREATE INDEX IF NOT EXISTS idx_reminders_user_id ON reminders(user_id);
+
+-- Index for cancelling the reminders of a note
+CREATE INDEX IF NOT EXISTS idx_reminders_note_id ON reminders(note_id);
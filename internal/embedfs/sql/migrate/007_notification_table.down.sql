@@ -0,0 +1,5 @@
+-- Migration: notification_table
+-- Created at: 2026-10-18 22:59:41
+-- Description: Create notifications table for the in-app inbox
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS notifications;
@@ -0,0 +1,18 @@
+-- Migration: notification_table
+-- Created at: 2026-10-18 22:59:41
+-- Description: Create notifications table for the in-app inbox
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS notifications (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  reminder_id INTEGER,
+  note_id INTEGER,
+  title TEXT NOT NULL,
+  body TEXT NOT NULL DEFAULT '',
+  url TEXT NOT NULL DEFAULT '',
+  read_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for listing a user's inbox
+CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications(user_id, created_at);
@@ -0,0 +1,5 @@
+-- Migration: calendar_token_table
+-- Created at: 2026-10-18 23:24:16
+-- Description: Create calendar_tokens table for iCalendar feeds
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS calendar_tokens;
@@ -0,0 +1,15 @@
+-- Migration: calendar_token_table
+-- Created at: 2026-10-18 23:24:16
+-- Description: Create calendar_tokens table for iCalendar feeds
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  token_hash TEXT NOT NULL UNIQUE, -- sha256 of the secret token, hex encoded
+  last_used_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for listing a user's tokens
+CREATE INDEX IF NOT EXISTS idx_calendar_tokens_user_id ON calendar_tokens(user_id);
@@ -0,0 +1,6 @@
+-- Migration: webhook_table
+-- Created at: 2026-10-18 23:41:37
+-- Description: Create webhooks and webhook_deliveries tables
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
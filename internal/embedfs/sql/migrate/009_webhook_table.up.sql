@@ -0,0 +1,48 @@
+-- Migration: webhook_table
+-- Created at: 2026-10-18 23:41:37
+-- Description: Create webhooks and webhook_deliveries tables
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS webhooks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL, -- HMAC-SHA256 key of the payload signatures
+  events TEXT NOT NULL DEFAULT '', -- comma separated event names, empty for all
+  is_active INTEGER NOT NULL DEFAULT 1, -- 1 active, 0 disabled
+  disabled_reason TEXT,
+  failure_count INTEGER NOT NULL DEFAULT 0, -- failed attempts since the last success
+  failing_since TIMESTAMP, -- first failed attempt since the last success
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for finding the webhooks of a user
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  webhook_id INTEGER NOT NULL,
+  event TEXT NOT NULL,
+  note_id INTEGER NOT NULL,
+  payload TEXT NOT NULL, -- JSON body, signed when sent
+  status TEXT NOT NULL DEFAULT 'pending', -- pending, sending, succeeded, failed
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL, -- next attempt, or lease expiry while sending
+  response_code INTEGER, -- HTTP status of the last attempt
+  response_body TEXT, -- start of the last response body
+  last_error TEXT,
+  duration_ms INTEGER NOT NULL DEFAULT 0, -- duration of the last attempt
+  redelivery_of INTEGER, -- delivery this one was redelivered from
+  delivered_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for the dispatcher picking due deliveries
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_next_attempt ON webhook_deliveries(status, next_attempt_at);
+
+-- Index for the delivery log of a webhook
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, id);
+
+-- Index for pruning old deliveries
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries(created_at);
@@ -0,0 +1,5 @@
+-- Migration: stream_event_table
+-- Created at: 2026-10-19 00:12:08
+-- Description: Create stream_events table backing the resumable event stream
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS stream_events;
@@ -0,0 +1,18 @@
+-- Migration: stream_event_table
+-- Created at: 2026-10-19 00:12:08
+-- Description: Create stream_events table backing the resumable event stream
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS stream_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT, -- AUTOINCREMENT keeps cursors monotonic after pruning
+  user_id INTEGER NOT NULL,
+  type TEXT NOT NULL,
+  note_id INTEGER NOT NULL,
+  data TEXT NOT NULL, -- JSON payload
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for replaying the events of a user after a cursor
+CREATE INDEX IF NOT EXISTS idx_stream_events_user_id ON stream_events(user_id, id);
+
+-- Index for pruning old events
+CREATE INDEX IF NOT EXISTS idx_stream_events_created_at ON stream_events(created_at);
@@ -0,0 +1,5 @@
+-- Migration: note_permission_table
+-- Created at: 2026-10-19 09:12:40
+-- Description: Create note_permissions table for sharing notes with other users
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_permissions;
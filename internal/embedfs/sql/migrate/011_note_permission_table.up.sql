@@ -0,0 +1,17 @@
+-- Migration: note_permission_table
+-- Created at: 2026-10-19 09:12:40
+-- Description: Create note_permissions table for sharing notes with other users
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS note_permissions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  note_id INTEGER NOT NULL, -- the grant covers this note and its subtree
+  user_id INTEGER NOT NULL, -- the user the note is shared with
+  role TEXT NOT NULL, -- viewer, commenter or editor
+  granted_by INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  UNIQUE (note_id, user_id)
+);
+
+-- Index for the "shared with me" listing and access checks
+CREATE INDEX IF NOT EXISTS idx_note_permissions_user_id ON note_permissions(user_id);
@@ -0,0 +1,10 @@
+-- Migration: workspace_table
+-- Created at: 2026-10-19 10:05:21
+-- Description: Create workspaces with members and invitations, and move notes into a personal workspace per user
+-- Write your DOWN migration here (rollback)
+DROP INDEX IF EXISTS idx_notes_workspace_id;
+ALTER TABLE notes DROP COLUMN workspace_id;
+ALTER TABLE users DROP COLUMN current_workspace_id;
+DROP TABLE IF EXISTS workspace_invitations;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
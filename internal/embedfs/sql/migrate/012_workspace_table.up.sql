@@ -0,0 +1,73 @@
+-- Migration: workspace_table
+-- Created at: 2026-10-19 10:05:21
+-- Description: Create workspaces with members and invitations, and move notes into a personal workspace per user
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS workspaces (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  owner_id INTEGER NOT NULL,
+  is_personal INTEGER NOT NULL DEFAULT 0, -- 1 the owner's personal workspace, 0 shared
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- At most one personal workspace per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workspaces_personal ON workspaces(owner_id) WHERE is_personal = 1;
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  workspace_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  role TEXT NOT NULL, -- owner, admin, member or guest
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  UNIQUE (workspace_id, user_id)
+);
+
+-- Index for listing a user's workspaces
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);
+
+CREATE TABLE IF NOT EXISTS workspace_invitations (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  workspace_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL, -- the invited user
+  role TEXT NOT NULL, -- role given on acceptance
+  invited_by INTEGER NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending', -- pending, accepted, declined or canceled
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for listing a user's pending invitations
+CREATE INDEX IF NOT EXISTS idx_workspace_invitations_user_id ON workspace_invitations(user_id, status);
+
+-- The workspace a user works in, set by switching
+ALTER TABLE users ADD COLUMN current_workspace_id INTEGER;
+
+ALTER TABLE notes ADD COLUMN workspace_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_notes_workspace_id ON notes(workspace_id, parent_id);
+
+-- Give every existing user a personal workspace holding their notes
+INSERT INTO workspaces (name, owner_id, is_personal)
+SELECT 'Personal', id, 1 FROM users
+WHERE NOT EXISTS (
+  SELECT 1 FROM workspaces w WHERE w.owner_id = users.id AND w.is_personal = 1
+);
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+SELECT id, owner_id, 'owner' FROM workspaces
+WHERE is_personal = 1 AND NOT EXISTS (
+  SELECT 1 FROM workspace_members m WHERE m.workspace_id = workspaces.id AND m.user_id = workspaces.owner_id
+);
+
+UPDATE notes
+SET workspace_id = (
+  SELECT w.id FROM workspaces w WHERE w.owner_id = notes.user_id AND w.is_personal = 1
+)
+WHERE workspace_id IS NULL;
+
+UPDATE users
+SET current_workspace_id = (
+  SELECT w.id FROM workspaces w WHERE w.owner_id = users.id AND w.is_personal = 1
+)
+WHERE current_workspace_id IS NULL;
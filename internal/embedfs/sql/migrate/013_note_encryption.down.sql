@@ -0,0 +1,6 @@
+-- Migration: note_encryption
+-- Created at: 2026-10-19 11:20:47
+-- Description: Add end-to-end encrypted notes and the key envelopes of their users
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS key_envelopes;
+ALTER TABLE notes DROP COLUMN is_encrypted;
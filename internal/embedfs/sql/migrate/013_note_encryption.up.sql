@@ -0,0 +1,24 @@
+-- Migration: note_encryption
+-- Created at: 2026-10-19 11:20:47
+-- Description: Add end-to-end encrypted notes and the key envelopes of their users
+-- Write your UP migration here
+ALTER TABLE notes ADD COLUMN is_encrypted INTEGER NOT NULL DEFAULT 0; -- 1 content is client-side ciphertext, 0 plain
+
+-- The data key encrypting a user's notes, wrapped by keys the server never
+-- sees: one derived from the passphrase and one from the recovery key
+CREATE TABLE IF NOT EXISTS key_envelopes (
+  user_id INTEGER PRIMARY KEY,
+  version INTEGER NOT NULL DEFAULT 1, -- incremented when the data key is replaced
+  algorithm TEXT NOT NULL, -- cipher of the data key and the notes
+  kdf TEXT NOT NULL, -- argon2id or pbkdf2-sha256
+  salt TEXT NOT NULL, -- base64
+  iterations INTEGER NOT NULL,
+  memory_kib INTEGER NOT NULL DEFAULT 0,
+  parallelism INTEGER NOT NULL DEFAULT 0,
+  wrapped_key TEXT NOT NULL, -- base64, data key wrapped by the passphrase key
+  proof_hash TEXT NOT NULL, -- sha256 of the proof derived from the passphrase key
+  recovery_wrapped_key TEXT NOT NULL, -- base64, data key wrapped by the recovery key
+  recovery_proof_hash TEXT NOT NULL, -- sha256 of the proof derived from the recovery key
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
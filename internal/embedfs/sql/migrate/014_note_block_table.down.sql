@@ -0,0 +1,5 @@
+-- Migration: note_block_table
+-- Created at: 2026-10-19 14:05:12
+-- Description: Create note_blocks table for the outline and block references
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_blocks;
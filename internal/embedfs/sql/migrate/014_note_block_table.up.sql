@@ -0,0 +1,22 @@
+-- Migration: note_block_table
+-- Created at: 2026-10-19 14:05:12
+-- Description: Create note_blocks table for the outline and block references
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS note_blocks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  note_id INTEGER NOT NULL,
+  block_id TEXT NOT NULL, -- stable ID, from a "^block-id" marker or generated
+  type TEXT NOT NULL, -- heading, paragraph, list_item, code or table
+  level INTEGER NOT NULL DEFAULT 0, -- heading level, 0 for other blocks
+  line INTEGER NOT NULL, -- 1-based line the block starts on
+  position INTEGER NOT NULL, -- order of the block in the note
+  text TEXT NOT NULL,
+  anchor TEXT NOT NULL, -- HTML id of the block in the rendered note
+  is_explicit INTEGER NOT NULL DEFAULT 0, -- 1 the ID is written in the content
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  UNIQUE (note_id, block_id)
+);
+
+-- Index for resolving ((block-id)) references
+CREATE INDEX IF NOT EXISTS idx_note_blocks_block_id ON note_blocks(block_id);
@@ -0,0 +1,5 @@
+-- Migration: note_link_table
+-- Created at: 2026-10-19 16:42:08
+-- Description: Create note_links table for wiki links and embeds between notes
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_links;
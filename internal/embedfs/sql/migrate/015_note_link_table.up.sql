@@ -0,0 +1,19 @@
+-- Migration: note_link_table
+-- Created at: 2026-10-19 16:42:08
+-- Description: Create note_links table for wiki links and embeds between notes
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS note_links (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  note_id INTEGER NOT NULL, -- the note containing the link
+  target_title TEXT NOT NULL, -- title of the linked note of the same user
+  fragment TEXT NOT NULL DEFAULT '', -- heading or ^block-id after "#"
+  kind TEXT NOT NULL, -- link or embed
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for listing a note's links
+CREATE INDEX IF NOT EXISTS idx_note_links_note_id ON note_links(note_id);
+
+-- Index for finding the notes linking to a title
+CREATE INDEX IF NOT EXISTS idx_note_links_target ON note_links(target_title COLLATE NOCASE, kind);
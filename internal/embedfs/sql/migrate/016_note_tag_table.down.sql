@@ -0,0 +1,6 @@
+-- Migration: note_tag_table
+-- Created at: 2026-10-20 10:12:37
+-- Description: Create note_tags table for the front-matter tags of notes, and index note titles
+-- Write your DOWN migration here (rollback)
+DROP INDEX IF EXISTS idx_notes_user_title;
+DROP TABLE IF EXISTS note_tags;
@@ -0,0 +1,18 @@
+-- Migration: note_tag_table
+-- Created at: 2026-10-20 10:12:37
+-- Description: Create note_tags table for the front-matter tags of notes, and index note titles
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS note_tags (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  note_id INTEGER NOT NULL,
+  tag TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  UNIQUE(note_id, tag)
+);
+
+-- Index for finding the notes with a tag
+CREATE INDEX IF NOT EXISTS idx_note_tags_tag ON note_tags(tag COLLATE NOCASE);
+
+-- Index for resolving wiki links to notes by title
+CREATE INDEX IF NOT EXISTS idx_notes_user_title ON notes(user_id, title COLLATE NOCASE);
@@ -0,0 +1,5 @@
+-- Migration: saved_search_table
+-- Created at: 2026-10-20 15:31:52
+-- Description: Create saved_searches table for smart folders
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS saved_searches;
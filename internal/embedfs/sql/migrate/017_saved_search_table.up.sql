@@ -0,0 +1,17 @@
+-- Migration: saved_search_table
+-- Created at: 2026-10-20 15:31:52
+-- Description: Create saved_searches table for smart folders
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS saved_searches (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  workspace_id INTEGER NOT NULL, -- the workspace whose notes are searched
+  name TEXT NOT NULL,
+  query TEXT NOT NULL,
+  position INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  updated_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for listing a user's saved searches in the sidebar
+CREATE INDEX IF NOT EXISTS idx_saved_searches_user_id ON saved_searches(user_id, workspace_id);
@@ -0,0 +1,5 @@
+-- Migration: note_search_table
+-- Created at: 2026-10-21 09:47:03
+-- Description: Create note_search full-text index of note titles and content
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_search;
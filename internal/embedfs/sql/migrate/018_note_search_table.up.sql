@@ -0,0 +1,7 @@
+-- Migration: note_search_table
+-- Created at: 2026-10-21 09:47:03
+-- Description: Create note_search full-text index of note titles and content
+-- Write your UP migration here
+-- Rows hold the text as tokens made by package search, with the note ID as
+-- docid. Notes are added to it when the server starts.
+CREATE VIRTUAL TABLE IF NOT EXISTS note_search USING fts4(title, content, tokenize=unicode61);
@@ -0,0 +1,5 @@
+-- Migration: note_open_table
+-- Created at: 2026-10-21 14:20:45
+-- Description: Create note_opens table counting how often users open notes
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_opens;
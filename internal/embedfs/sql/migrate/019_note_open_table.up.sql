@@ -0,0 +1,14 @@
+-- Migration: note_open_table
+-- Created at: 2026-10-21 14:20:45
+-- Description: Create note_opens table counting how often users open notes
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS note_opens (
+  user_id INTEGER NOT NULL,
+  note_id INTEGER NOT NULL,
+  count INTEGER NOT NULL DEFAULT 0,
+  last_opened_at TIMESTAMP NOT NULL DEFAULT (datetime('now')),
+  PRIMARY KEY (user_id, note_id)
+);
+
+-- Index for removing the opens of a deleted note
+CREATE INDEX IF NOT EXISTS idx_note_opens_note_id ON note_opens(note_id);
@@ -0,0 +1,6 @@
+-- Migration: note_term_table
+-- Created at: 2026-10-22 10:05:12
+-- Description: Create note_terms and note_vectors tables holding the term vectors of notes for finding related notes
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_vectors;
+DROP TABLE IF EXISTS note_terms;
@@ -0,0 +1,19 @@
+-- Migration: note_term_table
+-- Created at: 2026-10-22 10:05:12
+-- Description: Create note_terms and note_vectors tables holding the term vectors of notes for finding related notes
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS note_terms (
+  note_id INTEGER NOT NULL,
+  term TEXT NOT NULL,
+  count INTEGER NOT NULL,
+  PRIMARY KEY (note_id, term)
+);
+
+-- Index for finding the notes sharing a term
+CREATE INDEX IF NOT EXISTS idx_note_terms_term ON note_terms(term);
+
+-- One row per indexed note, with the number of its terms
+CREATE TABLE IF NOT EXISTS note_vectors (
+  note_id INTEGER PRIMARY KEY,
+  length INTEGER NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Migration: note_duplicate_table
+-- Created at: 2026-10-22 15:32:40
+-- Description: Create note_signatures and note_duplicates tables for finding near-duplicate notes
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS note_duplicates;
+DROP TABLE IF EXISTS note_signatures;
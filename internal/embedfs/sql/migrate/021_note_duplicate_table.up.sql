@@ -0,0 +1,22 @@
+-- Migration: note_duplicate_table
+-- Created at: 2026-10-22 15:32:40
+-- Description: Create note_signatures and note_duplicates tables for finding near-duplicate notes
+-- Write your UP migration here
+-- MinHash signature of a note's content, as of the note's updated_at
+CREATE TABLE IF NOT EXISTS note_signatures (
+  note_id INTEGER PRIMARY KEY,
+  signature BLOB NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+-- Clusters of near-duplicate notes found by the last run of the detection
+-- job. The cluster ID is the smallest note ID of the cluster.
+CREATE TABLE IF NOT EXISTS note_duplicates (
+  note_id INTEGER PRIMARY KEY,
+  workspace_id INTEGER NOT NULL,
+  cluster_id INTEGER NOT NULL,
+  similarity REAL NOT NULL
+);
+
+-- Index for listing the clusters of a workspace
+CREATE INDEX IF NOT EXISTS idx_note_duplicates_workspace_cluster ON note_duplicates(workspace_id, cluster_id);
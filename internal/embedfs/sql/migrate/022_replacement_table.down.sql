@@ -0,0 +1,6 @@
+-- Migration: replacement_table
+-- Created at: 2026-10-23 11:18:27
+-- Description: Create replacements and replacement_notes tables recording find and replace changes so they can be reverted
+-- Write your DOWN migration here (rollback)
+DROP TABLE IF EXISTS replacement_notes;
+DROP TABLE IF EXISTS replacements;
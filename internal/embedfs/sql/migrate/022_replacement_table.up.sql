@@ -0,0 +1,34 @@
+-- Migration: replacement_table
+-- Created at: 2026-10-23 11:18:27
+-- Description: Create replacements and replacement_notes tables recording find and replace changes so they can be reverted
+-- Write your UP migration here
+CREATE TABLE IF NOT EXISTS replacements (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  workspace_id INTEGER NOT NULL,
+  find TEXT NOT NULL,
+  replacement TEXT NOT NULL,
+  is_regex INTEGER NOT NULL DEFAULT 0,
+  case_sensitive INTEGER NOT NULL DEFAULT 0,
+  root_id INTEGER, -- the subtree searched, NULL for the whole workspace
+  tag TEXT NOT NULL DEFAULT '', -- the tag notes must have, empty for any
+  note_count INTEGER NOT NULL DEFAULT 0,
+  match_count INTEGER NOT NULL DEFAULT 0,
+  reverted_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+);
+
+-- Index for listing a user's replacements
+CREATE INDEX IF NOT EXISTS idx_replacements_user_id ON replacements(user_id, workspace_id);
+
+-- The content of each changed note before and after a replacement
+CREATE TABLE IF NOT EXISTS replacement_notes (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  replacement_id INTEGER NOT NULL,
+  note_id INTEGER NOT NULL,
+  content_before TEXT NOT NULL,
+  content_after TEXT NOT NULL
+);
+
+-- Index for reverting a replacement
+CREATE INDEX IF NOT EXISTS idx_replacement_notes_replacement_id ON replacement_notes(replacement_id);
@@ -0,0 +1,6 @@
+-- Migration: note_search_reindex
+-- Created at: 2026-10-23 16:42:09
+-- Description: Empty note_search so that notes are indexed again without a token for the last character of CJK runs
+-- Write your DOWN migration here (rollback)
+-- Notes missing from the index are added to it when the server starts
+DELETE FROM note_search;
@@ -1,9 +1,14 @@
 // Package infra provides infrastructure components including configuration, database, logging, and routing.
 package infra
 
+import "os"
+
 type Config struct {
 	App struct {
 		Addr string
+		// BaseURL is the public URL of the web app, used for links in
+		// notifications sent outside the app
+		BaseURL string
 	}
 	DB struct {
 		Driver string
@@ -12,13 +17,34 @@ type Config struct {
 	Log struct {
 		Level string
 	}
+	Mail struct {
+		// SMTPAddr is the host:port of the SMTP server; email delivery is
+		// disabled when it is empty
+		SMTPAddr string
+		Username string
+		Password string
+		From     string
+	}
 }
 
 func LoadConfig() *Config {
 	cfg := &Config{}
 	cfg.App.Addr = ":18080"
+	cfg.App.BaseURL = envOr("YAN_BASE_URL", "http://localhost:18080")
 	cfg.DB.Driver = "sqlite3"
 	cfg.DB.DSN = "./data.db?_loc=auto"
 	cfg.Log.Level = "info"
+	cfg.Mail.SMTPAddr = os.Getenv("YAN_SMTP_ADDR")
+	cfg.Mail.Username = os.Getenv("YAN_SMTP_USERNAME")
+	cfg.Mail.Password = os.Getenv("YAN_SMTP_PASSWORD")
+	cfg.Mail.From = envOr("YAN_SMTP_FROM", "yan@localhost")
 	return cfg
 }
+
+// envOr returns the environment variable key, or def when it is not set
+func envOr(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
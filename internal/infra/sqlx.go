@@ -21,13 +21,35 @@ func NewDB(config *Config) (*sqlx.DB, error) {
 	return db, nil
 }
 
-// AutoMigrate runs all SQL migration files from the embedded filesystem
+// AutoMigrate runs the SQL migration files from the embedded filesystem that
+// have not been applied yet. Applied migrations are recorded in
+// schema_migrations; databases from before the table existed run every file
+// once more, which the earlier migrations are written to allow.
 func AutoMigrate(db *sqlx.DB, logger *Logger) error {
 	logger.Info("Starting database migration...")
 
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			name TEXT PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL DEFAULT (datetime('now'))
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var applied []string
+	if err := db.Select(&applied, `SELECT name FROM schema_migrations`); err != nil {
+		return fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	isApplied := make(map[string]bool, len(applied))
+	for _, name := range applied {
+		isApplied[name] = true
+	}
+
 	// Read all .up.sql files from the embedded filesystem
 	var migrations []string
-	err := fs.WalkDir(embedfs.SQLFile, "sql/migrate", func(path string, d fs.DirEntry, err error) error {
+	err = fs.WalkDir(embedfs.SQLFile, "sql/migrate", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -43,9 +65,14 @@ func AutoMigrate(db *sqlx.DB, logger *Logger) error {
 	// Sort migrations by filename to ensure they run in order
 	sort.Strings(migrations)
 
-	// Execute each migration
+	// Execute each pending migration
+	executed := 0
 	for _, migrationPath := range migrations {
-		logger.Infof("Running migration: %s", filepath.Base(migrationPath))
+		name := filepath.Base(migrationPath)
+		if isApplied[name] {
+			continue
+		}
+		logger.Infof("Running migration: %s", name)
 
 		// Read the SQL file content
 		content, err := fs.ReadFile(embedfs.SQLFile, migrationPath)
@@ -53,15 +80,27 @@ func AutoMigrate(db *sqlx.DB, logger *Logger) error {
 			return fmt.Errorf("failed to read migration file %s: %w", migrationPath, err)
 		}
 
-		// Execute the SQL
-		_, err = db.Exec(string(content))
+		// Execute the SQL and record it together
+		tx, err := db.Beginx()
 		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(content)); err != nil {
+			_ = tx.Rollback()
 			return fmt.Errorf("failed to execute migration %s: %w", migrationPath, err)
 		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES (?)`, name); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", migrationPath, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", migrationPath, err)
+		}
 
-		logger.Infof("Successfully executed migration: %s", filepath.Base(migrationPath))
+		executed++
+		logger.Infof("Successfully executed migration: %s", name)
 	}
 
-	logger.Infof("Database migration completed. Executed %d migrations.", len(migrations))
+	logger.Infof("Database migration completed. Executed %d migrations.", executed)
 	return nil
 }
@@ -0,0 +1,218 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Block types
+const (
+	BlockHeading   = "heading"
+	BlockParagraph = "paragraph"
+	BlockListItem  = "list_item"
+	BlockCode      = "code"
+	BlockTable     = "table"
+	BlockEmbed     = "embed"
+)
+
+// BlockAnchorPrefix starts the HTML id of blocks other than headings, which
+// keep their slug
+const BlockAnchorPrefix = "block-"
+
+var (
+	// blockIDRe matches a "^block-id" marker at the end of a paragraph
+	blockIDRe    = regexp.MustCompile(`(?:^|\s)\^([A-Za-z0-9][A-Za-z0-9-]*)\s*$`)
+	validBlockID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+)
+
+// IsBlockID reports whether s may be used as a block ID
+func IsBlockID(s string) bool {
+	return validBlockID.MatchString(s)
+}
+
+// Block is a unit of a note that can be referenced on its own: a heading, a
+// paragraph, a list item, a code block, a table or an embed
+type Block struct {
+	Type string
+	// Level is the level of a heading, and 0 for other blocks
+	Level int
+	// Line is the 1-based line the block starts on
+	Line int
+	// Text is the readable text of the block without markup
+	Text string
+	// ID is the ID given in the source with a trailing " ^block-id", empty
+	// if none. Only paragraphs and list items carry one.
+	ID string
+	// HeadingID is the anchor of a heading
+	HeadingID string
+}
+
+// ExtractBlocks returns the blocks of markdown source in document order.
+// A list item is one block with the text of its first paragraph; nested
+// lists and paragraphs after the first yield blocks of their own.
+func ExtractBlocks(src []byte) []Block {
+	var blocks []Block
+	body := blankFrontMatter(src)
+	_ = ast.Walk(Parse(src), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var sb strings.Builder
+		block := Block{}
+		switch n := n.(type) {
+		case *ast.Heading:
+			block.Type = BlockHeading
+			block.Level = n.Level
+			if id, ok := n.AttributeString("id"); ok {
+				block.HeadingID = string(id.([]byte))
+			}
+			writeInlineText(&sb, n, body)
+		case *ast.Paragraph, *ast.TextBlock:
+			block.Type = BlockParagraph
+			if item, ok := n.Parent().(*ast.ListItem); ok && item.FirstChild() == n {
+				block.Type = BlockListItem
+				block.ID = explicitBlockID(item)
+			} else {
+				block.ID = explicitBlockID(n)
+			}
+			writeInlineText(&sb, n, body)
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			block.Type = BlockCode
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				sb.Write(line.Value(body))
+			}
+		case *extast.Table:
+			block.Type = BlockTable
+			writeTableText(&sb, n, body)
+		case *Embed:
+			block.Type = BlockEmbed
+			sb.WriteString(n.DisplayText())
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		line, ok := blockLine(n, body)
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+		block.Line = line
+		block.Text = strings.Join(strings.Fields(sb.String()), " ")
+		blocks = append(blocks, block)
+
+		// The inline content of these is the block; nested blocks of list
+		// items are reached through the list item itself
+		return ast.WalkSkipChildren, nil
+	})
+	return blocks
+}
+
+// explicitBlockID returns the ID the blockIDTransformer found on n
+func explicitBlockID(n ast.Node) string {
+	v, ok := n.AttributeString(blockIDAttr)
+	if !ok {
+		return ""
+	}
+	return string(v.([]byte))
+}
+
+// blockLine returns the 1-based line n starts on in src
+func blockLine(n ast.Node, src []byte) (int, bool) {
+	start := -1
+	switch n := n.(type) {
+	case *ast.FencedCodeBlock:
+		if n.Lines().Len() == 0 {
+			return 0, false
+		}
+		// The opening fence is the line before the first line of code
+		return bytes.Count(src[:n.Lines().At(0).Start], []byte("\n")), true
+	case *extast.Table:
+		_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+			if entering && c.Kind() == extast.KindTableCell && c.Lines().Len() > 0 {
+				start = c.Lines().At(0).Start
+				return ast.WalkStop, nil
+			}
+			return ast.WalkContinue, nil
+		})
+	default:
+		if n.Lines().Len() > 0 {
+			start = n.Lines().At(0).Start
+		}
+	}
+	if start < 0 {
+		return 0, false
+	}
+	return bytes.Count(src[:start], []byte("\n")) + 1, true
+}
+
+// writeTableText appends the text of the table cells, a row per line
+func writeTableText(sb *strings.Builder, table ast.Node, src []byte) {
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			writeInlineText(sb, cell, src)
+			sb.WriteByte(' ')
+		}
+		sb.WriteByte('\n')
+	}
+}
+
+// blockIDAttr is the attribute holding the explicit ID of a block. HTML
+// output leaves it out; the rendered "id" is the anchor.
+const blockIDAttr = "block-id"
+
+// blockIDTransformer takes "^block-id" markers off the end of paragraphs and
+// list items and turns them into anchors. Blocks without a marker get the
+// anchor the caller assigned to their line, if any.
+type blockIDTransformer struct {
+	anchors map[int]string
+}
+
+func (t *blockIDTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	src := reader.Source()
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		target := n
+		switch n.Kind() {
+		case ast.KindParagraph, ast.KindTextBlock:
+			// Paragraphs of tight lists are not rendered as elements, so
+			// the list item carries the anchor
+			if item, ok := n.Parent().(*ast.ListItem); ok && item.FirstChild() == n {
+				target = item
+			}
+			if last, ok := n.LastChild().(*ast.Text); ok {
+				value := last.Segment.Value(src)
+				if m := blockIDRe.FindSubmatchIndex(value); m != nil {
+					id := value[m[2]:m[3]]
+					target.SetAttributeString(blockIDAttr, append([]byte(nil), id...))
+					target.SetAttributeString("id", []byte(BlockAnchorPrefix+string(id)))
+					last.Segment = last.Segment.WithStop(last.Segment.Start + m[0])
+					return ast.WalkContinue, nil
+				}
+			}
+		case ast.KindFencedCodeBlock, ast.KindCodeBlock, extast.KindTable:
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		if len(t.anchors) == 0 {
+			return ast.WalkContinue, nil
+		}
+		if line, ok := blockLine(n, src); ok {
+			if anchor, ok := t.anchors[line]; ok {
+				target.SetAttributeString("id", []byte(anchor))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,86 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindBlockRef is the node kind of BlockRef
+var KindBlockRef = ast.NewNodeKind("BlockRef")
+
+// BlockRef is an inline ((block-id)) reference to a block of any note
+type BlockRef struct {
+	ast.BaseInline
+	ID []byte
+}
+
+func (n *BlockRef) Kind() ast.NodeKind {
+	return KindBlockRef
+}
+
+func (n *BlockRef) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"ID": string(n.ID),
+	}, nil)
+}
+
+// DisplayText returns the text shown for an unresolved reference
+func (n *BlockRef) DisplayText() string {
+	return "((" + string(n.ID) + "))"
+}
+
+type blockRefParser struct{}
+
+func (p *blockRefParser) Trigger() []byte {
+	return []byte{'('}
+}
+
+func (p *blockRefParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("((")) {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("))"))
+	if end <= 0 {
+		return nil
+	}
+	id := line[2 : 2+end]
+	if !validBlockID.Match(id) {
+		return nil
+	}
+	block.Advance(end + 4)
+	return &BlockRef{ID: append([]byte(nil), id...)}
+}
+
+type blockRefRenderer struct {
+	resolve func(id string) (href string, text string, ok bool)
+}
+
+func (r *blockRefRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindBlockRef, r.render)
+}
+
+func (r *blockRefRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*BlockRef)
+	if r.resolve != nil {
+		if href, text, ok := r.resolve(string(n.ID)); ok {
+			_, _ = w.WriteString(`<a class="blockref" href="` + html.EscapeString(href) + `">` + html.EscapeString(text) + `</a>`)
+			return ast.WalkSkipChildren, nil
+		}
+	}
+	_, _ = w.WriteString(`<span class="blockref blockref-missing">` + html.EscapeString(n.DisplayText()) + `</span>`)
+	return ast.WalkSkipChildren, nil
+}
+
+func blockRefParserOption() parser.Option {
+	return parser.WithInlineParsers(util.Prioritized(&blockRefParser{}, wikiLinkParserPriority))
+}
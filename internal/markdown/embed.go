@@ -0,0 +1,199 @@
+package markdown
+
+import (
+	"html"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindEmbed is the node kind of Embed
+var KindEmbed = ast.NewNodeKind("Embed")
+
+// Embed is a ![[Target]] standing alone in a paragraph. It is replaced by
+// the content of the target note, or of one of its sections or blocks with
+// ![[Target#Heading]] and ![[Target#^block-id]]. Embeds within text are
+// plain wiki links.
+type Embed struct {
+	ast.BaseBlock
+	Target []byte
+	Label  []byte
+}
+
+func (n *Embed) Kind() ast.NodeKind {
+	return KindEmbed
+}
+
+func (n *Embed) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Target": string(n.Target),
+		"Label":  string(n.Label),
+	}, nil)
+}
+
+// DisplayText returns the label shown for the embed
+func (n *Embed) DisplayText() string {
+	if len(n.Label) > 0 {
+		return string(n.Label)
+	}
+	return string(n.Target)
+}
+
+// EmbedContent is what an embed is replaced with
+type EmbedContent struct {
+	Href  string
+	Title string
+	// HTML is the rendered content of the target, or nil to only show the
+	// link, e.g. to break a cycle
+	HTML []byte
+}
+
+// Link is a wiki link or embed in markdown source
+type Link struct {
+	// Title is the title of the target note
+	Title string
+	// Fragment is the heading or "^block-id" after "#", empty if none
+	Fragment string
+	Embed    bool
+}
+
+// EmbedRef is an embed standing alone on its lines of the source
+type EmbedRef struct {
+	Target string
+	// Line is the 1-based line of the embed
+	Line int
+}
+
+// ExtractLinks returns the distinct wiki links and embeds of markdown source
+// in document order
+func ExtractLinks(src []byte) []Link {
+	var links []Link
+	seen := make(map[Link]bool)
+	add := func(target []byte, embed bool) {
+		title, fragment, _ := strings.Cut(string(target), "#")
+		l := Link{Title: strings.TrimSpace(title), Fragment: strings.TrimSpace(fragment), Embed: embed}
+		if l.Title == "" || seen[l] {
+			return
+		}
+		seen[l] = true
+		links = append(links, l)
+	}
+
+	_ = ast.Walk(Parse(src), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *WikiLink:
+			add(n.Target, n.Embed)
+		case *Embed:
+			add(n.Target, true)
+		}
+		return ast.WalkContinue, nil
+	})
+	return links
+}
+
+// ExtractEmbeds returns the embeds of markdown source that stand alone in
+// their paragraph, in document order
+func ExtractEmbeds(src []byte) []EmbedRef {
+	var embeds []EmbedRef
+	body := blankFrontMatter(src)
+	_ = ast.Walk(Parse(src), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		e, ok := n.(*Embed)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if line, ok := blockLine(e, body); ok {
+			embeds = append(embeds, EmbedRef{Target: string(e.Target), Line: line})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	return embeds
+}
+
+// embedTransformer turns paragraphs holding nothing but an embed into Embed
+// blocks
+type embedTransformer struct{}
+
+func (t *embedTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	src := reader.Source()
+	var paragraphs []*ast.Paragraph
+	var embeds []*WikiLink
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		p, ok := n.(*ast.Paragraph)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		var link *WikiLink
+		for c := p.FirstChild(); c != nil; c = c.NextSibling() {
+			if l, ok := c.(*WikiLink); ok && l.Embed && link == nil {
+				link = l
+				continue
+			}
+			if t, ok := c.(*ast.Text); ok && len(strings.TrimSpace(string(t.Segment.Value(src)))) == 0 {
+				continue
+			}
+			link = nil
+			break
+		}
+		if link != nil {
+			paragraphs = append(paragraphs, p)
+			embeds = append(embeds, link)
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	for i, p := range paragraphs {
+		e := &Embed{Target: embeds[i].Target, Label: embeds[i].Label}
+		e.SetLines(p.Lines())
+		p.Parent().ReplaceChild(p.Parent(), p, e)
+	}
+}
+
+type embedRenderer struct {
+	resolve         func(target string) (*EmbedContent, bool)
+	resolveWikiLink func(target string) (string, bool)
+}
+
+func (r *embedRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindEmbed, r.render)
+}
+
+func (r *embedRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*Embed)
+	if r.resolve != nil {
+		if content, ok := r.resolve(string(n.Target)); ok {
+			class := "embed"
+			if content.HTML == nil {
+				class += " embed-collapsed"
+			}
+			title := content.Title
+			if len(n.Label) > 0 {
+				title = string(n.Label)
+			}
+			_, _ = w.WriteString(`<div class="` + class + `"><div class="embed-title"><a href="` + html.EscapeString(content.Href) + `">` + html.EscapeString(title) + "</a></div>\n")
+			if content.HTML != nil {
+				_, _ = w.WriteString(`<div class="embed-content">` + "\n")
+				_, _ = w.Write(content.HTML)
+				_, _ = w.WriteString("</div>\n")
+			}
+			_, _ = w.WriteString("</div>\n")
+			return ast.WalkSkipChildren, nil
+		}
+	}
+
+	// Without content the embed is a link to the note
+	_, _ = w.WriteString("<p>")
+	link := &WikiLink{Target: n.Target, Label: n.Label, Embed: true}
+	_, _ = (&wikiLinkRenderer{resolve: r.resolveWikiLink}).render(w, source, link, true)
+	_, _ = w.WriteString("</p>\n")
+	return ast.WalkSkipChildren, nil
+}
@@ -0,0 +1,72 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/goccy/go-yaml"
+)
+
+// SplitFrontMatter separates a leading YAML front-matter block, delimited by
+// "---" lines, from the rest of the source. It returns nil front-matter when
+// the source has none.
+func SplitFrontMatter(src []byte) (frontMatter []byte, body []byte) {
+	end, fmEnd := frontMatterBounds(src)
+	if end < 0 {
+		return nil, src
+	}
+	return src[bytes.IndexByte(src, '\n')+1 : fmEnd], src[end:]
+}
+
+// ParseFrontMatter decodes the front-matter of src into a map. It returns nil
+// when the source has no front-matter.
+func ParseFrontMatter(src []byte) (map[string]any, error) {
+	fm, _ := SplitFrontMatter(src)
+	if fm == nil {
+		return nil, nil
+	}
+
+	values := map[string]any{}
+	if err := yaml.Unmarshal(fm, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// frontMatterBounds returns the offset where the body starts and where the
+// YAML inside the delimiters ends, or -1 when src has no front-matter
+func frontMatterBounds(src []byte) (end int, fmEnd int) {
+	pos := 0
+	for i := 0; pos < len(src); i++ {
+		next := len(src)
+		if n := bytes.IndexByte(src[pos:], '\n'); n >= 0 {
+			next = pos + n + 1
+		}
+		line := string(bytes.TrimRight(src[pos:next], "\r\n"))
+		switch {
+		case i == 0 && line != "---":
+			return -1, -1
+		case i > 0 && (line == "---" || line == "..."):
+			return next, pos
+		}
+		pos = next
+	}
+	return -1, -1
+}
+
+// blankFrontMatter replaces the front-matter of src with spaces, keeping line
+// breaks, so the markdown parser ignores it while node segments still point
+// at the right offsets of the original source
+func blankFrontMatter(src []byte) []byte {
+	end, _ := frontMatterBounds(src)
+	if end < 0 {
+		return src
+	}
+
+	blanked := append([]byte(nil), src...)
+	for i := 0; i < end; i++ {
+		if blanked[i] != '\n' && blanked[i] != '\r' {
+			blanked[i] = ' '
+		}
+	}
+	return blanked
+}
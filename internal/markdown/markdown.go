@@ -0,0 +1,283 @@
+// Package markdown renders note content to HTML with the rules shared by every
+// output of Yan (exports, published sites, the render API).
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// highlightStyle is the chroma style HighlightCSS emits rules for
+const highlightStyle = "github"
+
+type config struct {
+	xhtml           bool
+	rewriteImage    func(dest string) string
+	rewriteLink     func(dest string) string
+	resolveWikiLink func(target string) (string, bool)
+	resolveBlockRef func(id string) (string, string, bool)
+	resolveEmbed    func(target string) (*EmbedContent, bool)
+	blockAnchors    map[int]string
+}
+
+// Option customizes a single Render call
+type Option func(*config)
+
+// WithXHTML makes the output well-formed XML, as required by EPUB documents
+func WithXHTML() Option {
+	return func(c *config) {
+		c.xhtml = true
+	}
+}
+
+// WithImageRewriter lets the caller replace the destination of every image,
+// e.g. to point at a copy embedded in an export. Returning dest unchanged
+// keeps the original reference.
+func WithImageRewriter(fn func(dest string) string) Option {
+	return func(c *config) {
+		c.rewriteImage = fn
+	}
+}
+
+// WithLinkRewriter lets the caller replace the destination of every link,
+// e.g. to turn links to notes into links to published pages
+func WithLinkRewriter(fn func(dest string) string) Option {
+	return func(c *config) {
+		c.rewriteLink = fn
+	}
+}
+
+// WithWikiLinkResolver maps the target of [[wiki links]] to a URL. Links the
+// resolver does not know are rendered as plain text.
+func WithWikiLinkResolver(fn func(target string) (href string, ok bool)) Option {
+	return func(c *config) {
+		c.resolveWikiLink = fn
+	}
+}
+
+// WithBlockRefResolver maps the ID of ((block references)) to a URL and the
+// text to show for it. References the resolver does not know are rendered
+// as plain text.
+func WithBlockRefResolver(fn func(id string) (href string, text string, ok bool)) Option {
+	return func(c *config) {
+		c.resolveBlockRef = fn
+	}
+}
+
+// WithEmbedResolver supplies the content of ![[embeds]]. Embeds the
+// resolver does not know are rendered as wiki links.
+func WithEmbedResolver(fn func(target string) (*EmbedContent, bool)) Option {
+	return func(c *config) {
+		c.resolveEmbed = fn
+	}
+}
+
+// WithBlockAnchors gives the blocks starting on the given 1-based lines an
+// HTML id, so links can point at them. Headings keep their slug, and blocks
+// with an explicit "^block-id" the anchor of that ID.
+func WithBlockAnchors(anchors map[int]string) Option {
+	return func(c *config) {
+		c.blockAnchors = anchors
+	}
+}
+
+// Render converts markdown source into an HTML fragment. Raw HTML in the
+// source is omitted and links with dangerous schemes such as javascript: are
+// dropped, so the output is safe to embed in a page. Code blocks carry chroma
+// classes that HighlightCSS styles. YAML front-matter is not rendered.
+func Render(src []byte, opts ...Option) ([]byte, error) {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var buf bytes.Buffer
+	if err := newGoldmark(cfg).Convert(blankFrontMatter(src), &buf, parser.WithContext(newParserContext())); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Parse returns the syntax tree of markdown source, for outputs that are not
+// HTML. Node segments refer back into src; front-matter yields no nodes.
+func Parse(src []byte) ast.Node {
+	return newGoldmark(&config{}).Parser().Parse(text.NewReader(blankFrontMatter(src)), parser.WithContext(newParserContext()))
+}
+
+// PlainText returns the readable text of markdown source without markup,
+// with blocks separated by newlines
+func PlainText(src []byte) string {
+	var sb strings.Builder
+	_ = ast.Walk(Parse(src), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.Text:
+			if entering {
+				sb.Write(n.Segment.Value(src))
+				if n.SoftLineBreak() || n.HardLineBreak() {
+					sb.WriteByte(' ')
+				}
+			}
+		case *ast.String:
+			if entering {
+				sb.Write(n.Value)
+			}
+		case *WikiLink:
+			if entering {
+				sb.WriteString(n.DisplayText())
+			}
+		case *BlockRef:
+			if entering {
+				sb.WriteString(n.DisplayText())
+			}
+		case *Embed:
+			if entering {
+				sb.WriteString(n.DisplayText())
+				sb.WriteByte('\n')
+			}
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if entering {
+				lines := n.Lines()
+				for i := 0; i < lines.Len(); i++ {
+					line := lines.At(i)
+					sb.Write(line.Value(src))
+				}
+			}
+		default:
+			if !entering && n.Type() == ast.TypeBlock && sb.Len() > 0 {
+				sb.WriteByte('\n')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(sb.String())
+}
+
+// HighlightCSS returns the stylesheet for the syntax highlighting classes in
+// rendered code blocks
+func HighlightCSS() string {
+	var sb strings.Builder
+	_ = chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(&sb, styles.Get(highlightStyle))
+	return sb.String()
+}
+
+// Slug turns text into a URL fragment the way heading anchors are built:
+// lower-cased, with letters and digits of any script kept and everything else
+// collapsed into dashes. It returns "" when nothing is left.
+func Slug(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
+
+func newParserContext() parser.Context {
+	return parser.NewContext(parser.WithIDs(&headingIDs{used: map[string]bool{}}))
+}
+
+// headingIDs generates heading anchors with Slug, so headings in any script
+// get a readable id. Duplicates are numbered like GitHub does.
+type headingIDs struct {
+	used map[string]bool
+}
+
+func (s *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	base := Slug(string(value))
+	if base == "" {
+		base = "heading"
+	}
+	id := base
+	for i := 1; s.used[id]; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
+	}
+	s.used[id] = true
+	return []byte(id)
+}
+
+func (s *headingIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+func newGoldmark(cfg *config) goldmark.Markdown {
+	rendererOpts := []renderer.Option{
+		renderer.WithNodeRenderers(
+			util.Prioritized(&wikiLinkRenderer{resolve: cfg.resolveWikiLink}, 100),
+			util.Prioritized(&blockRefRenderer{resolve: cfg.resolveBlockRef}, 100),
+			util.Prioritized(&embedRenderer{resolve: cfg.resolveEmbed, resolveWikiLink: cfg.resolveWikiLink}, 100),
+		),
+	}
+	if cfg.xhtml {
+		rendererOpts = append(rendererOpts, html.WithXHTML())
+	}
+
+	parserOpts := []parser.Option{
+		parser.WithAutoHeadingID(),
+		wikiLinkParserOption(),
+		blockRefParserOption(),
+		parser.WithASTTransformers(
+			util.Prioritized(&embedTransformer{}, 99),
+			util.Prioritized(&blockIDTransformer{anchors: cfg.blockAnchors}, 100),
+		),
+	}
+	if cfg.rewriteImage != nil || cfg.rewriteLink != nil {
+		parserOpts = append(parserOpts, parser.WithASTTransformers(
+			util.Prioritized(&destTransformer{image: cfg.rewriteImage, link: cfg.rewriteLink}, 100),
+		))
+	}
+
+	return goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			extension.Footnote,
+			highlighting.NewHighlighting(highlighting.WithFormatOptions(chromahtml.WithClasses(true))),
+		),
+		goldmark.WithParserOptions(parserOpts...),
+		goldmark.WithRendererOptions(rendererOpts...),
+	)
+}
+
+// destTransformer rewrites image and link destinations after parsing
+type destTransformer struct {
+	image func(dest string) string
+	link  func(dest string) string
+}
+
+func (t *destTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Image:
+			if t.image != nil {
+				n.Destination = []byte(t.image(string(n.Destination)))
+			}
+		case *ast.Link:
+			if t.link != nil {
+				n.Destination = []byte(t.link(string(n.Destination)))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
@@ -0,0 +1,31 @@
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// noteLinkPathRe matches the editor route of a note in the web app
+var noteLinkPathRe = regexp.MustCompile(`^/edit/(\d+)/?$`)
+
+// NoteLinkID reports whether dest points at a note in the web app, either
+// as a relative /edit/:id path or as an absolute URL, and returns its ID.
+func NoteLinkID(dest string) (int64, bool) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return 0, false
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return 0, false
+	}
+	m := noteLinkPathRe.FindStringSubmatch(u.Path)
+	if m == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,98 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Section is a part of markdown source starting at a heading
+type Section struct {
+	// Title is the readable text of the heading
+	Title string
+	// Start is the offset of the heading line, End the offset where the
+	// section ends
+	Start int
+	End   int
+	// Body is the source of the section below its heading
+	Body []byte
+}
+
+// SplitSections returns the sections of markdown source that start at its
+// top-level headings of the given level, in document order. A section ends
+// at the next heading of that level or above, or at the end of the source.
+// Headings in lists, quotes and code do not start sections.
+func SplitSections(src []byte, level int) []Section {
+	type heading struct {
+		level int
+		start int
+		body  int
+		title string
+	}
+	var headings []heading
+	body := blankFrontMatter(src)
+	doc := Parse(src)
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		h, ok := n.(*ast.Heading)
+		if !ok || h.Lines().Len() == 0 {
+			continue
+		}
+		first := h.Lines().At(0)
+		last := h.Lines().At(h.Lines().Len() - 1)
+		start := lineStart(body, first.Start)
+		end := lineEnd(body, last.Stop)
+
+		// A setext heading is underlined on the next line
+		if trimmed := bytes.TrimLeft(body[start:], " "); len(trimmed) == 0 || trimmed[0] != '#' {
+			end = lineEnd(body, end)
+		}
+
+		var sb strings.Builder
+		writeInlineText(&sb, h, body)
+		headings = append(headings, heading{
+			level: h.Level,
+			start: start,
+			body:  end,
+			title: strings.Join(strings.Fields(sb.String()), " "),
+		})
+	}
+
+	var sections []Section
+	for i, h := range headings {
+		if h.level != level {
+			continue
+		}
+		end := len(src)
+		for _, next := range headings[i+1:] {
+			if next.level <= level {
+				end = next.start
+				break
+			}
+		}
+		sections = append(sections, Section{
+			Title: h.title,
+			Start: h.start,
+			End:   end,
+			Body:  src[h.body:end],
+		})
+	}
+	return sections
+}
+
+// lineStart returns the offset of the start of the line holding pos
+func lineStart(src []byte, pos int) int {
+	return bytes.LastIndexByte(src[:pos], '\n') + 1
+}
+
+// lineEnd returns the offset after the line break ending the line holding
+// pos, or the end of src
+func lineEnd(src []byte, pos int) int {
+	if pos >= len(src) {
+		return len(src)
+	}
+	if i := bytes.IndexByte(src[pos:], '\n'); i >= 0 {
+		return pos + i + 1
+	}
+	return len(src)
+}
@@ -0,0 +1,40 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// ExtractTags returns the distinct tags of the "tags" front-matter field of
+// src, given as a list or as one string separated by commas or spaces. A
+// leading "#" is dropped and tags differing only in case are the same tag.
+func ExtractTags(src []byte) []string {
+	values, err := ParseFrontMatter(src)
+	if err != nil || values == nil {
+		return nil
+	}
+
+	var raw []string
+	switch v := values["tags"].(type) {
+	case string:
+		raw = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				raw = append(raw, s)
+			}
+		}
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, t := range raw {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "#")
+		key := strings.ToLower(t)
+		if t == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		tags = append(tags, t)
+	}
+	return tags
+}
@@ -0,0 +1,111 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+)
+
+// ErrNoTaskOnLine is returned when a line to be rewritten is not a task item
+var ErrNoTaskOnLine = errors.New("line is not a task")
+
+// DueDateLayout is the layout of due dates in @due(...) annotations
+const DueDateLayout = "2006-01-02"
+
+var (
+	dueRe      = regexp.MustCompile(`@due\((\d{4}-\d{2}-\d{2})\)`)
+	checkboxRe = regexp.MustCompile(`^(\s*(?:>\s*)*(?:[-*+]|\d+[.)])\s+\[)[ xX](\])`)
+)
+
+// Task is a "- [ ]" or "- [x]" item of a markdown checklist
+type Task struct {
+	// Line is the 1-based line of the checkbox in the source
+	Line int
+	// Text is the item text without markup and without the due annotation
+	Text string
+	Done bool
+	// Due is the date of the @due(2006-01-02) annotation, empty if none
+	Due string
+}
+
+// ExtractTasks returns the checklist items of markdown source in document
+// order. Checkboxes inside code blocks are not tasks.
+func ExtractTasks(src []byte) []Task {
+	var tasks []Task
+	_ = ast.Walk(Parse(src), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		box, ok := n.(*extast.TaskCheckBox)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		block := box.Parent()
+		if block == nil || block.Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+		var sb strings.Builder
+		writeInlineText(&sb, block, src)
+		text := sb.String()
+
+		task := Task{
+			Line: bytes.Count(src[:block.Lines().At(0).Start], []byte("\n")) + 1,
+			Done: box.IsChecked,
+		}
+		if m := dueRe.FindStringSubmatch(text); m != nil {
+			task.Due = m[1]
+		}
+		task.Text = strings.Join(strings.Fields(dueRe.ReplaceAllString(text, "")), " ")
+		tasks = append(tasks, task)
+		return ast.WalkSkipChildren, nil
+	})
+	return tasks
+}
+
+// SetTaskDone rewrites the checkbox on the given 1-based line of src
+func SetTaskDone(src []byte, line int, done bool) ([]byte, error) {
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	if line < 1 || line > len(lines) {
+		return nil, ErrNoTaskOnLine
+	}
+
+	mark := " "
+	if done {
+		mark = "x"
+	}
+	target := lines[line-1]
+	if !checkboxRe.Match(target) {
+		return nil, ErrNoTaskOnLine
+	}
+	lines[line-1] = checkboxRe.ReplaceAll(target, []byte("${1}"+mark+"${2}"))
+	return bytes.Join(lines, nil), nil
+}
+
+// writeInlineText appends the text of the inline children of n
+func writeInlineText(sb *strings.Builder, n ast.Node, src []byte) {
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *ast.Text:
+			sb.Write(c.Segment.Value(src))
+			if c.SoftLineBreak() || c.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(c.Value)
+		case *ast.CodeSpan:
+			for t := c.FirstChild(); t != nil; t = t.NextSibling() {
+				if text, ok := t.(*ast.Text); ok {
+					sb.Write(text.Segment.Value(src))
+				}
+			}
+		case *WikiLink:
+			sb.WriteString(c.DisplayText())
+		case *BlockRef:
+			sb.WriteString(c.DisplayText())
+		default:
+			writeInlineText(sb, c, src)
+		}
+	}
+}
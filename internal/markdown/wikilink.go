@@ -0,0 +1,213 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindWikiLink is the node kind of WikiLink
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is an inline [[Target]] or [[Target|Label]] reference to another
+// note by title. With a leading "!" it asks for the note to be embedded.
+type WikiLink struct {
+	ast.BaseInline
+	Target []byte
+	Label  []byte
+	Embed  bool
+}
+
+func (n *WikiLink) Kind() ast.NodeKind {
+	return KindWikiLink
+}
+
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Target": string(n.Target),
+		"Label":  string(n.Label),
+		"Embed":  strconv.FormatBool(n.Embed),
+	}, nil)
+}
+
+// DisplayText returns the label shown for the link
+func (n *WikiLink) DisplayText() string {
+	if len(n.Label) > 0 {
+		return string(n.Label)
+	}
+	return string(n.Target)
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'[', '!'}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	embed := bytes.HasPrefix(line, []byte("![["))
+	if embed {
+		line = line[1:]
+	}
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line[2:], []byte("]]"))
+	if end <= 0 {
+		return nil
+	}
+	inner := line[2 : 2+end]
+	if bytes.ContainsAny(inner, "[]\n") {
+		return nil
+	}
+
+	target, label, _ := bytes.Cut(inner, []byte("|"))
+	target = bytes.TrimSpace(target)
+	if len(target) == 0 {
+		return nil
+	}
+	advance := end + 4
+	if embed {
+		advance++
+	}
+	block.Advance(advance)
+	return &WikiLink{
+		Target: append([]byte(nil), target...),
+		Label:  append([]byte(nil), bytes.TrimSpace(label)...),
+		Embed:  embed,
+	}
+}
+
+type wikiLinkRenderer struct {
+	resolve func(target string) (string, bool)
+}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.render)
+}
+
+func (r *wikiLinkRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*WikiLink)
+	label := html.EscapeString(n.DisplayText())
+	if r.resolve != nil {
+		if href, ok := r.resolve(string(n.Target)); ok {
+			_, _ = w.WriteString(`<a class="wikilink" href="` + html.EscapeString(href) + `">` + label + `</a>`)
+			return ast.WalkSkipChildren, nil
+		}
+	}
+	_, _ = w.WriteString(`<span class="wikilink wikilink-missing">` + label + `</span>`)
+	return ast.WalkSkipChildren, nil
+}
+
+// wikiLinkParserPriority runs before the standard link parser (200)
+const wikiLinkParserPriority = 199
+
+func wikiLinkParserOption() parser.Option {
+	return parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{}, wikiLinkParserPriority))
+}
+
+// RewriteLinks points the wiki links and embeds of markdown source at other
+// notes. Titles maps the lowercased title of a target to its replacement;
+// fragments and labels are kept. Links in code are left alone.
+func RewriteLinks(src []byte, titles map[string]string) []byte {
+	var out bytes.Buffer
+	fence := ""
+	for _, line := range bytes.SplitAfter(src, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		if len(line)-len(trimmed) < 4 {
+			if marker := fenceMarker(trimmed); marker != "" {
+				if fence == "" {
+					fence = marker
+				} else if strings.HasPrefix(marker, fence[:1]) && len(marker) >= len(fence) {
+					fence = ""
+				}
+				out.Write(line)
+				continue
+			}
+		}
+		if fence != "" {
+			out.Write(line)
+			continue
+		}
+		out.Write(rewriteLineLinks(line, titles))
+	}
+	return out.Bytes()
+}
+
+// fenceMarker returns the run of backticks or tildes opening or closing a
+// fenced code block at the start of line, or "" if there is none
+func fenceMarker(line []byte) string {
+	if len(line) == 0 || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return string(line[:n])
+}
+
+// rewriteLineLinks rewrites the links of one line outside code spans
+func rewriteLineLinks(line []byte, titles map[string]string) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(line); {
+		// Copy code spans as they are
+		if line[i] == '`' {
+			n := 0
+			for i+n < len(line) && line[i+n] == '`' {
+				n++
+			}
+			ticks := line[i : i+n]
+			if end := bytes.Index(line[i+n:], ticks); end >= 0 {
+				out.Write(line[i : i+n+end+n])
+				i += n + end + n
+				continue
+			}
+			out.Write(ticks)
+			i += n
+			continue
+		}
+
+		if !bytes.HasPrefix(line[i:], []byte("[[")) {
+			out.WriteByte(line[i])
+			i++
+			continue
+		}
+		end := bytes.Index(line[i+2:], []byte("]]"))
+		if end <= 0 || bytes.ContainsAny(line[i+2:i+2+end], "[]\n") {
+			out.WriteString("[[")
+			i += 2
+			continue
+		}
+		inner := string(line[i+2 : i+2+end])
+		target, label, hasLabel := strings.Cut(inner, "|")
+		title, fragment, hasFragment := strings.Cut(target, "#")
+		if replacement, ok := titles[strings.ToLower(strings.TrimSpace(title))]; ok && strings.TrimSpace(title) != "" {
+			title = strings.Replace(title, strings.TrimSpace(title), replacement, 1)
+			inner = title
+			if hasFragment {
+				inner += "#" + fragment
+			}
+			if hasLabel {
+				inner += "|" + label
+			}
+		}
+		out.WriteString("[[" + inner + "]]")
+		i += end + 4
+	}
+	return out.Bytes()
+}
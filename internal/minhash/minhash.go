@@ -0,0 +1,161 @@
+// Package minhash estimates how much texts overlap, to find near-duplicates
+// without comparing every pair of texts.
+//
+// A text is cut into shingles, its overlapping runs of a few characters
+// after lowercasing and collapsing whitespace. The Jaccard similarity of two
+// texts is the share of their shingles they have in common. A signature
+// keeps, for each of Size hash functions, the smallest hash of the text's
+// shingles; two signatures agree at a position with a probability equal to
+// the similarity of their texts.
+//
+// Locality-sensitive hashing groups the positions of a signature into bands
+// and hashes each band. Texts sharing a band hash are candidates for a
+// closer look: texts similar past about (1/Bands)^(1/Rows) share one with
+// high probability, while dissimilar texts rarely do.
+package minhash
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Size is the number of hash functions of a signature
+	Size = 128
+	// Bands and Rows split a signature for locality-sensitive hashing
+	Bands = 16
+	Rows  = Size / Bands
+	// ShingleLength is the length in characters of a shingle
+	ShingleLength = 4
+	// MinShingles is the fewest shingles a text needs for its signature to
+	// mean anything
+	MinShingles = 8
+)
+
+// seeds derive the Size hash functions from the hash of a shingle. They are
+// fixed, so signatures stay comparable across restarts.
+var seeds = func() [Size]uint64 {
+	var s [Size]uint64
+	x := uint64(0x5f3759df)
+	for i := range s {
+		x = mix(x + 0x9e3779b97f4a7c15)
+		s[i] = x
+	}
+	return s
+}()
+
+// Signature is the MinHash signature of a text
+type Signature []uint32
+
+// New returns the signature of the text, or false if the text is too short
+// to have one
+func New(text string) (Signature, bool) {
+	runes := normalize(text)
+	if len(runes)-ShingleLength+1 < MinShingles {
+		return nil, false
+	}
+
+	sig := make(Signature, Size)
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	seen := make(map[uint64]bool)
+	for i := 0; i+ShingleLength <= len(runes); i++ {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(string(runes[i : i+ShingleLength])))
+		shingle := h.Sum64()
+		if seen[shingle] {
+			continue
+		}
+		seen[shingle] = true
+
+		for j, seed := range seeds {
+			if v := uint32(mix(shingle ^ seed)); v < sig[j] {
+				sig[j] = v
+			}
+		}
+	}
+	return sig, true
+}
+
+// Similarity estimates the Jaccard similarity of the texts of two
+// signatures
+func (s Signature) Similarity(o Signature) float64 {
+	if len(s) != Size || len(o) != Size {
+		return 0
+	}
+	same := 0
+	for i := range s {
+		if s[i] == o[i] {
+			same++
+		}
+	}
+	return float64(same) / Size
+}
+
+// Bands returns the hash of each band of the signature. Hashes of different
+// bands never collide on purpose, so they can share one lookup table.
+func (s Signature) Bands() []uint64 {
+	bands := make([]uint64, 0, Bands)
+	buf := make([]byte, 4)
+	for b := 0; b < Bands && (b+1)*Rows <= len(s); b++ {
+		h := fnv.New64a()
+		binary.LittleEndian.PutUint32(buf, uint32(b))
+		_, _ = h.Write(buf)
+		for _, v := range s[b*Rows : (b+1)*Rows] {
+			binary.LittleEndian.PutUint32(buf, v)
+			_, _ = h.Write(buf)
+		}
+		bands = append(bands, h.Sum64())
+	}
+	return bands
+}
+
+// Bytes encodes the signature for storage
+func (s Signature) Bytes() []byte {
+	b := make([]byte, 4*len(s))
+	for i, v := range s {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+// FromBytes decodes a signature encoded by Bytes
+func FromBytes(b []byte) Signature {
+	s := make(Signature, len(b)/4)
+	for i := range s {
+		s[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	return s
+}
+
+// normalize lowercases the text and collapses its whitespace and
+// punctuation into single spaces
+func normalize(text string) []rune {
+	var sb strings.Builder
+	space := true
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(unicode.ToLower(r))
+			space = false
+			continue
+		}
+		if !space {
+			sb.WriteByte(' ')
+			space = true
+		}
+	}
+	return []rune(strings.TrimSpace(sb.String()))
+}
+
+// mix is the finalizer of SplitMix64, which spreads the bits of x
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+type CalendarToken struct {
+	ID         int64      `db:"id" json:"id"`
+	UserID     int64      `db:"user_id" json:"userId"`
+	Name       string     `db:"name" json:"name"`
+	TokenHash  string     `db:"token_hash" json:"-"` // sha256 of the secret token
+	LastUsedAt *time.Time `db:"last_used_at" json:"lastUsedAt"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+}
+
+func (CalendarToken) TableName() string {
+	return "calendar_tokens"
+}
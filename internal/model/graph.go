@@ -0,0 +1,38 @@
+package model
+
+// GraphNode is a note in the knowledge graph
+type GraphNode struct {
+	ID         int64      `db:"id" json:"id"`
+	ParentID   NullInt64  `db:"parent_id" json:"parentId"`
+	Title      string     `db:"title" json:"title"`
+	Icon       NullString `db:"icon" json:"icon"`
+	IsFavorite int        `db:"is_favorite" json:"isFavorite"`
+	Tags       []string   `db:"-" json:"tags"`
+}
+
+// GraphEdge is a relation between two notes of the knowledge graph. Parent
+// edges go from the parent to the child, link and embed edges from the
+// note containing the link to its target. Tag edges join two notes sharing
+// Tag and have no direction.
+type GraphEdge struct {
+	Source int64  `db:"source" json:"source"`
+	Target int64  `db:"target" json:"target"`
+	Type   string `db:"type" json:"type"`
+	Tag    string `db:"tag" json:"tag,omitempty"`
+}
+
+const (
+	// Graph edge types
+	GraphEdgeParent = "parent"
+	GraphEdgeLink   = "link"
+	GraphEdgeEmbed  = "embed"
+	GraphEdgeTag    = "tag"
+)
+
+// Graph is a set of notes and the relations between them. Truncated is set
+// when the notes of a tag carried by many notes are not all joined.
+type Graph struct {
+	Nodes     []*GraphNode `json:"nodes"`
+	Edges     []*GraphEdge `json:"edges"`
+	Truncated bool         `json:"truncated"`
+}
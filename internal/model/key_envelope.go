@@ -0,0 +1,35 @@
+package model
+
+// Key derivation functions for the passphrase key
+const (
+	KDFArgon2id     = "argon2id"
+	KDFPBKDF2SHA256 = "pbkdf2-sha256"
+)
+
+// KeyAlgorithmAES256GCM is the cipher of the data key and of note content
+const KeyAlgorithmAES256GCM = "aes-256-gcm"
+
+// KeyEnvelope holds a user's data key for end-to-end encrypted notes. The
+// key is only stored wrapped: by a key the client derives from the user's
+// passphrase with the KDF parameters, and by a recovery key the user keeps
+// offline. The server checks possession of either through the hash of a
+// proof the client derives from the same key.
+type KeyEnvelope struct {
+	BaseModel
+	UserID             int64  `db:"user_id" json:"userId"`
+	Version            int    `db:"version" json:"version"`
+	Algorithm          string `db:"algorithm" json:"algorithm"`
+	KDF                string `db:"kdf" json:"kdf"`
+	Salt               string `db:"salt" json:"salt"`
+	Iterations         int    `db:"iterations" json:"iterations"`
+	MemoryKiB          int    `db:"memory_kib" json:"memoryKib"`
+	Parallelism        int    `db:"parallelism" json:"parallelism"`
+	WrappedKey         string `db:"wrapped_key" json:"wrappedKey"`
+	ProofHash          string `db:"proof_hash" json:"-"`
+	RecoveryWrappedKey string `db:"recovery_wrapped_key" json:"recoveryWrappedKey"`
+	RecoveryProofHash  string `db:"recovery_proof_hash" json:"-"`
+}
+
+func (KeyEnvelope) TableName() string {
+	return "key_envelopes"
+}
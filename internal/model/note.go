@@ -5,12 +5,14 @@ type Note struct {
 	ID         int64      `db:"id" json:"id"`
 	ParentID   NullInt64  `db:"parent_id" json:"parentId"`
 	UserID     int64         `db:"user_id" json:"userId"`
+	WorkspaceID int64        `db:"workspace_id" json:"workspaceId"`
 	Title      string        `db:"title" json:"title"`
 	Content    string        `db:"content" json:"content"`
 	Icon       NullString    `db:"icon" json:"icon"`
 	IsFavorite int           `db:"is_favorite" json:"isFavorite"` // 1 favorite, 0 not favorite
 	Position   int           `db:"position" json:"position"`
 	Status     int           `db:"status" json:"status"` // 1 normal, 0 trashed
+	IsEncrypted int          `db:"is_encrypted" json:"isEncrypted"` // 1 content is client-side ciphertext, 0 plain
 }
 
 const (
@@ -25,6 +27,12 @@ const (
 	NoteFavoriteYes = 1
 )
 
+const (
+	// Note encryption
+	NotePlain     = 0
+	NoteEncrypted = 1
+)
+
 func (Note) TableName() string {
 	return "notes"
 }
@@ -41,6 +49,66 @@ func (n Note) IsFavorited() bool {
 	return n.IsFavorite == NoteFavoriteYes
 }
 
+// IsEncryptedNote reports whether the content is ciphertext the server
+// cannot read
+func (n Note) IsEncryptedNote() bool {
+	return n.IsEncrypted == NoteEncrypted
+}
+
 func (n Note) IsRoot() bool {
 	return !n.ParentID.Valid
 }
+
+// NoteNode is a note together with its ordered children, used when a whole
+// subtree is processed at once (export, import, publishing).
+type NoteNode struct {
+	*Note
+	Children []*NoteNode
+}
+
+// BuildNoteTree assembles root and its descendants into a tree. Descendants
+// whose parent is not part of the set are dropped. The order of descendants is
+// kept, so callers should pass them sorted by position.
+func BuildNoteTree(root *Note, descendants []*Note) *NoteNode {
+	rootNode := &NoteNode{Note: root}
+	nodes := map[int64]*NoteNode{root.ID: rootNode}
+	for _, n := range descendants {
+		nodes[n.ID] = &NoteNode{Note: n}
+	}
+
+	for _, n := range descendants {
+		if !n.ParentID.Valid {
+			continue
+		}
+		parent, ok := nodes[n.ParentID.Int64]
+		if !ok {
+			continue
+		}
+		parent.Children = append(parent.Children, nodes[n.ID])
+	}
+
+	return rootNode
+}
+
+// Walk visits the node and all of its descendants depth-first, in order.
+// depth is 0 for the node Walk was called on.
+func (n *NoteNode) Walk(fn func(node *NoteNode, depth int)) {
+	n.walk(fn, 0)
+}
+
+func (n *NoteNode) walk(fn func(node *NoteNode, depth int), depth int) {
+	fn(n, depth)
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// StripEncrypted empties the content of the encrypted notes of the tree, for
+// output that is read where it cannot be decrypted. Their titles are kept.
+func (n *NoteNode) StripEncrypted() {
+	n.Walk(func(node *NoteNode, _ int) {
+		if node.IsEncryptedNote() {
+			node.Content = ""
+		}
+	})
+}
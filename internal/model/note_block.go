@@ -0,0 +1,23 @@
+package model
+
+// NoteBlock is a heading, paragraph, list item, code block or table of a
+// note. BlockID stays the same while the note is edited, so ((block-id))
+// references keep pointing at it.
+type NoteBlock struct {
+	BaseModel
+	ID         int64  `db:"id" json:"-"`
+	NoteID     int64  `db:"note_id" json:"noteId"`
+	BlockID    string `db:"block_id" json:"id"`
+	Type       string `db:"type" json:"type"`
+	Level      int    `db:"level" json:"level"`
+	Line       int    `db:"line" json:"line"`
+	Position   int    `db:"position" json:"position"`
+	Text       string `db:"text" json:"text"`
+	Anchor     string `db:"anchor" json:"anchor"`
+	IsExplicit int    `db:"is_explicit" json:"isExplicit"` // 1 the ID is written in the content
+	NoteTitle  string `db:"note_title" json:"noteTitle,omitempty"`
+}
+
+func (NoteBlock) TableName() string {
+	return "note_blocks"
+}
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// NoteSignature is the MinHash signature of a note's content as of the
+// note's UpdatedAt. Notes too short to compare have an empty signature.
+type NoteSignature struct {
+	NoteID    int64     `db:"note_id" json:"noteId"`
+	Signature []byte    `db:"signature" json:"-"`
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+func (NoteSignature) TableName() string {
+	return "note_signatures"
+}
+
+// NoteDuplicate places a note in a cluster of near-duplicates. Similarity
+// is the highest estimated similarity of the note to another of the
+// cluster.
+type NoteDuplicate struct {
+	NoteID      int64   `db:"note_id" json:"noteId"`
+	WorkspaceID int64   `db:"workspace_id" json:"workspaceId"`
+	ClusterID   int64   `db:"cluster_id" json:"clusterId"`
+	Similarity  float64 `db:"similarity" json:"similarity"`
+}
+
+func (NoteDuplicate) TableName() string {
+	return "note_duplicates"
+}
+
+// DuplicateNote is a note of a cluster of near-duplicates, as reviewed
+type DuplicateNote struct {
+	ID         int64      `db:"id" json:"id"`
+	ClusterID  int64      `db:"cluster_id" json:"-"`
+	ParentID   NullInt64  `db:"parent_id" json:"parentId"`
+	Title      string     `db:"title" json:"title"`
+	Icon       NullString `db:"icon" json:"icon"`
+	Similarity float64    `db:"similarity" json:"similarity"`
+	UpdatedAt  time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+// DuplicateCluster is a group of near-duplicate notes. Similarity is the
+// highest estimated similarity of two of its notes.
+type DuplicateCluster struct {
+	ID         int64            `json:"id"`
+	Similarity float64          `json:"similarity"`
+	Notes      []*DuplicateNote `json:"notes"`
+}
@@ -0,0 +1,30 @@
+package model
+
+// NoteLink is a [[wiki link]] or ![[embed]] in a note. The target is the
+// note of the same user with that title, so links follow renames and notes
+// created after the link.
+type NoteLink struct {
+	BaseModel
+	ID          int64  `db:"id" json:"id"`
+	NoteID      int64  `db:"note_id" json:"noteId"`
+	TargetTitle string `db:"target_title" json:"targetTitle"`
+	Fragment    string `db:"fragment" json:"fragment"`
+	Kind        string `db:"kind" json:"kind"`
+}
+
+const (
+	// Note link kinds
+	NoteLinkLink  = "link"
+	NoteLinkEmbed = "embed"
+)
+
+func (NoteLink) TableName() string {
+	return "note_links"
+}
+
+// EmbeddingNote is a note that embeds another one, directly at depth 1 or
+// through the notes embedding it at greater depths
+type EmbeddingNote struct {
+	Note
+	Depth int `db:"-" json:"depth"`
+}
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// NoteOpen counts how often a user opened a note
+type NoteOpen struct {
+	UserID       int64     `db:"user_id" json:"userId"`
+	NoteID       int64     `db:"note_id" json:"noteId"`
+	Count        int       `db:"count" json:"count"`
+	LastOpenedAt time.Time `db:"last_opened_at" json:"lastOpenedAt"`
+}
+
+func (NoteOpen) TableName() string {
+	return "note_opens"
+}
+
+// NoteTitle is a note without its content, as kept by the title index
+type NoteTitle struct {
+	ID          int64      `db:"id" json:"id"`
+	ParentID    NullInt64  `db:"parent_id" json:"parentId"`
+	UserID      int64      `db:"user_id" json:"userId"`
+	WorkspaceID int64      `db:"workspace_id" json:"workspaceId"`
+	Title       string     `db:"title" json:"title"`
+	Icon        NullString `db:"icon" json:"icon"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+// NoteSuggestion is a note offered for a typed title, with the titles of
+// its ancestors from the top
+type NoteSuggestion struct {
+	ID          int64      `json:"id"`
+	Title       string     `json:"title"`
+	Icon        NullString `json:"icon"`
+	Breadcrumbs []string   `json:"breadcrumbs"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// Note roles, from least to most access. A grant on a note applies to its
+// whole subtree. Owner is never granted; it is the role of the note's user.
+const (
+	NoteRoleViewer    = "viewer"
+	NoteRoleCommenter = "commenter"
+	NoteRoleEditor    = "editor"
+	NoteRoleOwner     = "owner"
+)
+
+var noteRoleRanks = map[string]int{
+	NoteRoleViewer:    1,
+	NoteRoleCommenter: 2,
+	NoteRoleEditor:    3,
+	NoteRoleOwner:     4,
+}
+
+// IsGrantableNoteRole reports whether role can be granted to another user
+func IsGrantableNoteRole(role string) bool {
+	return role == NoteRoleViewer || role == NoteRoleCommenter || role == NoteRoleEditor
+}
+
+// NoteRoleAllows reports whether role grants at least the access of want
+func NoteRoleAllows(role string, want string) bool {
+	return noteRoleRanks[role] > 0 && noteRoleRanks[role] >= noteRoleRanks[want]
+}
+
+// HigherNoteRole returns the role with more access
+func HigherNoteRole(a string, b string) string {
+	if noteRoleRanks[b] > noteRoleRanks[a] {
+		return b
+	}
+	return a
+}
+
+type NotePermission struct {
+	BaseModel
+	ID        int64  `db:"id" json:"id"`
+	NoteID    int64  `db:"note_id" json:"noteId"`
+	UserID    int64  `db:"user_id" json:"userId"`
+	Role      string `db:"role" json:"role"`
+	GrantedBy int64  `db:"granted_by" json:"grantedBy"`
+	// Username and Email describe the grantee when listing
+	Username string `db:"username" json:"username"`
+	Email    string `db:"email" json:"email"`
+}
+
+func (NotePermission) TableName() string {
+	return "note_permissions"
+}
+
+// SharedNote is a note another user granted access to
+type SharedNote struct {
+	Note
+	Role      string    `db:"role" json:"role"`
+	OwnerName string    `db:"owner_name" json:"ownerName"`
+	SharedAt  time.Time `db:"shared_at" json:"sharedAt"`
+}
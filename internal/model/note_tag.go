@@ -0,0 +1,19 @@
+package model
+
+// NoteTag is a tag listed in the "tags" front-matter field of a note
+type NoteTag struct {
+	BaseModel
+	ID     int64  `db:"id" json:"id"`
+	NoteID int64  `db:"note_id" json:"noteId"`
+	Tag    string `db:"tag" json:"tag"`
+}
+
+func (NoteTag) TableName() string {
+	return "note_tags"
+}
+
+// TagCount is a tag and the number of notes carrying it
+type TagCount struct {
+	Tag   string `db:"tag" json:"tag"`
+	Count int    `db:"count" json:"count"`
+}
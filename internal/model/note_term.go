@@ -0,0 +1,24 @@
+package model
+
+// TermMatch is a term found in a note, with the note's length in terms and
+// what is shown of it
+type TermMatch struct {
+	NoteID   int64      `db:"note_id"`
+	ParentID NullInt64  `db:"parent_id"`
+	Title    string     `db:"title"`
+	Icon     NullString `db:"icon"`
+	Term     string     `db:"term"`
+	Count    int        `db:"count"`
+	Length   int        `db:"length"`
+}
+
+// RelatedNote is a note similar to another, with the terms they share that
+// weigh most in its score
+type RelatedNote struct {
+	ID       int64      `json:"id"`
+	ParentID NullInt64  `json:"parentId"`
+	Title    string     `json:"title"`
+	Icon     NullString `json:"icon"`
+	Score    float64    `json:"score"`
+	Terms    []string   `json:"terms"`
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type Notification struct {
+	ID         int64      `db:"id" json:"id"`
+	UserID     int64      `db:"user_id" json:"userId"`
+	ReminderID NullInt64  `db:"reminder_id" json:"reminderId"`
+	NoteID     NullInt64  `db:"note_id" json:"noteId"`
+	Title      string     `db:"title" json:"title"`
+	Body       string     `db:"body" json:"body"`
+	URL        string     `db:"url" json:"url"`
+	ReadAt     *time.Time `db:"read_at" json:"readAt"`
+	CreatedAt  time.Time  `db:"created_at" json:"createdAt"`
+}
+
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+func (n Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
@@ -0,0 +1,39 @@
+package model
+
+import "database/sql"
+
+type NoteProperty struct {
+	BaseModel
+	ID     int64  `db:"id" json:"id"`
+	NoteID int64  `db:"note_id" json:"noteId"`
+	Name   string `db:"name" json:"name"`
+	Type   string `db:"type" json:"type"`
+	Value  string `db:"value" json:"value"` // canonical text form of the value
+	Source string `db:"source" json:"source"`
+	// ValueNumber holds number values for numeric comparison
+	ValueNumber sql.NullFloat64 `db:"value_number" json:"-"`
+}
+
+const (
+	// Property types
+	PropertyTypeText     = "text"
+	PropertyTypeNumber   = "number"
+	PropertyTypeDate     = "date"
+	PropertyTypeSelect   = "select"
+	PropertyTypeURL      = "url"
+	PropertyTypeCheckbox = "checkbox"
+)
+
+const (
+	// Property sources
+	PropertySourceAPI         = "api"
+	PropertySourceFrontMatter = "frontmatter"
+)
+
+func (NoteProperty) TableName() string {
+	return "note_properties"
+}
+
+func (p NoteProperty) IsFromFrontMatter() bool {
+	return p.Source == PropertySourceFrontMatter
+}
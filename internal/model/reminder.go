@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+type Reminder struct {
+	BaseModel
+	ID            int64      `db:"id" json:"id"`
+	UserID        int64      `db:"user_id" json:"userId"`
+	NoteID        int64      `db:"note_id" json:"noteId"`
+	TaskID        NullInt64  `db:"task_id" json:"taskId"`
+	RemindAt      time.Time  `db:"remind_at" json:"remindAt"`
+	Channel       string     `db:"channel" json:"channel"` // email, webhook or inbox
+	Target        NullString `db:"target" json:"target"`   // webhook url
+	Status        string     `db:"status" json:"status"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"-"`
+	LastError     NullString `db:"last_error" json:"lastError"`
+	SentAt        *time.Time `db:"sent_at" json:"sentAt"`
+}
+
+const (
+	// Reminder status
+	ReminderStatusPending   = "pending"
+	ReminderStatusSending   = "sending"
+	ReminderStatusSent      = "sent"
+	ReminderStatusFailed    = "failed"
+	ReminderStatusCancelled = "cancelled"
+)
+
+func (Reminder) TableName() string {
+	return "reminders"
+}
+
+func (r Reminder) IsPending() bool {
+	return r.Status == ReminderStatusPending || r.Status == ReminderStatusSending
+}
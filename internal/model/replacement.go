@@ -0,0 +1,74 @@
+package model
+
+import "time"
+
+// Replacement is a find and replace applied across notes, recorded so it can
+// be reverted
+type Replacement struct {
+	ID            int64      `db:"id" json:"id"`
+	UserID        int64      `db:"user_id" json:"userId"`
+	WorkspaceID   int64      `db:"workspace_id" json:"workspaceId"`
+	Find          string     `db:"find" json:"find"`
+	Replacement   string     `db:"replacement" json:"replacement"`
+	IsRegex       int        `db:"is_regex" json:"isRegex"`             // 1 regular expression, 0 literal text
+	CaseSensitive int        `db:"case_sensitive" json:"caseSensitive"` // 1 case sensitive, 0 not
+	RootID        NullInt64  `db:"root_id" json:"rootId"`
+	Tag           string     `db:"tag" json:"tag"`
+	NoteCount     int        `db:"note_count" json:"noteCount"`
+	MatchCount    int        `db:"match_count" json:"matchCount"`
+	RevertedAt    *time.Time `db:"reverted_at" json:"revertedAt"`
+	CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
+}
+
+func (Replacement) TableName() string {
+	return "replacements"
+}
+
+func (r Replacement) IsReverted() bool {
+	return r.RevertedAt != nil
+}
+
+// ReplacementNote is the content of a note before and after a replacement
+type ReplacementNote struct {
+	ID            int64  `db:"id" json:"id"`
+	ReplacementID int64  `db:"replacement_id" json:"replacementId"`
+	NoteID        int64  `db:"note_id" json:"noteId"`
+	Before        string `db:"content_before" json:"-"`
+	After         string `db:"content_after" json:"-"`
+}
+
+func (ReplacementNote) TableName() string {
+	return "replacement_notes"
+}
+
+// ReplaceMatch is a match of a find and replace in a note, with the rest of
+// its line around it. Line and Column are 1-based, Column in characters.
+type ReplaceMatch struct {
+	NoteID      int64  `json:"noteId"`
+	Title       string `json:"title"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	Before      string `json:"before"`
+	Match       string `json:"match"`
+	After       string `json:"after"`
+	Replacement string `json:"replacement"`
+}
+
+// ReplacePreview lists the matches a find and replace would change.
+// Matches stops at a limit, which Truncated reports; the counts are
+// complete.
+type ReplacePreview struct {
+	NoteCount  int             `json:"noteCount"`
+	MatchCount int             `json:"matchCount"`
+	Matches    []*ReplaceMatch `json:"matches"`
+	Truncated  bool            `json:"truncated"`
+}
+
+// ReplacementRevert is the outcome of reverting a replacement. Notes
+// edited since the replacement keep their content and are listed in
+// Skipped.
+type ReplacementRevert struct {
+	Replacement *Replacement `json:"replacement"`
+	Reverted    []int64      `json:"reverted"`
+	Skipped     []int64      `json:"skipped"`
+}
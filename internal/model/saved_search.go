@@ -0,0 +1,17 @@
+package model
+
+// SavedSearch is a search query kept under a name, listed in the sidebar
+// like a folder holding the notes it matches
+type SavedSearch struct {
+	BaseModel
+	ID          int64  `db:"id" json:"id"`
+	UserID      int64  `db:"user_id" json:"userId"`
+	WorkspaceID int64  `db:"workspace_id" json:"workspaceId"`
+	Name        string `db:"name" json:"name"`
+	Query       string `db:"query" json:"query"`
+	Position    int    `db:"position" json:"position"`
+}
+
+func (SavedSearch) TableName() string {
+	return "saved_searches"
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// StreamEvent is a change pushed to the open clients of a user. IDs increase
+// monotonically and serve as the resume cursor of the stream.
+type StreamEvent struct {
+	ID        int64     `db:"id" json:"id"`
+	UserID    int64     `db:"user_id" json:"userId"`
+	Type      string    `db:"type" json:"type"`
+	NoteID    int64     `db:"note_id" json:"noteId"`
+	Data      string    `db:"data" json:"data"` // JSON payload
+	CreatedAt time.Time `db:"created_at" json:"createdAt"`
+}
+
+func (StreamEvent) TableName() string {
+	return "stream_events"
+}
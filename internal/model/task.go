@@ -0,0 +1,27 @@
+package model
+
+type Task struct {
+	BaseModel
+	ID        int64      `db:"id" json:"id"`
+	NoteID    int64      `db:"note_id" json:"noteId"`
+	UserID    int64      `db:"user_id" json:"userId"`
+	Line      int        `db:"line" json:"line"` // 1-based line of the checkbox in the note content
+	Text      string     `db:"text" json:"text"`
+	DueDate   NullString `db:"due_date" json:"dueDate"` // YYYY-MM-DD
+	Done      int        `db:"done" json:"done"`        // 1 done, 0 open
+	NoteTitle string     `db:"note_title" json:"noteTitle"`
+}
+
+const (
+	// Task completion
+	TaskOpen = 0
+	TaskDone = 1
+)
+
+func (Task) TableName() string {
+	return "tasks"
+}
+
+func (t Task) IsDone() bool {
+	return t.Done == TaskDone
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventList is a set of event names, stored comma separated
+type EventList []string
+
+func (l EventList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *EventList) Scan(src any) error {
+	var s string
+	switch v := src.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into EventList", src)
+	}
+
+	*l = EventList{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			*l = append(*l, e)
+		}
+	}
+	return nil
+}
+
+// Has reports whether event is in the list. An empty list has every event.
+func (l EventList) Has(event string) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, e := range l {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type Webhook struct {
+	BaseModel
+	ID     int64     `db:"id" json:"id"`
+	UserID int64     `db:"user_id" json:"userId"`
+	URL    string    `db:"url" json:"url"`
+	Secret string    `db:"secret" json:"-"` // HMAC key of the payload signatures
+	Events EventList `db:"events" json:"events"`
+	// IsActive is cleared by the user or when the endpoint keeps failing
+	IsActive       int        `db:"is_active" json:"isActive"` // 1 active, 0 disabled
+	DisabledReason NullString `db:"disabled_reason" json:"disabledReason"`
+	// FailureCount counts the failed attempts since the last success, which
+	// FailingSince dates
+	FailureCount int        `db:"failure_count" json:"failureCount"`
+	FailingSince *time.Time `db:"failing_since" json:"failingSince"`
+}
+
+const (
+	// Webhook active state
+	WebhookDisabled = 0
+	WebhookActive   = 1
+)
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+func (w Webhook) IsEnabled() bool {
+	return w.IsActive == WebhookActive
+}
+
+// WebhookDelivery is a payload sent, or to be sent, to a webhook
+type WebhookDelivery struct {
+	ID            int64      `db:"id" json:"id"`
+	WebhookID     int64      `db:"webhook_id" json:"webhookId"`
+	Event         string     `db:"event" json:"event"`
+	NoteID        int64      `db:"note_id" json:"noteId"`
+	Payload       string     `db:"payload" json:"payload"`
+	Status        string     `db:"status" json:"status"`
+	Attempts      int        `db:"attempts" json:"attempts"`
+	NextAttemptAt time.Time  `db:"next_attempt_at" json:"-"`
+	ResponseCode  NullInt64  `db:"response_code" json:"responseCode"`
+	ResponseBody  NullString `db:"response_body" json:"responseBody"`
+	LastError     NullString `db:"last_error" json:"lastError"`
+	DurationMs    int64      `db:"duration_ms" json:"durationMs"`
+	RedeliveryOf  NullInt64  `db:"redelivery_of" json:"redeliveryOf"`
+	DeliveredAt   *time.Time `db:"delivered_at" json:"deliveredAt"`
+	CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time  `db:"updated_at" json:"updatedAt"`
+}
+
+const (
+	// Webhook delivery status
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSending   = "sending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusFailed    = "failed"
+)
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
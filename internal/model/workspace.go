@@ -0,0 +1,110 @@
+package model
+
+// Workspace roles, from least to most access. Guests only see the notes
+// shared with them; members edit every note of the workspace; admins also
+// manage members and invitations; the owner can delete the workspace.
+const (
+	WorkspaceRoleGuest  = "guest"
+	WorkspaceRoleMember = "member"
+	WorkspaceRoleAdmin  = "admin"
+	WorkspaceRoleOwner  = "owner"
+)
+
+var workspaceRoleRanks = map[string]int{
+	WorkspaceRoleGuest:  1,
+	WorkspaceRoleMember: 2,
+	WorkspaceRoleAdmin:  3,
+	WorkspaceRoleOwner:  4,
+}
+
+// IsAssignableWorkspaceRole reports whether role can be given to a member.
+// There is a single owner, who creates the workspace.
+func IsAssignableWorkspaceRole(role string) bool {
+	return role == WorkspaceRoleGuest || role == WorkspaceRoleMember || role == WorkspaceRoleAdmin
+}
+
+// WorkspaceRoleAllows reports whether role grants at least the access of want
+func WorkspaceRoleAllows(role string, want string) bool {
+	return workspaceRoleRanks[role] > 0 && workspaceRoleRanks[role] >= workspaceRoleRanks[want]
+}
+
+// WorkspaceNoteRole returns the role a workspace role gives on the notes of
+// the workspace, or "" for none
+func WorkspaceNoteRole(role string) string {
+	switch role {
+	case WorkspaceRoleOwner, WorkspaceRoleAdmin:
+		return NoteRoleOwner
+	case WorkspaceRoleMember:
+		return NoteRoleEditor
+	default:
+		return ""
+	}
+}
+
+const (
+	// Workspace personal flag
+	WorkspaceShared   = 0
+	WorkspacePersonal = 1
+)
+
+type Workspace struct {
+	BaseModel
+	ID         int64  `db:"id" json:"id"`
+	Name       string `db:"name" json:"name"`
+	OwnerID    int64  `db:"owner_id" json:"ownerId"`
+	IsPersonal int    `db:"is_personal" json:"isPersonal"` // 1 personal, 0 shared
+	// Role is the role of the user the workspace was loaded for
+	Role      string `db:"role" json:"role"`
+	IsCurrent bool   `db:"-" json:"isCurrent"`
+}
+
+func (Workspace) TableName() string {
+	return "workspaces"
+}
+
+func (w Workspace) IsPersonalWorkspace() bool {
+	return w.IsPersonal == WorkspacePersonal
+}
+
+type WorkspaceMember struct {
+	BaseModel
+	ID          int64  `db:"id" json:"id"`
+	WorkspaceID int64  `db:"workspace_id" json:"workspaceId"`
+	UserID      int64  `db:"user_id" json:"userId"`
+	Role        string `db:"role" json:"role"`
+	Username    string `db:"username" json:"username"`
+	Email       string `db:"email" json:"email"`
+}
+
+func (WorkspaceMember) TableName() string {
+	return "workspace_members"
+}
+
+const (
+	// Invitation status
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+	InvitationCanceled = "canceled"
+)
+
+type WorkspaceInvitation struct {
+	BaseModel
+	ID            int64  `db:"id" json:"id"`
+	WorkspaceID   int64  `db:"workspace_id" json:"workspaceId"`
+	WorkspaceName string `db:"workspace_name" json:"workspaceName"`
+	UserID        int64  `db:"user_id" json:"userId"`
+	Username      string `db:"username" json:"username"`
+	Role          string `db:"role" json:"role"`
+	InvitedBy     int64  `db:"invited_by" json:"invitedBy"`
+	InviterName   string `db:"inviter_name" json:"inviterName"`
+	Status        string `db:"status" json:"status"`
+}
+
+func (WorkspaceInvitation) TableName() string {
+	return "workspace_invitations"
+}
+
+func (i WorkspaceInvitation) IsPending() bool {
+	return i.Status == InvitationPending
+}
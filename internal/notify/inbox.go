@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+type inboxNotifier struct {
+	notificationRepo repo.NotificationRepo
+}
+
+// NewInboxNotifier stores messages in the user's in-app notification inbox
+func NewInboxNotifier(notificationRepo repo.NotificationRepo) Notifier {
+	return &inboxNotifier{
+		notificationRepo: notificationRepo,
+	}
+}
+
+func (n *inboxNotifier) Channel() string {
+	return ChannelInbox
+}
+
+func (n *inboxNotifier) Notify(ctx context.Context, msg *Message) error {
+	notification := &model.Notification{
+		UserID: msg.UserID,
+		Title:  msg.Title,
+		Body:   msg.Body,
+		URL:    msg.URL,
+	}
+	if msg.ReminderID != 0 {
+		notification.ReminderID = model.NullInt64{NullInt64: sql.NullInt64{Int64: msg.ReminderID, Valid: true}}
+	}
+	if msg.NoteID != 0 {
+		notification.NoteID = model.NullInt64{NullInt64: sql.NullInt64{Int64: msg.NoteID, Valid: true}}
+	}
+	return n.notificationRepo.Create(ctx, notification)
+}
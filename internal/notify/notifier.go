@@ -0,0 +1,45 @@
+// Package notify delivers messages to users through pluggable channels such
+// as email, webhooks and the in-app inbox.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Delivery channels
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelInbox   = "inbox"
+)
+
+// ErrPermanent marks delivery failures that retrying cannot fix
+var ErrPermanent = errors.New("permanent delivery failure")
+
+// Permanent wraps err so that callers stop retrying the delivery
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// Message is a notification for a single user
+type Message struct {
+	UserID int64
+	// Email is the address of the user, used by the email channel
+	Email string
+	// Target is a channel specific destination, e.g. the URL of a webhook
+	Target string
+	Title  string
+	Body   string
+	// URL links to the note the message is about
+	URL        string
+	NoteID     int64
+	ReminderID int64
+}
+
+// Notifier delivers messages over one channel
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, msg *Message) error
+}
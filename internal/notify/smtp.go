@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/infra"
+)
+
+type smtpNotifier struct {
+	addr     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPNotifier sends messages as plain text email through the SMTP server
+// of the mail config. STARTTLS is used when the server offers it.
+func NewSMTPNotifier(config *infra.Config) Notifier {
+	return &smtpNotifier{
+		addr:     config.Mail.SMTPAddr,
+		username: config.Mail.Username,
+		password: config.Mail.Password,
+		from:     config.Mail.From,
+	}
+}
+
+func (n *smtpNotifier) Channel() string {
+	return ChannelEmail
+}
+
+func (n *smtpNotifier) Notify(ctx context.Context, msg *Message) error {
+	if n.addr == "" {
+		return Permanent(errors.New("email delivery is not configured"))
+	}
+	if msg.Email == "" {
+		return Permanent(errors.New("user has no email address"))
+	}
+
+	host, _, err := net.SplitHostPort(n.addr)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.username, n.password, host)); err != nil {
+			return Permanent(err)
+		}
+	}
+
+	if err := c.Mail(n.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.Email); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.compose(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose renders msg as an RFC 5322 message
+func (n *smtpNotifier) compose(msg *Message) []byte {
+	var body bytes.Buffer
+	qp := quotedprintable.NewWriter(&body)
+	text := msg.Body
+	if msg.URL != "" {
+		text += "\n\n" + msg.URL
+	}
+	_, _ = qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	_ = qp.Close()
+
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	domain := "localhost"
+	if at := strings.LastIndexByte(n.from, '@'); at >= 0 {
+		domain = strings.TrimSuffix(n.from[at+1:], ">")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.Email)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return buf.Bytes()
+}
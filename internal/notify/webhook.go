@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/safehttp"
+)
+
+// webhookTimeout bounds a single webhook request
+const webhookTimeout = 10 * time.Second
+
+type webhookNotifier struct {
+	client *http.Client
+}
+
+// NewWebhookNotifier posts messages as JSON to the URL in Message.Target,
+// which must be at a public address
+func NewWebhookNotifier() Notifier {
+	return &webhookNotifier{
+		client: safehttp.NewClient(webhookTimeout),
+	}
+}
+
+type webhookPayload struct {
+	Event      string    `json:"event"`
+	ReminderID int64     `json:"reminderId,omitempty"`
+	NoteID     int64     `json:"noteId,omitempty"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	URL        string    `json:"url,omitempty"`
+	SentAt     time.Time `json:"sentAt"`
+}
+
+func (n *webhookNotifier) Channel() string {
+	return ChannelWebhook
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, msg *Message) error {
+	u, err := url.Parse(msg.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Permanent(fmt.Errorf("invalid webhook url %q", msg.Target))
+	}
+	if err := safehttp.CheckURL(u); err != nil {
+		return Permanent(err)
+	}
+
+	event := "notification"
+	if msg.ReminderID != 0 {
+		event = "reminder"
+	}
+	payload, err := json.Marshal(webhookPayload{
+		Event:      event,
+		ReminderID: msg.ReminderID,
+		NoteID:     msg.NoteID,
+		Title:      msg.Title,
+		Body:       msg.Body,
+		URL:        msg.URL,
+		SentAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Yan-Notifier")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		if errors.Is(err, safehttp.ErrPrivateAddress) {
+			return Permanent(err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded with %s", resp.Status)
+	// Client errors will not change on retry, except for timeouts and rate limits
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+	return err
+}
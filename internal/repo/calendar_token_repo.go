@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type CalendarTokenRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.CalendarToken, error)
+	GetByHash(ctx context.Context, tokenHash string) (*model.CalendarToken, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*model.CalendarToken, error)
+	Create(ctx context.Context, t *model.CalendarToken) error
+	Delete(ctx context.Context, id int64) error
+	TouchLastUsed(ctx context.Context, id int64) error
+}
+
+type calendarTokenRepo struct {
+	db *sqlx.DB
+}
+
+func NewCalendarTokenRepo(db *sqlx.DB) CalendarTokenRepo {
+	return &calendarTokenRepo{db: db}
+}
+
+func (r *calendarTokenRepo) GetByID(ctx context.Context, id int64) (*model.CalendarToken, error) {
+	var t model.CalendarToken
+	err := r.db.GetContext(ctx, &t, `
+		SELECT id, user_id, name, token_hash, last_used_at, created_at
+		FROM calendar_tokens
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (r *calendarTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*model.CalendarToken, error) {
+	var t model.CalendarToken
+	err := r.db.GetContext(ctx, &t, `
+		SELECT id, user_id, name, token_hash, last_used_at, created_at
+		FROM calendar_tokens
+		WHERE token_hash = ?
+		LIMIT 1
+	`, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (r *calendarTokenRepo) GetByUserID(ctx context.Context, userID int64) ([]*model.CalendarToken, error) {
+	tokens := make([]*model.CalendarToken, 0)
+	err := r.db.SelectContext(ctx, &tokens, `
+		SELECT id, user_id, name, token_hash, last_used_at, created_at
+		FROM calendar_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *calendarTokenRepo) Create(ctx context.Context, t *model.CalendarToken) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO calendar_tokens (user_id, name, token_hash)
+		VALUES (?, ?, ?)
+	`, t.UserID, t.Name, t.TokenHash)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	t.ID = id
+	return nil
+}
+
+func (r *calendarTokenRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM calendar_tokens WHERE id = ?
+	`, id)
+
+	return err
+}
+
+func (r *calendarTokenRepo) TouchLastUsed(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE calendar_tokens SET last_used_at = datetime('now') WHERE id = ?
+	`, id)
+
+	return err
+}
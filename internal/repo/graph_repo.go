@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+// GraphRepo reads the notes of a workspace and the relations between them
+// without loading note content
+type GraphRepo interface {
+	GetNodes(ctx context.Context, workspaceID int64, userID int64) ([]*model.GraphNode, error)
+	GetLinkEdges(ctx context.Context, workspaceID int64, userID int64) ([]*model.GraphEdge, error)
+}
+
+type graphRepo struct {
+	db *sqlx.DB
+}
+
+func NewGraphRepo(db *sqlx.DB) GraphRepo {
+	return &graphRepo{db: db}
+}
+
+// GetNodes returns the normal notes of the workspace. A non-zero userID
+// keeps only the notes of that user.
+func (r *graphRepo) GetNodes(ctx context.Context, workspaceID int64, userID int64) ([]*model.GraphNode, error) {
+	nodes := make([]*model.GraphNode, 0)
+	err := r.db.SelectContext(ctx, &nodes, `
+		SELECT id, parent_id, title, icon, is_favorite
+		FROM notes
+		WHERE workspace_id = ? AND (? = 0 OR user_id = ?) AND status = 1
+		ORDER BY id ASC
+	`, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
+
+// GetLinkEdges returns the wiki links and embeds of the normal notes of the
+// workspace. A link goes to the most recently updated normal note of the
+// same user with the target title, as when it is followed; links to no note
+// are left out. A non-zero userID keeps only the notes of that user.
+func (r *graphRepo) GetLinkEdges(ctx context.Context, workspaceID int64, userID int64) ([]*model.GraphEdge, error) {
+	edges := make([]*model.GraphEdge, 0)
+	err := r.db.SelectContext(ctx, &edges, `
+		SELECT DISTINCT source, target, type
+		FROM (
+			SELECT
+				l.note_id AS source,
+				(
+					SELECT t.id FROM notes t
+					WHERE t.user_id = n.user_id AND t.title = l.target_title COLLATE NOCASE AND t.status = 1
+					ORDER BY t.updated_at DESC
+					LIMIT 1
+				) AS target,
+				l.kind AS type
+			FROM note_links l
+			JOIN notes n ON n.id = l.note_id
+			WHERE n.workspace_id = ? AND (? = 0 OR n.user_id = ?) AND n.status = 1
+		)
+		WHERE target IS NOT NULL AND target != source
+		ORDER BY source ASC, target ASC
+	`, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return edges, nil
+}
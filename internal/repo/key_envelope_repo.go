@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+type KeyEnvelopeRepo interface {
+	GetByUserID(ctx context.Context, userID int64) (*model.KeyEnvelope, error)
+	Create(ctx context.Context, e *model.KeyEnvelope) error
+	Update(ctx context.Context, e *model.KeyEnvelope, version int) (bool, error)
+	Rekey(ctx context.Context, e *model.KeyEnvelope, version int, contents []NoteContent) (bool, error)
+}
+
+type keyEnvelopeRepo struct {
+	db *sqlx.DB
+}
+
+func NewKeyEnvelopeRepo(db *sqlx.DB) KeyEnvelopeRepo {
+	return &keyEnvelopeRepo{db: db}
+}
+
+func (r *keyEnvelopeRepo) GetByUserID(ctx context.Context, userID int64) (*model.KeyEnvelope, error) {
+	var e model.KeyEnvelope
+	err := r.db.GetContext(ctx, &e, `
+		SELECT
+			user_id, version, algorithm, kdf, salt, iterations, memory_kib,
+			parallelism, wrapped_key, proof_hash, recovery_wrapped_key,
+			recovery_proof_hash, created_at, updated_at
+		FROM key_envelopes
+		WHERE user_id = ?
+		LIMIT 1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+func (r *keyEnvelopeRepo) Create(ctx context.Context, e *model.KeyEnvelope) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO key_envelopes (
+			user_id, version, algorithm, kdf, salt, iterations, memory_kib,
+			parallelism, wrapped_key, proof_hash, recovery_wrapped_key,
+			recovery_proof_hash
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		e.UserID,
+		e.Version,
+		e.Algorithm,
+		e.KDF,
+		e.Salt,
+		e.Iterations,
+		e.MemoryKiB,
+		e.Parallelism,
+		e.WrappedKey,
+		e.ProofHash,
+		e.RecoveryWrappedKey,
+		e.RecoveryProofHash,
+	)
+	return err
+}
+
+// Update replaces the envelope if it is still at version. It reports false
+// when another change came first.
+func (r *keyEnvelopeRepo) Update(ctx context.Context, e *model.KeyEnvelope, version int) (bool, error) {
+	return updateKeyEnvelope(ctx, r.db, e, version)
+}
+
+// Rekey replaces the envelope of a new data key together with the content
+// of every note encrypted with the old one, in a single transaction
+func (r *keyEnvelopeRepo) Rekey(ctx context.Context, e *model.KeyEnvelope, version int, contents []NoteContent) (bool, error) {
+	updated := false
+	err := utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		ok, err := updateKeyEnvelope(ctx, tx, e, version)
+		if err != nil || !ok {
+			return err
+		}
+		if err := updateContents(ctx, tx, contents, model.NoteEncrypted); err != nil {
+			return err
+		}
+		updated = true
+		return nil
+	})
+
+	return updated, err
+}
+
+func updateKeyEnvelope(ctx context.Context, db sqlx.ExecerContext, e *model.KeyEnvelope, version int) (bool, error) {
+	res, err := db.ExecContext(ctx, `
+		UPDATE key_envelopes
+		SET
+			version = ?,
+			algorithm = ?,
+			kdf = ?,
+			salt = ?,
+			iterations = ?,
+			memory_kib = ?,
+			parallelism = ?,
+			wrapped_key = ?,
+			proof_hash = ?,
+			recovery_wrapped_key = ?,
+			recovery_proof_hash = ?,
+			updated_at = datetime('now')
+		WHERE user_id = ? AND version = ?
+	`,
+		e.Version,
+		e.Algorithm,
+		e.KDF,
+		e.Salt,
+		e.Iterations,
+		e.MemoryKiB,
+		e.Parallelism,
+		e.WrappedKey,
+		e.ProofHash,
+		e.RecoveryWrappedKey,
+		e.RecoveryProofHash,
+		e.UserID,
+		version,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
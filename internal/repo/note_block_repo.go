@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+type NoteBlockRepo interface {
+	GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteBlock, error)
+	Get(ctx context.Context, noteID int64, blockID string) (*model.NoteBlock, error)
+	GetByBlockID(ctx context.Context, blockID string) ([]*model.NoteBlock, error)
+	Save(ctx context.Context, noteID int64, blocks []*model.NoteBlock) error
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+}
+
+type noteBlockRepo struct {
+	db *sqlx.DB
+}
+
+func NewNoteBlockRepo(db *sqlx.DB) NoteBlockRepo {
+	return &noteBlockRepo{db: db}
+}
+
+func (r *noteBlockRepo) GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteBlock, error) {
+	blocks := make([]*model.NoteBlock, 0)
+	err := r.db.SelectContext(ctx, &blocks, `
+		SELECT
+			id, note_id, block_id, type, level, line, position, text, anchor,
+			is_explicit, created_at, updated_at
+		FROM note_blocks
+		WHERE note_id = ?
+		ORDER BY position ASC
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+func (r *noteBlockRepo) Get(ctx context.Context, noteID int64, blockID string) (*model.NoteBlock, error) {
+	var b model.NoteBlock
+	err := r.db.GetContext(ctx, &b, `
+		SELECT
+			id, note_id, block_id, type, level, line, position, text, anchor,
+			is_explicit, created_at, updated_at
+		FROM note_blocks
+		WHERE note_id = ? AND block_id = ?
+		LIMIT 1
+	`, noteID, blockID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// GetByBlockID returns the blocks with the ID in normal notes, most
+// recently updated note first. Explicit IDs may be used by several notes.
+func (r *noteBlockRepo) GetByBlockID(ctx context.Context, blockID string) ([]*model.NoteBlock, error) {
+	blocks := make([]*model.NoteBlock, 0)
+	err := r.db.SelectContext(ctx, &blocks, `
+		SELECT
+			b.id, b.note_id, b.block_id, b.type, b.level, b.line, b.position,
+			b.text, b.anchor, b.is_explicit, b.created_at, b.updated_at,
+			n.title AS note_title
+		FROM note_blocks b
+		JOIN notes n ON n.id = b.note_id
+		WHERE b.block_id = ? AND n.status = 1
+		ORDER BY n.updated_at DESC, b.position ASC
+	`, blockID)
+	if err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// Save replaces the blocks of the note in a single transaction
+func (r *noteBlockRepo) Save(ctx context.Context, noteID int64, blocks []*model.NoteBlock) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_blocks WHERE note_id = ?
+		`, noteID)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range blocks {
+			b.NoteID = noteID
+			res, err := tx.ExecContext(ctx, `
+				INSERT INTO note_blocks (
+					note_id, block_id, type, level, line, position, text, anchor,
+					is_explicit
+				) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, b.NoteID, b.BlockID, b.Type, b.Level, b.Line, b.Position, b.Text, b.Anchor, b.IsExplicit)
+			if err != nil {
+				return err
+			}
+			if b.ID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *noteBlockRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_blocks WHERE note_id = ?
+	`, noteID)
+
+	return err
+}
@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+// NoteDuplicateRepo stores the MinHash signatures of notes and the clusters
+// of near-duplicates found from them. Only the normal, unencrypted notes of
+// a workspace are compared.
+type NoteDuplicateRepo interface {
+	GetWorkspaceIDs(ctx context.Context) ([]int64, error)
+	GetUnsigned(ctx context.Context, workspaceID int64) ([]*model.Note, error)
+	SaveSignature(ctx context.Context, noteID int64, signature []byte, updatedAt time.Time) error
+	GetSignatures(ctx context.Context, workspaceID int64) ([]*model.NoteSignature, error)
+	SaveClusters(ctx context.Context, workspaceID int64, duplicates []*model.NoteDuplicate) error
+	GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.DuplicateNote, error)
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+}
+
+type noteDuplicateRepo struct {
+	db *sqlx.DB
+}
+
+func NewNoteDuplicateRepo(db *sqlx.DB) NoteDuplicateRepo {
+	return &noteDuplicateRepo{db: db}
+}
+
+// GetWorkspaceIDs returns the workspaces holding notes
+func (r *noteDuplicateRepo) GetWorkspaceIDs(ctx context.Context) ([]int64, error) {
+	ids := make([]int64, 0)
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT DISTINCT workspace_id FROM notes ORDER BY workspace_id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetUnsigned returns the notes of the workspace without a signature or
+// changed since theirs was computed
+func (r *noteDuplicateRepo) GetUnsigned(ctx context.Context, workspaceID int64) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT
+			n.id, n.parent_id, n.user_id, n.workspace_id, n.title, n.content,
+			n.icon, n.is_favorite, n.position, n.status, n.is_encrypted, n.created_at, n.updated_at
+		FROM notes n
+		LEFT JOIN note_signatures s ON s.note_id = n.id
+		WHERE n.workspace_id = ? AND n.status = 1 AND n.is_encrypted = 0
+			AND (s.note_id IS NULL OR datetime(s.updated_at) < datetime(n.updated_at))
+		ORDER BY n.id ASC
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+func (r *noteDuplicateRepo) SaveSignature(ctx context.Context, noteID int64, signature []byte, updatedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO note_signatures (note_id, signature, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (note_id) DO UPDATE
+		SET signature = excluded.signature, updated_at = excluded.updated_at
+	`, noteID, signature, updatedAt)
+
+	return err
+}
+
+// GetSignatures returns the non-empty signatures of the notes of the
+// workspace
+func (r *noteDuplicateRepo) GetSignatures(ctx context.Context, workspaceID int64) ([]*model.NoteSignature, error) {
+	signatures := make([]*model.NoteSignature, 0)
+	err := r.db.SelectContext(ctx, &signatures, `
+		SELECT s.note_id, s.signature, s.updated_at
+		FROM note_signatures s
+		JOIN notes n ON n.id = s.note_id
+		WHERE n.workspace_id = ? AND n.status = 1 AND n.is_encrypted = 0 AND length(s.signature) > 0
+		ORDER BY s.note_id ASC
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return signatures, nil
+}
+
+// SaveClusters replaces the clusters of the workspace in a single
+// transaction
+func (r *noteDuplicateRepo) SaveClusters(ctx context.Context, workspaceID int64, duplicates []*model.NoteDuplicate) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_duplicates WHERE workspace_id = ?
+		`, workspaceID)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range duplicates {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO note_duplicates (note_id, workspace_id, cluster_id, similarity)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT (note_id) DO UPDATE
+				SET workspace_id = excluded.workspace_id, cluster_id = excluded.cluster_id,
+					similarity = excluded.similarity
+			`, d.NoteID, workspaceID, d.ClusterID, d.Similarity)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetByWorkspaceID returns the clustered notes of the workspace that are
+// still normal, by cluster and then most similar first. A non-zero userID
+// keeps only the notes of that user.
+func (r *noteDuplicateRepo) GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.DuplicateNote, error) {
+	notes := make([]*model.DuplicateNote, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT n.id, d.cluster_id, n.parent_id, n.title, n.icon, d.similarity, n.updated_at
+		FROM note_duplicates d
+		JOIN notes n ON n.id = d.note_id
+		WHERE d.workspace_id = ? AND n.workspace_id = ? AND (? = 0 OR n.user_id = ?)
+			AND n.status = 1 AND n.is_encrypted = 0
+		ORDER BY d.cluster_id ASC, d.similarity DESC, n.id ASC
+	`, workspaceID, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+func (r *noteDuplicateRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_signatures WHERE note_id = ?
+		`, noteID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM note_duplicates WHERE note_id = ?
+		`, noteID)
+		return err
+	})
+}
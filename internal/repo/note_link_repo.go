@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+type NoteLinkRepo interface {
+	GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteLink, error)
+	GetSources(ctx context.Context, userID int64, title string, kind string) ([]*model.Note, error)
+	Save(ctx context.Context, noteID int64, links []*model.NoteLink) error
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+}
+
+type noteLinkRepo struct {
+	db *sqlx.DB
+}
+
+func NewNoteLinkRepo(db *sqlx.DB) NoteLinkRepo {
+	return &noteLinkRepo{db: db}
+}
+
+func (r *noteLinkRepo) GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteLink, error) {
+	links := make([]*model.NoteLink, 0)
+	err := r.db.SelectContext(ctx, &links, `
+		SELECT id, note_id, target_title, fragment, kind, created_at, updated_at
+		FROM note_links
+		WHERE note_id = ?
+		ORDER BY id ASC
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// GetSources returns the user's normal notes with a link of the kind to
+// the title, compared case-insensitively
+func (r *noteLinkRepo) GetSources(ctx context.Context, userID int64, title string, kind string) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
+		FROM notes
+		WHERE user_id = ? AND status = 1 AND id IN (
+			SELECT note_id FROM note_links
+			WHERE target_title = ? COLLATE NOCASE AND kind = ?
+		)
+		ORDER BY updated_at DESC
+	`, userID, title, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// Save replaces the links of the note in a single transaction
+func (r *noteLinkRepo) Save(ctx context.Context, noteID int64, links []*model.NoteLink) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_links WHERE note_id = ?
+		`, noteID)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range links {
+			l.NoteID = noteID
+			res, err := tx.ExecContext(ctx, `
+				INSERT INTO note_links (note_id, target_title, fragment, kind)
+				VALUES (?, ?, ?, ?)
+			`, l.NoteID, l.TargetTitle, l.Fragment, l.Kind)
+			if err != nil {
+				return err
+			}
+			if l.ID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *noteLinkRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_links WHERE note_id = ?
+	`, noteID)
+
+	return err
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type NoteOpenRepo interface {
+	GetByUserID(ctx context.Context, userID int64) ([]*model.NoteOpen, error)
+	Record(ctx context.Context, userID int64, noteID int64) (*model.NoteOpen, error)
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+}
+
+type noteOpenRepo struct {
+	db *sqlx.DB
+}
+
+func NewNoteOpenRepo(db *sqlx.DB) NoteOpenRepo {
+	return &noteOpenRepo{db: db}
+}
+
+func (r *noteOpenRepo) GetByUserID(ctx context.Context, userID int64) ([]*model.NoteOpen, error) {
+	opens := make([]*model.NoteOpen, 0)
+	err := r.db.SelectContext(ctx, &opens, `
+		SELECT user_id, note_id, count, last_opened_at
+		FROM note_opens
+		WHERE user_id = ?
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return opens, nil
+}
+
+// Record counts an open of the note by the user
+func (r *noteOpenRepo) Record(ctx context.Context, userID int64, noteID int64) (*model.NoteOpen, error) {
+	var o model.NoteOpen
+	err := r.db.GetContext(ctx, &o, `
+		INSERT INTO note_opens (user_id, note_id, count, last_opened_at)
+		VALUES (?, ?, 1, datetime('now'))
+		ON CONFLICT (user_id, note_id) DO UPDATE
+		SET count = count + 1, last_opened_at = datetime('now')
+		RETURNING user_id, note_id, count, last_opened_at
+	`, userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &o, nil
+}
+
+func (r *noteOpenRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_opens WHERE note_id = ?
+	`, noteID)
+
+	return err
+}
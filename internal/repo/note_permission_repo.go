@@ -0,0 +1,135 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type NotePermissionRepo interface {
+	Get(ctx context.Context, noteID int64, userID int64) (*model.NotePermission, error)
+	GetByNoteID(ctx context.Context, noteID int64) ([]*model.NotePermission, error)
+	GetInheritedRoles(ctx context.Context, noteID int64, userID int64) ([]string, error)
+	GetSharedWithUser(ctx context.Context, userID int64) ([]*model.SharedNote, error)
+	Upsert(ctx context.Context, p *model.NotePermission) error
+	Delete(ctx context.Context, noteID int64, userID int64) error
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+}
+
+type notePermissionRepo struct {
+	db *sqlx.DB
+}
+
+func NewNotePermissionRepo(db *sqlx.DB) NotePermissionRepo {
+	return &notePermissionRepo{db: db}
+}
+
+func (r *notePermissionRepo) Get(ctx context.Context, noteID int64, userID int64) (*model.NotePermission, error) {
+	var p model.NotePermission
+	err := r.db.GetContext(ctx, &p, `
+		SELECT
+			p.id, p.note_id, p.user_id, p.role, p.granted_by,
+			u.username, u.email, p.created_at, p.updated_at
+		FROM note_permissions p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.note_id = ? AND p.user_id = ?
+		LIMIT 1
+	`, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (r *notePermissionRepo) GetByNoteID(ctx context.Context, noteID int64) ([]*model.NotePermission, error) {
+	permissions := make([]*model.NotePermission, 0)
+	err := r.db.SelectContext(ctx, &permissions, `
+		SELECT
+			p.id, p.note_id, p.user_id, p.role, p.granted_by,
+			u.username, u.email, p.created_at, p.updated_at
+		FROM note_permissions p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.note_id = ?
+		ORDER BY p.created_at ASC
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// GetInheritedRoles returns the roles granted to the user on the note and on
+// each of its ancestors
+func (r *notePermissionRepo) GetInheritedRoles(ctx context.Context, noteID int64, userID int64) ([]string, error) {
+	roles := make([]string, 0)
+	err := r.db.SelectContext(ctx, &roles, `
+		WITH RECURSIVE ancestors(id, parent_id) AS (
+			SELECT id, parent_id FROM notes WHERE id = ?
+			UNION
+			SELECT n.id, n.parent_id FROM notes n JOIN ancestors a ON n.id = a.parent_id
+		)
+		SELECT role
+		FROM note_permissions
+		WHERE user_id = ? AND note_id IN (SELECT id FROM ancestors)
+	`, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GetSharedWithUser returns the normal notes granted to the user, most
+// recently shared first. Notes below them are reachable through the tree.
+func (r *notePermissionRepo) GetSharedWithUser(ctx context.Context, userID int64) ([]*model.SharedNote, error) {
+	notes := make([]*model.SharedNote, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT
+			n.id, n.parent_id, n.user_id, n.workspace_id, n.title, n.content,
+			n.icon, n.is_favorite, n.position, n.status, n.is_encrypted, n.created_at, n.updated_at,
+			p.role, u.username AS owner_name, p.created_at AS shared_at
+		FROM note_permissions p
+		JOIN notes n ON n.id = p.note_id
+		JOIN users u ON u.id = n.user_id
+		WHERE p.user_id = ? AND n.status = 1
+		ORDER BY p.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// Upsert grants the role, replacing an existing grant of the user on the
+// note
+func (r *notePermissionRepo) Upsert(ctx context.Context, p *model.NotePermission) error {
+	return r.db.GetContext(ctx, &p.ID, `
+		INSERT INTO note_permissions (note_id, user_id, role, granted_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (note_id, user_id) DO UPDATE SET
+			role = excluded.role,
+			granted_by = excluded.granted_by,
+			updated_at = datetime('now')
+		RETURNING id
+	`, p.NoteID, p.UserID, p.Role, p.GrantedBy)
+}
+
+func (r *notePermissionRepo) Delete(ctx context.Context, noteID int64, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_permissions WHERE note_id = ? AND user_id = ?
+	`, noteID, userID)
+
+	return err
+}
+
+func (r *notePermissionRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_permissions WHERE note_id = ?
+	`, noteID)
+
+	return err
+}
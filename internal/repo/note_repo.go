@@ -7,19 +7,49 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
 )
 
 type NoteRepo interface {
 	GetByID(ctx context.Context, id int64) (*model.Note, error)
-	GetByUserID(ctx context.Context, userID int64, status int) ([]*model.Note, error)
-	GetByParentID(ctx context.Context, parentID sql.NullInt64, userID int64, status int) ([]*model.Note, error)
-	GetFavorites(ctx context.Context, userID int64) ([]*model.Note, error)
+	GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64, status int) ([]*model.Note, error)
+	GetByParentID(ctx context.Context, parentID sql.NullInt64, workspaceID int64, userID int64, status int) ([]*model.Note, error)
+	GetFavorites(ctx context.Context, workspaceID int64, userID int64) ([]*model.Note, error)
+	CountByWorkspaceID(ctx context.Context, workspaceID int64) (int, error)
+	GetByTitle(ctx context.Context, userID int64, title string) (*model.Note, error)
+	GetTitles(ctx context.Context, workspaceID int64) ([]*model.NoteTitle, error)
+	GetDailyNotes(ctx context.Context, userID int64) ([]*model.Note, error)
+	GetDescendants(ctx context.Context, id int64, status int) ([]*model.Note, error)
 	Create(ctx context.Context, n *model.Note) error
+	CreateTree(ctx context.Context, parentID sql.NullInt64, nodes []*model.NoteNode) error
 	Update(ctx context.Context, n *model.Note) error
 	Delete(ctx context.Context, id int64) error
 	UpdateStatus(ctx context.Context, id int64, status int) error
 	UpdateFavorite(ctx context.Context, id int64, isFavorite int) error
 	UpdatePosition(ctx context.Context, id int64, position int) error
+	GetEncryptedIDs(ctx context.Context, userID int64) ([]int64, error)
+	SetEncryption(ctx context.Context, contents []NoteContent, isEncrypted int) error
+	Merge(ctx context.Context, m *NoteMerge) error
+	Split(ctx context.Context, id int64, content string, children []*model.Note) error
+}
+
+// NoteContent is the new content of a note in a batch update
+type NoteContent struct {
+	ID      int64
+	Content string
+}
+
+// NoteMerge is a merge of notes into one of them
+type NoteMerge struct {
+	// TargetID is the note kept, which gets Content
+	TargetID int64
+	Content  string
+	// SourceIDs are the notes merged into the target, in order. They are
+	// trashed, and their children move to the end of the target's.
+	SourceIDs []int64
+	// Relinked holds the new content of the notes whose links were
+	// redirected from a source to the target
+	Relinked []NoteContent
 }
 
 type noteRepo struct {
@@ -34,8 +64,8 @@ func (r *noteRepo) GetByID(ctx context.Context, id int64) (*model.Note, error) {
 	var n model.Note
 	err := r.db.GetContext(ctx, &n, `
 		SELECT
-			id, parent_id, user_id, title, content,
-			icon, is_favorite, position, status, created_at, updated_at
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
 		FROM notes
 		WHERE id = ?
 		LIMIT 1
@@ -47,16 +77,18 @@ func (r *noteRepo) GetByID(ctx context.Context, id int64) (*model.Note, error) {
 	return &n, nil
 }
 
-func (r *noteRepo) GetByUserID(ctx context.Context, userID int64, status int) ([]*model.Note, error) {
+// GetByWorkspaceID returns the notes of the workspace. A non-zero userID
+// keeps only the notes of that user.
+func (r *noteRepo) GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64, status int) ([]*model.Note, error) {
 	notes := make([]*model.Note, 0)
 	err := r.db.SelectContext(ctx, &notes, `
 		SELECT
-			id, parent_id, user_id, title, content,
-			icon, is_favorite, position, status, created_at, updated_at
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
 		FROM notes
-		WHERE user_id = ? AND status = ?
+		WHERE workspace_id = ? AND (? = 0 OR user_id = ?) AND status = ?
 		ORDER BY position ASC, created_at DESC
-	`, userID, status)
+	`, workspaceID, userID, userID, status)
 	if err != nil {
 		return nil, err
 	}
@@ -64,28 +96,31 @@ func (r *noteRepo) GetByUserID(ctx context.Context, userID int64, status int) ([
 	return notes, nil
 }
 
-func (r *noteRepo) GetByParentID(ctx context.Context, parentID sql.NullInt64, userID int64, status int) ([]*model.Note, error) {
+// GetByParentID returns the children of parentID, or the top-level notes
+// when it is null, in the workspace. A non-zero userID keeps only the notes
+// of that user.
+func (r *noteRepo) GetByParentID(ctx context.Context, parentID sql.NullInt64, workspaceID int64, userID int64, status int) ([]*model.Note, error) {
 	notes := make([]*model.Note, 0)
 	var err error
 
 	if parentID.Valid {
 		err = r.db.SelectContext(ctx, &notes, `
 			SELECT
-				id, parent_id, user_id, title, content,
-				icon, is_favorite, position, status, created_at, updated_at
+				id, parent_id, user_id, workspace_id, title, content,
+				icon, is_favorite, position, status, is_encrypted, created_at, updated_at
 			FROM notes
-			WHERE parent_id = ? AND user_id = ? AND status = ?
+			WHERE parent_id = ? AND workspace_id = ? AND (? = 0 OR user_id = ?) AND status = ?
 			ORDER BY position ASC, created_at DESC
-		`, parentID.Int64, userID, status)
+		`, parentID.Int64, workspaceID, userID, userID, status)
 	} else {
 		err = r.db.SelectContext(ctx, &notes, `
 			SELECT
-				id, parent_id, user_id, title, content,
-				icon, is_favorite, position, status, created_at, updated_at
+				id, parent_id, user_id, workspace_id, title, content,
+				icon, is_favorite, position, status, is_encrypted, created_at, updated_at
 			FROM notes
-			WHERE parent_id IS NULL AND user_id = ? AND status = ?
+			WHERE parent_id IS NULL AND workspace_id = ? AND (? = 0 OR user_id = ?) AND status = ?
 			ORDER BY position ASC, created_at DESC
-		`, userID, status)
+		`, workspaceID, userID, userID, status)
 	}
 
 	if err != nil {
@@ -95,15 +130,82 @@ func (r *noteRepo) GetByParentID(ctx context.Context, parentID sql.NullInt64, us
 	return notes, nil
 }
 
-func (r *noteRepo) GetFavorites(ctx context.Context, userID int64) ([]*model.Note, error) {
+// GetFavorites returns the favorite normal notes of the workspace. A
+// non-zero userID keeps only the notes of that user.
+func (r *noteRepo) GetFavorites(ctx context.Context, workspaceID int64, userID int64) ([]*model.Note, error) {
 	notes := make([]*model.Note, 0)
 	err := r.db.SelectContext(ctx, &notes, `
 		SELECT
-			id, parent_id, user_id, title, content,
-			icon, is_favorite, position, status, created_at, updated_at
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
 		FROM notes
-		WHERE user_id = ? AND is_favorite = 1 AND status = 1
+		WHERE workspace_id = ? AND (? = 0 OR user_id = ?) AND is_favorite = 1 AND status = 1
 		ORDER BY position ASC, created_at DESC
+	`, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// CountByWorkspaceID counts the notes of the workspace, trashed included
+func (r *noteRepo) CountByWorkspaceID(ctx context.Context, workspaceID int64) (int, error) {
+	var count int
+	err := r.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM notes WHERE workspace_id = ?
+	`, workspaceID)
+
+	return count, err
+}
+
+// GetByTitle returns the user's most recently updated normal note with the
+// given title, compared case-insensitively
+func (r *noteRepo) GetByTitle(ctx context.Context, userID int64, title string) (*model.Note, error) {
+	var n model.Note
+	err := r.db.GetContext(ctx, &n, `
+		SELECT
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
+		FROM notes
+		WHERE user_id = ? AND title = ? COLLATE NOCASE AND status = 1
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`, userID, title)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// GetTitles returns the normal notes of the workspace without their content
+func (r *noteRepo) GetTitles(ctx context.Context, workspaceID int64) ([]*model.NoteTitle, error) {
+	titles := make([]*model.NoteTitle, 0)
+	err := r.db.SelectContext(ctx, &titles, `
+		SELECT id, parent_id, user_id, workspace_id, title, icon, updated_at
+		FROM notes
+		WHERE workspace_id = ? AND status = 1
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
+
+// GetDailyNotes returns the user's normal notes titled with a date in
+// YYYY-MM-DD form
+func (r *noteRepo) GetDailyNotes(ctx context.Context, userID int64) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
+		FROM notes
+		WHERE user_id = ? AND status = 1
+			AND title GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]-[0-3][0-9]'
+		ORDER BY title ASC
 	`, userID)
 	if err != nil {
 		return nil, err
@@ -112,21 +214,75 @@ func (r *noteRepo) GetFavorites(ctx context.Context, userID int64) ([]*model.Not
 	return notes, nil
 }
 
+// GetDescendants returns every note below id whose ancestors up to id all have
+// the given status, ordered by position.
+func (r *noteRepo) GetDescendants(ctx context.Context, id int64, status int) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		WITH RECURSIVE tree(id) AS (
+			SELECT id FROM notes WHERE parent_id = ? AND status = ?
+			UNION
+			SELECT n.id FROM notes n JOIN tree t ON n.parent_id = t.id
+			WHERE n.status = ?
+		)
+		SELECT
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
+		FROM notes
+		WHERE id IN (SELECT id FROM tree)
+		ORDER BY position ASC, created_at DESC
+	`, id, status, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
 func (r *noteRepo) Create(ctx context.Context, n *model.Note) error {
-	res, err := r.db.ExecContext(ctx, `
+	return insertNote(ctx, r.db, n)
+}
+
+// CreateTree inserts the given nodes and all their children under parentID in
+// a single transaction. The IDs of the created notes are written back into
+// the nodes.
+func (r *noteRepo) CreateTree(ctx context.Context, parentID sql.NullInt64, nodes []*model.NoteNode) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		return insertNodes(ctx, tx, parentID, nodes)
+	})
+}
+
+func insertNodes(ctx context.Context, tx *sqlx.Tx, parentID sql.NullInt64, nodes []*model.NoteNode) error {
+	for _, node := range nodes {
+		node.ParentID = model.NullInt64{NullInt64: parentID}
+		if err := insertNote(ctx, tx, node.Note); err != nil {
+			return err
+		}
+		childParent := sql.NullInt64{Int64: node.ID, Valid: true}
+		if err := insertNodes(ctx, tx, childParent, node.Children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertNote(ctx context.Context, db sqlx.ExecerContext, n *model.Note) error {
+	res, err := db.ExecContext(ctx, `
 		INSERT INTO notes (
 			parent_id,
 			user_id,
+			workspace_id,
 			title,
 			content,
 			icon,
 			is_favorite,
 			position,
 			status
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		n.ParentID,
 		n.UserID,
+		n.WorkspaceID,
 		n.Title,
 		n.Content,
 		n.Icon,
@@ -211,3 +367,126 @@ func (r *noteRepo) UpdatePosition(ctx context.Context, id int64, position int) e
 
 	return err
 }
+
+// GetEncryptedIDs returns the IDs of the user's encrypted notes, trashed
+// included
+func (r *noteRepo) GetEncryptedIDs(ctx context.Context, userID int64) ([]int64, error) {
+	ids := make([]int64, 0)
+	err := r.db.SelectContext(ctx, &ids, `
+		SELECT id FROM notes WHERE user_id = ? AND is_encrypted = 1
+		ORDER BY id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// SetEncryption replaces the content of the given notes and marks them
+// encrypted or plain, in a single transaction
+func (r *noteRepo) SetEncryption(ctx context.Context, contents []NoteContent, isEncrypted int) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		return updateContents(ctx, tx, contents, isEncrypted)
+	})
+}
+
+func updateContents(ctx context.Context, db sqlx.ExecerContext, contents []NoteContent, isEncrypted int) error {
+	for _, c := range contents {
+		_, err := db.ExecContext(ctx, `
+			UPDATE notes
+			SET content = ?, is_encrypted = ?, updated_at = datetime('now')
+			WHERE id = ?
+		`, c.Content, isEncrypted, c.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge writes a merge of notes in a single transaction
+func (r *noteRepo) Merge(ctx context.Context, m *NoteMerge) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE notes
+			SET content = ?, updated_at = datetime('now')
+			WHERE id = ?
+		`, m.Content, m.TargetID)
+		if err != nil {
+			return err
+		}
+
+		for _, sourceID := range m.SourceIDs {
+			var next int
+			err := tx.GetContext(ctx, &next, `
+				SELECT COALESCE(MAX(position) + 1, 0) FROM notes WHERE parent_id = ?
+			`, m.TargetID)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				UPDATE notes
+				SET parent_id = ?, position = position + ?, updated_at = datetime('now')
+				WHERE parent_id = ?
+			`, m.TargetID, next, sourceID)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				UPDATE notes
+				SET status = 0, updated_at = datetime('now')
+				WHERE id = ?
+			`, sourceID)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, c := range m.Relinked {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE notes
+				SET content = ?, updated_at = datetime('now')
+				WHERE id = ?
+			`, c.Content, c.ID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Split gives the note its new content and inserts the children split off
+// it after its other children, in a single transaction. The IDs and
+// positions of the children are written back into them.
+func (r *noteRepo) Split(ctx context.Context, id int64, content string, children []*model.Note) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE notes
+			SET content = ?, updated_at = datetime('now')
+			WHERE id = ?
+		`, content, id)
+		if err != nil {
+			return err
+		}
+
+		var next int
+		err = tx.GetContext(ctx, &next, `
+			SELECT COALESCE(MAX(position) + 1, 0) FROM notes WHERE parent_id = ?
+		`, id)
+		if err != nil {
+			return err
+		}
+
+		for i, child := range children {
+			child.Position = next + i
+			if err := insertNote(ctx, tx, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+type NoteTagRepo interface {
+	GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteTag, error)
+	GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.NoteTag, error)
+	CountByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.TagCount, error)
+	Save(ctx context.Context, noteID int64, tags []*model.NoteTag) error
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+}
+
+type noteTagRepo struct {
+	db *sqlx.DB
+}
+
+func NewNoteTagRepo(db *sqlx.DB) NoteTagRepo {
+	return &noteTagRepo{db: db}
+}
+
+func (r *noteTagRepo) GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteTag, error) {
+	tags := make([]*model.NoteTag, 0)
+	err := r.db.SelectContext(ctx, &tags, `
+		SELECT id, note_id, tag, created_at, updated_at
+		FROM note_tags
+		WHERE note_id = ?
+		ORDER BY id ASC
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// GetByWorkspaceID returns the tags of the normal notes of the workspace. A
+// non-zero userID keeps only the notes of that user.
+func (r *noteTagRepo) GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.NoteTag, error) {
+	tags := make([]*model.NoteTag, 0)
+	err := r.db.SelectContext(ctx, &tags, `
+		SELECT t.id, t.note_id, t.tag, t.created_at, t.updated_at
+		FROM note_tags t
+		JOIN notes n ON n.id = t.note_id
+		WHERE n.workspace_id = ? AND (? = 0 OR n.user_id = ?) AND n.status = 1
+		ORDER BY t.note_id ASC, t.id ASC
+	`, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// CountByWorkspaceID counts the normal notes of the workspace carrying each
+// tag, compared case-insensitively. A non-zero userID counts only the notes
+// of that user.
+func (r *noteTagRepo) CountByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.TagCount, error) {
+	counts := make([]*model.TagCount, 0)
+	err := r.db.SelectContext(ctx, &counts, `
+		SELECT MIN(t.tag) AS tag, COUNT(*) AS count
+		FROM note_tags t
+		JOIN notes n ON n.id = t.note_id
+		WHERE n.workspace_id = ? AND (? = 0 OR n.user_id = ?) AND n.status = 1
+		GROUP BY t.tag COLLATE NOCASE
+		ORDER BY count DESC, tag COLLATE NOCASE ASC
+	`, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// Save replaces the tags of the note in a single transaction
+func (r *noteTagRepo) Save(ctx context.Context, noteID int64, tags []*model.NoteTag) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_tags WHERE note_id = ?
+		`, noteID)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range tags {
+			t.NoteID = noteID
+			res, err := tx.ExecContext(ctx, `
+				INSERT INTO note_tags (note_id, tag)
+				VALUES (?, ?)
+			`, t.NoteID, t.Tag)
+			if err != nil {
+				return err
+			}
+			if t.ID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *noteTagRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_tags WHERE note_id = ?
+	`, noteID)
+
+	return err
+}
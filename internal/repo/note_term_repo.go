@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+// NoteTermRepo stores the term vectors of notes. The statistics and
+// matches it returns only count the normal notes of a workspace, and of one
+// user when userID is not 0.
+type NoteTermRepo interface {
+	Save(ctx context.Context, noteID int64, terms map[string]int) error
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+	GetUnindexed(ctx context.Context) ([]*model.Note, error)
+	GetStats(ctx context.Context, workspaceID int64, userID int64) (int, float64, error)
+	GetDocFrequencies(ctx context.Context, workspaceID int64, userID int64, terms []string) (map[string]int, error)
+	GetMatches(ctx context.Context, workspaceID int64, userID int64, terms []string, excludeID int64) ([]*model.TermMatch, error)
+}
+
+type noteTermRepo struct {
+	db *sqlx.DB
+}
+
+func NewNoteTermRepo(db *sqlx.DB) NoteTermRepo {
+	return &noteTermRepo{db: db}
+}
+
+// Save replaces the term vector of the note
+func (r *noteTermRepo) Save(ctx context.Context, noteID int64, terms map[string]int) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_terms WHERE note_id = ?
+		`, noteID)
+		if err != nil {
+			return err
+		}
+
+		length := 0
+		for term, count := range terms {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO note_terms (note_id, term, count)
+				VALUES (?, ?, ?)
+			`, noteID, term, count)
+			if err != nil {
+				return err
+			}
+			length += count
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO note_vectors (note_id, length)
+			VALUES (?, ?)
+			ON CONFLICT (note_id) DO UPDATE SET length = excluded.length
+		`, noteID, length)
+		return err
+	})
+}
+
+func (r *noteTermRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_terms WHERE note_id = ?
+		`, noteID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM note_vectors WHERE note_id = ?
+		`, noteID)
+		return err
+	})
+}
+
+// GetUnindexed returns the notes without a term vector
+func (r *noteTermRepo) GetUnindexed(ctx context.Context) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
+		FROM notes
+		WHERE id NOT IN (SELECT note_id FROM note_vectors)
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// GetStats returns the number of indexed notes and their average length
+func (r *noteTermRepo) GetStats(ctx context.Context, workspaceID int64, userID int64) (int, float64, error) {
+	var stats struct {
+		Docs      int     `db:"docs"`
+		AvgLength float64 `db:"avg_length"`
+	}
+	err := r.db.GetContext(ctx, &stats, `
+		SELECT COUNT(*) AS docs, COALESCE(AVG(v.length), 0) AS avg_length
+		FROM note_vectors v
+		JOIN notes n ON n.id = v.note_id
+		WHERE n.workspace_id = ? AND (? = 0 OR n.user_id = ?) AND n.status = 1
+	`, workspaceID, userID, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return stats.Docs, stats.AvgLength, nil
+}
+
+// GetDocFrequencies returns the number of notes each term occurs in
+func (r *noteTermRepo) GetDocFrequencies(ctx context.Context, workspaceID int64, userID int64, terms []string) (map[string]int, error) {
+	freqs := make(map[string]int, len(terms))
+	if len(terms) == 0 {
+		return freqs, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT t.term, COUNT(*) AS docs
+		FROM note_terms t
+		JOIN notes n ON n.id = t.note_id
+		WHERE t.term IN (?) AND n.workspace_id = ? AND (? = 0 OR n.user_id = ?) AND n.status = 1
+		GROUP BY t.term
+	`, terms, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Term string `db:"term"`
+		Docs int    `db:"docs"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		freqs[row.Term] = row.Docs
+	}
+	return freqs, nil
+}
+
+// GetMatches returns the occurrences of the terms in notes other than
+// excludeID
+func (r *noteTermRepo) GetMatches(ctx context.Context, workspaceID int64, userID int64, terms []string, excludeID int64) ([]*model.TermMatch, error) {
+	matches := make([]*model.TermMatch, 0)
+	if len(terms) == 0 {
+		return matches, nil
+	}
+
+	query, args, err := sqlx.In(`
+		SELECT t.note_id, n.parent_id, n.title, n.icon, t.term, t.count, v.length
+		FROM note_terms t
+		JOIN note_vectors v ON v.note_id = t.note_id
+		JOIN notes n ON n.id = t.note_id
+		WHERE t.term IN (?) AND t.note_id != ?
+			AND n.workspace_id = ? AND (? = 0 OR n.user_id = ?) AND n.status = 1
+	`, terms, excludeID, workspaceID, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.db.SelectContext(ctx, &matches, r.db.Rebind(query), args...); err != nil {
+		return nil, err
+	}
+
+	return matches, nil
+}
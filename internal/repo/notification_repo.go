@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type NotificationRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.Notification, error)
+	GetByUserID(ctx context.Context, userID int64, unreadOnly bool) ([]*model.Notification, error)
+	Create(ctx context.Context, n *model.Notification) error
+	MarkRead(ctx context.Context, id int64) error
+	MarkAllRead(ctx context.Context, userID int64) error
+}
+
+type notificationRepo struct {
+	db *sqlx.DB
+}
+
+func NewNotificationRepo(db *sqlx.DB) NotificationRepo {
+	return &notificationRepo{db: db}
+}
+
+func (r *notificationRepo) GetByID(ctx context.Context, id int64) (*model.Notification, error) {
+	var n model.Notification
+	err := r.db.GetContext(ctx, &n, `
+		SELECT id, user_id, reminder_id, note_id, title, body, url, read_at, created_at
+		FROM notifications
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+func (r *notificationRepo) GetByUserID(ctx context.Context, userID int64, unreadOnly bool) ([]*model.Notification, error) {
+	notifications := make([]*model.Notification, 0)
+	err := r.db.SelectContext(ctx, &notifications, `
+		SELECT id, user_id, reminder_id, note_id, title, body, url, read_at, created_at
+		FROM notifications
+		WHERE user_id = ? AND (? = 0 OR read_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+	`, userID, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (r *notificationRepo) Create(ctx context.Context, n *model.Notification) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, reminder_id, note_id, title, body, url)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, n.UserID, n.ReminderID, n.NoteID, n.Title, n.Body, n.URL)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	n.ID = id
+	return nil
+}
+
+func (r *notificationRepo) MarkRead(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE notifications SET read_at = datetime('now') WHERE id = ? AND read_at IS NULL
+	`, id)
+
+	return err
+}
+
+func (r *notificationRepo) MarkAllRead(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE notifications SET read_at = datetime('now') WHERE user_id = ? AND read_at IS NULL
+	`, userID)
+
+	return err
+}
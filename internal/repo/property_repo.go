@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+// PropertyFilter matches notes whose property Name equals Value. Number is
+// compared against number properties instead when it is valid.
+type PropertyFilter struct {
+	Name   string
+	Value  string
+	Number *float64
+}
+
+// NoteDate is a date property together with the title of its note
+type NoteDate struct {
+	NoteID    int64     `db:"note_id"`
+	NoteTitle string    `db:"note_title"`
+	Name      string    `db:"name"`
+	Value     string    `db:"value"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+type PropertyRepo interface {
+	GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteProperty, error)
+	GetByName(ctx context.Context, noteID int64, name string) (*model.NoteProperty, error)
+	Upsert(ctx context.Context, p *model.NoteProperty) error
+	Delete(ctx context.Context, noteID int64, name string) error
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+	ReplaceBySource(ctx context.Context, noteID int64, source string, props []*model.NoteProperty) error
+	FindNoteIDs(ctx context.Context, userID int64, filters []PropertyFilter) ([]int64, error)
+	GetDates(ctx context.Context, userID int64) ([]*NoteDate, error)
+}
+
+type propertyRepo struct {
+	db *sqlx.DB
+}
+
+func NewPropertyRepo(db *sqlx.DB) PropertyRepo {
+	return &propertyRepo{db: db}
+}
+
+func (r *propertyRepo) GetByNoteID(ctx context.Context, noteID int64) ([]*model.NoteProperty, error) {
+	props := make([]*model.NoteProperty, 0)
+	err := r.db.SelectContext(ctx, &props, `
+		SELECT
+			id, note_id, name, type, value, value_number, source,
+			created_at, updated_at
+		FROM note_properties
+		WHERE note_id = ?
+		ORDER BY name ASC
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return props, nil
+}
+
+func (r *propertyRepo) GetByName(ctx context.Context, noteID int64, name string) (*model.NoteProperty, error) {
+	var p model.NoteProperty
+	err := r.db.GetContext(ctx, &p, `
+		SELECT
+			id, note_id, name, type, value, value_number, source,
+			created_at, updated_at
+		FROM note_properties
+		WHERE note_id = ? AND name = ?
+		LIMIT 1
+	`, noteID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Upsert creates the property or replaces the property of the same name
+func (r *propertyRepo) Upsert(ctx context.Context, p *model.NoteProperty) error {
+	return upsertProperty(ctx, r.db, p)
+}
+
+func upsertProperty(ctx context.Context, db sqlx.ExtContext, p *model.NoteProperty) error {
+	p.TouchUpdated()
+	return sqlx.GetContext(ctx, db, &p.ID, `
+		INSERT INTO note_properties (note_id, name, type, value, value_number, source)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (note_id, name) DO UPDATE SET
+			name = excluded.name,
+			type = excluded.type,
+			value = excluded.value,
+			value_number = excluded.value_number,
+			source = excluded.source,
+			updated_at = datetime('now')
+		RETURNING id
+	`, p.NoteID, p.Name, p.Type, p.Value, p.ValueNumber, p.Source)
+}
+
+func (r *propertyRepo) Delete(ctx context.Context, noteID int64, name string) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_properties WHERE note_id = ? AND name = ?
+	`, noteID, name)
+
+	return err
+}
+
+func (r *propertyRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_properties WHERE note_id = ?
+	`, noteID)
+
+	return err
+}
+
+// ReplaceBySource swaps all properties of the note that came from source for
+// props in a single transaction. Properties of other sources with the same
+// name are taken over.
+func (r *propertyRepo) ReplaceBySource(ctx context.Context, noteID int64, source string, props []*model.NoteProperty) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_properties WHERE note_id = ? AND source = ?
+		`, noteID, source)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range props {
+			p.NoteID = noteID
+			p.Source = source
+			if err := upsertProperty(ctx, tx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FindNoteIDs returns the IDs of the user's notes that match every filter
+func (r *propertyRepo) FindNoteIDs(ctx context.Context, userID int64, filters []PropertyFilter) ([]int64, error) {
+	ids := make([]int64, 0)
+	if len(filters) == 0 {
+		return ids, nil
+	}
+
+	parts := make([]string, 0, len(filters))
+	args := make([]any, 0, len(filters)*4)
+	for _, f := range filters {
+		parts = append(parts, `
+			SELECT p.note_id FROM note_properties p
+			JOIN notes n ON n.id = p.note_id
+			WHERE n.user_id = ? AND p.name = ? AND (p.value = ? OR p.value_number = ?)
+		`)
+		args = append(args, userID, f.Name, f.Value, f.Number)
+	}
+
+	err := r.db.SelectContext(ctx, &ids, strings.Join(parts, "INTERSECT"), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// GetDates returns the date properties of the user's normal notes
+func (r *propertyRepo) GetDates(ctx context.Context, userID int64) ([]*NoteDate, error) {
+	dates := make([]*NoteDate, 0)
+	err := r.db.SelectContext(ctx, &dates, `
+		SELECT p.note_id, n.title AS note_title, p.name, p.value, p.updated_at
+		FROM note_properties p
+		JOIN notes n ON n.id = p.note_id
+		WHERE n.user_id = ? AND n.status = 1 AND p.type = 'date'
+		ORDER BY p.value ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return dates, nil
+}
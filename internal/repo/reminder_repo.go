@@ -0,0 +1,169 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type ReminderRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.Reminder, error)
+	GetByUserID(ctx context.Context, userID int64, status string, noteID sql.NullInt64) ([]*model.Reminder, error)
+	Create(ctx context.Context, r *model.Reminder) error
+	Delete(ctx context.Context, id int64) error
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.Reminder, error)
+	UpdateDelivery(ctx context.Context, r *model.Reminder) error
+	CancelByNoteID(ctx context.Context, noteID int64, reason string) error
+}
+
+type reminderRepo struct {
+	db *sqlx.DB
+}
+
+func NewReminderRepo(db *sqlx.DB) ReminderRepo {
+	return &reminderRepo{db: db}
+}
+
+func (r *reminderRepo) GetByID(ctx context.Context, id int64) (*model.Reminder, error) {
+	var rem model.Reminder
+	err := r.db.GetContext(ctx, &rem, `
+		SELECT
+			id, user_id, note_id, task_id, remind_at, channel, target, status,
+			attempts, next_attempt_at, last_error, sent_at, created_at, updated_at
+		FROM reminders
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rem, nil
+}
+
+// GetByUserID lists the user's reminders, optionally only those with the
+// given status or of one note
+func (r *reminderRepo) GetByUserID(ctx context.Context, userID int64, status string, noteID sql.NullInt64) ([]*model.Reminder, error) {
+	reminders := make([]*model.Reminder, 0)
+	err := r.db.SelectContext(ctx, &reminders, `
+		SELECT
+			id, user_id, note_id, task_id, remind_at, channel, target, status,
+			attempts, next_attempt_at, last_error, sent_at, created_at, updated_at
+		FROM reminders
+		WHERE user_id = ?
+			AND (? = '' OR status = ?)
+			AND (? IS NULL OR note_id = ?)
+		ORDER BY remind_at ASC
+	`, userID, status, status, noteID, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+func (r *reminderRepo) Create(ctx context.Context, rem *model.Reminder) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO reminders (
+			user_id,
+			note_id,
+			task_id,
+			remind_at,
+			channel,
+			target,
+			status,
+			next_attempt_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		rem.UserID,
+		rem.NoteID,
+		rem.TaskID,
+		rem.RemindAt,
+		rem.Channel,
+		rem.Target,
+		rem.Status,
+		rem.NextAttemptAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	rem.ID = id
+	return nil
+}
+
+func (r *reminderRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM reminders WHERE id = ?
+	`, id)
+
+	return err
+}
+
+// ClaimDue atomically marks up to limit due reminders as sending and returns
+// them. The claim is a lease: a reminder whose sender died before recording
+// the outcome is picked up again once the lease has expired.
+func (r *reminderRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.Reminder, error) {
+	reminders := make([]*model.Reminder, 0)
+	err := r.db.SelectContext(ctx, &reminders, `
+		UPDATE reminders
+		SET status = 'sending', next_attempt_at = ?, updated_at = datetime('now')
+		WHERE id IN (
+			SELECT id FROM reminders
+			WHERE status IN ('pending', 'sending') AND next_attempt_at <= ?
+			ORDER BY next_attempt_at ASC
+			LIMIT ?
+		)
+		RETURNING
+			id, user_id, note_id, task_id, remind_at, channel, target, status,
+			attempts, next_attempt_at, last_error, sent_at, created_at, updated_at
+	`, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *reminderRepo) UpdateDelivery(ctx context.Context, rem *model.Reminder) error {
+	rem.TouchUpdated()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reminders
+		SET
+			status = ?,
+			attempts = ?,
+			next_attempt_at = ?,
+			last_error = ?,
+			sent_at = ?,
+			updated_at = datetime('now')
+		WHERE id = ?
+	`,
+		rem.Status,
+		rem.Attempts,
+		rem.NextAttemptAt,
+		rem.LastError,
+		rem.SentAt,
+		rem.ID,
+	)
+	return err
+}
+
+// CancelByNoteID cancels the undelivered reminders of a note
+func (r *reminderRepo) CancelByNoteID(ctx context.Context, noteID int64, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE reminders
+		SET status = 'cancelled', last_error = ?, updated_at = datetime('now')
+		WHERE note_id = ? AND status IN ('pending', 'sending')
+	`, reason, noteID)
+
+	return err
+}
@@ -0,0 +1,202 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+// errContentChanged rolls back a replacement when a note changed after it
+// was read
+var errContentChanged = errors.New("note content changed")
+
+type ReplacementRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.Replacement, error)
+	GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.Replacement, error)
+	GetNotes(ctx context.Context, replacementID int64) ([]*model.ReplacementNote, error)
+	Apply(ctx context.Context, r *model.Replacement, notes []*model.ReplacementNote) (bool, error)
+	Revert(ctx context.Context, id int64, notes []*model.ReplacementNote) ([]int64, error)
+}
+
+type replacementRepo struct {
+	db *sqlx.DB
+}
+
+func NewReplacementRepo(db *sqlx.DB) ReplacementRepo {
+	return &replacementRepo{db: db}
+}
+
+func (r *replacementRepo) GetByID(ctx context.Context, id int64) (*model.Replacement, error) {
+	var rep model.Replacement
+	err := r.db.GetContext(ctx, &rep, `
+		SELECT
+			id, user_id, workspace_id, find, replacement, is_regex, case_sensitive,
+			root_id, tag, note_count, match_count, reverted_at, created_at
+		FROM replacements
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rep, nil
+}
+
+// GetByWorkspaceID returns the user's replacements in the workspace, newest
+// first
+func (r *replacementRepo) GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.Replacement, error) {
+	replacements := make([]*model.Replacement, 0)
+	err := r.db.SelectContext(ctx, &replacements, `
+		SELECT
+			id, user_id, workspace_id, find, replacement, is_regex, case_sensitive,
+			root_id, tag, note_count, match_count, reverted_at, created_at
+		FROM replacements
+		WHERE workspace_id = ? AND user_id = ?
+		ORDER BY id DESC
+	`, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return replacements, nil
+}
+
+func (r *replacementRepo) GetNotes(ctx context.Context, replacementID int64) ([]*model.ReplacementNote, error) {
+	notes := make([]*model.ReplacementNote, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT id, replacement_id, note_id, content_before, content_after
+		FROM replacement_notes
+		WHERE replacement_id = ?
+		ORDER BY id ASC
+	`, replacementID)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// Apply records the replacement and writes the new content of its notes in
+// a single transaction. It writes nothing and returns false if a note no
+// longer has the content it was replaced in.
+func (r *replacementRepo) Apply(ctx context.Context, rep *model.Replacement, notes []*model.ReplacementNote) (bool, error) {
+	err := utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO replacements (
+				user_id, workspace_id, find, replacement, is_regex, case_sensitive,
+				root_id, tag, note_count, match_count
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			rep.UserID,
+			rep.WorkspaceID,
+			rep.Find,
+			rep.Replacement,
+			rep.IsRegex,
+			rep.CaseSensitive,
+			rep.RootID,
+			rep.Tag,
+			rep.NoteCount,
+			rep.MatchCount,
+		)
+		if err != nil {
+			return err
+		}
+		rep.ID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, n := range notes {
+			n.ReplacementID = rep.ID
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO replacement_notes (replacement_id, note_id, content_before, content_after)
+				VALUES (?, ?, ?, ?)
+			`, n.ReplacementID, n.NoteID, n.Before, n.After)
+			if err != nil {
+				return err
+			}
+
+			res, err := tx.ExecContext(ctx, `
+				UPDATE notes
+				SET content = ?, updated_at = datetime('now')
+				WHERE id = ? AND content = ?
+			`, n.After, n.NoteID, n.Before)
+			if err != nil {
+				return err
+			}
+			changed, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if changed == 0 {
+				return errContentChanged
+			}
+		}
+
+		return tx.GetContext(ctx, &rep.CreatedAt, `
+			SELECT created_at FROM replacements WHERE id = ?
+		`, rep.ID)
+	})
+	if err == errContentChanged {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Revert puts back the content of the given notes of the replacement that
+// still have the content it gave them, and marks it reverted, in a single
+// transaction. It returns the IDs of the notes reverted, or sql.ErrNoRows if
+// the replacement was already reverted.
+func (r *replacementRepo) Revert(ctx context.Context, id int64, notes []*model.ReplacementNote) ([]int64, error) {
+	reverted := make([]int64, 0, len(notes))
+	err := utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE replacements
+			SET reverted_at = datetime('now')
+			WHERE id = ? AND reverted_at IS NULL
+		`, id)
+		if err != nil {
+			return err
+		}
+		marked, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if marked == 0 {
+			return sql.ErrNoRows
+		}
+
+		for _, n := range notes {
+			res, err := tx.ExecContext(ctx, `
+				UPDATE notes
+				SET content = ?, updated_at = datetime('now')
+				WHERE id = ? AND content = ?
+			`, n.Before, n.NoteID, n.After)
+			if err != nil {
+				return err
+			}
+			changed, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if changed > 0 {
+				reverted = append(reverted, n.NoteID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reverted, nil
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type SavedSearchRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.SavedSearch, error)
+	GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.SavedSearch, error)
+	Create(ctx context.Context, s *model.SavedSearch) error
+	Update(ctx context.Context, s *model.SavedSearch) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type savedSearchRepo struct {
+	db *sqlx.DB
+}
+
+func NewSavedSearchRepo(db *sqlx.DB) SavedSearchRepo {
+	return &savedSearchRepo{db: db}
+}
+
+func (r *savedSearchRepo) GetByID(ctx context.Context, id int64) (*model.SavedSearch, error) {
+	var s model.SavedSearch
+	err := r.db.GetContext(ctx, &s, `
+		SELECT id, user_id, workspace_id, name, query, position, created_at, updated_at
+		FROM saved_searches
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetByWorkspaceID returns the user's saved searches of the workspace in
+// sidebar order
+func (r *savedSearchRepo) GetByWorkspaceID(ctx context.Context, workspaceID int64, userID int64) ([]*model.SavedSearch, error) {
+	searches := make([]*model.SavedSearch, 0)
+	err := r.db.SelectContext(ctx, &searches, `
+		SELECT id, user_id, workspace_id, name, query, position, created_at, updated_at
+		FROM saved_searches
+		WHERE workspace_id = ? AND user_id = ?
+		ORDER BY position ASC, id ASC
+	`, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return searches, nil
+}
+
+func (r *savedSearchRepo) Create(ctx context.Context, s *model.SavedSearch) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO saved_searches (user_id, workspace_id, name, query, position)
+		VALUES (?, ?, ?, ?, ?)
+	`, s.UserID, s.WorkspaceID, s.Name, s.Query, s.Position)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	s.ID = id
+	return nil
+}
+
+func (r *savedSearchRepo) Update(ctx context.Context, s *model.SavedSearch) error {
+	s.TouchUpdated()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE saved_searches
+		SET name = ?, query = ?, position = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, s.Name, s.Query, s.Position, s.ID)
+
+	return err
+}
+
+func (r *savedSearchRepo) Delete(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM saved_searches WHERE id = ?
+	`, id)
+
+	return err
+}
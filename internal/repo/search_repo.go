@@ -0,0 +1,274 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/search"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+// searchWeights weighs matches in the title over matches in the content
+var searchWeights = []float64{3, 1}
+
+type SearchRepo interface {
+	Search(ctx context.Context, workspaceID int64, userID int64, q *search.Query) ([]*model.Note, error)
+	Index(ctx context.Context, note *model.Note) error
+	IndexMissing(ctx context.Context, notes []*model.Note) error
+	Unindex(ctx context.Context, noteID int64) error
+	GetUnindexed(ctx context.Context) ([]*model.Note, error)
+}
+
+type searchRepo struct {
+	db *sqlx.DB
+}
+
+func NewSearchRepo(db *sqlx.DB) SearchRepo {
+	return &searchRepo{db: db}
+}
+
+// Search returns the notes of the workspace matching the query, best
+// matches of its words first and then most recently updated first. Trashed
+// notes only match when the query asks for them. A non-zero userID keeps
+// only the notes of that user.
+func (r *searchRepo) Search(ctx context.Context, workspaceID int64, userID int64, q *search.Query) ([]*model.Note, error) {
+	where, args := compileQuery(q, workspaceID)
+
+	status := "n.status = 1"
+	if q.IncludesTrashed() {
+		status = "1 = 1"
+	}
+
+	notes := make([]*model.Note, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT
+			n.id, n.parent_id, n.user_id, n.workspace_id, n.title, n.content,
+			n.icon, n.is_favorite, n.position, n.status, n.is_encrypted, n.created_at, n.updated_at
+		FROM notes n
+		WHERE n.workspace_id = ? AND (? = 0 OR n.user_id = ?) AND `+status+` AND (`+where+`)
+		ORDER BY n.updated_at DESC, n.id DESC
+	`, append([]any{workspaceID, userID, userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.rank(ctx, notes, q); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// rank sorts the notes by the BM25 score of the words the query looks for
+func (r *searchRepo) rank(ctx context.Context, notes []*model.Note, q *search.Query) error {
+	var phrases []string
+	for _, group := range q.Groups {
+		for _, t := range group {
+			if phrase := matchExpr(t); phrase != "" && !t.Negated {
+				phrases = append(phrases, phrase)
+			}
+		}
+	}
+	if len(phrases) == 0 || len(notes) < 2 {
+		return nil
+	}
+
+	ids := make([]int64, len(notes))
+	for i, n := range notes {
+		ids[i] = n.ID
+	}
+	query, args, err := sqlx.In(`
+		SELECT docid, matchinfo(note_search, 'pcnalx') AS info
+		FROM note_search
+		WHERE note_search MATCH ? AND docid IN (?)
+	`, strings.Join(phrases, " OR "), ids)
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		DocID int64  `db:"docid"`
+		Info  []byte `db:"info"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return err
+	}
+
+	scores := make(map[int64]float64, len(rows))
+	for _, row := range rows {
+		scores[row.DocID] = search.BM25(row.Info, searchWeights)
+	}
+	sort.SliceStable(notes, func(i, j int) bool {
+		return scores[notes[i].ID] > scores[notes[j].ID]
+	})
+	return nil
+}
+
+// Index stores the title and content of the note in the full-text index.
+// The content of an encrypted note is ciphertext and is left out.
+func (r *searchRepo) Index(ctx context.Context, note *model.Note) error {
+	content := ""
+	if !note.IsEncryptedNote() {
+		content = search.Tokens(note.Content)
+	}
+
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM note_search WHERE docid = ?
+		`, note.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO note_search (docid, title, content)
+			VALUES (?, ?, ?)
+		`, note.ID, search.Tokens(note.Title), content)
+		return err
+	})
+}
+
+// IndexMissing stores the notes of GetUnindexed in the full-text index in a
+// single transaction. A note indexed since, on a change, keeps that newer
+// entry.
+func (r *searchRepo) IndexMissing(ctx context.Context, notes []*model.Note) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		for _, note := range notes {
+			content := ""
+			if !note.IsEncryptedNote() {
+				content = search.Tokens(note.Content)
+			}
+
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO note_search (docid, title, content)
+				SELECT ?, ?, ?
+				WHERE NOT EXISTS (SELECT 1 FROM note_search WHERE docid = ?)
+			`, note.ID, search.Tokens(note.Title), content, note.ID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *searchRepo) Unindex(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM note_search WHERE docid = ?
+	`, noteID)
+
+	return err
+}
+
+// GetUnindexed returns the notes missing from the full-text index
+func (r *searchRepo) GetUnindexed(ctx context.Context) ([]*model.Note, error) {
+	notes := make([]*model.Note, 0)
+	err := r.db.SelectContext(ctx, &notes, `
+		SELECT
+			id, parent_id, user_id, workspace_id, title, content,
+			icon, is_favorite, position, status, is_encrypted, created_at, updated_at
+		FROM notes
+		WHERE id NOT IN (SELECT docid FROM note_search)
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// compileQuery turns the query into a condition on the notes table "n"
+// and its arguments
+func compileQuery(q *search.Query, workspaceID int64) (string, []any) {
+	var args []any
+	groups := make([]string, 0, len(q.Groups))
+	for _, group := range q.Groups {
+		conds := make([]string, 0, len(group))
+		for _, t := range group {
+			cond, condArgs := compileTerm(t, workspaceID)
+			if t.Negated {
+				cond = "NOT " + cond
+			}
+			conds = append(conds, cond)
+			args = append(args, condArgs...)
+		}
+		groups = append(groups, "("+strings.Join(conds, " AND ")+")")
+	}
+	return strings.Join(groups, " OR "), args
+}
+
+func compileTerm(t search.Term, workspaceID int64) (string, []any) {
+	if expr := matchExpr(t); expr != "" {
+		if t.Field == search.FieldTitle {
+			return `n.id IN (SELECT docid FROM note_search WHERE title MATCH ?)`, []any{expr}
+		}
+		return `n.id IN (SELECT docid FROM note_search WHERE note_search MATCH ?)`, []any{expr}
+	}
+
+	switch t.Field {
+	case search.FieldTitle:
+		return `(n.title LIKE ? ESCAPE '\')`, []any{likePattern(t.Value)}
+	case search.FieldTag:
+		return `EXISTS (SELECT 1 FROM note_tags t WHERE t.note_id = n.id AND t.tag = ? COLLATE NOCASE)`, []any{t.Value}
+	case search.FieldIs:
+		switch t.Value {
+		case search.IsFavorite:
+			return "(n.is_favorite = 1)", nil
+		case search.IsTrashed:
+			return "(n.status = 0)", nil
+		case search.IsEncrypted:
+			return "(n.is_encrypted = 1)", nil
+		}
+	case search.FieldHas:
+		switch t.Value {
+		case search.HasTask:
+			return `EXISTS (SELECT 1 FROM tasks t WHERE t.note_id = n.id)`, nil
+		case search.HasLink:
+			return `EXISTS (SELECT 1 FROM note_links l WHERE l.note_id = n.id AND l.kind = 'link')`, nil
+		case search.HasEmbed:
+			return `EXISTS (SELECT 1 FROM note_links l WHERE l.note_id = n.id AND l.kind = 'embed')`, nil
+		case search.HasTag:
+			return `EXISTS (SELECT 1 FROM note_tags t WHERE t.note_id = n.id)`, nil
+		}
+	case search.FieldIn:
+		return `n.id IN (
+			WITH RECURSIVE below(id) AS (
+				SELECT c.id FROM notes c JOIN notes p ON p.id = c.parent_id
+				WHERE p.workspace_id = ? AND p.title = ? COLLATE NOCASE
+				UNION
+				SELECT c.id FROM notes c JOIN below b ON c.parent_id = b.id
+			)
+			SELECT id FROM below
+		)`, []any{workspaceID, t.Value}
+	case search.FieldCreated, search.FieldUpdated:
+		column := "n.created_at"
+		if t.Field == search.FieldUpdated {
+			column = "n.updated_at"
+		}
+		return fmt.Sprintf("(date(%s) %s ?)", column, t.Op), []any{t.Value}
+	}
+
+	// Text the full-text index cannot find, such as punctuation or a lone
+	// CJK character, is looked up as it is. It matches the title, and the
+	// content unless it is ciphertext.
+	pattern := likePattern(t.Value)
+	return `(n.title LIKE ? ESCAPE '\' OR (n.is_encrypted = 0 AND n.content LIKE ? ESCAPE '\'))`, []any{pattern, pattern}
+}
+
+// matchExpr returns the full-text query of a word or title term, or "" if
+// the term is not looked up in the full-text index
+func matchExpr(t search.Term) string {
+	if t.Field != search.FieldText && t.Field != search.FieldTitle {
+		return ""
+	}
+	return search.MatchPhrase(t.Value)
+}
+
+// likePattern matches s anywhere, with the wildcards of LIKE escaped
+func likePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(s) + "%"
+}
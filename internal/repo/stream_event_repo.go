@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type StreamEventRepo interface {
+	Create(ctx context.Context, e *model.StreamEvent) error
+	GetAfter(ctx context.Context, userID int64, afterID int64, limit int) ([]*model.StreamEvent, error)
+	GetIDRange(ctx context.Context) (minID int64, maxID int64, err error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type streamEventRepo struct {
+	db *sqlx.DB
+}
+
+func NewStreamEventRepo(db *sqlx.DB) StreamEventRepo {
+	return &streamEventRepo{db: db}
+}
+
+func (r *streamEventRepo) Create(ctx context.Context, e *model.StreamEvent) error {
+	return r.db.GetContext(ctx, e, `
+		INSERT INTO stream_events (user_id, type, note_id, data, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, user_id, type, note_id, data, created_at
+	`, e.UserID, e.Type, e.NoteID, e.Data, time.Now().UTC().Truncate(time.Second))
+}
+
+// GetAfter returns up to limit events of the user following the cursor
+// afterID, oldest first
+func (r *streamEventRepo) GetAfter(ctx context.Context, userID int64, afterID int64, limit int) ([]*model.StreamEvent, error) {
+	events := make([]*model.StreamEvent, 0)
+	err := r.db.SelectContext(ctx, &events, `
+		SELECT id, user_id, type, note_id, data, created_at
+		FROM stream_events
+		WHERE user_id = ? AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// GetIDRange returns the lowest retained event ID and the highest ID ever
+// assigned, which survives pruning
+func (r *streamEventRepo) GetIDRange(ctx context.Context) (minID int64, maxID int64, err error) {
+	err = r.db.QueryRowxContext(ctx, `
+		SELECT
+			COALESCE((SELECT MIN(id) FROM stream_events), 0),
+			COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'stream_events'), 0)
+	`).Scan(&minID, &maxID)
+	return minID, maxID, err
+}
+
+// DeleteBefore prunes events created before the given time and returns how
+// many were removed
+func (r *streamEventRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM stream_events WHERE created_at < ?
+	`, before.UTC().Truncate(time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
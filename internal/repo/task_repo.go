@@ -0,0 +1,184 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+// TaskFilter selects the tasks of a user. Zero fields do not filter.
+type TaskFilter struct {
+	// UserID keeps the tasks of that user, or of anyone when 0
+	UserID int64
+	Done   *int
+	// DueBefore and DueAfter are inclusive YYYY-MM-DD bounds; tasks without a
+	// due date never match them
+	DueBefore string
+	DueAfter  string
+	HasDue    bool
+	// ParentID limits tasks to the note and its descendants
+	ParentID sql.NullInt64
+}
+
+type TaskRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.Task, error)
+	GetByNoteID(ctx context.Context, noteID int64) ([]*model.Task, error)
+	List(ctx context.Context, filter TaskFilter) ([]*model.Task, error)
+	Save(ctx context.Context, noteID int64, tasks []*model.Task) error
+	DeleteByNoteID(ctx context.Context, noteID int64) error
+}
+
+type taskRepo struct {
+	db *sqlx.DB
+}
+
+func NewTaskRepo(db *sqlx.DB) TaskRepo {
+	return &taskRepo{db: db}
+}
+
+func (r *taskRepo) GetByID(ctx context.Context, id int64) (*model.Task, error) {
+	var t model.Task
+	err := r.db.GetContext(ctx, &t, `
+		SELECT
+			t.id, t.note_id, t.user_id, t.line, t.text, t.due_date, t.done,
+			t.created_at, t.updated_at, n.title AS note_title
+		FROM tasks t
+		JOIN notes n ON n.id = t.note_id
+		WHERE t.id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (r *taskRepo) GetByNoteID(ctx context.Context, noteID int64) ([]*model.Task, error) {
+	tasks := make([]*model.Task, 0)
+	err := r.db.SelectContext(ctx, &tasks, `
+		SELECT
+			t.id, t.note_id, t.user_id, t.line, t.text, t.due_date, t.done,
+			t.created_at, t.updated_at, n.title AS note_title
+		FROM tasks t
+		JOIN notes n ON n.id = t.note_id
+		WHERE t.note_id = ?
+		ORDER BY t.line ASC
+	`, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// List returns the matching tasks of notes that are not trashed, the ones due
+// first
+func (r *taskRepo) List(ctx context.Context, filter TaskFilter) ([]*model.Task, error) {
+	where := []string{"(? = 0 OR t.user_id = ?)", "n.status = 1"}
+	args := []any{filter.UserID, filter.UserID}
+	if filter.Done != nil {
+		where = append(where, "t.done = ?")
+		args = append(args, *filter.Done)
+	}
+	if filter.DueBefore != "" {
+		where = append(where, "t.due_date <= ?")
+		args = append(args, filter.DueBefore)
+	}
+	if filter.DueAfter != "" {
+		where = append(where, "t.due_date >= ?")
+		args = append(args, filter.DueAfter)
+	}
+	if filter.HasDue {
+		where = append(where, "t.due_date IS NOT NULL")
+	}
+	if filter.ParentID.Valid {
+		where = append(where, `t.note_id IN (
+			WITH RECURSIVE tree(id) AS (
+				SELECT ?
+				UNION
+				SELECT c.id FROM notes c JOIN tree ON c.parent_id = tree.id
+				WHERE c.status = 1
+			)
+			SELECT id FROM tree
+		)`)
+		args = append(args, filter.ParentID.Int64)
+	}
+
+	tasks := make([]*model.Task, 0)
+	err := r.db.SelectContext(ctx, &tasks, `
+		SELECT
+			t.id, t.note_id, t.user_id, t.line, t.text, t.due_date, t.done,
+			t.created_at, t.updated_at, n.title AS note_title
+		FROM tasks t
+		JOIN notes n ON n.id = t.note_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY t.due_date IS NULL, t.due_date ASC, t.note_id ASC, t.line ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// Save makes tasks the complete task list of the note in a single
+// transaction: tasks with an ID are updated, the others are created and
+// stored tasks missing from the list are deleted.
+func (r *taskRepo) Save(ctx context.Context, noteID int64, tasks []*model.Task) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		keep := []any{noteID}
+		placeholders := []string{"-1"}
+		for _, t := range tasks {
+			if t.ID > 0 {
+				keep = append(keep, t.ID)
+				placeholders = append(placeholders, "?")
+			}
+		}
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM tasks WHERE note_id = ? AND id NOT IN (`+strings.Join(placeholders, ", ")+`)
+		`, keep...)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range tasks {
+			t.NoteID = noteID
+			if t.ID > 0 {
+				_, err = tx.ExecContext(ctx, `
+					UPDATE tasks
+					SET line = ?, text = ?, due_date = ?, done = ?, updated_at = datetime('now')
+					WHERE id = ?
+				`, t.Line, t.Text, t.DueDate, t.Done, t.ID)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			res, err := tx.ExecContext(ctx, `
+				INSERT INTO tasks (note_id, user_id, line, text, due_date, done)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, t.NoteID, t.UserID, t.Line, t.Text, t.DueDate, t.Done)
+			if err != nil {
+				return err
+			}
+			if t.ID, err = res.LastInsertId(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *taskRepo) DeleteByNoteID(ctx context.Context, noteID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM tasks WHERE note_id = ?
+	`, noteID)
+
+	return err
+}
@@ -12,6 +12,7 @@ import (
 type UserRepo interface {
 	GetByID(ctx context.Context, id int64) (*model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByUsername(ctx context.Context, username string) ([]*model.User, error)
 	Create(ctx context.Context, u *model.User) error
 	Update(ctx context.Context, u *model.User) error
 	DisableByID(ctx context.Context, id int64) error
@@ -60,6 +61,24 @@ func (r *userRepo) GetByEmail(ctx context.Context, email string) (*model.User, e
 	return &u, nil
 }
 
+// GetByUsername returns the users with the given username, which is not
+// unique
+func (r *userRepo) GetByUsername(ctx context.Context, username string) ([]*model.User, error) {
+	users := make([]*model.User, 0)
+	err := r.db.SelectContext(ctx, &users, `
+		SELECT
+			id, username, password_hash, email,
+			status, is_admin, created_at, updated_at
+		FROM users
+		WHERE username = ?
+	`, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (r *userRepo) Create(ctx context.Context, u *model.User) error {
 	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
 		var hasAdmin bool
@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+type WebhookDeliveryRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.WebhookDelivery, error)
+	GetByWebhookID(ctx context.Context, webhookID int64, limit int) ([]*model.WebhookDelivery, error)
+	Create(ctx context.Context, d *model.WebhookDelivery) error
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, d *model.WebhookDelivery) error
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type webhookDeliveryRepo struct {
+	db *sqlx.DB
+}
+
+func NewWebhookDeliveryRepo(db *sqlx.DB) WebhookDeliveryRepo {
+	return &webhookDeliveryRepo{db: db}
+}
+
+func (r *webhookDeliveryRepo) GetByID(ctx context.Context, id int64) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	err := r.db.GetContext(ctx, &d, `
+		SELECT
+			id, webhook_id, event, note_id, payload, status, attempts,
+			next_attempt_at, response_code, response_body, last_error,
+			duration_ms, redelivery_of, delivered_at, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// GetByWebhookID returns the latest deliveries of the webhook, newest first
+func (r *webhookDeliveryRepo) GetByWebhookID(ctx context.Context, webhookID int64, limit int) ([]*model.WebhookDelivery, error) {
+	deliveries := make([]*model.WebhookDelivery, 0)
+	err := r.db.SelectContext(ctx, &deliveries, `
+		SELECT
+			id, webhook_id, event, note_id, payload, status, attempts,
+			next_attempt_at, response_code, response_body, last_error,
+			duration_ms, redelivery_of, delivered_at, created_at, updated_at
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (r *webhookDeliveryRepo) Create(ctx context.Context, d *model.WebhookDelivery) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (
+			webhook_id,
+			event,
+			note_id,
+			payload,
+			status,
+			next_attempt_at,
+			redelivery_of
+		) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`,
+		d.WebhookID,
+		d.Event,
+		d.NoteID,
+		d.Payload,
+		d.Status,
+		d.NextAttemptAt,
+		d.RedeliveryOf,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	d.ID = id
+	return nil
+}
+
+// ClaimDue atomically marks up to limit due deliveries of active webhooks as
+// sending and returns them, oldest first. Like reminders, the claim is a
+// lease that expires if the sender dies before recording the outcome.
+func (r *webhookDeliveryRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*model.WebhookDelivery, error) {
+	deliveries := make([]*model.WebhookDelivery, 0)
+	err := r.db.SelectContext(ctx, &deliveries, `
+		UPDATE webhook_deliveries
+		SET status = 'sending', next_attempt_at = ?, updated_at = datetime('now')
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status IN ('pending', 'sending') AND d.next_attempt_at <= ?
+				AND w.is_active = 1
+			ORDER BY d.next_attempt_at ASC, d.id ASC
+			LIMIT ?
+		)
+		RETURNING
+			id, webhook_id, event, note_id, payload, status, attempts,
+			next_attempt_at, response_code, response_body, last_error,
+			duration_ms, redelivery_of, delivered_at, created_at, updated_at
+	`, now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery records the outcome of a delivery attempt
+func (r *webhookDeliveryRepo) UpdateDelivery(ctx context.Context, d *model.WebhookDelivery) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET
+			status = ?,
+			attempts = ?,
+			next_attempt_at = ?,
+			response_code = ?,
+			response_body = ?,
+			last_error = ?,
+			duration_ms = ?,
+			delivered_at = ?,
+			updated_at = datetime('now')
+		WHERE id = ? AND status = 'sending'
+	`,
+		d.Status,
+		d.Attempts,
+		d.NextAttemptAt,
+		d.ResponseCode,
+		d.ResponseBody,
+		d.LastError,
+		d.DurationMs,
+		d.DeliveredAt,
+		d.ID,
+	)
+	return err
+}
+
+// DeleteBefore prunes finished deliveries created before the given time and
+// returns how many were removed
+func (r *webhookDeliveryRepo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM webhook_deliveries
+		WHERE created_at < ? AND status IN ('succeeded', 'failed')
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,197 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+type WebhookRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.Webhook, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*model.Webhook, error)
+	GetActiveByUserID(ctx context.Context, userID int64) ([]*model.Webhook, error)
+	Create(ctx context.Context, w *model.Webhook) error
+	Update(ctx context.Context, w *model.Webhook) error
+	Delete(ctx context.Context, id int64) error
+	Enable(ctx context.Context, id int64) error
+	Disable(ctx context.Context, id int64, reason string) error
+	RecordSuccess(ctx context.Context, id int64) error
+	RecordFailure(ctx context.Context, id int64, at time.Time) (*model.Webhook, error)
+}
+
+type webhookRepo struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepo(db *sqlx.DB) WebhookRepo {
+	return &webhookRepo{db: db}
+}
+
+func (r *webhookRepo) GetByID(ctx context.Context, id int64) (*model.Webhook, error) {
+	var w model.Webhook
+	err := r.db.GetContext(ctx, &w, `
+		SELECT
+			id, user_id, url, secret, events, is_active, disabled_reason,
+			failure_count, failing_since, created_at, updated_at
+		FROM webhooks
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func (r *webhookRepo) GetByUserID(ctx context.Context, userID int64) ([]*model.Webhook, error) {
+	webhooks := make([]*model.Webhook, 0)
+	err := r.db.SelectContext(ctx, &webhooks, `
+		SELECT
+			id, user_id, url, secret, events, is_active, disabled_reason,
+			failure_count, failing_since, created_at, updated_at
+		FROM webhooks
+		WHERE user_id = ?
+		ORDER BY id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *webhookRepo) GetActiveByUserID(ctx context.Context, userID int64) ([]*model.Webhook, error) {
+	webhooks := make([]*model.Webhook, 0)
+	err := r.db.SelectContext(ctx, &webhooks, `
+		SELECT
+			id, user_id, url, secret, events, is_active, disabled_reason,
+			failure_count, failing_since, created_at, updated_at
+		FROM webhooks
+		WHERE user_id = ? AND is_active = 1
+		ORDER BY id ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *webhookRepo) Create(ctx context.Context, w *model.Webhook) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO webhooks (user_id, url, secret, events, is_active)
+		VALUES (?, ?, ?, ?, ?)
+	`, w.UserID, w.URL, w.Secret, w.Events, w.IsActive)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	w.ID = id
+	return nil
+}
+
+// Update saves the endpoint settings of the webhook
+func (r *webhookRepo) Update(ctx context.Context, w *model.Webhook) error {
+	w.TouchUpdated()
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhooks
+		SET url = ?, secret = ?, events = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, w.URL, w.Secret, w.Events, w.ID)
+
+	return err
+}
+
+// Delete removes the webhook together with its delivery log
+func (r *webhookRepo) Delete(ctx context.Context, id int64) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM webhook_deliveries WHERE webhook_id = ?
+		`, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM webhooks WHERE id = ?
+		`, id)
+		return err
+	})
+}
+
+// Enable reactivates the webhook with a clean failure record
+func (r *webhookRepo) Enable(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhooks
+		SET
+			is_active = 1,
+			disabled_reason = NULL,
+			failure_count = 0,
+			failing_since = NULL,
+			updated_at = datetime('now')
+		WHERE id = ?
+	`, id)
+
+	return err
+}
+
+// Disable deactivates the webhook and fails its undelivered payloads in a
+// single transaction
+func (r *webhookRepo) Disable(ctx context.Context, id int64, reason string) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE webhooks
+			SET is_active = 0, disabled_reason = ?, updated_at = datetime('now')
+			WHERE id = ?
+		`, reason, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = 'failed', last_error = ?, updated_at = datetime('now')
+			WHERE webhook_id = ? AND status IN ('pending', 'sending')
+		`, "webhook disabled: "+reason, id)
+		return err
+	})
+}
+
+// RecordSuccess clears the failure record of the webhook
+func (r *webhookRepo) RecordSuccess(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE webhooks
+		SET failure_count = 0, failing_since = NULL
+		WHERE id = ? AND failure_count > 0
+	`, id)
+
+	return err
+}
+
+// RecordFailure counts a failed attempt made at at and returns the updated
+// webhook
+func (r *webhookRepo) RecordFailure(ctx context.Context, id int64, at time.Time) (*model.Webhook, error) {
+	var w model.Webhook
+	err := r.db.GetContext(ctx, &w, `
+		UPDATE webhooks
+		SET failure_count = failure_count + 1, failing_since = COALESCE(failing_since, ?)
+		WHERE id = ?
+		RETURNING
+			id, user_id, url, secret, events, is_active, disabled_reason,
+			failure_count, failing_since, created_at, updated_at
+	`, at, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
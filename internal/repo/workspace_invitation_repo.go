@@ -0,0 +1,175 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+type WorkspaceInvitationRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.WorkspaceInvitation, error)
+	GetPending(ctx context.Context, workspaceID int64, userID int64) (*model.WorkspaceInvitation, error)
+	GetPendingByWorkspaceID(ctx context.Context, workspaceID int64) ([]*model.WorkspaceInvitation, error)
+	GetPendingByUserID(ctx context.Context, userID int64) ([]*model.WorkspaceInvitation, error)
+	Create(ctx context.Context, i *model.WorkspaceInvitation) error
+	UpdateRole(ctx context.Context, id int64, role string, invitedBy int64) error
+	Resolve(ctx context.Context, id int64, status string) (bool, error)
+	Accept(ctx context.Context, i *model.WorkspaceInvitation) (bool, error)
+}
+
+type workspaceInvitationRepo struct {
+	db *sqlx.DB
+}
+
+func NewWorkspaceInvitationRepo(db *sqlx.DB) WorkspaceInvitationRepo {
+	return &workspaceInvitationRepo{db: db}
+}
+
+const selectWorkspaceInvitation = `
+	SELECT
+		i.id, i.workspace_id, w.name AS workspace_name, i.user_id,
+		u.username, i.role, i.invited_by, inviter.username AS inviter_name,
+		i.status, i.created_at, i.updated_at
+	FROM workspace_invitations i
+	JOIN workspaces w ON w.id = i.workspace_id
+	JOIN users u ON u.id = i.user_id
+	JOIN users inviter ON inviter.id = i.invited_by
+`
+
+func (r *workspaceInvitationRepo) GetByID(ctx context.Context, id int64) (*model.WorkspaceInvitation, error) {
+	var i model.WorkspaceInvitation
+	err := r.db.GetContext(ctx, &i, selectWorkspaceInvitation+`
+		WHERE i.id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+func (r *workspaceInvitationRepo) GetPending(ctx context.Context, workspaceID int64, userID int64) (*model.WorkspaceInvitation, error) {
+	var i model.WorkspaceInvitation
+	err := r.db.GetContext(ctx, &i, selectWorkspaceInvitation+`
+		WHERE i.workspace_id = ? AND i.user_id = ? AND i.status = 'pending'
+		LIMIT 1
+	`, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+func (r *workspaceInvitationRepo) GetPendingByWorkspaceID(ctx context.Context, workspaceID int64) ([]*model.WorkspaceInvitation, error) {
+	invitations := make([]*model.WorkspaceInvitation, 0)
+	err := r.db.SelectContext(ctx, &invitations, selectWorkspaceInvitation+`
+		WHERE i.workspace_id = ? AND i.status = 'pending'
+		ORDER BY i.created_at DESC
+	`, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+func (r *workspaceInvitationRepo) GetPendingByUserID(ctx context.Context, userID int64) ([]*model.WorkspaceInvitation, error) {
+	invitations := make([]*model.WorkspaceInvitation, 0)
+	err := r.db.SelectContext(ctx, &invitations, selectWorkspaceInvitation+`
+		WHERE i.user_id = ? AND i.status = 'pending'
+		ORDER BY i.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+func (r *workspaceInvitationRepo) Create(ctx context.Context, i *model.WorkspaceInvitation) error {
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO workspace_invitations (workspace_id, user_id, role, invited_by, status)
+		VALUES (?, ?, ?, ?, ?)
+	`, i.WorkspaceID, i.UserID, i.Role, i.InvitedBy, model.InvitationPending)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	i.ID = id
+	return nil
+}
+
+func (r *workspaceInvitationRepo) UpdateRole(ctx context.Context, id int64, role string, invitedBy int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE workspace_invitations
+		SET role = ?, invited_by = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, role, invitedBy, id)
+
+	return err
+}
+
+// Resolve moves a pending invitation to status. It reports false when the
+// invitation was no longer pending.
+func (r *workspaceInvitationRepo) Resolve(ctx context.Context, id int64, status string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE workspace_invitations
+		SET status = ?, updated_at = datetime('now')
+		WHERE id = ? AND status = 'pending'
+	`, status, id)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Accept marks a pending invitation accepted and adds its user to the
+// workspace with its role, in a single transaction. It reports false when
+// the invitation was no longer pending.
+func (r *workspaceInvitationRepo) Accept(ctx context.Context, i *model.WorkspaceInvitation) (bool, error) {
+	accepted := false
+	err := utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		var role string
+		err := tx.GetContext(ctx, &role, `
+			UPDATE workspace_invitations
+			SET status = ?, updated_at = datetime('now')
+			WHERE id = ? AND status = 'pending'
+			RETURNING role
+		`, model.InvitationAccepted, i.ID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			VALUES (?, ?, ?)
+			ON CONFLICT (workspace_id, user_id) DO NOTHING
+		`, i.WorkspaceID, i.UserID, role)
+		if err != nil {
+			return err
+		}
+
+		accepted = true
+		return nil
+	})
+
+	return accepted, err
+}
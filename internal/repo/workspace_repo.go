@@ -0,0 +1,248 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/utils"
+)
+
+type WorkspaceRepo interface {
+	GetByID(ctx context.Context, id int64) (*model.Workspace, error)
+	GetForUser(ctx context.Context, id int64, userID int64) (*model.Workspace, error)
+	GetByUserID(ctx context.Context, userID int64) ([]*model.Workspace, error)
+	GetPersonal(ctx context.Context, userID int64) (*model.Workspace, error)
+	Create(ctx context.Context, w *model.Workspace) error
+	UpdateName(ctx context.Context, id int64, name string) error
+	Delete(ctx context.Context, id int64) error
+	GetCurrentID(ctx context.Context, userID int64) (sql.NullInt64, error)
+	SetCurrent(ctx context.Context, userID int64, id int64) error
+	GetMember(ctx context.Context, id int64, userID int64) (*model.WorkspaceMember, error)
+	GetMembers(ctx context.Context, id int64) ([]*model.WorkspaceMember, error)
+	UpdateMemberRole(ctx context.Context, id int64, userID int64, role string) error
+	RemoveMember(ctx context.Context, id int64, userID int64) error
+}
+
+type workspaceRepo struct {
+	db *sqlx.DB
+}
+
+func NewWorkspaceRepo(db *sqlx.DB) WorkspaceRepo {
+	return &workspaceRepo{db: db}
+}
+
+func (r *workspaceRepo) GetByID(ctx context.Context, id int64) (*model.Workspace, error) {
+	var w model.Workspace
+	err := r.db.GetContext(ctx, &w, `
+		SELECT id, name, owner_id, is_personal, created_at, updated_at
+		FROM workspaces
+		WHERE id = ?
+		LIMIT 1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// GetForUser returns the workspace with the user's role in it. It fails
+// with sql.ErrNoRows when the user is not a member.
+func (r *workspaceRepo) GetForUser(ctx context.Context, id int64, userID int64) (*model.Workspace, error) {
+	var w model.Workspace
+	err := r.db.GetContext(ctx, &w, `
+		SELECT w.id, w.name, w.owner_id, w.is_personal, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE w.id = ? AND m.user_id = ?
+		LIMIT 1
+	`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// GetByUserID returns the workspaces the user is a member of, the personal
+// one first
+func (r *workspaceRepo) GetByUserID(ctx context.Context, userID int64) ([]*model.Workspace, error) {
+	workspaces := make([]*model.Workspace, 0)
+	err := r.db.SelectContext(ctx, &workspaces, `
+		SELECT w.id, w.name, w.owner_id, w.is_personal, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = ?
+		ORDER BY (w.is_personal = 1 AND w.owner_id = m.user_id) DESC, w.name COLLATE NOCASE ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return workspaces, nil
+}
+
+func (r *workspaceRepo) GetPersonal(ctx context.Context, userID int64) (*model.Workspace, error) {
+	var w model.Workspace
+	err := r.db.GetContext(ctx, &w, `
+		SELECT w.id, w.name, w.owner_id, w.is_personal, m.role, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id AND m.user_id = w.owner_id
+		WHERE w.owner_id = ? AND w.is_personal = 1
+		LIMIT 1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// Create inserts the workspace and makes its owner a member, in a single
+// transaction
+func (r *workspaceRepo) Create(ctx context.Context, w *model.Workspace) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO workspaces (name, owner_id, is_personal)
+			VALUES (?, ?, ?)
+		`, w.Name, w.OwnerID, w.IsPersonal)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO workspace_members (workspace_id, user_id, role)
+			VALUES (?, ?, ?)
+		`, id, w.OwnerID, model.WorkspaceRoleOwner)
+		if err != nil {
+			return err
+		}
+
+		w.ID = id
+		w.Role = model.WorkspaceRoleOwner
+		return nil
+	})
+}
+
+func (r *workspaceRepo) UpdateName(ctx context.Context, id int64, name string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE workspaces
+		SET name = ?, updated_at = datetime('now')
+		WHERE id = ?
+	`, name, id)
+
+	return err
+}
+
+// Delete removes the workspace with its members and invitations, and
+// resets the current workspace of the users who were in it
+func (r *workspaceRepo) Delete(ctx context.Context, id int64) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET current_workspace_id = NULL WHERE current_workspace_id = ?
+		`, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM workspace_invitations WHERE workspace_id = ?
+		`, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM workspace_members WHERE workspace_id = ?
+		`, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM workspaces WHERE id = ?
+		`, id)
+		return err
+	})
+}
+
+func (r *workspaceRepo) GetCurrentID(ctx context.Context, userID int64) (sql.NullInt64, error) {
+	var id sql.NullInt64
+	err := r.db.GetContext(ctx, &id, `
+		SELECT current_workspace_id FROM users WHERE id = ?
+	`, userID)
+
+	return id, err
+}
+
+func (r *workspaceRepo) SetCurrent(ctx context.Context, userID int64, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users SET current_workspace_id = ? WHERE id = ?
+	`, id, userID)
+
+	return err
+}
+
+func (r *workspaceRepo) GetMember(ctx context.Context, id int64, userID int64) (*model.WorkspaceMember, error) {
+	var m model.WorkspaceMember
+	err := r.db.GetContext(ctx, &m, `
+		SELECT
+			m.id, m.workspace_id, m.user_id, m.role,
+			u.username, u.email, m.created_at, m.updated_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = ? AND m.user_id = ?
+		LIMIT 1
+	`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func (r *workspaceRepo) GetMembers(ctx context.Context, id int64) ([]*model.WorkspaceMember, error) {
+	members := make([]*model.WorkspaceMember, 0)
+	err := r.db.SelectContext(ctx, &members, `
+		SELECT
+			m.id, m.workspace_id, m.user_id, m.role,
+			u.username, u.email, m.created_at, m.updated_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = ?
+		ORDER BY m.created_at ASC, m.id ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *workspaceRepo) UpdateMemberRole(ctx context.Context, id int64, userID int64, role string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE workspace_members
+		SET role = ?, updated_at = datetime('now')
+		WHERE workspace_id = ? AND user_id = ?
+	`, role, id, userID)
+
+	return err
+}
+
+// RemoveMember removes the user from the workspace, resetting their current
+// workspace if it was this one
+func (r *workspaceRepo) RemoveMember(ctx context.Context, id int64, userID int64) error {
+	return utils.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET current_workspace_id = NULL
+			WHERE id = ? AND current_workspace_id = ?
+		`, userID, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?
+		`, id, userID)
+		return err
+	})
+}
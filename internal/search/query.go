@@ -0,0 +1,269 @@
+// Package search parses the note search language.
+//
+// A query is a list of terms that must all match. OR between terms starts
+// another list, and a note matches the query when it matches any of them.
+// A term is a word or a "quoted phrase" found in the title or content, or a
+// filter written as name:value, such as tag:work, is:favorite, in:"Projects"
+// or updated:>2026-01-01. A leading "-" negates a term. Values holding spaces
+// are quoted.
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filters
+const (
+	// FieldText matches words of the title or content
+	FieldText = ""
+	// FieldTitle matches words of the title
+	FieldTitle = "title"
+	// FieldTag matches notes with the front-matter tag
+	FieldTag = "tag"
+	// FieldIs matches notes in a state, one of the Is* values
+	FieldIs = "is"
+	// FieldHas matches notes holding something, one of the Has* values
+	FieldHas = "has"
+	// FieldIn matches the notes below a note with the title, at any depth
+	FieldIn      = "in"
+	FieldCreated = "created"
+	FieldUpdated = "updated"
+)
+
+// Values of is:
+const (
+	IsFavorite  = "favorite"
+	IsTrashed   = "trashed"
+	IsEncrypted = "encrypted"
+)
+
+// Values of has:
+const (
+	HasTask  = "task"
+	HasLink  = "link"
+	HasEmbed = "embed"
+	HasTag   = "tag"
+)
+
+// DateLayout is the layout of the values of date filters
+const DateLayout = "2006-01-02"
+
+var (
+	fields    = []string{FieldTitle, FieldTag, FieldIs, FieldHas, FieldIn, FieldCreated, FieldUpdated}
+	isValues  = []string{IsFavorite, IsTrashed, IsEncrypted}
+	hasValues = []string{HasTask, HasLink, HasEmbed, HasTag}
+	// dateOps are the comparisons of date filters, longest first
+	dateOps = []string{">=", "<=", ">", "<", "="}
+)
+
+// Term is a word, phrase or filter of a query
+type Term struct {
+	// Field is the filter name, or FieldText for words and phrases
+	Field string
+	// Op compares dates: one of >, >=, <, <= and =
+	Op      string
+	Value   string
+	Negated bool
+	// Start and End are the character offsets of the term in the query
+	Start int
+	End   int
+}
+
+// Query is a parsed search. A note matches when it matches every term of
+// any of the groups.
+type Query struct {
+	Groups [][]Term
+}
+
+// IncludesTrashed reports whether the query asks for trashed notes, which
+// are left out otherwise
+func (q *Query) IncludesTrashed() bool {
+	for _, group := range q.Groups {
+		for _, t := range group {
+			if t.Field == FieldIs && t.Value == IsTrashed && !t.Negated {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseError is a query that cannot be parsed. Start and End are the
+// character offsets of the offending token.
+type ParseError struct {
+	Message string `json:"message"`
+	Token   string `json:"token"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s at %d: %q", e.Message, e.Start, e.Token)
+}
+
+// Parse parses a query, returning a *ParseError when it is malformed
+func Parse(query string) (*Query, error) {
+	p := &parser{src: []rune(query)}
+	return p.parse()
+}
+
+type parser struct {
+	src []rune
+	pos int
+}
+
+func (p *parser) parse() (*Query, error) {
+	q := &Query{Groups: [][]Term{nil}}
+	orStart, orEnd := -1, -1
+	for {
+		for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			break
+		}
+
+		start := p.pos
+		if end := p.wordEnd(start); string(p.src[start:end]) == "OR" {
+			if len(q.Groups[len(q.Groups)-1]) == 0 {
+				return nil, p.errorAt("OR needs a term on both sides", start, end)
+			}
+			q.Groups = append(q.Groups, nil)
+			orStart, orEnd = start, end
+			p.pos = end
+			continue
+		}
+
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		last := len(q.Groups) - 1
+		q.Groups[last] = append(q.Groups[last], t)
+	}
+
+	if len(q.Groups[len(q.Groups)-1]) == 0 {
+		if orStart >= 0 {
+			return nil, p.errorAt("OR needs a term on both sides", orStart, orEnd)
+		}
+		return nil, &ParseError{Message: "empty query"}
+	}
+	return q, nil
+}
+
+// term parses the term at the current position
+func (p *parser) term() (Term, error) {
+	t := Term{Start: p.pos}
+	if p.src[p.pos] == '-' && p.pos+1 < len(p.src) && !unicode.IsSpace(p.src[p.pos+1]) {
+		t.Negated = true
+		p.pos++
+	}
+
+	// A filter name is letters followed by a colon
+	nameEnd := p.pos
+	for nameEnd < len(p.src) && unicode.IsLetter(p.src[nameEnd]) {
+		nameEnd++
+	}
+	if nameEnd > p.pos && nameEnd < len(p.src) && p.src[nameEnd] == ':' {
+		name := strings.ToLower(string(p.src[p.pos:nameEnd]))
+		if !contains(fields, name) {
+			return t, p.errorAt(fmt.Sprintf("unknown filter %q", name), t.Start, nameEnd+1)
+		}
+		t.Field = name
+		p.pos = nameEnd + 1
+	}
+
+	if t.Field == FieldCreated || t.Field == FieldUpdated {
+		t.Op = "="
+		for _, op := range dateOps {
+			if strings.HasPrefix(string(p.src[p.pos:]), op) {
+				t.Op = op
+				p.pos += len(op)
+				break
+			}
+		}
+	}
+
+	valueStart := p.pos
+	value, err := p.value()
+	if err != nil {
+		return t, err
+	}
+	t.Value = value
+	t.End = p.pos
+
+	if t.Value == "" {
+		if t.Field == FieldText {
+			return t, p.errorAt("empty phrase", t.Start, t.End)
+		}
+		return t, p.errorAt(fmt.Sprintf("missing value for %s:", t.Field), t.Start, t.End)
+	}
+	switch t.Field {
+	case FieldIs, FieldHas:
+		t.Value = strings.ToLower(t.Value)
+		values := isValues
+		if t.Field == FieldHas {
+			values = hasValues
+		}
+		if !contains(values, t.Value) {
+			return t, p.errorAt(fmt.Sprintf("%s: must be one of %s", t.Field, strings.Join(values, ", ")), valueStart, t.End)
+		}
+	case FieldCreated, FieldUpdated:
+		if _, err := time.Parse(DateLayout, t.Value); err != nil {
+			return t, p.errorAt("invalid date, expected YYYY-MM-DD", valueStart, t.End)
+		}
+	}
+	return t, nil
+}
+
+// value reads a quoted phrase or a word
+func (p *parser) value() (string, error) {
+	if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+		end := p.wordEnd(p.pos)
+		value := string(p.src[p.pos:end])
+		p.pos = end
+		return value, nil
+	}
+
+	start := p.pos
+	for i := start + 1; i < len(p.src); i++ {
+		if p.src[i] == '"' {
+			p.pos = i + 1
+			return strings.TrimSpace(string(p.src[start+1 : i])), nil
+		}
+	}
+	return "", p.errorAt("unterminated quote", start, len(p.src))
+}
+
+// wordEnd returns the end of the word starting at start
+func (p *parser) wordEnd(start int) int {
+	end := start
+	for end < len(p.src) && !unicode.IsSpace(p.src[end]) {
+		end++
+	}
+	return end
+}
+
+func (p *parser) errorAt(message string, start int, end int) *ParseError {
+	return &ParseError{
+		Message: message,
+		Token:   string(p.src[start:end]),
+		Start:   start,
+		End:     end,
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
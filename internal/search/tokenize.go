@@ -0,0 +1,191 @@
+package search
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Text is indexed as space separated tokens, so that the full-text index
+// needs no tokenizer of its own. Words of scripts written with spaces are
+// lowercased tokens. Chinese, Japanese and Korean are written without
+// spaces, so runs of their characters are cut into overlapping bigrams:
+// "全文搜索" becomes "全文 文搜 搜索", and the phrase of the bigrams of a query
+// matches wherever the query appears as a substring, also next to words of
+// other scripts. A run of a single character is a token of its own. A
+// single character of a longer run is in no token by itself, so a query
+// holding a lone character has no phrase and is matched as a substring of
+// the text instead.
+
+// isCJK reports whether r belongs to a script written without spaces
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Tokens returns the text as it is stored in the full-text index
+func Tokens(text string) string {
+	var sb strings.Builder
+	forEachRun(text, func(run []rune, cjk bool) {
+		if !cjk {
+			writeToken(&sb, string(run))
+			return
+		}
+		if len(run) == 1 {
+			writeToken(&sb, string(run))
+			return
+		}
+		for i := 0; i+1 < len(run); i++ {
+			writeToken(&sb, string(run[i:i+2]))
+		}
+	})
+	return sb.String()
+}
+
+// MatchPhrase returns the full-text query matching the text as a phrase, or
+// "" when the text holds no word or a lone CJK character, which the index
+// cannot find. The last word may be the beginning of a longer one.
+func MatchPhrase(text string) string {
+	var tokens []string
+	lone := false
+	forEachRun(text, func(run []rune, cjk bool) {
+		if !cjk {
+			tokens = append(tokens, string(run))
+			return
+		}
+		if len(run) == 1 {
+			lone = true
+			return
+		}
+		for i := 0; i+1 < len(run); i++ {
+			tokens = append(tokens, string(run[i:i+2]))
+		}
+	})
+	if len(tokens) == 0 || lone {
+		return ""
+	}
+
+	// A last word of another script also matches the longer words starting
+	// with it. A last bigram is complete and must match as it is.
+	if last := tokens[len(tokens)-1]; !isCJK([]rune(last)[0]) {
+		tokens[len(tokens)-1] = last + "*"
+	}
+	return `"` + strings.Join(tokens, " ") + `"`
+}
+
+// Terms returns the terms of the text for comparing notes: its words of two
+// or more characters and the bigrams of its CJK runs, with their counts
+func Terms(text string) map[string]int {
+	terms := make(map[string]int)
+	forEachRun(text, func(run []rune, cjk bool) {
+		if !cjk {
+			if len(run) > 1 {
+				terms[string(run)]++
+			}
+			return
+		}
+		for i := 0; i+1 < len(run); i++ {
+			terms[string(run[i:i+2])]++
+		}
+	})
+	return terms
+}
+
+// forEachRun calls fn with the lowercased words of the text and its runs of
+// CJK characters, in order
+func forEachRun(text string, fn func(run []rune, cjk bool)) {
+	var run []rune
+	runCJK := false
+	flush := func() {
+		if len(run) > 0 {
+			fn(run, runCJK)
+			run = nil
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !runCJK {
+				flush()
+			}
+			runCJK = true
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if runCJK {
+				flush()
+			}
+			runCJK = false
+			run = append(run, unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+}
+
+func writeToken(sb *strings.Builder, token string) {
+	if sb.Len() > 0 {
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(token)
+}
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// IDF is the BM25 inverse document frequency of a term found in docs of
+// rows documents
+func IDF(docs float64, rows float64) float64 {
+	return math.Log((rows-docs+0.5)/(docs+0.5) + 1)
+}
+
+// TermWeight is the BM25 weight of a term found tf times in a document of
+// the given length, among documents of the average length
+func TermWeight(tf float64, length float64, avgLength float64) float64 {
+	norm := 1 - bm25B
+	if avgLength > 0 {
+		norm += bm25B * length / avgLength
+	}
+	return tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
+
+// BM25 scores a row of a full-text match from its SQLite matchinfo in the
+// "pcnalx" format. Weights gives the weight of each column; columns beyond
+// it count once.
+func BM25(matchinfo []byte, weights []float64) float64 {
+	values := make([]uint32, len(matchinfo)/4)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(matchinfo[i*4:])
+	}
+	if len(values) < 3 {
+		return 0
+	}
+
+	phrases, columns, rows := int(values[0]), int(values[1]), float64(values[2])
+	avgLengths := values[3 : 3+columns]
+	lengths := values[3+columns : 3+2*columns]
+	hits := values[3+2*columns:]
+	if len(hits) < 3*phrases*columns {
+		return 0
+	}
+
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns; c++ {
+			x := hits[3*(p*columns+c):]
+			tf, docs := float64(x[0]), float64(x[2])
+			if tf == 0 {
+				continue
+			}
+			weight := 1.0
+			if c < len(weights) {
+				weight = weights[c]
+			}
+			score += weight * IDF(docs, rows) * TermWeight(tf, float64(lengths[c]), float64(avgLengths[c]))
+		}
+	}
+	return score
+}
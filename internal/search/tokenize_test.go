@@ -0,0 +1,95 @@
+package search
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"全文搜索", "全文 文搜 搜索"},
+		{"我们使用Go语言开发", "我们 们使 使用 go 语言 言开 开发"},
+		{"Hello, World", "hello world"},
+		{"用 Go", "用 go"},
+	}
+	for _, tt := range tests {
+		if got := Tokens(tt.text); got != tt.want {
+			t.Errorf("Tokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// TestMatchPhraseMixedScripts checks that phrases mixing CJK and other
+// scripts match the text they were taken from. A query without a phrase is
+// matched as a substring, as the search does.
+func TestMatchPhraseMixedScripts(t *testing.T) {
+	text := "我们使用Go语言开发"
+	tests := []struct {
+		query string
+		match bool
+	}{
+		{"使用Go", true},
+		{"使用go语言", true},
+		{"Go语言", true},
+		{"我们使用", true},
+		{"语", true},
+		{"我", true},
+		{"发", true},
+		{"用Go", true},
+		{"开发", true},
+		{"使用Rust", false},
+		{"发Go", false},
+	}
+	indexed := strings.Fields(Tokens(text))
+	for _, tt := range tests {
+		phrase := MatchPhrase(tt.query)
+		got := strings.Contains(strings.ToLower(text), strings.ToLower(tt.query))
+		if phrase != "" {
+			got = phraseMatches(indexed, phrase)
+		}
+		if got != tt.match {
+			t.Errorf("MatchPhrase(%q) = %s matching %q: got %v, want %v", tt.query, phrase, text, got, tt.match)
+		}
+	}
+}
+
+// TestMatchPhraseLoneCharacter checks that a lone CJK character of a query,
+// which no bigram holds by itself, is left to the substring match
+func TestMatchPhraseLoneCharacter(t *testing.T) {
+	for _, query := range []string{"发", "用Go", "Go 发"} {
+		if phrase := MatchPhrase(query); phrase != "" {
+			t.Errorf("MatchPhrase(%q) = %s, want no phrase", query, phrase)
+		}
+	}
+	if phrase := MatchPhrase("开发"); phrase != `"开发"` {
+		t.Errorf(`MatchPhrase("开发") = %s, want "开发"`, phrase)
+	}
+}
+
+// phraseMatches reports whether the tokens of an FTS phrase query appear in
+// order in the indexed tokens, a trailing "*" matching any token with that
+// prefix
+func phraseMatches(indexed []string, phrase string) bool {
+	query := strings.Fields(strings.Trim(phrase, `"`))
+	for start := 0; start+len(query) <= len(indexed); start++ {
+		matched := true
+		for i, q := range query {
+			token := indexed[start+i]
+			if prefix, ok := strings.CutSuffix(q, "*"); ok {
+				matched = strings.HasPrefix(token, prefix)
+			} else {
+				matched = token == q
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
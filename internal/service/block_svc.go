@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrBlockNotFound = errors.New("block not found")
+)
+
+// OutlineHeading is an entry of a note's table of contents
+type OutlineHeading struct {
+	BlockID  string            `json:"blockId"`
+	Level    int               `json:"level"`
+	Text     string            `json:"text"`
+	Anchor   string            `json:"anchor"`
+	Line     int               `json:"line"`
+	Children []*OutlineHeading `json:"children"`
+}
+
+// NoteOutline is the structure of a note: its headings nested by level, and
+// every block in document order
+type NoteOutline struct {
+	NoteID   int64              `json:"noteId"`
+	Title    string             `json:"title"`
+	Headings []*OutlineHeading  `json:"headings"`
+	Blocks   []*model.NoteBlock `json:"blocks"`
+}
+
+// BlockService keeps the blocks of notes and their IDs. A block keeps its ID
+// while the note is edited around and inside it; a "^block-id" marker at the
+// end of a paragraph or list item sets the ID explicitly.
+type BlockService interface {
+	Outline(ctx context.Context, id int64, userID int64) (*NoteOutline, error)
+	Resolve(ctx context.Context, blockID string, userID int64) (*model.NoteBlock, error)
+	Blocks(ctx context.Context, note *model.Note) ([]*model.NoteBlock, error)
+}
+
+type blockService struct {
+	blockRepo   repo.NoteBlockRepo
+	noteService NoteService
+}
+
+func NewBlockService(blockRepo repo.NoteBlockRepo, noteService NoteService, events *NoteEvents) BlockService {
+	s := &blockService{
+		blockRepo:   blockRepo,
+		noteService: noteService,
+	}
+	events.Subscribe("blocks", s.onNoteEvent)
+	return s
+}
+
+// Outline returns the headings and blocks of a note the user may read
+func (s *blockService) Outline(ctx context.Context, id int64, userID int64) (*NoteOutline, error) {
+	note, err := s.noteService.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if note.IsEncryptedNote() {
+		return nil, ErrNoteEncrypted
+	}
+
+	blocks, err := s.Blocks(ctx, note)
+	if err != nil {
+		return nil, err
+	}
+
+	outline := &NoteOutline{
+		NoteID:   note.ID,
+		Title:    note.Title,
+		Headings: make([]*OutlineHeading, 0),
+		Blocks:   blocks,
+	}
+	// Each heading goes below the nearest previous heading of a lower level
+	var stack []*OutlineHeading
+	for _, b := range blocks {
+		if b.Type != markdown.BlockHeading {
+			continue
+		}
+		h := &OutlineHeading{
+			BlockID:  b.BlockID,
+			Level:    b.Level,
+			Text:     b.Text,
+			Anchor:   b.Anchor,
+			Line:     b.Line,
+			Children: make([]*OutlineHeading, 0),
+		}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			outline.Headings = append(outline.Headings, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+
+	return outline, nil
+}
+
+// Resolve finds the block a ((block-id)) reference points at, among the
+// notes the user may read. When several notes use the ID, the most recently
+// updated one wins.
+func (s *blockService) Resolve(ctx context.Context, blockID string, userID int64) (*model.NoteBlock, error) {
+	if !markdown.IsBlockID(blockID) {
+		return nil, ErrBlockNotFound
+	}
+
+	blocks, err := s.blockRepo.GetByBlockID(ctx, blockID)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if _, err := s.noteService.Authorize(ctx, b.NoteID, userID, model.NoteRoleViewer); err == nil {
+			return b, nil
+		} else if err != ErrNoteUnauthorized && err != ErrNoteNotFound {
+			return nil, err
+		}
+	}
+
+	return nil, ErrBlockNotFound
+}
+
+// Blocks returns the blocks of the note as its content is now, storing them
+// first if they are out of date. The caller checks access.
+func (s *blockService) Blocks(ctx context.Context, note *model.Note) ([]*model.NoteBlock, error) {
+	existing, err := s.blockRepo.GetByNoteID(ctx, note.ID)
+	if err != nil {
+		return nil, err
+	}
+	if note.IsEncryptedNote() {
+		return make([]*model.NoteBlock, 0), nil
+	}
+
+	blocks, err := extractBlocks(note, existing)
+	if err != nil {
+		return nil, err
+	}
+	if sameBlocks(existing, blocks) {
+		return existing, nil
+	}
+	if err := s.blockRepo.Save(ctx, note.ID, blocks); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// onNoteEvent keeps the stored blocks in sync with note content
+func (s *blockService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated:
+		if e.Previous != nil && e.Previous.Content == e.Note.Content {
+			return nil
+		}
+		// Blocks cannot be read out of an encrypted note
+		if e.Note.IsEncryptedNote() {
+			return s.blockRepo.DeleteByNoteID(ctx, e.Note.ID)
+		}
+		_, err := s.Blocks(ctx, e.Note)
+		return err
+	case NoteDeleted:
+		return s.blockRepo.DeleteByNoteID(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+// extractBlocks parses the blocks of the note and gives them IDs: the one
+// written in the content, or else the ID of the stored block they continue,
+// or else a new one
+func extractBlocks(note *model.Note, existing []*model.NoteBlock) ([]*model.NoteBlock, error) {
+	extracted := markdown.ExtractBlocks([]byte(note.Content))
+	blocks := make([]*model.NoteBlock, 0, len(extracted))
+	explicit := make(map[string]bool)
+	for i, e := range extracted {
+		b := &model.NoteBlock{
+			NoteID:   note.ID,
+			Type:     e.Type,
+			Level:    e.Level,
+			Line:     e.Line,
+			Position: i,
+			Text:     e.Text,
+		}
+		// The first block with an ID keeps it
+		if e.ID != "" && !explicit[e.ID] {
+			b.BlockID = e.ID
+			b.IsExplicit = 1
+			explicit[e.ID] = true
+		}
+		if e.Type == markdown.BlockHeading {
+			b.Anchor = e.HeadingID
+		}
+		blocks = append(blocks, b)
+	}
+
+	matchBlockIDs(existing, blocks)
+
+	taken := make(map[string]bool, len(blocks))
+	for _, b := range blocks {
+		taken[b.BlockID] = true
+	}
+	for _, b := range blocks {
+		for b.BlockID == "" {
+			id, err := randomHex(4)
+			if err != nil {
+				return nil, err
+			}
+			if !taken[id] {
+				b.BlockID = id
+				taken[id] = true
+			}
+		}
+		if b.Anchor == "" {
+			b.Anchor = markdown.BlockAnchorPrefix + b.BlockID
+		}
+	}
+	return blocks, nil
+}
+
+// matchBlockIDs gives blocks without an explicit ID the IDs of the stored
+// blocks they continue, so references survive edits of the note. A block
+// continues the nearest stored block of the same type with the same text,
+// or else the one on the same line, or else the stored block that followed
+// or preceded the block next to it.
+func matchBlockIDs(existing []*model.NoteBlock, blocks []*model.NoteBlock) {
+	used := make(map[string]bool, len(existing))
+	for _, b := range blocks {
+		if b.BlockID != "" {
+			used[b.BlockID] = true
+		}
+	}
+
+	for _, b := range blocks {
+		if b.BlockID != "" {
+			continue
+		}
+		var best *model.NoteBlock
+		for _, old := range existing {
+			if used[old.BlockID] || old.Type != b.Type || old.Text != b.Text {
+				continue
+			}
+			if best == nil || absInt(old.Line-b.Line) < absInt(best.Line-b.Line) {
+				best = old
+			}
+		}
+		if best != nil {
+			b.BlockID = best.BlockID
+			used[best.BlockID] = true
+		}
+	}
+
+	for _, b := range blocks {
+		if b.BlockID != "" {
+			continue
+		}
+		for _, old := range existing {
+			if !used[old.BlockID] && old.Type == b.Type && old.Line == b.Line {
+				b.BlockID = old.BlockID
+				used[old.BlockID] = true
+				break
+			}
+		}
+	}
+
+	// An edited block sits next to a block that was matched, at the same
+	// side as the stored block it continues
+	index := make(map[string]int, len(existing))
+	for i, old := range existing {
+		index[old.BlockID] = i
+	}
+	continues := func(b *model.NoteBlock, neighbor *model.NoteBlock, offset int) bool {
+		i, ok := index[neighbor.BlockID]
+		if !ok || neighbor.BlockID == "" || i+offset < 0 || i+offset >= len(existing) {
+			return false
+		}
+		old := existing[i+offset]
+		if used[old.BlockID] || old.Type != b.Type {
+			return false
+		}
+		b.BlockID = old.BlockID
+		used[old.BlockID] = true
+		return true
+	}
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].BlockID == "" {
+			continues(blocks[i], blocks[i-1], 1)
+		}
+	}
+	for i := len(blocks) - 2; i >= 0; i-- {
+		if blocks[i].BlockID == "" {
+			continues(blocks[i], blocks[i+1], -1)
+		}
+	}
+}
+
+// sameBlocks reports whether stored blocks already describe the content
+func sameBlocks(existing []*model.NoteBlock, blocks []*model.NoteBlock) bool {
+	if len(existing) != len(blocks) {
+		return false
+	}
+	for i, old := range existing {
+		b := blocks[i]
+		if old.BlockID != b.BlockID || old.Type != b.Type || old.Level != b.Level ||
+			old.Line != b.Line || old.Text != b.Text || old.Anchor != b.Anchor ||
+			old.IsExplicit != b.IsExplicit {
+			return false
+		}
+	}
+	return true
+}
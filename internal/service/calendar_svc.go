@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ray-d-song/yan/internal/convert"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrCalendarTokenNotFound     = errors.New("calendar token not found")
+	ErrCalendarTokenUnauthorized = errors.New("unauthorized to access this calendar token")
+	ErrInvalidCalendarToken      = errors.New("invalid calendar token")
+)
+
+const maxCalendarTokenNameLength = 100
+
+// CalendarFeedPath is the public path of the feed of a token, relative to the
+// base URL
+const CalendarFeedPath = "/api/v1/calendar/feed/"
+
+// NewCalendarToken is a freshly created token. The secret is only available
+// at creation, the database keeps its hash.
+type NewCalendarToken struct {
+	*model.CalendarToken
+	Token   string `json:"token"`
+	FeedURL string `json:"feedUrl"`
+}
+
+type CalendarService interface {
+	ListTokens(ctx context.Context, userID int64) ([]*model.CalendarToken, error)
+	CreateToken(ctx context.Context, userID int64, name string) (*NewCalendarToken, error)
+	RevokeToken(ctx context.Context, id int64, userID int64) error
+	Feed(ctx context.Context, token string) ([]byte, error)
+}
+
+type calendarService struct {
+	tokenRepo    repo.CalendarTokenRepo
+	noteRepo     repo.NoteRepo
+	propertyRepo repo.PropertyRepo
+	taskRepo     repo.TaskRepo
+	baseURL      string
+}
+
+func NewCalendarService(
+	tokenRepo repo.CalendarTokenRepo,
+	noteRepo repo.NoteRepo,
+	propertyRepo repo.PropertyRepo,
+	taskRepo repo.TaskRepo,
+	config *infra.Config,
+) CalendarService {
+	return &calendarService{
+		tokenRepo:    tokenRepo,
+		noteRepo:     noteRepo,
+		propertyRepo: propertyRepo,
+		taskRepo:     taskRepo,
+		baseURL:      strings.TrimSuffix(config.App.BaseURL, "/"),
+	}
+}
+
+func (s *calendarService) ListTokens(ctx context.Context, userID int64) ([]*model.CalendarToken, error) {
+	return s.tokenRepo.GetByUserID(ctx, userID)
+}
+
+// CreateToken issues a new secret token for the user's feed
+func (s *calendarService) CreateToken(ctx context.Context, userID int64, name string) (*NewCalendarToken, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxCalendarTokenNameLength {
+		return nil, fmt.Errorf("%w: name is too long", ErrInvalidCalendarToken)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	t := &model.CalendarToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashCalendarToken(token),
+	}
+	if err := s.tokenRepo.Create(ctx, t); err != nil {
+		return nil, err
+	}
+
+	created, err := s.tokenRepo.GetByID(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NewCalendarToken{
+		CalendarToken: created,
+		Token:         token,
+		FeedURL:       s.baseURL + CalendarFeedPath + token + ".ics",
+	}, nil
+}
+
+// RevokeToken deletes the token, after which its feed is gone
+func (s *calendarService) RevokeToken(ctx context.Context, id int64, userID int64) error {
+	t, err := s.tokenRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrCalendarTokenNotFound
+		}
+		return err
+	}
+
+	// Check if the user owns this token
+	if t.UserID != userID {
+		return ErrCalendarTokenUnauthorized
+	}
+
+	return s.tokenRepo.Delete(ctx, id)
+}
+
+// Feed renders the calendar of the token's owner: daily notes, date
+// properties and tasks with a due date
+func (s *calendarService) Feed(ctx context.Context, token string) ([]byte, error) {
+	t, err := s.tokenRepo.GetByHash(ctx, hashCalendarToken(token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCalendarTokenNotFound
+		}
+		return nil, err
+	}
+
+	events := make([]convert.ICalEvent, 0)
+
+	daily, err := s.noteRepo.GetDailyNotes(ctx, t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range daily {
+		start, err := time.Parse(markdown.DueDateLayout, n.Title)
+		if err != nil {
+			continue
+		}
+		// Encrypted notes only show their title
+		description := ""
+		if !n.IsEncryptedNote() {
+			description = excerpt(markdown.PlainText([]byte(n.Content)), 500)
+		}
+		events = append(events, convert.ICalEvent{
+			UID:         fmt.Sprintf("note-%d@yan", n.ID),
+			Summary:     n.Title,
+			Description: description,
+			URL:         s.noteURL(n.ID),
+			Start:       start,
+			AllDay:      true,
+			Updated:     n.UpdatedAt,
+		})
+	}
+
+	dates, err := s.propertyRepo.GetDates(ctx, t.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dates {
+		e := convert.ICalEvent{
+			UID:     fmt.Sprintf("note-%d-%s@yan", d.NoteID, strings.ToLower(d.Name)),
+			Summary: d.NoteTitle,
+			URL:     s.noteURL(d.NoteID),
+			Updated: d.UpdatedAt,
+		}
+		if !strings.EqualFold(d.Name, "date") {
+			e.Summary = d.NoteTitle + " (" + d.Name + ")"
+		}
+		if start, err := time.Parse(markdown.DueDateLayout, d.Value); err == nil {
+			e.Start, e.AllDay = start, true
+		} else if start, err := time.Parse(time.RFC3339, d.Value); err == nil {
+			e.Start = start
+		} else {
+			continue
+		}
+		events = append(events, e)
+	}
+
+	tasks, err := s.taskRepo.List(ctx, repo.TaskFilter{UserID: t.UserID, HasDue: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		start, err := time.Parse(markdown.DueDateLayout, task.DueDate.String)
+		if err != nil {
+			continue
+		}
+		summary := "☐ " + task.Text
+		if task.IsDone() {
+			summary = "✓ " + task.Text
+		}
+		events = append(events, convert.ICalEvent{
+			UID:         fmt.Sprintf("task-%d@yan", task.ID),
+			Summary:     summary,
+			Description: task.NoteTitle,
+			URL:         s.noteURL(task.NoteID),
+			Start:       start,
+			AllDay:      true,
+			Updated:     task.UpdatedAt,
+		})
+	}
+
+	if err := s.tokenRepo.TouchLastUsed(ctx, t.ID); err != nil {
+		infra.Warnf("Failed to record use of calendar token %d: %v", t.ID, err)
+	}
+
+	return convert.EncodeICalendar("Yan", events), nil
+}
+
+func (s *calendarService) noteURL(id int64) string {
+	return fmt.Sprintf("%s/edit/%d", s.baseURL, id)
+}
+
+// hashCalendarToken returns the hex sha256 under which a token is stored
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,623 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/crdt"
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+var ErrCollabClosed = errors.New("collaboration is shutting down")
+
+const (
+	// collabSaveInterval is how often a room with changes writes its text
+	// back to the note
+	collabSaveInterval = 5 * time.Second
+	collabSaveTimeout  = 10 * time.Second
+	// collabPeerBuffer is how many messages a peer may fall behind before it
+	// is disconnected
+	collabPeerBuffer      = 256
+	collabMaxAwareness    = 4 << 10
+	collabMaxOpsPerUpdate = 1000
+	// collabServerClient is the client ID of changes made outside the room,
+	// such as saves through the REST API
+	collabServerClient = "server"
+)
+
+// Collaboration message types. Clients send update, awareness and sync; the
+// server sends all of them plus error.
+const (
+	// CollabSync carries the whole document. The server sends it on join and
+	// in reply to a client sync request; clients replace their replica.
+	CollabSync = "sync"
+	// CollabUpdate carries operations made by ClientID
+	CollabUpdate = "update"
+	// CollabAwareness carries the presence state of ClientID, such as its
+	// cursor; a null state means the client left
+	CollabAwareness = "awareness"
+	CollabError     = "error"
+)
+
+// CollabMessage is a message of the collaboration protocol
+type CollabMessage struct {
+	Type     string          `json:"type"`
+	Session  string          `json:"session,omitempty"`
+	ClientID string          `json:"clientId,omitempty"`
+	UserID   int64           `json:"userId,omitempty"`
+	Username string          `json:"username,omitempty"`
+	Clock    int64           `json:"clock,omitempty"`
+	Items    []crdt.Item     `json:"items,omitempty"`
+	Ops      []crdt.Op       `json:"ops,omitempty"`
+	State    json.RawMessage `json:"state,omitempty"`
+	Message  string          `json:"message,omitempty"`
+	// ReadOnly tells a client in a sync that its updates are refused
+	ReadOnly bool `json:"readOnly,omitempty"`
+}
+
+// CollabPeer is one connection editing a note
+type CollabPeer struct {
+	ClientID string
+	userID   int64
+	username string
+	// readOnly peers may watch but not edit
+	readOnly bool
+	room     *collabRoom
+	out      chan []byte
+	closed   bool
+	// awareness is the last presence state the peer sent
+	awareness json.RawMessage
+}
+
+// Outgoing delivers the messages for the peer. It is closed when the peer is
+// disconnected.
+func (p *CollabPeer) Outgoing() <-chan []byte {
+	return p.out
+}
+
+type collabRoom struct {
+	noteID int64
+
+	mu      sync.Mutex
+	session string
+	doc     *crdt.Text
+	peers   map[*CollabPeer]struct{}
+	dirty   bool
+	// editorID is the user of the latest change; the room saves as them
+	editorID int64
+	// saved is the content the note has in the database as far as the room
+	// knows
+	saved   string
+	closing bool
+
+	stop   chan struct{}
+	done   chan struct{}
+	closed chan struct{}
+}
+
+// CollabService lets several connections edit a note at once. Each note
+// being edited has a room holding a CRDT replica of its content; peers send
+// operations, which the room applies and relays to the other peers, and the
+// room writes the merged text back to the note periodically and when the
+// last peer leaves.
+type CollabService interface {
+	Join(ctx context.Context, noteID int64, userID int64) (*CollabPeer, error)
+	Receive(peer *CollabPeer, data []byte)
+	Leave(peer *CollabPeer)
+	Close(ctx context.Context) error
+}
+
+type collabService struct {
+	noteService NoteService
+	userService UserService
+
+	mu     sync.Mutex
+	rooms  map[int64]*collabRoom
+	closed bool
+}
+
+func NewCollabService(noteService NoteService, userService UserService, events *NoteEvents) CollabService {
+	s := &collabService{
+		noteService: noteService,
+		userService: userService,
+		rooms:       make(map[int64]*collabRoom),
+	}
+	events.Subscribe("collab", s.onNoteEvent)
+	return s
+}
+
+// Join adds a peer to the room of the note, opening the room if needed. The
+// user needs the same access as for reading the note; users who cannot edit
+// it join read-only. Encrypted notes are not edited together, as the
+// server cannot read them.
+func (s *collabService) Join(ctx context.Context, noteID int64, userID int64) (*CollabPeer, error) {
+	note, err := s.noteService.GetByID(ctx, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if note.IsEncryptedNote() {
+		return nil, ErrNoteEncrypted
+	}
+	role, err := s.noteService.RoleOf(ctx, note, userID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userService.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientID, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	peer := &CollabPeer{
+		ClientID: clientID,
+		userID:   userID,
+		username: user.Username,
+		readOnly: !model.NoteRoleAllows(role, model.NoteRoleEditor),
+		out:      make(chan []byte, collabPeerBuffer),
+	}
+
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return nil, ErrCollabClosed
+		}
+		room := s.rooms[noteID]
+		if room == nil {
+			room, err = s.openRoom(ctx, noteID, userID)
+			if err != nil {
+				s.mu.Unlock()
+				return nil, err
+			}
+			s.rooms[noteID] = room
+		}
+
+		room.mu.Lock()
+		if room.closing {
+			// Wait for the final save so the new room starts from it
+			room.mu.Unlock()
+			s.mu.Unlock()
+			select {
+			case <-room.closed:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		peer.room = room
+		room.peers[peer] = struct{}{}
+		s.send(peer, s.syncMessage(room, peer))
+		for other := range room.peers {
+			if other != peer && other.awareness != nil {
+				s.send(peer, awarenessMessage(other, other.awareness))
+			}
+		}
+		room.mu.Unlock()
+		s.mu.Unlock()
+		return peer, nil
+	}
+}
+
+// openRoom creates the room of a note from its stored content. s.mu must be
+// held.
+func (s *collabService) openRoom(ctx context.Context, noteID int64, userID int64) (*collabRoom, error) {
+	note, err := s.noteService.GetByID(ctx, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	session, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	room := &collabRoom{
+		noteID:   noteID,
+		session:  session,
+		doc:      crdt.NewText(note.Content),
+		peers:    make(map[*CollabPeer]struct{}),
+		editorID: userID,
+		saved:    note.Content,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+	go s.autosave(room)
+	return room, nil
+}
+
+// Receive handles a message from the peer
+func (s *collabService) Receive(peer *CollabPeer, data []byte) {
+	room := peer.room
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if peer.closed {
+		return
+	}
+
+	var msg CollabMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.sendError(peer, "invalid message: "+err.Error())
+		return
+	}
+
+	switch msg.Type {
+	case CollabUpdate:
+		s.applyUpdate(room, peer, msg.Ops)
+	case CollabAwareness:
+		if len(msg.State) > collabMaxAwareness {
+			s.sendError(peer, "awareness state is too large")
+			return
+		}
+		peer.awareness = msg.State
+		s.broadcast(room, peer, awarenessMessage(peer, msg.State))
+	case CollabSync:
+		s.send(peer, s.syncMessage(room, peer))
+	default:
+		s.sendError(peer, fmt.Sprintf("unknown message type %q", msg.Type))
+	}
+}
+
+// applyUpdate applies the operations of peer and relays the ones that took.
+// A peer that sends an operation the room cannot apply is out of sync and
+// gets the document again. room.mu must be held.
+func (s *collabService) applyUpdate(room *collabRoom, peer *CollabPeer, ops []crdt.Op) {
+	if peer.readOnly {
+		s.sendError(peer, "read-only access to this note")
+		s.send(peer, s.syncMessage(room, peer))
+		return
+	}
+	if len(ops) > collabMaxOpsPerUpdate {
+		s.sendError(peer, "too many operations in one update")
+		s.send(peer, s.syncMessage(room, peer))
+		return
+	}
+
+	applied := make([]crdt.Op, 0, len(ops))
+	var err error
+	for _, op := range ops {
+		if op.Type == crdt.OpInsert && op.ID.Client != peer.ClientID {
+			err = fmt.Errorf("%w: insert as client %q", crdt.ErrInvalidOp, op.ID.Client)
+			break
+		}
+		if err = room.doc.Apply(op); err != nil {
+			break
+		}
+		applied = append(applied, op)
+	}
+
+	if len(applied) > 0 {
+		room.dirty = true
+		room.editorID = peer.userID
+		s.broadcast(room, peer, &CollabMessage{Type: CollabUpdate, ClientID: peer.ClientID, Ops: applied})
+	}
+	if err != nil {
+		s.sendError(peer, err.Error())
+		s.send(peer, s.syncMessage(room, peer))
+	}
+}
+
+// Leave removes the peer from its room, closing the room after a final save
+// when it was the last one
+func (s *collabService) Leave(peer *CollabPeer) {
+	room := peer.room
+	room.mu.Lock()
+	s.removePeer(room, peer)
+	last := len(room.peers) == 0 && !room.closing
+	if last {
+		room.closing = true
+	}
+	room.mu.Unlock()
+
+	if last {
+		s.closeRoom(room, true)
+	}
+}
+
+// Close saves and closes every room
+func (s *collabService) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	rooms := make([]*collabRoom, 0, len(s.rooms))
+	for _, room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	s.mu.Unlock()
+
+	for _, room := range rooms {
+		room.mu.Lock()
+		closing := room.closing
+		room.closing = true
+		for peer := range room.peers {
+			s.removePeer(room, peer)
+		}
+		room.mu.Unlock()
+
+		if closing {
+			// Another goroutine is closing it already
+			select {
+			case <-room.closed:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		s.closeRoom(room, true)
+	}
+	return nil
+}
+
+// closeRoom stops the room, saves its changes when save is set and removes
+// it. The room must have been marked closing.
+func (s *collabService) closeRoom(room *collabRoom, save bool) {
+	close(room.stop)
+	<-room.done
+	if save {
+		s.save(room)
+	}
+
+	s.mu.Lock()
+	if s.rooms[room.noteID] == room {
+		delete(s.rooms, room.noteID)
+	}
+	s.mu.Unlock()
+	close(room.closed)
+}
+
+// autosave writes the room's changes to the note until the room stops
+func (s *collabService) autosave(room *collabRoom) {
+	defer close(room.done)
+
+	ticker := time.NewTicker(collabSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-room.stop:
+			return
+		case <-ticker.C:
+			s.save(room)
+		}
+	}
+}
+
+// save writes the room's text to the note if it changed since the last save
+func (s *collabService) save(room *collabRoom) {
+	room.mu.Lock()
+	if !room.dirty {
+		room.mu.Unlock()
+		return
+	}
+	content := room.doc.String()
+	editorID := room.editorID
+	previous := room.saved
+	room.dirty = false
+	// Set before writing so that the update event of this save is not taken
+	// for an outside change
+	room.saved = content
+	room.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), collabSaveTimeout)
+	defer cancel()
+	err := s.writeContent(ctx, room.noteID, editorID, content)
+	if err != nil {
+		infra.Errorf("failed to save collaborative edits of note %d: %v", room.noteID, err)
+		room.mu.Lock()
+		room.dirty = true
+		if room.saved == content {
+			room.saved = previous
+		}
+		room.mu.Unlock()
+	}
+}
+
+func (s *collabService) writeContent(ctx context.Context, noteID int64, userID int64, content string) error {
+	note, err := s.noteService.GetByID(ctx, noteID, userID)
+	if err != nil {
+		return err
+	}
+	if note.Content == content {
+		return nil
+	}
+	// Never write plain text over ciphertext
+	if note.IsEncryptedNote() {
+		return ErrNoteEncrypted
+	}
+	note.Content = content
+	return s.noteService.Update(ctx, note, userID)
+}
+
+// onNoteEvent merges outside changes of a note into its room, and closes
+// the room of a deleted or newly encrypted note
+func (s *collabService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	if e.Type != NoteUpdated && e.Type != NoteDeleted {
+		return nil
+	}
+
+	s.mu.Lock()
+	room := s.rooms[e.Note.ID]
+	s.mu.Unlock()
+	if room == nil {
+		return nil
+	}
+
+	room.mu.Lock()
+	if room.closing {
+		room.mu.Unlock()
+		return nil
+	}
+
+	if e.Type == NoteDeleted || e.Note.IsEncryptedNote() {
+		message := "note was deleted"
+		if e.Type != NoteDeleted {
+			message = "note was encrypted"
+		}
+		room.closing = true
+		for peer := range room.peers {
+			s.sendError(peer, message)
+			s.removePeer(room, peer)
+		}
+		room.mu.Unlock()
+		go s.closeRoom(room, false)
+		return nil
+	}
+	defer room.mu.Unlock()
+
+	if e.Note.Content == room.saved {
+		return nil
+	}
+	room.saved = e.Note.Content
+	ops := diffOps(room.doc, e.Note.Content)
+	for _, op := range ops {
+		if err := room.doc.Apply(op); err != nil {
+			return err
+		}
+	}
+	room.dirty = room.doc.String() != room.saved
+	if len(ops) > 0 {
+		s.broadcast(room, nil, &CollabMessage{Type: CollabUpdate, ClientID: collabServerClient, Ops: ops})
+	}
+	return nil
+}
+
+// diffOps returns the operations turning the text of doc into content: the
+// part between their common prefix and suffix is replaced
+func diffOps(doc *crdt.Text, content string) []crdt.Op {
+	visible := make([]crdt.Item, 0)
+	for _, item := range doc.Items() {
+		if !item.Deleted {
+			visible = append(visible, item)
+		}
+	}
+	runes := []rune(content)
+
+	prefix := 0
+	for prefix < len(visible) && prefix < len(runes) && visible[prefix].Char == string(runes[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(visible)-prefix && suffix < len(runes)-prefix &&
+		visible[len(visible)-1-suffix].Char == string(runes[len(runes)-1-suffix]) {
+		suffix++
+	}
+
+	ops := make([]crdt.Op, 0, 2)
+	if removed := visible[prefix : len(visible)-suffix]; len(removed) > 0 {
+		ids := make([]crdt.ID, len(removed))
+		for i, item := range removed {
+			ids[i] = item.ID
+		}
+		ops = append(ops, crdt.Op{Type: crdt.OpDelete, IDs: ids})
+	}
+	if added := runes[prefix : len(runes)-suffix]; len(added) > 0 {
+		var origin crdt.ID
+		if prefix > 0 {
+			origin = visible[prefix-1].ID
+		}
+		ops = append(ops, crdt.Op{
+			Type:   crdt.OpInsert,
+			ID:     crdt.ID{Client: collabServerClient, Clock: doc.Clock() + 1},
+			Origin: origin,
+			Text:   string(added),
+		})
+	}
+	return ops
+}
+
+// removePeer disconnects peer and tells the others it left. room.mu must be
+// held.
+func (s *collabService) removePeer(room *collabRoom, peer *CollabPeer) {
+	if peer.closed {
+		return
+	}
+	peer.closed = true
+	delete(room.peers, peer)
+	close(peer.out)
+	s.broadcast(room, nil, awarenessMessage(peer, nil))
+}
+
+func (s *collabService) syncMessage(room *collabRoom, peer *CollabPeer) *CollabMessage {
+	return &CollabMessage{
+		Type:     CollabSync,
+		Session:  room.session,
+		ClientID: peer.ClientID,
+		UserID:   peer.userID,
+		Username: peer.username,
+		Clock:    room.doc.Clock(),
+		Items:    room.doc.Items(),
+		ReadOnly: peer.readOnly,
+	}
+}
+
+func awarenessMessage(peer *CollabPeer, state json.RawMessage) *CollabMessage {
+	if state == nil {
+		state = json.RawMessage("null")
+	}
+	return &CollabMessage{
+		Type:     CollabAwareness,
+		ClientID: peer.ClientID,
+		UserID:   peer.userID,
+		Username: peer.username,
+		State:    state,
+	}
+}
+
+// broadcast sends msg to every peer of the room but except. room.mu must be
+// held.
+func (s *collabService) broadcast(room *collabRoom, except *CollabPeer, msg *CollabMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		infra.Errorf("failed to encode collaboration message: %v", err)
+		return
+	}
+	for peer := range room.peers {
+		if peer != except {
+			s.sendRaw(room, peer, data)
+		}
+	}
+}
+
+// send queues msg for peer. room.mu must be held.
+func (s *collabService) send(peer *CollabPeer, msg *CollabMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		infra.Errorf("failed to encode collaboration message: %v", err)
+		return
+	}
+	s.sendRaw(peer.room, peer, data)
+}
+
+// sendError tells peer what went wrong. room.mu must be held.
+func (s *collabService) sendError(peer *CollabPeer, message string) {
+	s.send(peer, &CollabMessage{Type: CollabError, Message: message})
+}
+
+// sendRaw queues data for peer, disconnecting peers that cannot keep up;
+// they rejoin and get the document again. room.mu must be held.
+func (s *collabService) sendRaw(room *collabRoom, peer *CollabPeer, data []byte) {
+	if peer.closed {
+		return
+	}
+	select {
+	case peer.out <- data:
+	default:
+		infra.Warnf("dropping slow collaborator %s of note %d", peer.ClientID, room.noteID)
+		s.removePeer(room, peer)
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
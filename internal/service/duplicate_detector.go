@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/minhash"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+const (
+	duplicateScanInterval = 5 * time.Minute
+	// duplicateThreshold is the estimated similarity from which two notes
+	// count as near-duplicates
+	duplicateThreshold = 0.7
+)
+
+// DuplicateDetector finds clusters of near-duplicate notes in the
+// background. Note events mark the workspace of the note for a new scan,
+// which signs the notes changed since their last signature with MinHash and
+// groups the notes whose signatures fall in the same LSH bucket and are
+// similar enough. Every workspace is scanned once after a start.
+type DuplicateDetector struct {
+	duplicateRepo repo.NoteDuplicateRepo
+
+	mu    sync.Mutex
+	dirty map[int64]bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDuplicateDetector(duplicateRepo repo.NoteDuplicateRepo, events *NoteEvents) *DuplicateDetector {
+	d := &DuplicateDetector{
+		duplicateRepo: duplicateRepo,
+		dirty:         make(map[int64]bool),
+	}
+	events.Subscribe("duplicates", d.onNoteEvent)
+	return d
+}
+
+// Start begins scanning in the background
+func (d *DuplicateDetector) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	go d.run(ctx)
+}
+
+// Stop ends scanning and waits for the scan in progress
+func (d *DuplicateDetector) Stop(ctx context.Context) error {
+	if d.cancel == nil {
+		return nil
+	}
+	d.cancel()
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *DuplicateDetector) run(ctx context.Context) {
+	defer close(d.done)
+
+	ids, err := d.duplicateRepo.GetWorkspaceIDs(ctx)
+	if err != nil {
+		infra.Errorf("failed to list workspaces for duplicate detection: %v", err)
+	}
+	d.mu.Lock()
+	for _, id := range ids {
+		d.dirty[id] = true
+	}
+	d.mu.Unlock()
+
+	ticker := time.NewTicker(duplicateScanInterval)
+	defer ticker.Stop()
+	for {
+		d.scanDirty(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *DuplicateDetector) scanDirty(ctx context.Context) {
+	d.mu.Lock()
+	ids := make([]int64, 0, len(d.dirty))
+	for id := range d.dirty {
+		ids = append(ids, id)
+	}
+	d.dirty = make(map[int64]bool)
+	d.mu.Unlock()
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := d.Scan(ctx, id); err != nil {
+			infra.Errorf("failed to detect duplicate notes in workspace %d: %v", id, err)
+			d.markDirty(id)
+		}
+	}
+}
+
+// Scan signs the changed notes of the workspace and replaces its clusters
+// of near-duplicates
+func (d *DuplicateDetector) Scan(ctx context.Context, workspaceID int64) error {
+	notes, err := d.duplicateRepo.GetUnsigned(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		signature := []byte{}
+		if sig, ok := minhash.New(note.Title + "\n" + markdown.PlainText([]byte(note.Content))); ok {
+			signature = sig.Bytes()
+		}
+		if err := d.duplicateRepo.SaveSignature(ctx, note.ID, signature, note.UpdatedAt); err != nil {
+			return err
+		}
+	}
+
+	signatures, err := d.duplicateRepo.GetSignatures(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	return d.duplicateRepo.SaveClusters(ctx, workspaceID, clusterDuplicates(signatures))
+}
+
+// onNoteEvent marks the workspace of a changed note for a scan, and forgets
+// deleted notes
+func (d *DuplicateDetector) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated, NoteTrashed, NoteRestored:
+		d.markDirty(e.Note.WorkspaceID)
+	case NoteDeleted:
+		d.markDirty(e.Note.WorkspaceID)
+		return d.duplicateRepo.DeleteByNoteID(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+func (d *DuplicateDetector) markDirty(workspaceID int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dirty[workspaceID] = true
+}
+
+// clusterDuplicates groups the notes whose signatures share an LSH bucket
+// and are similar past duplicateThreshold. Notes without a near-duplicate
+// are left out.
+func clusterDuplicates(signatures []*model.NoteSignature) []*model.NoteDuplicate {
+	sigs := make([]minhash.Signature, len(signatures))
+	buckets := make(map[uint64][]int)
+	for i, s := range signatures {
+		sigs[i] = minhash.FromBytes(s.Signature)
+		for _, band := range sigs[i].Bands() {
+			buckets[band] = append(buckets[band], i)
+		}
+	}
+
+	// Union-find over the signatures
+	parent := make([]int, len(signatures))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	best := make(map[int]float64)
+	compared := make(map[[2]int]bool)
+	for _, members := range buckets {
+		for a := 0; a < len(members); a++ {
+			for b := a + 1; b < len(members); b++ {
+				pair := [2]int{members[a], members[b]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+
+				sim := sigs[pair[0]].Similarity(sigs[pair[1]])
+				if sim < duplicateThreshold {
+					continue
+				}
+				for _, i := range pair {
+					if sim > best[i] {
+						best[i] = sim
+					}
+				}
+				if ra, rb := find(pair[0]), find(pair[1]); ra != rb {
+					parent[ra] = rb
+				}
+			}
+		}
+	}
+
+	// Signatures are sorted by note ID, so the first note of a cluster has
+	// its smallest ID
+	clusterIDs := make(map[int]int64)
+	duplicates := make([]*model.NoteDuplicate, 0, len(best))
+	for i, s := range signatures {
+		if _, ok := best[i]; !ok {
+			continue
+		}
+		root := find(i)
+		if _, ok := clusterIDs[root]; !ok {
+			clusterIDs[root] = s.NoteID
+		}
+		duplicates = append(duplicates, &model.NoteDuplicate{
+			NoteID:     s.NoteID,
+			ClusterID:  clusterIDs[root],
+			Similarity: best[i],
+		})
+	}
+	return duplicates
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+// DuplicateService lists the clusters of near-duplicate notes found by the
+// DuplicateDetector, for the user to review and merge
+type DuplicateService interface {
+	List(ctx context.Context, userID int64) ([]*model.DuplicateCluster, error)
+}
+
+type duplicateService struct {
+	duplicateRepo repo.NoteDuplicateRepo
+	noteService   NoteService
+}
+
+func NewDuplicateService(duplicateRepo repo.NoteDuplicateRepo, noteService NoteService) DuplicateService {
+	return &duplicateService{
+		duplicateRepo: duplicateRepo,
+		noteService:   noteService,
+	}
+}
+
+// List returns the clusters of the user's current workspace, most similar
+// first. Notes merged or trashed since the last scan are left out, and so
+// are the clusters with a single note left.
+func (s *duplicateService) List(ctx context.Context, userID int64) ([]*model.DuplicateCluster, error) {
+	workspaceID, ownerID, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.duplicateRepo.GetByWorkspaceID(ctx, workspaceID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters := make([]*model.DuplicateCluster, 0)
+	var current *model.DuplicateCluster
+	for _, n := range notes {
+		if current == nil || current.ID != n.ClusterID {
+			current = &model.DuplicateCluster{ID: n.ClusterID}
+			clusters = append(clusters, current)
+		}
+		current.Notes = append(current.Notes, n)
+		if n.Similarity > current.Similarity {
+			current.Similarity = n.Similarity
+		}
+	}
+
+	kept := make([]*model.DuplicateCluster, 0, len(clusters))
+	for _, c := range clusters {
+		if len(c.Notes) > 1 {
+			kept = append(kept, c)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].Similarity > kept[j].Similarity
+	})
+	return kept, nil
+}
@@ -0,0 +1,397 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrKeyEnvelopeNotFound   = errors.New("encryption key is not set up")
+	ErrKeyEnvelopeExists     = errors.New("encryption key is already set up")
+	ErrKeyEnvelopeOutOfDate  = errors.New("encryption key has changed")
+	ErrInvalidKeyEnvelope    = errors.New("invalid encryption key")
+	ErrKeyProofMismatch      = errors.New("passphrase or recovery key does not match")
+	ErrInvalidNoteEncryption = errors.New("invalid note encryption")
+	ErrNoteEncrypted         = errors.New("note is encrypted")
+)
+
+const (
+	minKeySaltBytes  = 16
+	minKeyProofBytes = 32
+
+	// Lower bounds of the KDF cost, following the OWASP recommendations
+	minArgon2idIterations = 2
+	minArgon2idMemoryKiB  = 19456
+	minPBKDF2Iterations   = 600000
+)
+
+// KeyEnvelopeInput is an envelope as the client builds it. Proof and
+// RecoveryProof are derived from the passphrase and recovery keys the same
+// way on every use; only their hashes are stored.
+type KeyEnvelopeInput struct {
+	Algorithm          string
+	KDF                string
+	Salt               string
+	Iterations         int
+	MemoryKiB          int
+	Parallelism        int
+	WrappedKey         string
+	Proof              string
+	RecoveryWrappedKey string
+	RecoveryProof      string
+}
+
+// KeyRotation replaces an envelope. Without Rekey the data key stays the
+// same and only its wrapping changes, so the recovery fields may be left
+// empty to keep the recovery key. With Rekey the client generated a new data
+// key and sends every encrypted note re-encrypted with it in Notes.
+type KeyRotation struct {
+	Version  int
+	Proof    string
+	Envelope KeyEnvelopeInput
+	Rekey    bool
+	Notes    []repo.NoteContent
+}
+
+// EncryptionService manages the key envelopes of end-to-end encrypted notes
+// and which notes are encrypted. The server never sees a key: it stores what
+// the client encrypted and checks proofs of the keys before replacing
+// anything. Encrypted content is left out of rendering, properties, tasks
+// and everything else that reads note text.
+type EncryptionService interface {
+	GetEnvelope(ctx context.Context, userID int64) (*model.KeyEnvelope, error)
+	Setup(ctx context.Context, userID int64, input KeyEnvelopeInput) (*model.KeyEnvelope, error)
+	Rotate(ctx context.Context, userID int64, rotation KeyRotation) (*model.KeyEnvelope, error)
+	Recover(ctx context.Context, userID int64, rotation KeyRotation) (*model.KeyEnvelope, error)
+	SetNoteEncryption(ctx context.Context, id int64, userID int64, encrypted bool, subtree bool, contents []repo.NoteContent) ([]*model.Note, error)
+}
+
+type encryptionService struct {
+	keyEnvelopeRepo repo.KeyEnvelopeRepo
+	noteRepo        repo.NoteRepo
+	noteService     NoteService
+	events          *NoteEvents
+}
+
+func NewEncryptionService(
+	keyEnvelopeRepo repo.KeyEnvelopeRepo,
+	noteRepo repo.NoteRepo,
+	noteService NoteService,
+	events *NoteEvents,
+) EncryptionService {
+	return &encryptionService{
+		keyEnvelopeRepo: keyEnvelopeRepo,
+		noteRepo:        noteRepo,
+		noteService:     noteService,
+		events:          events,
+	}
+}
+
+func (s *encryptionService) GetEnvelope(ctx context.Context, userID int64) (*model.KeyEnvelope, error) {
+	e, err := s.keyEnvelopeRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrKeyEnvelopeNotFound
+		}
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Setup stores the user's first envelope
+func (s *encryptionService) Setup(ctx context.Context, userID int64, input KeyEnvelopeInput) (*model.KeyEnvelope, error) {
+	if _, err := s.GetEnvelope(ctx, userID); err == nil {
+		return nil, ErrKeyEnvelopeExists
+	} else if err != ErrKeyEnvelopeNotFound {
+		return nil, err
+	}
+
+	e, err := buildKeyEnvelope(input, true)
+	if err != nil {
+		return nil, err
+	}
+	e.UserID = userID
+	e.Version = 1
+
+	if err := s.keyEnvelopeRepo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+
+	return s.GetEnvelope(ctx, userID)
+}
+
+// Rotate replaces the envelope for a new passphrase, new KDF parameters or a
+// new data key. rotation.Proof proves the current passphrase.
+func (s *encryptionService) Rotate(ctx context.Context, userID int64, rotation KeyRotation) (*model.KeyEnvelope, error) {
+	current, err := s.GetEnvelope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !proofMatches(rotation.Proof, current.ProofHash) {
+		return nil, ErrKeyProofMismatch
+	}
+
+	return s.replace(ctx, current, rotation)
+}
+
+// Recover sets a new passphrase for a user who lost theirs. rotation.Proof
+// proves the recovery key; the data key cannot change.
+func (s *encryptionService) Recover(ctx context.Context, userID int64, rotation KeyRotation) (*model.KeyEnvelope, error) {
+	current, err := s.GetEnvelope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !proofMatches(rotation.Proof, current.RecoveryProofHash) {
+		return nil, ErrKeyProofMismatch
+	}
+	if rotation.Rekey {
+		return nil, fmt.Errorf("%w: recovery cannot replace the data key", ErrInvalidKeyEnvelope)
+	}
+
+	return s.replace(ctx, current, rotation)
+}
+
+func (s *encryptionService) replace(ctx context.Context, current *model.KeyEnvelope, rotation KeyRotation) (*model.KeyEnvelope, error) {
+	if rotation.Version != current.Version {
+		return nil, ErrKeyEnvelopeOutOfDate
+	}
+
+	// A new data key needs a new recovery wrapping too
+	e, err := buildKeyEnvelope(rotation.Envelope, rotation.Rekey)
+	if err != nil {
+		return nil, err
+	}
+	if e.RecoveryWrappedKey == "" {
+		e.RecoveryWrappedKey = current.RecoveryWrappedKey
+		e.RecoveryProofHash = current.RecoveryProofHash
+	}
+	e.UserID = current.UserID
+	e.Version = current.Version + 1
+
+	var ok bool
+	if rotation.Rekey {
+		ids, err := s.noteRepo.GetEncryptedIDs(ctx, current.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := matchContents(ids, rotation.Notes); err != nil {
+			return nil, err
+		}
+		ok, err = s.keyEnvelopeRepo.Rekey(ctx, e, current.Version, rotation.Notes)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			for _, c := range rotation.Notes {
+				s.publishUpdated(ctx, c.ID, nil)
+			}
+		}
+	} else {
+		if len(rotation.Notes) > 0 {
+			return nil, fmt.Errorf("%w: notes are only re-encrypted with a new data key", ErrInvalidKeyEnvelope)
+		}
+		ok, err = s.keyEnvelopeRepo.Update(ctx, e, current.Version)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, ErrKeyEnvelopeOutOfDate
+	}
+
+	return s.GetEnvelope(ctx, current.UserID)
+}
+
+// SetNoteEncryption encrypts or decrypts the note, and with subtree also
+// its descendants. The client sends the new content of every note whose
+// state changes: ciphertext when encrypting, plain text when decrypting.
+// Only the note's user holds the key, so descendants of other users are
+// left as they are. It returns the changed notes.
+func (s *encryptionService) SetNoteEncryption(ctx context.Context, id int64, userID int64, encrypted bool, subtree bool, contents []repo.NoteContent) ([]*model.Note, error) {
+	note, err := s.noteService.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if note.UserID != userID {
+		return nil, ErrNoteUnauthorized
+	}
+	if _, err := s.GetEnvelope(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	state := model.NotePlain
+	if encrypted {
+		state = model.NoteEncrypted
+	}
+
+	targets := make([]*model.Note, 0)
+	if note.IsEncrypted != state {
+		targets = append(targets, note)
+	}
+	if subtree {
+		descendants, err := s.noteRepo.GetDescendants(ctx, id, model.NoteStatusNormal)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range descendants {
+			if d.UserID == userID && d.IsEncrypted != state {
+				targets = append(targets, d)
+			}
+		}
+	}
+
+	ids := make([]int64, 0, len(targets))
+	for _, t := range targets {
+		ids = append(ids, t.ID)
+	}
+	if err := matchContents(ids, contents); err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return []*model.Note{}, nil
+	}
+
+	if err := s.noteRepo.SetEncryption(ctx, contents, state); err != nil {
+		return nil, err
+	}
+
+	changed := make([]*model.Note, 0, len(targets))
+	for _, t := range targets {
+		if n := s.publishUpdated(ctx, t.ID, t); n != nil {
+			changed = append(changed, n)
+		}
+	}
+	return changed, nil
+}
+
+// publishUpdated announces the new content of a note and returns it as
+// stored
+func (s *encryptionService) publishUpdated(ctx context.Context, id int64, previous *model.Note) *model.Note {
+	note, err := s.noteRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	s.events.Publish(ctx, NoteEvent{Type: NoteUpdated, Note: note, Previous: previous})
+	return note
+}
+
+// matchContents checks that contents holds exactly one entry for each of ids
+func matchContents(ids []int64, contents []repo.NoteContent) error {
+	expected := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		expected[id] = true
+	}
+
+	seen := make(map[int64]bool, len(contents))
+	for _, c := range contents {
+		if !expected[c.ID] {
+			return fmt.Errorf("%w: unexpected content for note %d", ErrInvalidNoteEncryption, c.ID)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("%w: duplicate content for note %d", ErrInvalidNoteEncryption, c.ID)
+		}
+		seen[c.ID] = true
+	}
+	for _, id := range ids {
+		if !seen[id] {
+			return fmt.Errorf("%w: missing content for note %d", ErrInvalidNoteEncryption, id)
+		}
+	}
+	return nil
+}
+
+// buildKeyEnvelope validates the input and hashes its proofs. The recovery
+// fields are optional unless withRecovery is set.
+func buildKeyEnvelope(input KeyEnvelopeInput, withRecovery bool) (*model.KeyEnvelope, error) {
+	if input.Algorithm != model.KeyAlgorithmAES256GCM {
+		return nil, fmt.Errorf("%w: algorithm must be %s", ErrInvalidKeyEnvelope, model.KeyAlgorithmAES256GCM)
+	}
+
+	switch input.KDF {
+	case model.KDFArgon2id:
+		if input.Iterations < minArgon2idIterations || input.MemoryKiB < minArgon2idMemoryKiB || input.Parallelism < 1 {
+			return nil, fmt.Errorf("%w: argon2id needs at least %d iterations, %d KiB of memory and a parallelism of 1",
+				ErrInvalidKeyEnvelope, minArgon2idIterations, minArgon2idMemoryKiB)
+		}
+	case model.KDFPBKDF2SHA256:
+		if input.Iterations < minPBKDF2Iterations {
+			return nil, fmt.Errorf("%w: pbkdf2-sha256 needs at least %d iterations", ErrInvalidKeyEnvelope, minPBKDF2Iterations)
+		}
+		input.MemoryKiB = 0
+		input.Parallelism = 0
+	default:
+		return nil, fmt.Errorf("%w: kdf must be argon2id or pbkdf2-sha256", ErrInvalidKeyEnvelope)
+	}
+
+	if n, ok := decodedLen(input.Salt); !ok || n < minKeySaltBytes {
+		return nil, fmt.Errorf("%w: salt must be at least %d base64 encoded bytes", ErrInvalidKeyEnvelope, minKeySaltBytes)
+	}
+	if n, ok := decodedLen(input.WrappedKey); !ok || n == 0 {
+		return nil, fmt.Errorf("%w: wrapped key must be base64 encoded", ErrInvalidKeyEnvelope)
+	}
+	proofHash, err := hashProof(input.Proof)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &model.KeyEnvelope{
+		Algorithm:   input.Algorithm,
+		KDF:         input.KDF,
+		Salt:        input.Salt,
+		Iterations:  input.Iterations,
+		MemoryKiB:   input.MemoryKiB,
+		Parallelism: input.Parallelism,
+		WrappedKey:  input.WrappedKey,
+		ProofHash:   proofHash,
+	}
+
+	if !withRecovery && input.RecoveryWrappedKey == "" && input.RecoveryProof == "" {
+		return e, nil
+	}
+	if n, ok := decodedLen(input.RecoveryWrappedKey); !ok || n == 0 {
+		return nil, fmt.Errorf("%w: recovery wrapped key must be base64 encoded", ErrInvalidKeyEnvelope)
+	}
+	recoveryProofHash, err := hashProof(input.RecoveryProof)
+	if err != nil {
+		return nil, err
+	}
+	e.RecoveryWrappedKey = input.RecoveryWrappedKey
+	e.RecoveryProofHash = recoveryProofHash
+
+	return e, nil
+}
+
+func hashProof(proof string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(proof)
+	if err != nil || len(raw) < minKeyProofBytes {
+		return "", fmt.Errorf("%w: proofs must be at least %d base64 encoded bytes", ErrInvalidKeyEnvelope, minKeyProofBytes)
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func proofMatches(proof string, hash string) bool {
+	computed, err := hashProof(proof)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+func decodedLen(s string) (int, bool) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return 0, false
+	}
+	return len(raw), true
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+const (
+	// streamBufferSize is how many events a slow client may fall behind
+	// before it is disconnected, to catch up by resuming
+	streamBufferSize = 64
+	// streamReplayLimit caps the events replayed on resume; clients that
+	// missed more are told to reload instead
+	streamReplayLimit   = 1000
+	streamRetention     = 7 * 24 * time.Hour
+	streamPruneInterval = time.Hour
+)
+
+// ErrStreamClosed is returned when subscribing to a stream that shut down
+var ErrStreamClosed = errors.New("event stream is closed")
+
+// StreamNote is a note as sent on the event stream. Content is left out;
+// clients fetch the note when they need it.
+type StreamNote struct {
+	ID         int64            `json:"id"`
+	ParentID   model.NullInt64  `json:"parentId"`
+	Title      string           `json:"title"`
+	Icon       model.NullString `json:"icon"`
+	IsFavorite int              `json:"isFavorite"`
+	Position   int              `json:"position"`
+	Status     int              `json:"status"`
+	UpdatedAt  time.Time        `json:"updatedAt"`
+}
+
+// StreamPayload is the data of a note event on the stream
+type StreamPayload struct {
+	Type NoteEventType `json:"type"`
+	Note *StreamNote   `json:"note"`
+	// PreviousParentID is set on moves
+	PreviousParentID *model.NullInt64 `json:"previousParentId,omitempty"`
+}
+
+// StreamSubscription receives the events of one user. Replay holds the
+// events missed since Cursor; Events delivers the live ones, which may repeat
+// replayed events, and is closed when the subscriber is dropped or the stream
+// shuts down.
+type StreamSubscription struct {
+	Cursor int64
+	Replay []*model.StreamEvent
+	// Reset reports that events were lost, so the client must reload its
+	// state. Cursor is then the point to continue from.
+	Reset  bool
+	Events <-chan *model.StreamEvent
+
+	userID int64
+	ch     chan *model.StreamEvent
+}
+
+// EventStream records note changes per user and fans them out to the
+// user's open connections. Every event is stored first, so a client that
+// reconnects with the ID of the last event it saw gets what it missed.
+type EventStream struct {
+	streamEventRepo repo.StreamEventRepo
+
+	mu     sync.Mutex
+	subs   map[int64]map[*StreamSubscription]struct{}
+	closed bool
+	pruned time.Time
+}
+
+func NewEventStream(streamEventRepo repo.StreamEventRepo, events *NoteEvents) *EventStream {
+	s := &EventStream{
+		streamEventRepo: streamEventRepo,
+		subs:            make(map[int64]map[*StreamSubscription]struct{}),
+	}
+	events.Subscribe("stream", s.onNoteEvent)
+	return s
+}
+
+// Subscribe opens a subscription for the user resuming after cursor. A zero
+// cursor starts at the current end of the stream.
+func (s *EventStream) Subscribe(ctx context.Context, userID int64, cursor int64) (*StreamSubscription, error) {
+	resume := cursor > 0
+	if !resume {
+		_, maxID, err := s.streamEventRepo.GetIDRange(ctx)
+		if err != nil {
+			return nil, err
+		}
+		cursor = maxID
+	}
+
+	ch := make(chan *model.StreamEvent, streamBufferSize)
+	sub := &StreamSubscription{Cursor: cursor, Events: ch, userID: userID, ch: ch}
+
+	// Register before reading the backlog so that no event falls in between;
+	// the caller skips live events already replayed
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrStreamClosed
+	}
+	if s.subs[userID] == nil {
+		s.subs[userID] = make(map[*StreamSubscription]struct{})
+	}
+	s.subs[userID][sub] = struct{}{}
+	s.mu.Unlock()
+
+	if resume {
+		minID, maxID, err := s.streamEventRepo.GetIDRange(ctx)
+		if err != nil {
+			s.Unsubscribe(sub)
+			return nil, err
+		}
+		// A cursor ahead of every assigned ID comes from another database,
+		// and one below the retained range means pruned events were missed
+		if cursor > maxID || (minID > 0 && cursor < minID-1) || (minID == 0 && cursor < maxID) {
+			sub.Reset, sub.Cursor = true, maxID
+			return sub, nil
+		}
+	}
+
+	replay, err := s.streamEventRepo.GetAfter(ctx, userID, cursor, streamReplayLimit+1)
+	if err != nil {
+		s.Unsubscribe(sub)
+		return nil, err
+	}
+	if len(replay) > streamReplayLimit {
+		sub.Reset, sub.Cursor = true, replay[len(replay)-1].ID
+		return sub, nil
+	}
+	sub.Replay = replay
+	return sub, nil
+}
+
+// Unsubscribe stops delivering events to sub
+func (s *EventStream) Unsubscribe(sub *StreamSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop(sub)
+}
+
+// Close ends every subscription, letting open connections finish before the
+// server shuts down
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, subs := range s.subs {
+		for sub := range subs {
+			s.drop(sub)
+		}
+	}
+}
+
+// drop removes sub and closes its channel. s.mu must be held.
+func (s *EventStream) drop(sub *StreamSubscription) {
+	subs, ok := s.subs[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(s.subs, sub.userID)
+	}
+	close(sub.ch)
+}
+
+// broadcast sends e to the open subscriptions of its user. Subscribers that
+// cannot keep up are dropped; they resume from their last event.
+func (s *EventStream) broadcast(e *model.StreamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.subs[e.UserID] {
+		select {
+		case sub.ch <- e:
+		default:
+			infra.Warnf("dropping slow event stream subscriber of user %d", e.UserID)
+			s.drop(sub)
+		}
+	}
+}
+
+// onNoteEvent stores the change and pushes it to the owner's connections
+func (s *EventStream) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	payload := StreamPayload{
+		Type: e.Type,
+		Note: &StreamNote{
+			ID:         e.Note.ID,
+			ParentID:   e.Note.ParentID,
+			Title:      e.Note.Title,
+			Icon:       e.Note.Icon,
+			IsFavorite: e.Note.IsFavorite,
+			Position:   e.Note.Position,
+			Status:     e.Note.Status,
+			UpdatedAt:  e.Note.UpdatedAt.UTC(),
+		},
+	}
+	if e.Type == NoteMoved && e.Previous != nil {
+		payload.PreviousParentID = &e.Previous.ParentID
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event := &model.StreamEvent{
+		UserID: e.Note.UserID,
+		Type:   string(e.Type),
+		NoteID: e.Note.ID,
+		Data:   string(data),
+	}
+	if err := s.streamEventRepo.Create(ctx, event); err != nil {
+		return err
+	}
+	s.broadcast(event)
+	s.prune(ctx)
+	return nil
+}
+
+// prune deletes events past the retention period, at most once per
+// streamPruneInterval
+func (s *EventStream) prune(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.pruned) < streamPruneInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.pruned = time.Now()
+	s.mu.Unlock()
+
+	if _, err := s.streamEventRepo.DeleteBefore(ctx, time.Now().Add(-streamRetention)); err != nil {
+		infra.Errorf("failed to prune stream events: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ray-d-song/yan/internal/convert"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported format")
+)
+
+const (
+	FormatOPML = "opml"
+	FormatEPUB = "epub"
+	FormatDOCX = "docx"
+)
+
+// maxEmbeddedImageSize bounds every image downloaded into an export
+const maxEmbeddedImageSize = 10 << 20
+
+// ExportResult is a rendered export ready to be sent to the client
+type ExportResult struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type ExportService interface {
+	Export(ctx context.Context, id int64, userID int64, format string) (*ExportResult, error)
+}
+
+type exportService struct {
+	noteService NoteService
+	userService UserService
+	linkService LinkService
+	fetchImage  convert.ImageFetcher
+}
+
+func NewExportService(noteService NoteService, userService UserService, linkService LinkService) ExportService {
+	return &exportService{
+		noteService: noteService,
+		userService: userService,
+		linkService: linkService,
+		fetchImage:  convert.NewImageFetcher(nil, maxEmbeddedImageSize),
+	}
+}
+
+func (s *exportService) Export(ctx context.Context, id int64, userID int64, format string) (*ExportResult, error) {
+	tree, err := s.noteService.GetTree(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	tree.StripEncrypted()
+	// Exports are read outside of Yan, so embedded notes go in as content
+	tree.Walk(func(node *model.NoteNode, _ int) {
+		if !node.IsEncryptedNote() {
+			node.Content = s.linkService.Expand(ctx, node.Note, userID)
+		}
+	})
+
+	switch strings.ToLower(format) {
+	case FormatOPML:
+		data, err := convert.EncodeOPML(tree)
+		if err != nil {
+			return nil, err
+		}
+		return &ExportResult{
+			Filename:    exportFilename(tree.Title, "opml"),
+			ContentType: "text/x-opml; charset=utf-8",
+			Data:        data,
+		}, nil
+	case FormatEPUB:
+		user, err := s.userService.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		data, err := convert.EncodeEPUB(ctx, tree, convert.EPUBOptions{
+			Author:     user.Username,
+			FetchImage: s.fetchImage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &ExportResult{
+			Filename:    exportFilename(tree.Title, "epub"),
+			ContentType: "application/epub+zip",
+			Data:        data,
+		}, nil
+	case FormatDOCX:
+		user, err := s.userService.GetByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		data, err := convert.EncodeDOCX(ctx, tree, convert.DOCXOptions{
+			Author:     user.Username,
+			FetchImage: s.fetchImage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &ExportResult{
+			Filename:    exportFilename(tree.Title, "docx"),
+			ContentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			Data:        data,
+		}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// exportFilename turns a note title into a file name with the given extension
+func exportFilename(title, ext string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '_'
+		}
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if name == "" {
+		name = "untitled"
+	}
+	return name + "." + ext
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrInvalidGraphFilter = errors.New("invalid graph filter")
+)
+
+const (
+	defaultGraphDepth = 1
+	maxGraphDepth     = 5
+	// maxGraphTagFanout is the number of notes of a tag joined to each
+	// other, which keeps the tag edges linear in the number of tagged notes
+	maxGraphTagFanout = 50
+)
+
+// GraphFilter narrows the knowledge graph. Zero fields do not filter.
+type GraphFilter struct {
+	// RootID limits the graph to the notes within Depth edges of the note
+	RootID int64
+	Depth  int
+	// Tags keeps the notes with any of the tags, besides the root
+	Tags []string
+}
+
+// GraphService builds the graph of the notes of a workspace from the stored
+// note tree, links and tags
+type GraphService interface {
+	Graph(ctx context.Context, userID int64, filter GraphFilter) (*model.Graph, error)
+}
+
+type graphService struct {
+	graphRepo   repo.GraphRepo
+	tagRepo     repo.NoteTagRepo
+	noteService NoteService
+}
+
+func NewGraphService(graphRepo repo.GraphRepo, tagRepo repo.NoteTagRepo, noteService NoteService) GraphService {
+	return &graphService{
+		graphRepo:   graphRepo,
+		tagRepo:     tagRepo,
+		noteService: noteService,
+	}
+}
+
+// Graph returns the notes the user lists in their current workspace and the
+// relations between them
+func (s *graphService) Graph(ctx context.Context, userID int64, filter GraphFilter) (*model.Graph, error) {
+	if filter.RootID == 0 && filter.Depth != 0 {
+		return nil, fmt.Errorf("%w: depth needs a root note", ErrInvalidGraphFilter)
+	}
+	if filter.RootID != 0 {
+		if filter.Depth == 0 {
+			filter.Depth = defaultGraphDepth
+		}
+		if filter.Depth < 1 || filter.Depth > maxGraphDepth {
+			return nil, fmt.Errorf("%w: depth must be between 1 and %d", ErrInvalidGraphFilter, maxGraphDepth)
+		}
+		if _, err := s.noteService.GetByID(ctx, filter.RootID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	workspaceID, ownerID, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := s.graphRepo.GetNodes(ctx, workspaceID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	links, err := s.graphRepo.GetLinkEdges(ctx, workspaceID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.tagRepo.GetByWorkspaceID(ctx, workspaceID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int64]*model.GraphNode, len(nodes))
+	for _, n := range nodes {
+		n.Tags = make([]string, 0)
+		byID[n.ID] = n
+	}
+	for _, t := range tags {
+		if n, ok := byID[t.NoteID]; ok {
+			n.Tags = append(n.Tags, t.Tag)
+		}
+	}
+	// The root may be a note shared with the user from another workspace
+	if filter.RootID != 0 && byID[filter.RootID] == nil {
+		return nil, ErrNoteNotFound
+	}
+
+	if len(filter.Tags) > 0 {
+		wanted := make(map[string]bool, len(filter.Tags))
+		for _, t := range filter.Tags {
+			wanted[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "#"))] = true
+		}
+		for id, n := range byID {
+			if id != filter.RootID && !hasAnyTag(n.Tags, wanted) {
+				delete(byID, id)
+			}
+		}
+	}
+
+	edges, truncated := graphEdges(nodes, links, byID, filter.RootID)
+
+	if filter.RootID != 0 {
+		reached := neighborhood(filter.RootID, filter.Depth, edges)
+		for id := range byID {
+			if !reached[id] {
+				delete(byID, id)
+			}
+		}
+		kept := make([]*model.GraphEdge, 0, len(edges))
+		for _, e := range edges {
+			if reached[e.Source] && reached[e.Target] {
+				kept = append(kept, e)
+			}
+		}
+		edges = kept
+	}
+
+	graph := &model.Graph{
+		Nodes:     make([]*model.GraphNode, 0, len(byID)),
+		Edges:     edges,
+		Truncated: truncated,
+	}
+	for _, n := range nodes {
+		if byID[n.ID] != nil {
+			graph.Nodes = append(graph.Nodes, n)
+		}
+	}
+	return graph, nil
+}
+
+// graphEdges returns the parent, link and embed, and shared tag edges
+// between the kept notes, and whether tag edges were left out
+func graphEdges(nodes []*model.GraphNode, links []*model.GraphEdge, kept map[int64]*model.GraphNode, rootID int64) ([]*model.GraphEdge, bool) {
+	edges := make([]*model.GraphEdge, 0, len(nodes)+len(links))
+	for _, n := range nodes {
+		if kept[n.ID] != nil && n.ParentID.Valid && kept[n.ParentID.Int64] != nil {
+			edges = append(edges, &model.GraphEdge{Source: n.ParentID.Int64, Target: n.ID, Type: model.GraphEdgeParent})
+		}
+	}
+	for _, l := range links {
+		if kept[l.Source] != nil && kept[l.Target] != nil {
+			edges = append(edges, l)
+		}
+	}
+
+	// Every two notes with a tag are joined, under the first spelling of it.
+	// Only the first maxGraphTagFanout notes of a tag are, the root first,
+	// since the edges grow with the square of the notes.
+	var order []string
+	tagged := make(map[string][]int64)
+	spelling := make(map[string]string)
+	for _, n := range nodes {
+		if kept[n.ID] == nil {
+			continue
+		}
+		for _, t := range n.Tags {
+			key := strings.ToLower(t)
+			if _, ok := spelling[key]; !ok {
+				spelling[key] = t
+				order = append(order, key)
+			}
+			if n.ID == rootID {
+				tagged[key] = append([]int64{n.ID}, tagged[key]...)
+			} else {
+				tagged[key] = append(tagged[key], n.ID)
+			}
+		}
+	}
+	truncated := false
+	for _, key := range order {
+		ids := tagged[key]
+		if len(ids) > maxGraphTagFanout {
+			ids = ids[:maxGraphTagFanout]
+			truncated = true
+		}
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				edges = append(edges, &model.GraphEdge{Source: ids[i], Target: ids[j], Type: model.GraphEdgeTag, Tag: spelling[key]})
+			}
+		}
+	}
+	return edges, truncated
+}
+
+// neighborhood returns the notes within depth edges of the root, following
+// edges in both directions
+func neighborhood(rootID int64, depth int, edges []*model.GraphEdge) map[int64]bool {
+	adjacent := make(map[int64][]int64)
+	for _, e := range edges {
+		adjacent[e.Source] = append(adjacent[e.Source], e.Target)
+		adjacent[e.Target] = append(adjacent[e.Target], e.Source)
+	}
+
+	reached := map[int64]bool{rootID: true}
+	level := []int64{rootID}
+	for d := 0; d < depth && len(level) > 0; d++ {
+		var next []int64
+		for _, id := range level {
+			for _, other := range adjacent[id] {
+				if !reached[other] {
+					reached[other] = true
+					next = append(next, other)
+				}
+			}
+		}
+		level = next
+	}
+	return reached
+}
+
+func hasAnyTag(tags []string, wanted map[string]bool) bool {
+	for _, t := range tags {
+		if wanted[strings.ToLower(t)] {
+			return true
+		}
+	}
+	return false
+}
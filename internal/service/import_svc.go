@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ray-d-song/yan/internal/convert"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrInvalidImport = errors.New("invalid import document")
+)
+
+// ImportOptions describes where and how an uploaded document is imported
+type ImportOptions struct {
+	Format   string
+	ParentID sql.NullInt64
+	// Title names the imported note when the document has no title of its own
+	Title string
+	// SplitLevel splits a document into child notes at headings up to this
+	// level, for formats that support it
+	SplitLevel int
+}
+
+type ImportService interface {
+	Import(ctx context.Context, r io.Reader, opts ImportOptions, userID int64) ([]*model.Note, error)
+}
+
+type importService struct {
+	noteRepo         repo.NoteRepo
+	noteService      NoteService
+	workspaceService WorkspaceService
+	events           *NoteEvents
+}
+
+func NewImportService(
+	noteRepo repo.NoteRepo,
+	noteService NoteService,
+	workspaceService WorkspaceService,
+	events *NoteEvents,
+) ImportService {
+	return &importService{
+		noteRepo:         noteRepo,
+		noteService:      noteService,
+		workspaceService: workspaceService,
+		events:           events,
+	}
+}
+
+// Import parses the document and creates its notes below opts.ParentID. It
+// returns the created top-level notes.
+func (s *importService) Import(ctx context.Context, r io.Reader, opts ImportOptions, userID int64) ([]*model.Note, error) {
+	// If parent_id is provided, the user must be able to edit it, and the
+	// notes go to its workspace like NoteService.Create would put them.
+	// Otherwise they go to the top of the user's current workspace.
+	ownerID := userID
+	var workspaceID int64
+	if opts.ParentID.Valid {
+		parent, err := s.noteService.Authorize(ctx, opts.ParentID.Int64, userID, model.NoteRoleEditor)
+		if err != nil {
+			if err == ErrNoteNotFound {
+				return nil, ErrInvalidParentNote
+			}
+			return nil, err
+		}
+		workspaceID = parent.WorkspaceID
+
+		memberRole, err := s.workspaceService.MemberRole(ctx, workspaceID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !model.WorkspaceRoleAllows(memberRole, model.WorkspaceRoleMember) {
+			ownerID = parent.UserID
+		}
+	} else {
+		workspace, err := s.workspaceService.Current(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !model.WorkspaceRoleAllows(workspace.Role, model.WorkspaceRoleMember) {
+			return nil, ErrNoteUnauthorized
+		}
+		workspaceID = workspace.ID
+	}
+
+	var nodes []*model.NoteNode
+	var err error
+	switch strings.ToLower(opts.Format) {
+	case FormatOPML:
+		nodes, err = convert.DecodeOPML(r)
+	case FormatDOCX:
+		var data []byte
+		data, err = io.ReadAll(io.LimitReader(r, convert.MaxDOCXSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > convert.MaxDOCXSize {
+			return nil, fmt.Errorf("%w: document is larger than %d bytes", ErrInvalidImport, convert.MaxDOCXSize)
+		}
+		nodes, err = convert.DecodeDOCX(data, convert.DOCXImportOptions{
+			Title:      opts.Title,
+			SplitLevel: opts.SplitLevel,
+		})
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, errors.Join(ErrInvalidImport, err)
+	}
+
+	for _, node := range nodes {
+		node.Walk(func(n *model.NoteNode, _ int) {
+			n.UserID = ownerID
+			n.WorkspaceID = workspaceID
+		})
+	}
+
+	if err := s.noteRepo.CreateTree(ctx, opts.ParentID, nodes); err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		node.Walk(func(n *model.NoteNode, _ int) {
+			created, err := s.noteRepo.GetByID(ctx, n.ID)
+			if err != nil {
+				created = n.Note
+			}
+			s.events.Publish(ctx, NoteEvent{Type: NoteCreated, Note: created})
+		})
+	}
+
+	notes := make([]*model.Note, 0, len(nodes))
+	for _, node := range nodes {
+		notes = append(notes, node.Note)
+	}
+	return notes, nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrEmbedNotFound = errors.New("embedded note not found")
+)
+
+// maxEmbedDepth is how deep embeds inside embedded notes are expanded
+const maxEmbedDepth = 5
+
+// LinkService keeps track of the [[wiki links]] and ![[embeds]] between
+// notes and resolves embeds. The target of a link is the note with its title
+// among the notes of the linking note's user; it is only embedded for users
+// who may read it.
+type LinkService interface {
+	ResolveEmbed(ctx context.Context, from *model.Note, target string, userID int64) (*model.Note, string, error)
+	Expand(ctx context.Context, note *model.Note, userID int64) string
+	EmbeddedBy(ctx context.Context, id int64, userID int64) ([]*model.EmbeddingNote, error)
+}
+
+type linkService struct {
+	linkRepo     repo.NoteLinkRepo
+	noteRepo     repo.NoteRepo
+	noteService  NoteService
+	blockService BlockService
+}
+
+func NewLinkService(
+	linkRepo repo.NoteLinkRepo,
+	noteRepo repo.NoteRepo,
+	noteService NoteService,
+	blockService BlockService,
+	events *NoteEvents,
+) LinkService {
+	s := &linkService{
+		linkRepo:     linkRepo,
+		noteRepo:     noteRepo,
+		noteService:  noteService,
+		blockService: blockService,
+	}
+	events.Subscribe("links", s.onNoteEvent)
+	return s
+}
+
+// ResolveEmbed returns the note an embed in from points at and the markdown
+// to embed: the whole note without front-matter, the section under a
+// heading, or a single block. It fails with ErrEmbedNotFound when there is
+// no such note or the user may not read it.
+func (s *linkService) ResolveEmbed(ctx context.Context, from *model.Note, target string, userID int64) (*model.Note, string, error) {
+	title, fragment, _ := strings.Cut(target, "#")
+	linked, err := s.noteRepo.GetByTitle(ctx, from.UserID, strings.TrimSpace(title))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", ErrEmbedNotFound
+		}
+		return nil, "", err
+	}
+	if _, err := s.noteService.Authorize(ctx, linked.ID, userID, model.NoteRoleViewer); err != nil {
+		if err == ErrNoteUnauthorized || err == ErrNoteNotFound {
+			return nil, "", ErrEmbedNotFound
+		}
+		return nil, "", err
+	}
+	if linked.IsEncryptedNote() {
+		return nil, "", ErrNoteEncrypted
+	}
+
+	content, err := s.fragment(ctx, linked, strings.TrimSpace(fragment))
+	if err != nil {
+		return nil, "", err
+	}
+	return linked, content, nil
+}
+
+// fragment returns the part of the note's content named by fragment
+func (s *linkService) fragment(ctx context.Context, note *model.Note, fragment string) (string, error) {
+	if fragment == "" {
+		_, body := markdown.SplitFrontMatter([]byte(note.Content))
+		return string(body), nil
+	}
+
+	blocks, err := s.blockService.Blocks(ctx, note)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(note.Content, "\n")
+
+	// A block runs until the next one starts
+	if blockID, ok := strings.CutPrefix(fragment, "^"); ok {
+		for i, b := range blocks {
+			if b.BlockID != blockID {
+				continue
+			}
+			end := len(lines)
+			if i+1 < len(blocks) {
+				end = max(blocks[i+1].Line-1, b.Line)
+			}
+			return strings.TrimSpace(strings.Join(lines[b.Line-1:end], "\n")), nil
+		}
+		return "", ErrEmbedNotFound
+	}
+
+	// A section runs until the next heading of the same or a higher level
+	slug := markdown.Slug(fragment)
+	for i, b := range blocks {
+		if b.Type != markdown.BlockHeading || markdown.Slug(b.Text) != slug {
+			continue
+		}
+		end := len(lines)
+		for _, next := range blocks[i+1:] {
+			if next.Type == markdown.BlockHeading && next.Level <= b.Level {
+				end = next.Line - 1
+				break
+			}
+		}
+		return strings.TrimSpace(strings.Join(lines[b.Line-1:end], "\n")), nil
+	}
+	return "", ErrEmbedNotFound
+}
+
+// Expand returns the content of the note with the embeds that stand on
+// their own lines replaced by the markdown they embed, for outputs that are
+// not rendered by the server. Embeds that cannot be resolved for the user,
+// that would repeat a note being expanded, or that are nested too deep stay
+// as they are.
+func (s *linkService) Expand(ctx context.Context, note *model.Note, userID int64) string {
+	return s.expand(ctx, note, note.Content, userID, []int64{note.ID})
+}
+
+func (s *linkService) expand(ctx context.Context, note *model.Note, content string, userID int64, path []int64) string {
+	embeds := markdown.ExtractEmbeds([]byte(content))
+	if len(embeds) == 0 || len(path) > maxEmbedDepth {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	for _, e := range embeds {
+		line := strings.TrimSpace(lines[e.Line-1])
+		if !strings.HasPrefix(line, "![[") || !strings.HasSuffix(line, "]]") {
+			continue
+		}
+		linked, embedded, err := s.ResolveEmbed(ctx, note, e.Target, userID)
+		if err != nil || containsID(path, linked.ID) {
+			continue
+		}
+		next := append(append([]int64(nil), path...), linked.ID)
+		lines[e.Line-1] = strings.TrimRight(s.expand(ctx, linked, embedded, userID, next), "\n")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// EmbeddedBy returns the notes the user may read that embed the note,
+// directly or through other embedding notes, nearest first
+func (s *linkService) EmbeddedBy(ctx context.Context, id int64, userID int64) ([]*model.EmbeddingNote, error) {
+	note, err := s.noteService.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.EmbeddingNote, 0)
+	seen := map[int64]bool{note.ID: true}
+	level := []*model.Note{note}
+	for depth := 1; depth <= maxEmbedDepth && len(level) > 0; depth++ {
+		var next []*model.Note
+		for _, target := range level {
+			sources, err := s.linkRepo.GetSources(ctx, target.UserID, target.Title, model.NoteLinkEmbed)
+			if err != nil {
+				return nil, err
+			}
+			for _, source := range sources {
+				if seen[source.ID] {
+					continue
+				}
+				seen[source.ID] = true
+				if _, err := s.noteService.Authorize(ctx, source.ID, userID, model.NoteRoleViewer); err != nil {
+					if err == ErrNoteUnauthorized {
+						continue
+					}
+					return nil, err
+				}
+				next = append(next, source)
+				result = append(result, &model.EmbeddingNote{Note: *source, Depth: depth})
+			}
+		}
+		level = next
+	}
+
+	return result, nil
+}
+
+// onNoteEvent keeps the links table in sync with note content
+func (s *linkService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated:
+		if e.Previous != nil && e.Previous.Content == e.Note.Content {
+			return nil
+		}
+		// Links cannot be read out of an encrypted note
+		if e.Note.IsEncryptedNote() {
+			return s.linkRepo.DeleteByNoteID(ctx, e.Note.ID)
+		}
+		return s.syncLinks(ctx, e.Note)
+	case NoteDeleted:
+		return s.linkRepo.DeleteByNoteID(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+func (s *linkService) syncLinks(ctx context.Context, note *model.Note) error {
+	extracted := markdown.ExtractLinks([]byte(note.Content))
+	links := make([]*model.NoteLink, 0, len(extracted))
+	for _, l := range extracted {
+		kind := model.NoteLinkLink
+		if l.Embed {
+			kind = model.NoteLinkEmbed
+		}
+		links = append(links, &model.NoteLink{
+			NoteID:      note.ID,
+			TargetTitle: l.Title,
+			Fragment:    l.Fragment,
+			Kind:        kind,
+		})
+	}
+
+	return s.linkRepo.Save(ctx, note.ID, links)
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrInvalidMerge = errors.New("invalid merge")
+	ErrInvalidSplit = errors.New("invalid split")
+)
+
+const (
+	minSplitLevel = 1
+	maxSplitLevel = 6
+)
+
+// MergeService combines notes into one and breaks notes into several, each
+// change written in one transaction.
+//
+// A merge keeps one of the notes, which gets the content of all of them in
+// the chosen order. The others go to the trash rather than being deleted,
+// so each keeps its own content, properties and tasks and can be restored.
+// Their children move under the kept note, and the links that led to them
+// lead to it instead.
+//
+// A split turns the sections of a note below its headings of one level into
+// child notes, and leaves a link to each where its section was.
+type MergeService interface {
+	Merge(ctx context.Context, targetID int64, ids []int64, userID int64) (*model.Note, error)
+	Split(ctx context.Context, id int64, level int, userID int64) (*model.Note, []*model.Note, error)
+}
+
+type mergeService struct {
+	noteRepo    repo.NoteRepo
+	linkRepo    repo.NoteLinkRepo
+	noteService NoteService
+	events      *NoteEvents
+}
+
+func NewMergeService(noteRepo repo.NoteRepo, linkRepo repo.NoteLinkRepo, noteService NoteService, events *NoteEvents) MergeService {
+	return &mergeService{
+		noteRepo:    noteRepo,
+		linkRepo:    linkRepo,
+		noteService: noteService,
+		events:      events,
+	}
+}
+
+// Merge merges the notes of ids, in that order, into the note targetID,
+// which must be one of them, and returns the merged note. The user must be
+// able to edit all of them, and they must belong to the same user and
+// workspace.
+func (s *mergeService) Merge(ctx context.Context, targetID int64, ids []int64, userID int64) (*model.Note, error) {
+	if len(ids) < 2 {
+		return nil, fmt.Errorf("%w: at least two notes are required", ErrInvalidMerge)
+	}
+	seen := make(map[int64]bool, len(ids))
+	var target *model.Note
+	notes := make([]*model.Note, 0, len(ids))
+	sources := make([]*model.Note, 0, len(ids)-1)
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: note %d is listed twice", ErrInvalidMerge, id)
+		}
+		seen[id] = true
+
+		note, err := s.noteService.Authorize(ctx, id, userID, model.NoteRoleEditor)
+		if err != nil {
+			return nil, err
+		}
+		if note.IsEncryptedNote() {
+			return nil, ErrNoteEncrypted
+		}
+		if !note.IsNormal() {
+			return nil, fmt.Errorf("%w: note %d is in the trash", ErrInvalidMerge, id)
+		}
+		notes = append(notes, note)
+		if id == targetID {
+			target = note
+		} else {
+			sources = append(sources, note)
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("%w: the kept note must be one of the merged notes", ErrInvalidMerge)
+	}
+
+	for _, source := range sources {
+		if source.UserID != target.UserID || source.WorkspaceID != target.WorkspaceID {
+			return nil, fmt.Errorf("%w: notes of different users or workspaces cannot be merged", ErrInvalidMerge)
+		}
+	}
+	if err := s.checkNotBelow(ctx, target, seen); err != nil {
+		return nil, err
+	}
+
+	// The kept note's front-matter stays on top
+	_, body := markdown.SplitFrontMatter([]byte(target.Content))
+	content := ""
+	for _, note := range notes {
+		content = appendSection(content, target.Title, note)
+	}
+	content = target.Content[:len(target.Content)-len(body)] + content
+
+	// Links by title reach the most recently updated note of the title, so
+	// only the titles leading to a source are redirected
+	titles := make(map[string]string)
+	for _, source := range sources {
+		if strings.EqualFold(source.Title, target.Title) {
+			continue
+		}
+		resolved, err := s.noteRepo.GetByTitle(ctx, source.UserID, source.Title)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if resolved != nil && resolved.ID == source.ID {
+			titles[strings.ToLower(strings.TrimSpace(source.Title))] = target.Title
+		}
+	}
+	content = string(markdown.RewriteLinks([]byte(content), titles))
+
+	linking, err := s.linkingNotes(ctx, sources, titles, seen, userID)
+	if err != nil {
+		return nil, err
+	}
+	relinked := make([]repo.NoteContent, 0, len(linking))
+	for _, n := range linking {
+		rewritten := string(markdown.RewriteLinks([]byte(n.Content), titles))
+		if rewritten != n.Content {
+			relinked = append(relinked, repo.NoteContent{ID: n.ID, Content: rewritten})
+		}
+	}
+
+	children := make([]*model.Note, 0)
+	for _, source := range sources {
+		list, err := s.noteRepo.GetByParentID(ctx, sql.NullInt64{Int64: source.ID, Valid: true}, source.WorkspaceID, 0, model.NoteStatusNormal)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, list...)
+	}
+
+	sourceIDs := make([]int64, 0, len(sources))
+	for _, source := range sources {
+		sourceIDs = append(sourceIDs, source.ID)
+	}
+	err = s.noteRepo.Merge(ctx, &repo.NoteMerge{
+		TargetID:  target.ID,
+		Content:   content,
+		SourceIDs: sourceIDs,
+		Relinked:  relinked,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := s.publish(ctx, target.ID, target, NoteUpdated)
+	for _, source := range sources {
+		s.publish(ctx, source.ID, nil, NoteTrashed)
+	}
+	for _, child := range children {
+		s.publish(ctx, child.ID, child, NoteMoved)
+	}
+	for _, n := range linking {
+		s.publish(ctx, n.ID, n, NoteUpdated)
+	}
+	if merged == nil {
+		return target, nil
+	}
+	return merged, nil
+}
+
+// Split turns the sections of the note at its headings of the given level
+// into child notes titled by the headings, after the note's children. The
+// note keeps its content outside those sections and a link to each new
+// note. It returns the note and the new notes.
+func (s *mergeService) Split(ctx context.Context, id int64, level int, userID int64) (*model.Note, []*model.Note, error) {
+	if level < minSplitLevel || level > maxSplitLevel {
+		return nil, nil, fmt.Errorf("%w: level must be between %d and %d", ErrInvalidSplit, minSplitLevel, maxSplitLevel)
+	}
+
+	note, err := s.noteService.Authorize(ctx, id, userID, model.NoteRoleEditor)
+	if err != nil {
+		return nil, nil, err
+	}
+	if note.IsEncryptedNote() {
+		return nil, nil, ErrNoteEncrypted
+	}
+	if !note.IsNormal() {
+		return nil, nil, fmt.Errorf("%w: note is in the trash", ErrInvalidSplit)
+	}
+
+	src := []byte(note.Content)
+	sections := markdown.SplitSections(src, level)
+	if len(sections) == 0 {
+		return nil, nil, fmt.Errorf("%w: note has no heading of level %d", ErrInvalidSplit, level)
+	}
+
+	// Links reach notes by title, so each new note needs its own
+	used := make(map[string]int)
+	children := make([]*model.Note, 0, len(sections))
+	var sb strings.Builder
+	pos := 0
+	for _, section := range sections {
+		title := section.Title
+		if title == "" {
+			title = note.Title
+		}
+		used[strings.ToLower(title)]++
+		if n := used[strings.ToLower(title)]; n > 1 {
+			title = fmt.Sprintf("%s (%d)", title, n)
+		}
+
+		children = append(children, &model.Note{
+			ParentID:    model.NullInt64{NullInt64: sql.NullInt64{Int64: note.ID, Valid: true}},
+			UserID:      note.UserID,
+			WorkspaceID: note.WorkspaceID,
+			Title:       title,
+			Content:     strings.Trim(string(section.Body), "\n") + "\n",
+			IsFavorite:  model.NoteFavoriteNo,
+			Status:      model.NoteStatusNormal,
+		})
+
+		sb.Write(src[pos:section.Start])
+		sb.WriteString("[[" + title + "]]\n")
+		if section.End < len(src) {
+			sb.WriteString("\n")
+		}
+		pos = section.End
+	}
+	sb.Write(src[pos:])
+
+	if err := s.noteRepo.Split(ctx, note.ID, sb.String(), children); err != nil {
+		return nil, nil, err
+	}
+
+	updated := s.publish(ctx, note.ID, note, NoteUpdated)
+	if updated == nil {
+		updated = note
+	}
+	created := make([]*model.Note, 0, len(children))
+	for _, child := range children {
+		if n := s.publish(ctx, child.ID, nil, NoteCreated); n != nil {
+			child = n
+		}
+		created = append(created, child)
+	}
+	return updated, created, nil
+}
+
+// checkNotBelow fails if the target is below one of the merged notes, whose
+// children would become its ancestors
+func (s *mergeService) checkNotBelow(ctx context.Context, target *model.Note, merged map[int64]bool) error {
+	parentID := target.ParentID
+	for i := 0; parentID.Valid && i < maxBreadcrumbDepth; i++ {
+		if merged[parentID.Int64] {
+			return fmt.Errorf("%w: note %d is below a note merged into it", ErrInvalidMerge, target.ID)
+		}
+		parent, err := s.noteRepo.GetByID(ctx, parentID.Int64)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return err
+		}
+		parentID = parent.ParentID
+	}
+	return nil
+}
+
+// linkingNotes returns the notes the user may edit that link to or embed a
+// source by one of the redirected titles, other than the merged notes
+func (s *mergeService) linkingNotes(ctx context.Context, sources []*model.Note, titles map[string]string, merged map[int64]bool, userID int64) ([]*model.Note, error) {
+	found := make(map[int64]bool)
+	notes := make([]*model.Note, 0)
+	for _, source := range sources {
+		if _, ok := titles[strings.ToLower(strings.TrimSpace(source.Title))]; !ok {
+			continue
+		}
+		for _, kind := range []string{model.NoteLinkLink, model.NoteLinkEmbed} {
+			list, err := s.linkRepo.GetSources(ctx, source.UserID, source.Title, kind)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range list {
+				if merged[n.ID] || found[n.ID] || n.IsEncryptedNote() {
+					continue
+				}
+				found[n.ID] = true
+
+				// Links in notes the user may not edit are left as they are
+				if _, err := s.noteService.Authorize(ctx, n.ID, userID, model.NoteRoleEditor); err != nil {
+					if err == ErrNoteUnauthorized {
+						continue
+					}
+					return nil, err
+				}
+				notes = append(notes, n)
+			}
+		}
+	}
+	return notes, nil
+}
+
+// publish re-reads a written note and announces the change to it
+func (s *mergeService) publish(ctx context.Context, id int64, previous *model.Note, typ NoteEventType) *model.Note {
+	note, err := s.noteRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	s.events.Publish(ctx, NoteEvent{Type: typ, Note: note, Previous: previous})
+	return note
+}
+
+// appendSection appends the body of a merged note to content. Its
+// front-matter is dropped, and a heading gives its title when it differs
+// from the kept one.
+func appendSection(content string, title string, note *model.Note) string {
+	_, body := markdown.SplitFrontMatter([]byte(note.Content))
+	section := strings.Trim(string(body), "\n")
+	if !strings.EqualFold(strings.TrimSpace(note.Title), strings.TrimSpace(title)) && strings.TrimSpace(note.Title) != "" {
+		section = strings.TrimRight("## "+strings.TrimSpace(note.Title)+"\n\n"+section, "\n")
+	}
+	if section == "" {
+		return content
+	}
+	if content == "" {
+		return section + "\n"
+	}
+	return strings.TrimRight(content, "\n") + "\n\n" + section + "\n"
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+)
+
+// NoteEventType names a change to a note
+type NoteEventType string
+
+const (
+	NoteCreated   NoteEventType = "note.created"
+	NoteUpdated   NoteEventType = "note.updated"
+	NoteTrashed   NoteEventType = "note.trashed"
+	NoteRestored  NoteEventType = "note.restored"
+	NoteDeleted   NoteEventType = "note.deleted"
+	NoteMoved     NoteEventType = "note.moved"
+	NoteFavorited NoteEventType = "note.favorited"
+)
+
+// NoteEvent describes a change to a note after it has been written
+type NoteEvent struct {
+	Type NoteEventType
+	// Note is the note as it is after the change, or as it was before it was
+	// deleted
+	Note *model.Note
+	// Previous is the note before an update or move, nil for other events
+	Previous *model.Note
+	At       time.Time
+}
+
+// NoteListener reacts to note changes. Errors are logged and do not fail the
+// change that triggered the event.
+type NoteListener func(ctx context.Context, e NoteEvent) error
+
+type noteListener struct {
+	name string
+	fn   NoteListener
+}
+
+// NoteEvents dispatches note changes to the features that derive data from
+// notes, such as properties, tasks and webhooks. Listeners run synchronously
+// in the order they subscribed.
+type NoteEvents struct {
+	mu        sync.RWMutex
+	listeners []noteListener
+}
+
+func NewNoteEvents() *NoteEvents {
+	return &NoteEvents{}
+}
+
+// Subscribe registers fn under name, which identifies it in logs
+func (b *NoteEvents) Subscribe(name string, fn NoteListener) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, noteListener{name: name, fn: fn})
+}
+
+// Publish notifies every listener of e
+func (b *NoteEvents) Publish(ctx context.Context, e NoteEvent) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	b.mu.RLock()
+	listeners := b.listeners
+	b.mu.RUnlock()
+
+	for _, l := range listeners {
+		if err := l.fn(ctx, e); err != nil {
+			infra.Errorf("note listener %s failed on %s of note %d: %v", l.name, e.Type, e.Note.ID, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 
+	"github.com/ray-d-song/yan/internal/infra"
 	"github.com/ray-d-song/yan/internal/model"
 	"github.com/ray-d-song/yan/internal/repo"
 )
@@ -15,11 +16,19 @@ var (
 	ErrInvalidParentNote = errors.New("invalid parent note")
 )
 
+// NoteService manages notes. Besides the owner, users the note or one of its
+// ancestors is shared with may use it as far as their role allows: viewers
+// and commenters read, editors also write. Deleting a note for good and
+// marking it favorite are left to the owner.
 type NoteService interface {
+	Authorize(ctx context.Context, id int64, userID int64, role string) (*model.Note, error)
+	RoleOf(ctx context.Context, note *model.Note, userID int64) (string, error)
 	GetByID(ctx context.Context, id int64, userID int64) (*model.Note, error)
 	GetByUserID(ctx context.Context, userID int64, status int) ([]*model.Note, error)
 	GetByParentID(ctx context.Context, parentID sql.NullInt64, userID int64, status int) ([]*model.Note, error)
 	GetFavorites(ctx context.Context, userID int64) ([]*model.Note, error)
+	ListScope(ctx context.Context, userID int64) (int64, int64, error)
+	GetTree(ctx context.Context, id int64, userID int64) (*model.NoteNode, error)
 	Create(ctx context.Context, n *model.Note) error
 	Update(ctx context.Context, n *model.Note, userID int64) error
 	Trash(ctx context.Context, id int64, userID int64) error
@@ -30,146 +39,361 @@ type NoteService interface {
 }
 
 type noteService struct {
-	noteRepo repo.NoteRepo
+	noteRepo         repo.NoteRepo
+	permissionRepo   repo.NotePermissionRepo
+	workspaceService WorkspaceService
+	events           *NoteEvents
 }
 
-func NewNoteService(noteRepo repo.NoteRepo) NoteService {
+func NewNoteService(
+	noteRepo repo.NoteRepo,
+	permissionRepo repo.NotePermissionRepo,
+	workspaceService WorkspaceService,
+	events *NoteEvents,
+) NoteService {
 	return &noteService{
-		noteRepo: noteRepo,
+		noteRepo:         noteRepo,
+		permissionRepo:   permissionRepo,
+		workspaceService: workspaceService,
+		events:           events,
 	}
 }
 
-func (s *noteService) GetByID(ctx context.Context, id int64, userID int64) (*model.Note, error) {
+// Authorize returns the note if the user's role on it is at least role
+func (s *noteService) Authorize(ctx context.Context, id int64, userID int64, role string) (*model.Note, error) {
+	note, _, err := s.authorize(ctx, id, userID, role)
+	return note, err
+}
+
+// authorize is Authorize that also returns the user's effective role
+func (s *noteService) authorize(ctx context.Context, id int64, userID int64, role string) (*model.Note, string, error) {
 	note, err := s.noteRepo.GetByID(ctx, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, ErrNoteNotFound
+			return nil, "", ErrNoteNotFound
 		}
-		return nil, err
+		return nil, "", err
 	}
 
-	// Check if the user owns this note
-	if note.UserID != userID {
-		return nil, ErrNoteUnauthorized
+	effective, err := s.RoleOf(ctx, note, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !model.NoteRoleAllows(effective, role) {
+		return nil, "", ErrNoteUnauthorized
 	}
 
-	return note, nil
+	return note, effective, nil
 }
 
+// RoleOf returns the user's effective role on the note: owner for the note's
+// user, otherwise the highest of the role their workspace membership gives
+// and the roles granted on the note or an ancestor. It fails with
+// ErrNoteUnauthorized when the user has no access.
+func (s *noteService) RoleOf(ctx context.Context, note *model.Note, userID int64) (string, error) {
+	if note.UserID == userID {
+		return model.NoteRoleOwner, nil
+	}
+
+	memberRole, err := s.workspaceService.MemberRole(ctx, note.WorkspaceID, userID)
+	if err != nil {
+		return "", err
+	}
+	role := model.WorkspaceNoteRole(memberRole)
+	if role == model.NoteRoleOwner {
+		return role, nil
+	}
+
+	roles, err := s.permissionRepo.GetInheritedRoles(ctx, note.ID, userID)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range roles {
+		role = model.HigherNoteRole(role, r)
+	}
+	if role == "" {
+		return "", ErrNoteUnauthorized
+	}
+
+	return role, nil
+}
+
+func (s *noteService) GetByID(ctx context.Context, id int64, userID int64) (*model.Note, error) {
+	return s.Authorize(ctx, id, userID, model.NoteRoleViewer)
+}
+
+// GetByUserID returns the notes of the user's current workspace. Guests only
+// get their own; the notes shared with them are listed by ShareService.
 func (s *noteService) GetByUserID(ctx context.Context, userID int64, status int) ([]*model.Note, error) {
-	return s.noteRepo.GetByUserID(ctx, userID, status)
+	workspaceID, ownerID, err := s.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.noteRepo.GetByWorkspaceID(ctx, workspaceID, ownerID, status)
 }
 
 func (s *noteService) GetByParentID(ctx context.Context, parentID sql.NullInt64, userID int64, status int) ([]*model.Note, error) {
-	// If parentID is valid, check if the parent note exists and belongs to the user
+	// If parentID is valid, the user must be able to read the parent, and
+	// with it all of its children
 	if parentID.Valid {
-		parentNote, err := s.noteRepo.GetByID(ctx, parentID.Int64)
+		parentNote, err := s.Authorize(ctx, parentID.Int64, userID, model.NoteRoleViewer)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if err == ErrNoteNotFound {
 				return nil, ErrInvalidParentNote
 			}
 			return nil, err
 		}
+		return s.noteRepo.GetByParentID(ctx, parentID, parentNote.WorkspaceID, 0, status)
+	}
 
-		if parentNote.UserID != userID {
-			return nil, ErrNoteUnauthorized
-		}
+	workspaceID, ownerID, err := s.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.noteRepo.GetByParentID(ctx, parentID, userID, status)
+	return s.noteRepo.GetByParentID(ctx, parentID, workspaceID, ownerID, status)
 }
 
 func (s *noteService) GetFavorites(ctx context.Context, userID int64) ([]*model.Note, error) {
-	return s.noteRepo.GetFavorites(ctx, userID)
+	workspaceID, ownerID, err := s.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.noteRepo.GetFavorites(ctx, workspaceID, ownerID)
+}
+
+// ListScope returns the workspace whose notes the user lists, and the user
+// to restrict the listing to, or 0 when the user sees every note of it
+func (s *noteService) ListScope(ctx context.Context, userID int64) (int64, int64, error) {
+	workspace, err := s.workspaceService.Current(ctx, userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if model.WorkspaceRoleAllows(workspace.Role, model.WorkspaceRoleMember) {
+		return workspace.ID, 0, nil
+	}
+
+	return workspace.ID, userID, nil
 }
 
+func (s *noteService) GetTree(ctx context.Context, id int64, userID int64) (*model.NoteNode, error) {
+	// Check if note exists and the user may read it
+	root, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	descendants, err := s.noteRepo.GetDescendants(ctx, id, model.NoteStatusNormal)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.BuildNoteTree(root, descendants), nil
+}
+
+// Create creates the note as n.UserID, below a parent the user may edit or
+// at the top of their current workspace. A note created in a tree shared
+// with a user who is not a member of its workspace belongs to the tree's
+// owner.
 func (s *noteService) Create(ctx context.Context, n *model.Note) error {
 	// If parent_id is provided, validate it
 	if n.ParentID.Valid {
-		parentNote, err := s.noteRepo.GetByID(ctx, n.ParentID.Int64)
+		parentNote, err := s.Authorize(ctx, n.ParentID.Int64, n.UserID, model.NoteRoleEditor)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if err == ErrNoteNotFound {
 				return ErrInvalidParentNote
 			}
 			return err
 		}
+		n.WorkspaceID = parentNote.WorkspaceID
 
-		// Parent note must belong to the same user
-		if parentNote.UserID != n.UserID {
+		memberRole, err := s.workspaceService.MemberRole(ctx, parentNote.WorkspaceID, n.UserID)
+		if err != nil {
+			return err
+		}
+		if !model.WorkspaceRoleAllows(memberRole, model.WorkspaceRoleMember) {
+			n.UserID = parentNote.UserID
+			// Favorites are the owner's own
+			n.IsFavorite = model.NoteFavoriteNo
+		}
+	} else {
+		workspace, err := s.workspaceService.Current(ctx, n.UserID)
+		if err != nil {
+			return err
+		}
+		// Guests only work in what is shared with them
+		if !model.WorkspaceRoleAllows(workspace.Role, model.WorkspaceRoleMember) {
 			return ErrNoteUnauthorized
 		}
+		n.WorkspaceID = workspace.ID
+	}
+
+	if err := s.noteRepo.Create(ctx, n); err != nil {
+		return err
 	}
 
-	return s.noteRepo.Create(ctx, n)
+	s.publish(ctx, n.ID, nil, NoteCreated)
+	return nil
 }
 
 func (s *noteService) Update(ctx context.Context, n *model.Note, userID int64) error {
-	// Check if note exists and belongs to user
-	existingNote, err := s.GetByID(ctx, n.ID, userID)
+	// Check if note exists and the user may edit it
+	existingNote, role, err := s.authorize(ctx, n.ID, userID, model.NoteRoleEditor)
 	if err != nil {
 		return err
 	}
+	isOwner := role == model.NoteRoleOwner
+
+	// Only the note's user holds the key to encrypted content
+	if existingNote.IsEncryptedNote() && n.Content != existingNote.Content && userID != existingNote.UserID {
+		return ErrNoteUnauthorized
+	}
 
-	// Keep the original user_id
+	// Keep the original user_id and workspace
 	n.UserID = existingNote.UserID
+	n.WorkspaceID = existingNote.WorkspaceID
+	if !isOwner {
+		n.IsFavorite = existingNote.IsFavorite
+	}
 
 	// If parent_id is being changed, validate it
-	if n.ParentID.Valid {
-		parentNote, err := s.noteRepo.GetByID(ctx, n.ParentID.Int64)
+	if n.ParentID.Valid && n.ParentID != existingNote.ParentID {
+		parentNote, err := s.Authorize(ctx, n.ParentID.Int64, userID, model.NoteRoleEditor)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if err == ErrNoteNotFound {
 				return ErrInvalidParentNote
 			}
 			return err
 		}
 
-		// Parent note must belong to the same user
-		if parentNote.UserID != userID {
-			return ErrNoteUnauthorized
+		// Parent note must be in the same workspace
+		if parentNote.WorkspaceID != existingNote.WorkspaceID {
+			return ErrInvalidParentNote
 		}
 
-		// Prevent circular reference (note cannot be its own parent)
+		// Prevent circular reference (note cannot be its own parent, nor
+		// below one of its descendants)
 		if n.ParentID.Int64 == n.ID {
 			return ErrInvalidParentNote
 		}
+		below, err := s.isBelow(ctx, parentNote, n.ID)
+		if err != nil {
+			return err
+		}
+		if below {
+			return ErrInvalidParentNote
+		}
 	}
 
-	return s.noteRepo.Update(ctx, n)
+	// Moving a note to the top level takes it out of what is shared, which
+	// is up to the owner and members of the workspace
+	if !n.ParentID.Valid && existingNote.ParentID.Valid && !isOwner {
+		memberRole, err := s.workspaceService.MemberRole(ctx, existingNote.WorkspaceID, userID)
+		if err != nil {
+			return err
+		}
+		if !model.WorkspaceRoleAllows(memberRole, model.WorkspaceRoleMember) {
+			return ErrNoteUnauthorized
+		}
+	}
+
+	if err := s.noteRepo.Update(ctx, n); err != nil {
+		return err
+	}
+
+	types := []NoteEventType{NoteUpdated}
+	if n.ParentID != existingNote.ParentID {
+		types = append(types, NoteMoved)
+	}
+	if n.Status != existingNote.Status {
+		if n.IsTrashed() {
+			types = append(types, NoteTrashed)
+		} else {
+			types = append(types, NoteRestored)
+		}
+	}
+	if n.IsFavorite != existingNote.IsFavorite {
+		types = append(types, NoteFavorited)
+	}
+	s.publish(ctx, n.ID, existingNote, types...)
+	return nil
+}
+
+// isBelow reports whether the note is the note ancestorID or below it
+func (s *noteService) isBelow(ctx context.Context, note *model.Note, ancestorID int64) (bool, error) {
+	if note.ID == ancestorID {
+		return true, nil
+	}
+	// The visited notes stop the walk at a broken, cyclic parent chain
+	visited := map[int64]bool{note.ID: true}
+	parentID := note.ParentID
+	for parentID.Valid && !visited[parentID.Int64] {
+		if parentID.Int64 == ancestorID {
+			return true, nil
+		}
+		visited[parentID.Int64] = true
+		parent, err := s.noteRepo.GetByID(ctx, parentID.Int64)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+			return false, err
+		}
+		parentID = parent.ParentID
+	}
+	return false, nil
 }
 
 func (s *noteService) Trash(ctx context.Context, id int64, userID int64) error {
-	// Check if note exists and belongs to user
-	_, err := s.GetByID(ctx, id, userID)
+	// Check if note exists and the user may edit it
+	_, err := s.Authorize(ctx, id, userID, model.NoteRoleEditor)
 	if err != nil {
 		return err
 	}
 
-	return s.noteRepo.UpdateStatus(ctx, id, model.NoteStatusTrashed)
+	if err := s.noteRepo.UpdateStatus(ctx, id, model.NoteStatusTrashed); err != nil {
+		return err
+	}
+
+	s.publish(ctx, id, nil, NoteTrashed)
+	return nil
 }
 
 func (s *noteService) Restore(ctx context.Context, id int64, userID int64) error {
-	// Check if note exists and belongs to user
-	_, err := s.GetByID(ctx, id, userID)
+	// Check if note exists and the user may edit it
+	_, err := s.Authorize(ctx, id, userID, model.NoteRoleEditor)
 	if err != nil {
 		return err
 	}
 
-	return s.noteRepo.UpdateStatus(ctx, id, model.NoteStatusNormal)
+	if err := s.noteRepo.UpdateStatus(ctx, id, model.NoteStatusNormal); err != nil {
+		return err
+	}
+
+	s.publish(ctx, id, nil, NoteRestored)
+	return nil
 }
 
 func (s *noteService) Delete(ctx context.Context, id int64, userID int64) error {
 	// Check if note exists and belongs to user
-	_, err := s.GetByID(ctx, id, userID)
+	note, err := s.Authorize(ctx, id, userID, model.NoteRoleOwner)
 	if err != nil {
 		return err
 	}
 
-	return s.noteRepo.Delete(ctx, id)
+	if err := s.noteRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.events.Publish(ctx, NoteEvent{Type: NoteDeleted, Note: note})
+	return nil
 }
 
 func (s *noteService) ToggleFavorite(ctx context.Context, id int64, userID int64) error {
 	// Check if note exists and belongs to user
-	note, err := s.GetByID(ctx, id, userID)
+	note, err := s.Authorize(ctx, id, userID, model.NoteRoleOwner)
 	if err != nil {
 		return err
 	}
@@ -180,15 +404,39 @@ func (s *noteService) ToggleFavorite(ctx context.Context, id int64, userID int64
 		newFavoriteStatus = model.NoteFavoriteYes
 	}
 
-	return s.noteRepo.UpdateFavorite(ctx, id, newFavoriteStatus)
+	if err := s.noteRepo.UpdateFavorite(ctx, id, newFavoriteStatus); err != nil {
+		return err
+	}
+
+	s.publish(ctx, id, nil, NoteFavorited)
+	return nil
 }
 
 func (s *noteService) UpdatePosition(ctx context.Context, id int64, position int, userID int64) error {
-	// Check if note exists and belongs to user
-	_, err := s.GetByID(ctx, id, userID)
+	// Check if note exists and the user may edit it
+	note, err := s.Authorize(ctx, id, userID, model.NoteRoleEditor)
 	if err != nil {
 		return err
 	}
 
-	return s.noteRepo.UpdatePosition(ctx, id, position)
+	if err := s.noteRepo.UpdatePosition(ctx, id, position); err != nil {
+		return err
+	}
+
+	s.publish(ctx, id, note, NoteMoved)
+	return nil
+}
+
+// publish re-reads the written note, so listeners see what is stored, and
+// announces the given changes to it
+func (s *noteService) publish(ctx context.Context, id int64, previous *model.Note, types ...NoteEventType) {
+	note, err := s.noteRepo.GetByID(ctx, id)
+	if err != nil {
+		infra.Errorf("failed to load note %d for %v events: %v", id, types, err)
+		return
+	}
+
+	for _, typ := range types {
+		s.events.Publish(ctx, NoteEvent{Type: typ, Note: note, Previous: previous})
+	}
 }
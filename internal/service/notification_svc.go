@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrNotificationNotFound     = errors.New("notification not found")
+	ErrNotificationUnauthorized = errors.New("unauthorized to access this notification")
+)
+
+type NotificationService interface {
+	List(ctx context.Context, userID int64, unreadOnly bool) ([]*model.Notification, error)
+	MarkRead(ctx context.Context, id int64, userID int64) error
+	MarkAllRead(ctx context.Context, userID int64) error
+}
+
+type notificationService struct {
+	notificationRepo repo.NotificationRepo
+}
+
+func NewNotificationService(notificationRepo repo.NotificationRepo) NotificationService {
+	return &notificationService{
+		notificationRepo: notificationRepo,
+	}
+}
+
+func (s *notificationService) List(ctx context.Context, userID int64, unreadOnly bool) ([]*model.Notification, error) {
+	return s.notificationRepo.GetByUserID(ctx, userID, unreadOnly)
+}
+
+func (s *notificationService) MarkRead(ctx context.Context, id int64, userID int64) error {
+	n, err := s.notificationRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+
+	// Check if the user owns this notification
+	if n.UserID != userID {
+		return ErrNotificationUnauthorized
+	}
+
+	return s.notificationRepo.MarkRead(ctx, id)
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID int64) error {
+	return s.notificationRepo.MarkAllRead(ctx, userID)
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrPropertyNotFound      = errors.New("property not found")
+	ErrInvalidProperty       = errors.New("invalid property")
+	ErrPropertyInFrontMatter = errors.New("property is defined in the note's front-matter")
+)
+
+// maxPropertyNameLength limits the length of property names
+const maxPropertyNameLength = 64
+
+// dateLayouts are the accepted forms of date property values; the first one
+// is also the canonical form of dates without a time
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04"}
+
+type PropertyService interface {
+	List(ctx context.Context, noteID int64, userID int64) ([]*model.NoteProperty, error)
+	Set(ctx context.Context, noteID int64, userID int64, p *model.NoteProperty) error
+	Delete(ctx context.Context, noteID int64, userID int64, name string) error
+	FilterNotes(ctx context.Context, userID int64, notes []*model.Note, filters map[string]string) ([]*model.Note, error)
+}
+
+type propertyService struct {
+	propertyRepo repo.PropertyRepo
+	noteService  NoteService
+}
+
+func NewPropertyService(propertyRepo repo.PropertyRepo, noteService NoteService, events *NoteEvents) PropertyService {
+	s := &propertyService{
+		propertyRepo: propertyRepo,
+		noteService:  noteService,
+	}
+	events.Subscribe("properties", s.onNoteEvent)
+	return s
+}
+
+func (s *propertyService) List(ctx context.Context, noteID int64, userID int64) ([]*model.NoteProperty, error) {
+	// Check if note exists and the user may read it
+	if _, err := s.noteService.GetByID(ctx, noteID, userID); err != nil {
+		return nil, err
+	}
+
+	return s.propertyRepo.GetByNoteID(ctx, noteID)
+}
+
+// Set creates or replaces a property of the note. Without a type, the type of
+// the existing property is kept or one is inferred from the value.
+func (s *propertyService) Set(ctx context.Context, noteID int64, userID int64, p *model.NoteProperty) error {
+	// Check if note exists and the user may edit it
+	if _, err := s.noteService.Authorize(ctx, noteID, userID, model.NoteRoleEditor); err != nil {
+		return err
+	}
+
+	existing, err := s.propertyRepo.GetByName(ctx, noteID, strings.TrimSpace(p.Name))
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if existing != nil {
+		// The next save of the content would overwrite it again
+		if existing.IsFromFrontMatter() {
+			return ErrPropertyInFrontMatter
+		}
+		if p.Type == "" {
+			p.Type = existing.Type
+		}
+	}
+	if p.Type == "" {
+		p.Type = inferPropertyType(p.Value)
+	}
+
+	p.NoteID = noteID
+	p.Source = model.PropertySourceAPI
+	if err := normalizeProperty(p); err != nil {
+		return err
+	}
+
+	return s.propertyRepo.Upsert(ctx, p)
+}
+
+func (s *propertyService) Delete(ctx context.Context, noteID int64, userID int64, name string) error {
+	// Check if note exists and the user may edit it
+	if _, err := s.noteService.Authorize(ctx, noteID, userID, model.NoteRoleEditor); err != nil {
+		return err
+	}
+
+	existing, err := s.propertyRepo.GetByName(ctx, noteID, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPropertyNotFound
+		}
+		return err
+	}
+	if existing.IsFromFrontMatter() {
+		return ErrPropertyInFrontMatter
+	}
+
+	return s.propertyRepo.Delete(ctx, noteID, name)
+}
+
+// FilterNotes keeps the notes whose properties equal every filter value.
+// Text values are compared case-insensitively and numbers numerically.
+func (s *propertyService) FilterNotes(ctx context.Context, userID int64, notes []*model.Note, filters map[string]string) ([]*model.Note, error) {
+	if len(filters) == 0 {
+		return notes, nil
+	}
+
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	repoFilters := make([]repo.PropertyFilter, 0, len(filters))
+	for _, name := range names {
+		f := repo.PropertyFilter{Name: name, Value: filters[name]}
+		if n, err := strconv.ParseFloat(f.Value, 64); err == nil {
+			f.Number = &n
+		}
+		repoFilters = append(repoFilters, f)
+	}
+
+	ids, err := s.propertyRepo.FindNoteIDs(ctx, userID, repoFilters)
+	if err != nil {
+		return nil, err
+	}
+	matched := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		matched[id] = true
+	}
+
+	filtered := make([]*model.Note, 0, len(ids))
+	for _, note := range notes {
+		if matched[note.ID] {
+			filtered = append(filtered, note)
+		}
+	}
+	return filtered, nil
+}
+
+// onNoteEvent keeps the front-matter properties in sync with the content
+func (s *propertyService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated:
+		if e.Previous != nil && e.Previous.Content == e.Note.Content {
+			return nil
+		}
+		return s.syncFrontMatter(ctx, e.Note)
+	case NoteDeleted:
+		return s.propertyRepo.DeleteByNoteID(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+func (s *propertyService) syncFrontMatter(ctx context.Context, note *model.Note) error {
+	// The front-matter of an encrypted note is not readable
+	if note.IsEncryptedNote() {
+		return s.propertyRepo.ReplaceBySource(ctx, note.ID, model.PropertySourceFrontMatter, nil)
+	}
+
+	values, err := markdown.ParseFrontMatter([]byte(note.Content))
+	if err != nil {
+		// Keep the last good properties while the front-matter is being edited
+		return fmt.Errorf("invalid front-matter: %w", err)
+	}
+
+	props := make([]*model.NoteProperty, 0, len(values))
+	for name, v := range values {
+		value, ok := frontMatterValue(v)
+		if !ok {
+			continue
+		}
+		p := &model.NoteProperty{Name: name, Type: inferPropertyType(value), Value: value}
+		if err := normalizeProperty(p); err != nil {
+			continue
+		}
+		props = append(props, p)
+	}
+
+	return s.propertyRepo.ReplaceBySource(ctx, note.ID, model.PropertySourceFrontMatter, props)
+}
+
+// frontMatterValue converts a decoded YAML scalar to property text. Lists and
+// maps are not properties.
+func frontMatterValue(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int, int64, uint64, float64:
+		return fmt.Sprint(v), true
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format(dateLayouts[0]), true
+		}
+		return v.Format(time.RFC3339), true
+	}
+	return "", false
+}
+
+// inferPropertyType guesses the type of a value given without one
+func inferPropertyType(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "true" || value == "false" {
+		return model.PropertyTypeCheckbox
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return model.PropertyTypeNumber
+	}
+	if _, ok := parsePropertyDate(value); ok {
+		return model.PropertyTypeDate
+	}
+	if u, err := url.Parse(value); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return model.PropertyTypeURL
+	}
+	return model.PropertyTypeText
+}
+
+// normalizeProperty validates the name, type and value of p and rewrites the
+// value into its canonical form
+func normalizeProperty(p *model.NoteProperty) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || len(p.Name) > maxPropertyNameLength || strings.ContainsFunc(p.Name, unicode.IsControl) {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidProperty, p.Name)
+	}
+
+	value := strings.TrimSpace(p.Value)
+	p.ValueNumber = sql.NullFloat64{}
+	switch p.Type {
+	case model.PropertyTypeText, model.PropertyTypeSelect:
+		p.Value = value
+	case model.PropertyTypeNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a number", ErrInvalidProperty, value)
+		}
+		p.Value = strconv.FormatFloat(n, 'f', -1, 64)
+		p.ValueNumber = sql.NullFloat64{Float64: n, Valid: true}
+	case model.PropertyTypeDate:
+		date, ok := parsePropertyDate(value)
+		if !ok {
+			return fmt.Errorf("%w: %q is not a date", ErrInvalidProperty, value)
+		}
+		p.Value = date
+	case model.PropertyTypeURL:
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return fmt.Errorf("%w: %q is not a URL", ErrInvalidProperty, value)
+		}
+		p.Value = value
+	case model.PropertyTypeCheckbox:
+		switch strings.ToLower(value) {
+		case "true", "yes", "on", "1", "x":
+			p.Value = "true"
+		case "false", "no", "off", "0", "":
+			p.Value = "false"
+		default:
+			return fmt.Errorf("%w: %q is not a checkbox value", ErrInvalidProperty, value)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidProperty, p.Type)
+	}
+	return nil
+}
+
+// parsePropertyDate returns value in canonical form if it is a date
+func parsePropertyDate(value string) (string, bool) {
+	for i, layout := range dateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if i == 0 {
+			return t.Format(dateLayouts[0]), true
+		}
+		return t.Format(time.RFC3339), true
+	}
+	return "", false
+}
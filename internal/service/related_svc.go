@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/ray-d-song/yan/internal/search"
+)
+
+const (
+	defaultRelatedLimit = 10
+	maxRelatedLimit     = 50
+	// relatedQueryTerms is how many of a note's most distinctive terms are
+	// looked up in other notes
+	relatedQueryTerms = 25
+	// relatedShownTerms is how many shared terms are returned with a note
+	relatedShownTerms = 5
+	// titleTermCount is how many times the terms of a title count, since a
+	// title says more about a note than a line of its content
+	titleTermCount = 3
+)
+
+// RelatedService finds the notes most similar to a note. Each note has a
+// vector of term counts, kept in sync with its title and content; a note's
+// terms weighted by TF-IDF are looked up in the other notes of the
+// workspace, which are ranked by BM25.
+type RelatedService interface {
+	IndexMissing(ctx context.Context) error
+	Related(ctx context.Context, noteID int64, userID int64, limit int) ([]*model.RelatedNote, error)
+}
+
+type relatedService struct {
+	noteTermRepo repo.NoteTermRepo
+	noteService  NoteService
+}
+
+func NewRelatedService(noteTermRepo repo.NoteTermRepo, noteService NoteService, events *NoteEvents) RelatedService {
+	s := &relatedService{
+		noteTermRepo: noteTermRepo,
+		noteService:  noteService,
+	}
+	events.Subscribe("related", s.onNoteEvent)
+	return s
+}
+
+// IndexMissing computes the term vectors of the notes without one, such as
+// the notes written before they existed
+func (s *relatedService) IndexMissing(ctx context.Context) error {
+	notes, err := s.noteTermRepo.GetUnindexed(ctx)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		if err := s.noteTermRepo.Save(ctx, note.ID, noteTerms(note)); err != nil {
+			return err
+		}
+	}
+	if len(notes) > 0 {
+		infra.Infof("Computed term vectors of %d notes", len(notes))
+	}
+	return nil
+}
+
+// Related returns the notes of the user's current workspace most similar to
+// the note, best first. Trashed notes are left out.
+func (s *relatedService) Related(ctx context.Context, noteID int64, userID int64, limit int) ([]*model.RelatedNote, error) {
+	if limit <= 0 {
+		limit = defaultRelatedLimit
+	}
+	if limit > maxRelatedLimit {
+		limit = maxRelatedLimit
+	}
+
+	note, err := s.noteService.GetByID(ctx, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	workspaceID, ownerID, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	related := make([]*model.RelatedNote, 0)
+	terms := noteTerms(note)
+	if len(terms) == 0 {
+		return related, nil
+	}
+
+	docs, avgLength, err := s.noteTermRepo.GetStats(ctx, workspaceID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, 0, len(terms))
+	for term := range terms {
+		list = append(list, term)
+	}
+	freqs, err := s.noteTermRepo.GetDocFrequencies(ctx, workspaceID, ownerID, list)
+	if err != nil {
+		return nil, err
+	}
+
+	// Weigh the note's terms by TF-IDF and keep the most distinctive. Terms
+	// no other note has cannot match, and terms most notes have say little.
+	self := 0
+	if note.IsNormal() && note.WorkspaceID == workspaceID &&
+		(ownerID == 0 || note.UserID == ownerID) {
+		self = 1
+	}
+	weights := make(map[string]float64, len(terms))
+	for _, term := range list {
+		df := freqs[term]
+		if df-self <= 0 {
+			continue
+		}
+		weights[term] = (1 + math.Log(float64(terms[term]))) * search.IDF(float64(df), float64(docs))
+	}
+	query := make([]string, 0, len(weights))
+	for term := range weights {
+		query = append(query, term)
+	}
+	sort.Slice(query, func(i, j int) bool {
+		if weights[query[i]] != weights[query[j]] {
+			return weights[query[i]] > weights[query[j]]
+		}
+		return query[i] < query[j]
+	})
+	if len(query) > relatedQueryTerms {
+		query = query[:relatedQueryTerms]
+	}
+
+	matches, err := s.noteTermRepo.GetMatches(ctx, workspaceID, ownerID, query, note.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	type termScore struct {
+		term  string
+		score float64
+	}
+	byNote := make(map[int64]*model.RelatedNote)
+	shared := make(map[int64][]termScore)
+	for _, m := range matches {
+		r, ok := byNote[m.NoteID]
+		if !ok {
+			r = &model.RelatedNote{
+				ID:       m.NoteID,
+				ParentID: m.ParentID,
+				Title:    m.Title,
+				Icon:     m.Icon,
+			}
+			byNote[m.NoteID] = r
+			related = append(related, r)
+		}
+		score := weights[m.Term] * search.TermWeight(float64(m.Count), float64(m.Length), avgLength)
+		r.Score += score
+		shared[m.NoteID] = append(shared[m.NoteID], termScore{term: m.Term, score: score})
+	}
+
+	sort.Slice(related, func(i, j int) bool {
+		if related[i].Score != related[j].Score {
+			return related[i].Score > related[j].Score
+		}
+		return related[i].ID < related[j].ID
+	})
+	if len(related) > limit {
+		related = related[:limit]
+	}
+
+	for _, r := range related {
+		ts := shared[r.ID]
+		sort.Slice(ts, func(i, j int) bool {
+			return ts[i].score > ts[j].score
+		})
+		r.Terms = make([]string, 0, relatedShownTerms)
+		for i := 0; i < len(ts) && i < relatedShownTerms; i++ {
+			r.Terms = append(r.Terms, ts[i].term)
+		}
+	}
+	return related, nil
+}
+
+// onNoteEvent keeps the term vectors in sync with note titles and content.
+// Trashed notes keep theirs for when they are restored, and are left out
+// when related notes are looked up.
+func (s *relatedService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated:
+		if e.Previous != nil && e.Previous.Title == e.Note.Title && e.Previous.Content == e.Note.Content &&
+			e.Previous.IsEncrypted == e.Note.IsEncrypted {
+			return nil
+		}
+		return s.noteTermRepo.Save(ctx, e.Note.ID, noteTerms(e.Note))
+	case NoteDeleted:
+		return s.noteTermRepo.DeleteByNoteID(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+// noteTerms returns the term vector of the note. The content of an
+// encrypted note is ciphertext and is left out.
+func noteTerms(note *model.Note) map[string]int {
+	terms := make(map[string]int)
+	if !note.IsEncryptedNote() {
+		terms = search.Terms(note.Content)
+	}
+	for term, count := range search.Terms(note.Title) {
+		terms[term] += count * titleTermCount
+	}
+	return terms
+}
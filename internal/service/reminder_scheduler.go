@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/notify"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+const (
+	reminderPollInterval = 15 * time.Second
+	// reminderLease must outlast a delivery attempt, or a slow delivery could
+	// be claimed a second time
+	reminderLease       = 2 * time.Minute
+	reminderSendTimeout = 30 * time.Second
+	reminderBatchSize   = 50
+	reminderMaxAttempts = 8
+	reminderBaseBackoff = 30 * time.Second
+	reminderMaxBackoff  = time.Hour
+	// reminderExcerptLength limits the note text included in a reminder
+	reminderExcerptLength = 280
+)
+
+// ReminderScheduler delivers due reminders through the notifier of their
+// channel. Delivery state lives in the database, so a restart neither loses
+// nor repeats reminders: sent reminders are never claimed again, and a
+// reminder claimed by a process that died is retried once its lease expires.
+type ReminderScheduler struct {
+	reminderRepo repo.ReminderRepo
+	noteRepo     repo.NoteRepo
+	taskRepo     repo.TaskRepo
+	userService  UserService
+	baseURL      string
+	notifiers    map[string]notify.Notifier
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewReminderScheduler(
+	reminderRepo repo.ReminderRepo,
+	noteRepo repo.NoteRepo,
+	taskRepo repo.TaskRepo,
+	userService UserService,
+	config *infra.Config,
+	notifiers []notify.Notifier,
+) *ReminderScheduler {
+	byChannel := make(map[string]notify.Notifier, len(notifiers))
+	for _, n := range notifiers {
+		byChannel[n.Channel()] = n
+	}
+	return &ReminderScheduler{
+		reminderRepo: reminderRepo,
+		noteRepo:     noteRepo,
+		taskRepo:     taskRepo,
+		userService:  userService,
+		baseURL:      strings.TrimSuffix(config.App.BaseURL, "/"),
+		notifiers:    byChannel,
+	}
+}
+
+// Start begins polling for due reminders in the background
+func (s *ReminderScheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx)
+}
+
+// Stop ends polling and waits for the delivery in progress
+func (s *ReminderScheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *ReminderScheduler) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(reminderPollInterval)
+	defer ticker.Stop()
+	for {
+		s.fireDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ReminderScheduler) fireDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		reminders, err := s.reminderRepo.ClaimDue(ctx, now, reminderLease, reminderBatchSize)
+		if err != nil {
+			infra.Errorf("failed to claim due reminders: %v", err)
+			return
+		}
+		for _, r := range reminders {
+			s.deliver(ctx, r)
+		}
+		if len(reminders) < reminderBatchSize {
+			return
+		}
+	}
+}
+
+func (s *ReminderScheduler) deliver(ctx context.Context, r *model.Reminder) {
+	// Record the outcome even when shutdown interrupts the delivery
+	recordCtx := context.WithoutCancel(ctx)
+
+	msg, reason, err := s.message(ctx, r)
+	if err == nil && reason == "" {
+		notifier, ok := s.notifiers[r.Channel]
+		if !ok {
+			err = notify.Permanent(errors.New("no notifier for channel " + r.Channel))
+		} else {
+			sendCtx, cancel := context.WithTimeout(ctx, reminderSendTimeout)
+			err = notifier.Notify(sendCtx, msg)
+			cancel()
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	switch {
+	case reason != "":
+		r.Status = model.ReminderStatusCancelled
+		r.LastError = model.NullString{NullString: sql.NullString{String: reason, Valid: true}}
+	case err == nil:
+		r.Status = model.ReminderStatusSent
+		r.Attempts++
+		r.LastError = model.NullString{}
+		r.SentAt = &now
+	default:
+		r.Attempts++
+		r.LastError = model.NullString{NullString: sql.NullString{String: err.Error(), Valid: true}}
+		if errors.Is(err, notify.ErrPermanent) || r.Attempts >= reminderMaxAttempts {
+			r.Status = model.ReminderStatusFailed
+		} else {
+			r.Status = model.ReminderStatusPending
+			r.NextAttemptAt = now.Add(reminderBackoff(r.Attempts))
+		}
+		infra.Warnf("reminder %d delivery attempt %d failed: %v", r.ID, r.Attempts, err)
+	}
+
+	if err := s.reminderRepo.UpdateDelivery(recordCtx, r); err != nil {
+		infra.Errorf("failed to record delivery of reminder %d: %v", r.ID, err)
+	}
+}
+
+// message builds the notification for r. A non-empty reason means the
+// reminder no longer applies and is cancelled instead.
+func (s *ReminderScheduler) message(ctx context.Context, r *model.Reminder) (msg *notify.Message, reason string, err error) {
+	note, err := s.noteRepo.GetByID(ctx, r.NoteID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "note was deleted", nil
+		}
+		return nil, "", err
+	}
+	if note.IsTrashed() {
+		return nil, "note is in the trash", nil
+	}
+
+	// Encrypted content must not leave the server in notifications
+	body := ""
+	if !note.IsEncryptedNote() {
+		body = excerpt(markdown.PlainText([]byte(note.Content)), reminderExcerptLength)
+	}
+	msg = &notify.Message{
+		UserID:     r.UserID,
+		Target:     r.Target.String,
+		Title:      "Reminder: " + note.Title,
+		Body:       body,
+		URL:        s.baseURL + "/edit/" + strconv.FormatInt(note.ID, 10),
+		NoteID:     note.ID,
+		ReminderID: r.ID,
+	}
+
+	if r.TaskID.Valid {
+		task, err := s.taskRepo.GetByID(ctx, r.TaskID.Int64)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, "task was removed", nil
+			}
+			return nil, "", err
+		}
+		if task.IsDone() {
+			return nil, "task is already done", nil
+		}
+		msg.Body = task.Text
+	}
+
+	if r.Channel == notify.ChannelEmail {
+		user, err := s.userService.GetByID(ctx, r.UserID)
+		if err != nil {
+			return nil, "", err
+		}
+		msg.Email = user.Email
+	}
+
+	return msg, "", nil
+}
+
+// reminderBackoff doubles the wait after every failed attempt
+func reminderBackoff(attempts int) time.Duration {
+	backoff := reminderBaseBackoff
+	for i := 1; i < attempts && backoff < reminderMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, reminderMaxBackoff)
+}
+
+// excerpt shortens text to at most n runes
+func excerpt(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/notify"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/ray-d-song/yan/internal/safehttp"
+)
+
+var (
+	ErrReminderNotFound     = errors.New("reminder not found")
+	ErrReminderUnauthorized = errors.New("unauthorized to access this reminder")
+	ErrInvalidReminder      = errors.New("invalid reminder")
+)
+
+type ReminderService interface {
+	List(ctx context.Context, userID int64, status string, noteID sql.NullInt64) ([]*model.Reminder, error)
+	Create(ctx context.Context, r *model.Reminder) error
+	Delete(ctx context.Context, id int64, userID int64) error
+}
+
+type reminderService struct {
+	reminderRepo repo.ReminderRepo
+	noteService  NoteService
+	taskService  TaskService
+}
+
+func NewReminderService(reminderRepo repo.ReminderRepo, noteService NoteService, taskService TaskService, events *NoteEvents) ReminderService {
+	s := &reminderService{
+		reminderRepo: reminderRepo,
+		noteService:  noteService,
+		taskService:  taskService,
+	}
+	events.Subscribe("reminders", s.onNoteEvent)
+	return s
+}
+
+func (s *reminderService) List(ctx context.Context, userID int64, status string, noteID sql.NullInt64) ([]*model.Reminder, error) {
+	return s.reminderRepo.GetByUserID(ctx, userID, status, noteID)
+}
+
+// Create schedules a reminder for a note of the user, or for one of the
+// note's tasks when TaskID is set
+func (s *reminderService) Create(ctx context.Context, r *model.Reminder) error {
+	// Check if note exists and belongs to user
+	if _, err := s.noteService.GetByID(ctx, r.NoteID, r.UserID); err != nil {
+		return err
+	}
+
+	if r.TaskID.Valid {
+		task, err := s.taskService.GetByID(ctx, r.TaskID.Int64, r.UserID)
+		if err != nil {
+			if err == ErrTaskNotFound || err == ErrTaskUnauthorized {
+				return fmt.Errorf("%w: unknown task", ErrInvalidReminder)
+			}
+			return err
+		}
+		if task.NoteID != r.NoteID {
+			return fmt.Errorf("%w: task belongs to another note", ErrInvalidReminder)
+		}
+	}
+
+	if r.RemindAt.IsZero() {
+		return fmt.Errorf("%w: remind time is required", ErrInvalidReminder)
+	}
+
+	switch r.Channel {
+	case notify.ChannelWebhook:
+		u, err := url.Parse(r.Target.String)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: webhook reminders need an http(s) target", ErrInvalidReminder)
+		}
+		if err := safehttp.CheckURL(u); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidReminder, err)
+		}
+	case notify.ChannelEmail, notify.ChannelInbox:
+		r.Target = model.NullString{}
+	default:
+		return fmt.Errorf("%w: unknown channel %q", ErrInvalidReminder, r.Channel)
+	}
+
+	// Times are stored in UTC at second precision so that they compare as text
+	r.RemindAt = r.RemindAt.UTC().Truncate(time.Second)
+	r.NextAttemptAt = r.RemindAt
+	r.Status = model.ReminderStatusPending
+	r.Attempts = 0
+	return s.reminderRepo.Create(ctx, r)
+}
+
+func (s *reminderService) Delete(ctx context.Context, id int64, userID int64) error {
+	r, err := s.reminderRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrReminderNotFound
+		}
+		return err
+	}
+
+	// Check if the user owns this reminder
+	if r.UserID != userID {
+		return ErrReminderUnauthorized
+	}
+
+	return s.reminderRepo.Delete(ctx, id)
+}
+
+// onNoteEvent cancels the reminders of deleted notes
+func (s *reminderService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	if e.Type != NoteDeleted {
+		return nil
+	}
+	return s.reminderRepo.CancelByNoteID(ctx, e.Note.ID, "note was deleted")
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+// renderCacheSize is the number of rendered notes kept in memory
+const renderCacheSize = 1024
+
+// blockRefExcerptLength is the number of characters of a referenced block
+// shown in place of a ((block-id)) reference
+const blockRefExcerptLength = 200
+
+// RenderedNote is the HTML of a note's content as of UpdatedAt
+type RenderedNote struct {
+	NoteID      int64
+	HTML        []byte
+	UpdatedAt   time.Time
+	ContentHash uint64
+	// Dynamic is set when the HTML holds content of other notes, or links
+	// shown by the reader's access to their targets, which change without
+	// the note changing
+	Dynamic bool
+}
+
+type RenderService interface {
+	Render(ctx context.Context, id int64, userID int64) (*RenderedNote, error)
+}
+
+type renderService struct {
+	noteRepo     repo.NoteRepo
+	noteService  NoteService
+	blockService BlockService
+	linkService  LinkService
+	cache        *renderCache
+}
+
+func NewRenderService(
+	noteRepo repo.NoteRepo,
+	noteService NoteService,
+	blockService BlockService,
+	linkService LinkService,
+) RenderService {
+	return &renderService{
+		noteRepo:     noteRepo,
+		noteService:  noteService,
+		blockService: blockService,
+		linkService:  linkService,
+		cache:        newRenderCache(renderCacheSize),
+	}
+}
+
+// Render returns the sanitized HTML of a note's content. Results are cached
+// for each reader, since links resolve with the reader's access, until the
+// note's updated_at changes.
+func (s *renderService) Render(ctx context.Context, id int64, userID int64) (*RenderedNote, error) {
+	note, err := s.noteService.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Encrypted content is decrypted and rendered by the client
+	if note.IsEncryptedNote() {
+		return nil, ErrNoteEncrypted
+	}
+
+	// updated_at only has second resolution, so the content hash keeps two
+	// edits within the same second from sharing a cache entry
+	key := renderCacheKey{updatedAt: note.UpdatedAt, contentHash: hashContent(note.Content)}
+	slot := renderCacheSlot{noteID: id, userID: userID}
+	if html, ok := s.cache.get(slot, key); ok {
+		return &RenderedNote{NoteID: id, HTML: html, UpdatedAt: note.UpdatedAt, ContentHash: key.contentHash}, nil
+	}
+
+	// Blocks get anchors so that references can point at them
+	blocks, err := s.blockService.Blocks(ctx, note)
+	if err != nil {
+		return nil, err
+	}
+	anchors := make(map[int]string, len(blocks))
+	for _, b := range blocks {
+		if b.Type != markdown.BlockHeading {
+			anchors[b.Line] = b.Anchor
+		}
+	}
+
+	dynamic := false
+	html, err := s.render(ctx, note, note.Content, userID, anchors, []int64{note.ID}, &dynamic)
+	if err != nil {
+		return nil, err
+	}
+
+	// Embedded and referenced content changes with other notes, and the
+	// links another reader sees with their access, so only renders without
+	// either are cached
+	if !dynamic {
+		s.cache.put(slot, key, html)
+	}
+	return &RenderedNote{
+		NoteID:      id,
+		HTML:        html,
+		UpdatedAt:   note.UpdatedAt,
+		ContentHash: key.contentHash,
+		Dynamic:     dynamic,
+	}, nil
+}
+
+// render converts content of the note to HTML for the user. path holds the
+// notes being rendered, the note itself last, so embeds can stop at cycles.
+func (s *renderService) render(ctx context.Context, note *model.Note, content string, userID int64, anchors map[int]string, path []int64, dynamic *bool) ([]byte, error) {
+	return markdown.Render([]byte(content),
+		markdown.WithBlockAnchors(anchors),
+		markdown.WithWikiLinkResolver(func(target string) (string, bool) {
+			// Links reach the notes of the note's owner, like the link index,
+			// and only show to readers of their target
+			title, heading, _ := strings.Cut(target, "#")
+			linked, err := s.noteRepo.GetByTitle(ctx, note.UserID, strings.TrimSpace(title))
+			if err != nil {
+				return "", false
+			}
+			if userID != note.UserID {
+				*dynamic = true
+				if _, err := s.noteService.Authorize(ctx, linked.ID, userID, model.NoteRoleViewer); err != nil {
+					return "", false
+				}
+			}
+			href := "/edit/" + strconv.FormatInt(linked.ID, 10)
+			// [[Note#^block-id]] points at a block, [[Note#Heading]] at a
+			// heading
+			if blockID, ok := strings.CutPrefix(strings.TrimSpace(heading), "^"); ok {
+				if anchor := s.blockAnchor(ctx, linked, blockID); anchor != "" {
+					href += "#" + anchor
+				}
+			} else if heading != "" {
+				href += "#" + markdown.Slug(heading)
+			}
+			return href, true
+		}),
+		markdown.WithBlockRefResolver(func(id string) (string, string, bool) {
+			*dynamic = true
+			b, err := s.blockService.Resolve(ctx, id, userID)
+			if err != nil {
+				return "", "", false
+			}
+			href := "/edit/" + strconv.FormatInt(b.NoteID, 10) + "#" + b.Anchor
+			return href, excerpt(b.Text, blockRefExcerptLength), true
+		}),
+		markdown.WithEmbedResolver(func(target string) (*markdown.EmbedContent, bool) {
+			*dynamic = true
+			linked, embedded, err := s.linkService.ResolveEmbed(ctx, note, target, userID)
+			if err != nil {
+				return nil, false
+			}
+			content := &markdown.EmbedContent{
+				Href:  "/edit/" + strconv.FormatInt(linked.ID, 10),
+				Title: linked.Title,
+			}
+			// Past the depth limit and at cycles only the link is shown
+			if len(path) > maxEmbedDepth || containsID(path, linked.ID) {
+				return content, true
+			}
+			next := append(append([]int64(nil), path...), linked.ID)
+			html, err := s.render(ctx, linked, embedded, userID, nil, next, dynamic)
+			if err != nil {
+				return nil, false
+			}
+			content.HTML = html
+			return content, true
+		}),
+	)
+}
+
+// blockAnchor returns the anchor of a block of the linked note, or "" if it
+// has no such block
+func (s *renderService) blockAnchor(ctx context.Context, note *model.Note, blockID string) string {
+	blocks, err := s.blockService.Blocks(ctx, note)
+	if err != nil {
+		return ""
+	}
+	for _, b := range blocks {
+		if b.BlockID == blockID {
+			return b.Anchor
+		}
+	}
+	return ""
+}
+
+func hashContent(content string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(content))
+	return h.Sum64()
+}
+
+type renderCacheKey struct {
+	updatedAt   time.Time
+	contentHash uint64
+}
+
+// renderCacheSlot is a note as rendered for one reader
+type renderCacheSlot struct {
+	noteID int64
+	userID int64
+}
+
+type renderCacheEntry struct {
+	slot renderCacheSlot
+	key  renderCacheKey
+	html []byte
+}
+
+// renderCache is a least recently used cache of rendered notes, holding one
+// entry per note and reader
+type renderCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[renderCacheSlot]*list.Element
+}
+
+func newRenderCache(size int) *renderCache {
+	return &renderCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[renderCacheSlot]*list.Element),
+	}
+}
+
+func (c *renderCache) get(slot renderCacheSlot, key renderCacheKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[slot]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*renderCacheEntry)
+	if !entry.key.updatedAt.Equal(key.updatedAt) || entry.key.contentHash != key.contentHash {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.html, true
+}
+
+func (c *renderCache) put(slot renderCacheSlot, key renderCacheKey, html []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[slot]; ok {
+		entry := el.Value.(*renderCacheEntry)
+		entry.key = key
+		entry.html = html
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[slot] = c.order.PushFront(&renderCacheEntry{slot: slot, key: key, html: html})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*renderCacheEntry).slot)
+	}
+}
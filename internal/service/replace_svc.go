@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrInvalidReplace          = errors.New("invalid find and replace")
+	ErrReplaceConflict         = errors.New("notes changed while replacing, try again")
+	ErrReplacementNotFound     = errors.New("replacement not found")
+	ErrReplacementUnauthorized = errors.New("unauthorized to access this replacement")
+	ErrReplacementReverted     = errors.New("replacement already reverted")
+)
+
+const (
+	// maxReplacePreviewMatches caps the matches a preview lists
+	maxReplacePreviewMatches = 500
+	// replaceContextRunes is the number of characters a preview shows on
+	// each side of a match
+	replaceContextRunes = 40
+)
+
+// ReplaceOptions describes a find and replace. Find is literal text unless
+// IsRegex is set, in which case Replacement may refer to its groups as $1
+// or ${name}, and ^ and $ match at line breaks. RootID limits it to a note
+// and its descendants and Tag to the notes carrying the tag; by default it
+// covers the notes the user lists in their current workspace.
+type ReplaceOptions struct {
+	Find          string
+	Replacement   string
+	IsRegex       bool
+	CaseSensitive bool
+	RootID        sql.NullInt64
+	Tag           string
+}
+
+// ReplaceService finds and replaces text in the content of many notes. A
+// preview lists each match in context without writing anything. Applying
+// writes every changed note in one transaction and records their content
+// before and after, so the whole replacement can be reverted at once.
+// Encrypted notes and the notes the user may not edit are left out.
+type ReplaceService interface {
+	Preview(ctx context.Context, opts ReplaceOptions, userID int64) (*model.ReplacePreview, error)
+	Apply(ctx context.Context, opts ReplaceOptions, userID int64) (*model.Replacement, error)
+	List(ctx context.Context, userID int64) ([]*model.Replacement, error)
+	Get(ctx context.Context, id int64, userID int64) (*model.Replacement, error)
+	Revert(ctx context.Context, id int64, userID int64) (*model.ReplacementRevert, error)
+}
+
+type replaceService struct {
+	replacementRepo repo.ReplacementRepo
+	noteRepo        repo.NoteRepo
+	tagRepo         repo.NoteTagRepo
+	noteService     NoteService
+	events          *NoteEvents
+}
+
+func NewReplaceService(replacementRepo repo.ReplacementRepo, noteRepo repo.NoteRepo, tagRepo repo.NoteTagRepo, noteService NoteService, events *NoteEvents) ReplaceService {
+	return &replaceService{
+		replacementRepo: replacementRepo,
+		noteRepo:        noteRepo,
+		tagRepo:         tagRepo,
+		noteService:     noteService,
+		events:          events,
+	}
+}
+
+// replaceEdit is a note a find and replace changes
+type replaceEdit struct {
+	note    *model.Note
+	content string
+	matches []*model.ReplaceMatch
+}
+
+// Preview lists the matches of the find and replace, with the text each
+// would become, without changing any note
+func (s *replaceService) Preview(ctx context.Context, opts ReplaceOptions, userID int64) (*model.ReplacePreview, error) {
+	_, edits, err := s.edits(ctx, opts, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &model.ReplacePreview{
+		NoteCount: len(edits),
+		Matches:   make([]*model.ReplaceMatch, 0),
+	}
+	for _, edit := range edits {
+		preview.MatchCount += len(edit.matches)
+		for _, m := range edit.matches {
+			if len(preview.Matches) == maxReplacePreviewMatches {
+				preview.Truncated = true
+				break
+			}
+			preview.Matches = append(preview.Matches, m)
+		}
+	}
+	return preview, nil
+}
+
+// Apply replaces the matches in every note of the scope at once and returns
+// the record of the replacement. It fails with ErrReplaceConflict, changing
+// nothing, if one of the notes is edited meanwhile.
+func (s *replaceService) Apply(ctx context.Context, opts ReplaceOptions, userID int64) (*model.Replacement, error) {
+	workspaceID, edits, err := s.edits(ctx, opts, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("%w: no note matches", ErrInvalidReplace)
+	}
+
+	replacement := &model.Replacement{
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		Find:        opts.Find,
+		Replacement: opts.Replacement,
+		RootID:      model.NullInt64{NullInt64: opts.RootID},
+		Tag:         strings.TrimSpace(opts.Tag),
+		NoteCount:   len(edits),
+	}
+	if opts.IsRegex {
+		replacement.IsRegex = 1
+	}
+	if opts.CaseSensitive {
+		replacement.CaseSensitive = 1
+	}
+	notes := make([]*model.ReplacementNote, 0, len(edits))
+	for _, edit := range edits {
+		replacement.MatchCount += len(edit.matches)
+		notes = append(notes, &model.ReplacementNote{
+			NoteID: edit.note.ID,
+			Before: edit.note.Content,
+			After:  edit.content,
+		})
+	}
+
+	ok, err := s.replacementRepo.Apply(ctx, replacement, notes)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrReplaceConflict
+	}
+
+	for _, edit := range edits {
+		s.publish(ctx, edit.note.ID, edit.note)
+	}
+	return replacement, nil
+}
+
+// List returns the user's replacements in their current workspace, newest
+// first
+func (s *replaceService) List(ctx context.Context, userID int64) ([]*model.Replacement, error) {
+	workspaceID, _, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.replacementRepo.GetByWorkspaceID(ctx, workspaceID, userID)
+}
+
+// Get returns one of the user's replacements
+func (s *replaceService) Get(ctx context.Context, id int64, userID int64) (*model.Replacement, error) {
+	replacement, err := s.replacementRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReplacementNotFound
+		}
+		return nil, err
+	}
+	if replacement.UserID != userID {
+		return nil, ErrReplacementUnauthorized
+	}
+
+	return replacement, nil
+}
+
+// Revert gives the notes of the replacement back their content from before
+// it, in one transaction. Notes edited since, deleted or no longer editable
+// by the user keep their content and are reported as skipped.
+func (s *replaceService) Revert(ctx context.Context, id int64, userID int64) (*model.ReplacementRevert, error) {
+	replacement, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if replacement.IsReverted() {
+		return nil, ErrReplacementReverted
+	}
+
+	notes, err := s.replacementRepo.GetNotes(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := make(map[int64]*model.Note, len(notes))
+	revertable := make([]*model.ReplacementNote, 0, len(notes))
+	for _, n := range notes {
+		note, err := s.noteService.Authorize(ctx, n.NoteID, userID, model.NoteRoleEditor)
+		if err != nil {
+			if err == ErrNoteNotFound || err == ErrNoteUnauthorized {
+				continue
+			}
+			return nil, err
+		}
+		previous[n.NoteID] = note
+		revertable = append(revertable, n)
+	}
+
+	reverted, err := s.replacementRepo.Revert(ctx, id, revertable)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrReplacementReverted
+		}
+		return nil, err
+	}
+
+	done := make(map[int64]bool, len(reverted))
+	for _, noteID := range reverted {
+		done[noteID] = true
+		s.publish(ctx, noteID, previous[noteID])
+	}
+	skipped := make([]int64, 0)
+	for _, n := range notes {
+		if !done[n.NoteID] {
+			skipped = append(skipped, n.NoteID)
+		}
+	}
+
+	if updated, err := s.replacementRepo.GetByID(ctx, id); err == nil {
+		replacement = updated
+	}
+	return &model.ReplacementRevert{
+		Replacement: replacement,
+		Reverted:    reverted,
+		Skipped:     skipped,
+	}, nil
+}
+
+// edits returns the workspace of the scope of the find and replace and the
+// notes of it the replacement changes, in tree order
+func (s *replaceService) edits(ctx context.Context, opts ReplaceOptions, userID int64) (int64, []*replaceEdit, error) {
+	re, err := compileReplace(opts)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	workspaceID, notes, err := s.scope(ctx, opts, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	edits := make([]*replaceEdit, 0)
+	for _, note := range notes {
+		if note.IsEncryptedNote() || !re.MatchString(note.Content) {
+			continue
+		}
+		role, err := s.noteService.RoleOf(ctx, note, userID)
+		if err != nil {
+			if err == ErrNoteUnauthorized {
+				continue
+			}
+			return 0, nil, err
+		}
+		if !model.NoteRoleAllows(role, model.NoteRoleEditor) {
+			continue
+		}
+
+		content, matches := replaceMatches(re, note, opts)
+		if content == note.Content {
+			continue
+		}
+		edits = append(edits, &replaceEdit{note: note, content: content, matches: matches})
+	}
+	return workspaceID, edits, nil
+}
+
+// scope returns the workspace and the normal notes a find and replace
+// covers
+func (s *replaceService) scope(ctx context.Context, opts ReplaceOptions, userID int64) (int64, []*model.Note, error) {
+	var workspaceID, ownerID int64
+	var notes []*model.Note
+	if opts.RootID.Valid {
+		root, err := s.noteService.Authorize(ctx, opts.RootID.Int64, userID, model.NoteRoleViewer)
+		if err != nil {
+			return 0, nil, err
+		}
+		descendants, err := s.noteRepo.GetDescendants(ctx, root.ID, model.NoteStatusNormal)
+		if err != nil {
+			return 0, nil, err
+		}
+		workspaceID = root.WorkspaceID
+		if root.IsNormal() {
+			notes = append(notes, root)
+		}
+		notes = append(notes, descendants...)
+	} else {
+		var err error
+		workspaceID, ownerID, err = s.noteService.ListScope(ctx, userID)
+		if err != nil {
+			return 0, nil, err
+		}
+		notes, err = s.noteRepo.GetByWorkspaceID(ctx, workspaceID, ownerID, model.NoteStatusNormal)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	tag := strings.TrimPrefix(strings.TrimSpace(opts.Tag), "#")
+	if tag == "" {
+		return workspaceID, notes, nil
+	}
+	tags, err := s.tagRepo.GetByWorkspaceID(ctx, workspaceID, ownerID)
+	if err != nil {
+		return 0, nil, err
+	}
+	tagged := make(map[int64]bool)
+	for _, t := range tags {
+		if strings.EqualFold(t.Tag, tag) {
+			tagged[t.NoteID] = true
+		}
+	}
+	filtered := make([]*model.Note, 0, len(tagged))
+	for _, note := range notes {
+		if tagged[note.ID] {
+			filtered = append(filtered, note)
+		}
+	}
+	return workspaceID, filtered, nil
+}
+
+// publish re-reads a replaced note and announces the change to it
+func (s *replaceService) publish(ctx context.Context, id int64, previous *model.Note) {
+	note, err := s.noteRepo.GetByID(ctx, id)
+	if err != nil {
+		return
+	}
+	s.events.Publish(ctx, NoteEvent{Type: NoteUpdated, Note: note, Previous: previous})
+}
+
+// compileReplace compiles the pattern of a find and replace, which must not
+// match empty text
+func compileReplace(opts ReplaceOptions) (*regexp.Regexp, error) {
+	if opts.Find == "" {
+		return nil, fmt.Errorf("%w: find is required", ErrInvalidReplace)
+	}
+
+	pattern := opts.Find
+	if !opts.IsRegex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReplace, err)
+	}
+	flags := "(?m)"
+	if !opts.CaseSensitive {
+		flags = "(?mi)"
+	}
+	re, err := regexp.Compile(flags + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReplace, err)
+	}
+	if re.MatchString("") {
+		return nil, fmt.Errorf("%w: pattern matches empty text", ErrInvalidReplace)
+	}
+	return re, nil
+}
+
+// replaceMatches replaces every match of re in the content of the note, and
+// returns the new content and the matches with their context
+func replaceMatches(re *regexp.Regexp, note *model.Note, opts ReplaceOptions) (string, []*model.ReplaceMatch) {
+	content := note.Content
+	var sb strings.Builder
+	matches := make([]*model.ReplaceMatch, 0)
+	line, lineStart, pos := 1, 0, 0
+	for _, loc := range re.FindAllStringSubmatchIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		replacement := opts.Replacement
+		if opts.IsRegex {
+			replacement = string(re.ExpandString(nil, opts.Replacement, content, loc))
+		}
+		sb.WriteString(content[pos:start])
+		sb.WriteString(replacement)
+
+		// Matches come in order, so lines are counted from the last one
+		line += strings.Count(content[lineStart:start], "\n")
+		lineStart = strings.LastIndexByte(content[:start], '\n') + 1
+		lineEnd := len(content)
+		if i := strings.IndexByte(content[end:], '\n'); i >= 0 {
+			lineEnd = end + i
+		}
+		matches = append(matches, &model.ReplaceMatch{
+			NoteID:      note.ID,
+			Title:       note.Title,
+			Line:        line,
+			Column:      utf8.RuneCountInString(content[lineStart:start]) + 1,
+			Before:      lastRunes(content[lineStart:start], replaceContextRunes),
+			Match:       content[start:end],
+			After:       firstRunes(content[end:lineEnd], replaceContextRunes),
+			Replacement: replacement,
+		})
+		pos = end
+	}
+	sb.WriteString(content[pos:])
+	return sb.String(), matches
+}
+
+// firstRunes returns the first n characters of s
+func firstRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// lastRunes returns the last n characters of s
+func lastRunes(s string, n int) string {
+	for i := len(s); i > 0; {
+		_, size := utf8.DecodeLastRuneInString(s[:i])
+		i -= size
+		n--
+		if n == 0 {
+			return s[i:]
+		}
+	}
+	return s
+}
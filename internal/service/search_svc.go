@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/ray-d-song/yan/internal/search"
+)
+
+var (
+	ErrSavedSearchNotFound     = errors.New("saved search not found")
+	ErrSavedSearchUnauthorized = errors.New("unauthorized to access this saved search")
+	ErrInvalidSavedSearch      = errors.New("invalid saved search")
+)
+
+const maxSavedSearchNameLength = 100
+
+// indexBatchSize is the number of notes added to the full-text index per
+// transaction when indexing the missing ones
+const indexBatchSize = 200
+
+// SearchService finds notes with the query language of package search, and
+// keeps the user's saved searches. Malformed queries fail with a
+// *search.ParseError. Words are looked up in a full-text index of the
+// notes, which the service keeps in sync with their titles and content.
+type SearchService interface {
+	IndexMissing(ctx context.Context) error
+	Search(ctx context.Context, userID int64, query string) ([]*model.Note, error)
+	ListSaved(ctx context.Context, userID int64) ([]*model.SavedSearch, error)
+	GetSaved(ctx context.Context, id int64, userID int64) (*model.SavedSearch, error)
+	CreateSaved(ctx context.Context, s *model.SavedSearch) error
+	UpdateSaved(ctx context.Context, s *model.SavedSearch, userID int64) error
+	DeleteSaved(ctx context.Context, id int64, userID int64) error
+	RunSaved(ctx context.Context, id int64, userID int64) ([]*model.Note, error)
+}
+
+type searchService struct {
+	searchRepo      repo.SearchRepo
+	savedSearchRepo repo.SavedSearchRepo
+	noteService     NoteService
+}
+
+func NewSearchService(
+	searchRepo repo.SearchRepo,
+	savedSearchRepo repo.SavedSearchRepo,
+	noteService NoteService,
+	events *NoteEvents,
+) SearchService {
+	s := &searchService{
+		searchRepo:      searchRepo,
+		savedSearchRepo: savedSearchRepo,
+		noteService:     noteService,
+	}
+	events.Subscribe("search", s.onNoteEvent)
+	return s
+}
+
+// IndexMissing adds the notes missing from the full-text index, such as the
+// notes written before it existed
+func (s *searchService) IndexMissing(ctx context.Context) error {
+	notes, err := s.searchRepo.GetUnindexed(ctx)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(notes); start += indexBatchSize {
+		batch := notes[start:min(start+indexBatchSize, len(notes))]
+		if err := s.searchRepo.IndexMissing(ctx, batch); err != nil {
+			return err
+		}
+	}
+	if len(notes) > 0 {
+		infra.Infof("Indexed %d notes for search", len(notes))
+	}
+	return nil
+}
+
+// Search returns the notes the user lists in their current workspace that
+// match the query
+func (s *searchService) Search(ctx context.Context, userID int64, query string) ([]*model.Note, error) {
+	q, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceID, ownerID, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.searchRepo.Search(ctx, workspaceID, ownerID, q)
+}
+
+// ListSaved returns the user's saved searches of their current workspace
+func (s *searchService) ListSaved(ctx context.Context, userID int64) ([]*model.SavedSearch, error) {
+	workspaceID, _, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.savedSearchRepo.GetByWorkspaceID(ctx, workspaceID, userID)
+}
+
+func (s *searchService) GetSaved(ctx context.Context, id int64, userID int64) (*model.SavedSearch, error) {
+	saved, err := s.savedSearchRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSavedSearchNotFound
+		}
+		return nil, err
+	}
+
+	// Check if the user owns this saved search
+	if saved.UserID != userID {
+		return nil, ErrSavedSearchUnauthorized
+	}
+
+	return saved, nil
+}
+
+// CreateSaved saves a search in the user's current workspace
+func (s *searchService) CreateSaved(ctx context.Context, saved *model.SavedSearch) error {
+	if err := validateSavedSearch(saved); err != nil {
+		return err
+	}
+
+	workspaceID, _, err := s.noteService.ListScope(ctx, saved.UserID)
+	if err != nil {
+		return err
+	}
+	saved.WorkspaceID = workspaceID
+
+	return s.savedSearchRepo.Create(ctx, saved)
+}
+
+func (s *searchService) UpdateSaved(ctx context.Context, saved *model.SavedSearch, userID int64) error {
+	existing, err := s.GetSaved(ctx, saved.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateSavedSearch(saved); err != nil {
+		return err
+	}
+	existing.Name = saved.Name
+	existing.Query = saved.Query
+	existing.Position = saved.Position
+	if err := s.savedSearchRepo.Update(ctx, existing); err != nil {
+		return err
+	}
+
+	*saved = *existing
+	return nil
+}
+
+func (s *searchService) DeleteSaved(ctx context.Context, id int64, userID int64) error {
+	if _, err := s.GetSaved(ctx, id, userID); err != nil {
+		return err
+	}
+
+	return s.savedSearchRepo.Delete(ctx, id)
+}
+
+// RunSaved returns the notes matching a saved search. Saved searches belong
+// to the workspace they were made in, and only run while it is current.
+func (s *searchService) RunSaved(ctx context.Context, id int64, userID int64) ([]*model.Note, error) {
+	saved, err := s.GetSaved(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := search.Parse(saved.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceID, ownerID, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if saved.WorkspaceID != workspaceID {
+		return nil, ErrSavedSearchNotFound
+	}
+
+	return s.searchRepo.Search(ctx, workspaceID, ownerID, q)
+}
+
+// onNoteEvent keeps the full-text index in sync with note titles and content
+func (s *searchService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated:
+		if e.Previous != nil && e.Previous.Title == e.Note.Title && e.Previous.Content == e.Note.Content &&
+			e.Previous.IsEncrypted == e.Note.IsEncrypted {
+			return nil
+		}
+		return s.searchRepo.Index(ctx, e.Note)
+	case NoteDeleted:
+		return s.searchRepo.Unindex(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+// validateSavedSearch checks the name and the query of a saved search
+func validateSavedSearch(saved *model.SavedSearch) error {
+	saved.Name = strings.TrimSpace(saved.Name)
+	if saved.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSavedSearch)
+	}
+	if utf8.RuneCountInString(saved.Name) > maxSavedSearchNameLength {
+		return fmt.Errorf("%w: name is too long", ErrInvalidSavedSearch)
+	}
+
+	saved.Query = strings.TrimSpace(saved.Query)
+	_, err := search.Parse(saved.Query)
+	return err
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrShareNotFound = errors.New("note is not shared with this user")
+	ErrInvalidShare  = errors.New("invalid share")
+)
+
+// ShareService manages who else may use a note. Grants cover the note's
+// subtree; NoteService enforces them.
+type ShareService interface {
+	List(ctx context.Context, noteID int64, userID int64) ([]*model.NotePermission, error)
+	Grant(ctx context.Context, noteID int64, userID int64, identity string, role string) (*model.NotePermission, error)
+	Revoke(ctx context.Context, noteID int64, userID int64, granteeID int64) error
+	SharedWithMe(ctx context.Context, userID int64) ([]*model.SharedNote, error)
+}
+
+type shareService struct {
+	permissionRepo repo.NotePermissionRepo
+	noteService    NoteService
+	userService    UserService
+}
+
+func NewShareService(
+	permissionRepo repo.NotePermissionRepo,
+	noteService NoteService,
+	userService UserService,
+	events *NoteEvents,
+) ShareService {
+	s := &shareService{
+		permissionRepo: permissionRepo,
+		noteService:    noteService,
+		userService:    userService,
+	}
+	events.Subscribe("shares", s.onNoteEvent)
+	return s
+}
+
+// List returns the grants made on the note itself. Only the owner, or an
+// admin of its workspace, manages them.
+func (s *shareService) List(ctx context.Context, noteID int64, userID int64) ([]*model.NotePermission, error) {
+	if _, err := s.noteService.Authorize(ctx, noteID, userID, model.NoteRoleOwner); err != nil {
+		return nil, err
+	}
+
+	return s.permissionRepo.GetByNoteID(ctx, noteID)
+}
+
+// Grant shares the note with the user identified by email or username,
+// replacing the role they had on it
+func (s *shareService) Grant(ctx context.Context, noteID int64, userID int64, identity string, role string) (*model.NotePermission, error) {
+	if !model.IsGrantableNoteRole(role) {
+		return nil, fmt.Errorf("%w: role must be viewer, commenter or editor", ErrInvalidShare)
+	}
+	note, err := s.noteService.Authorize(ctx, noteID, userID, model.NoteRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	grantee, err := s.userService.FindByIdentity(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if grantee.ID == note.UserID {
+		return nil, fmt.Errorf("%w: cannot share a note with its owner", ErrInvalidShare)
+	}
+
+	p := &model.NotePermission{
+		NoteID:    noteID,
+		UserID:    grantee.ID,
+		Role:      role,
+		GrantedBy: userID,
+	}
+	if err := s.permissionRepo.Upsert(ctx, p); err != nil {
+		return nil, err
+	}
+
+	return s.permissionRepo.Get(ctx, noteID, grantee.ID)
+}
+
+// Revoke removes the grant of granteeID on the note. The owner revokes
+// anyone; a grantee may remove their own access.
+func (s *shareService) Revoke(ctx context.Context, noteID int64, userID int64, granteeID int64) error {
+	if granteeID != userID {
+		if _, err := s.noteService.Authorize(ctx, noteID, userID, model.NoteRoleOwner); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.permissionRepo.Get(ctx, noteID, granteeID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrShareNotFound
+		}
+		return err
+	}
+
+	return s.permissionRepo.Delete(ctx, noteID, granteeID)
+}
+
+func (s *shareService) SharedWithMe(ctx context.Context, userID int64) ([]*model.SharedNote, error) {
+	return s.permissionRepo.GetSharedWithUser(ctx, userID)
+}
+
+// onNoteEvent drops the grants of deleted notes
+func (s *shareService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	if e.Type != NoteDeleted {
+		return nil
+	}
+	return s.permissionRepo.DeleteByNoteID(ctx, e.Note.ID)
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+const (
+	defaultSuggestLimit = 10
+	maxSuggestLimit     = 50
+	// maxBreadcrumbDepth stops walking up a broken parent chain
+	maxBreadcrumbDepth = 32
+)
+
+// Weights of the parts of a suggestion's score. A better kind of title
+// match outweighs recency and opens, which order the notes matching alike.
+const (
+	suggestExactWeight     = 8.0
+	suggestPrefixWeight    = 6.0
+	suggestWordWeight      = 4.0
+	suggestSubstringWeight = 2.0
+	suggestRecencyWeight   = 2.0
+	suggestOpenWeight      = 1.0
+	// suggestRecencyDays is the age in days at which recency counts half
+	suggestRecencyDays = 7.0
+)
+
+// SuggestService offers notes by title for the link picker and the quick
+// switcher. It answers from memory: the titles of a workspace are loaded on
+// its first use and then kept in sync with note events, and each user's
+// open counts are loaded on their first request.
+type SuggestService interface {
+	Suggest(ctx context.Context, userID int64, prefix string, limit int) ([]*model.NoteSuggestion, error)
+	RecordOpen(ctx context.Context, noteID int64, userID int64) error
+}
+
+// titleEntry is a note of the title index
+type titleEntry struct {
+	note  *model.NoteTitle
+	lower string
+	words []string
+}
+
+type suggestService struct {
+	noteRepo    repo.NoteRepo
+	openRepo    repo.NoteOpenRepo
+	noteService NoteService
+	mu          sync.RWMutex
+	workspaces  map[int64]map[int64]*titleEntry
+	opens       map[int64]map[int64]*model.NoteOpen
+}
+
+func NewSuggestService(noteRepo repo.NoteRepo, openRepo repo.NoteOpenRepo, noteService NoteService, events *NoteEvents) SuggestService {
+	s := &suggestService{
+		noteRepo:    noteRepo,
+		openRepo:    openRepo,
+		noteService: noteService,
+		workspaces:  make(map[int64]map[int64]*titleEntry),
+		opens:       make(map[int64]map[int64]*model.NoteOpen),
+	}
+	events.Subscribe("suggest", s.onNoteEvent)
+	return s
+}
+
+// Suggest returns the notes of the user's current workspace whose titles
+// match the prefix, best first. An empty prefix offers the notes the user
+// is most likely to switch to.
+func (s *suggestService) Suggest(ctx context.Context, userID int64, prefix string, limit int) ([]*model.NoteSuggestion, error) {
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+
+	workspaceID, ownerID, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.load(ctx, workspaceID, userID); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := s.workspaces[workspaceID]
+	opens := s.opens[userID]
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	now := time.Now()
+
+	type scored struct {
+		entry *titleEntry
+		score float64
+	}
+	matches := make([]scored, 0)
+	for _, e := range entries {
+		if ownerID != 0 && e.note.UserID != ownerID {
+			continue
+		}
+		match := titleMatch(e, prefix)
+		if match == 0 {
+			continue
+		}
+
+		last := e.note.UpdatedAt
+		count := 0
+		if o, ok := opens[e.note.ID]; ok {
+			count = o.Count
+			if o.LastOpenedAt.After(last) {
+				last = o.LastOpenedAt
+			}
+		}
+		days := math.Max(now.Sub(last).Hours()/24, 0)
+		score := match +
+			suggestRecencyWeight*suggestRecencyDays/(suggestRecencyDays+days) +
+			suggestOpenWeight*math.Log1p(float64(count))
+		matches = append(matches, scored{entry: e, score: score})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].entry.note.ID < matches[j].entry.note.ID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	suggestions := make([]*model.NoteSuggestion, 0, len(matches))
+	for _, m := range matches {
+		suggestions = append(suggestions, &model.NoteSuggestion{
+			ID:          m.entry.note.ID,
+			Title:       m.entry.note.Title,
+			Icon:        m.entry.note.Icon,
+			Breadcrumbs: breadcrumbs(entries, m.entry.note),
+		})
+	}
+	return suggestions, nil
+}
+
+// RecordOpen counts an open of the note by the user. The caller checks
+// access.
+func (s *suggestService) RecordOpen(ctx context.Context, noteID int64, userID int64) error {
+	open, err := s.openRepo.Record(ctx, userID, noteID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if opens, ok := s.opens[userID]; ok {
+		opens[noteID] = open
+	}
+	return nil
+}
+
+// load reads the titles of the workspace and the opens of the user if they
+// are not in memory yet. It holds the lock while reading, so no note event
+// slips in between the read and the index.
+func (s *suggestService) load(ctx context.Context, workspaceID int64, userID int64) error {
+	s.mu.RLock()
+	_, hasWorkspace := s.workspaces[workspaceID]
+	_, hasOpens := s.opens[userID]
+	s.mu.RUnlock()
+	if hasWorkspace && hasOpens {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.workspaces[workspaceID]; !ok {
+		titles, err := s.noteRepo.GetTitles(ctx, workspaceID)
+		if err != nil {
+			return err
+		}
+		entries := make(map[int64]*titleEntry, len(titles))
+		for _, t := range titles {
+			entries[t.ID] = newTitleEntry(t)
+		}
+		s.workspaces[workspaceID] = entries
+	}
+
+	if _, ok := s.opens[userID]; !ok {
+		list, err := s.openRepo.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		opens := make(map[int64]*model.NoteOpen, len(list))
+		for _, o := range list {
+			opens[o.NoteID] = o
+		}
+		s.opens[userID] = opens
+	}
+	return nil
+}
+
+// onNoteEvent keeps the loaded title indexes in sync with the notes
+func (s *suggestService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	if e.Type == NoteDeleted {
+		if err := s.openRepo.DeleteByNoteID(ctx, e.Note.ID); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.Type == NoteDeleted {
+		for _, opens := range s.opens {
+			delete(opens, e.Note.ID)
+		}
+	}
+	entries, ok := s.workspaces[e.Note.WorkspaceID]
+	if !ok {
+		return nil
+	}
+	if e.Type == NoteDeleted || e.Note.Status != model.NoteStatusNormal {
+		delete(entries, e.Note.ID)
+		return nil
+	}
+	entries[e.Note.ID] = newTitleEntry(&model.NoteTitle{
+		ID:          e.Note.ID,
+		ParentID:    e.Note.ParentID,
+		UserID:      e.Note.UserID,
+		WorkspaceID: e.Note.WorkspaceID,
+		Title:       e.Note.Title,
+		Icon:        e.Note.Icon,
+		UpdatedAt:   e.Note.UpdatedAt,
+	})
+	return nil
+}
+
+func newTitleEntry(t *model.NoteTitle) *titleEntry {
+	lower := strings.ToLower(t.Title)
+	return &titleEntry{
+		note:  t,
+		lower: lower,
+		words: strings.Fields(lower),
+	}
+}
+
+// titleMatch returns the weight of how well the title matches the
+// lowercased prefix, or 0 if it does not
+func titleMatch(e *titleEntry, prefix string) float64 {
+	if prefix == "" {
+		return suggestSubstringWeight
+	}
+	if e.lower == prefix {
+		return suggestExactWeight
+	}
+	if strings.HasPrefix(e.lower, prefix) {
+		return suggestPrefixWeight
+	}
+	for i := 1; i < len(e.words); i++ {
+		if strings.HasPrefix(e.words[i], prefix) {
+			return suggestWordWeight
+		}
+	}
+	if strings.Contains(e.lower, prefix) {
+		return suggestSubstringWeight
+	}
+	return 0
+}
+
+// breadcrumbs returns the titles of the note's ancestors, from the top
+func breadcrumbs(entries map[int64]*titleEntry, note *model.NoteTitle) []string {
+	crumbs := make([]string, 0)
+	parentID := note.ParentID
+	for i := 0; parentID.Valid && i < maxBreadcrumbDepth; i++ {
+		parent, ok := entries[parentID.Int64]
+		if !ok {
+			break
+		}
+		crumbs = append(crumbs, parent.note.Title)
+		parentID = parent.note.ParentID
+	}
+	for i, j := 0, len(crumbs)-1; i < j; i, j = i+1, j-1 {
+		crumbs[i], crumbs[j] = crumbs[j], crumbs[i]
+	}
+	return crumbs
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+// TagService keeps the tags listed in the front-matter of notes
+type TagService interface {
+	List(ctx context.Context, userID int64) ([]*model.TagCount, error)
+}
+
+type tagService struct {
+	tagRepo     repo.NoteTagRepo
+	noteService NoteService
+}
+
+func NewTagService(tagRepo repo.NoteTagRepo, noteService NoteService, events *NoteEvents) TagService {
+	s := &tagService{
+		tagRepo:     tagRepo,
+		noteService: noteService,
+	}
+	events.Subscribe("tags", s.onNoteEvent)
+	return s
+}
+
+// List returns the tags of the notes the user lists in their current
+// workspace, most used first
+func (s *tagService) List(ctx context.Context, userID int64) ([]*model.TagCount, error) {
+	workspaceID, ownerID, err := s.noteService.ListScope(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tagRepo.CountByWorkspaceID(ctx, workspaceID, ownerID)
+}
+
+// onNoteEvent keeps the tags table in sync with note content
+func (s *tagService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated:
+		if e.Previous != nil && e.Previous.Content == e.Note.Content {
+			return nil
+		}
+		// The front-matter of an encrypted note is not readable
+		if e.Note.IsEncryptedNote() {
+			return s.tagRepo.DeleteByNoteID(ctx, e.Note.ID)
+		}
+		return s.syncTags(ctx, e.Note)
+	case NoteDeleted:
+		return s.tagRepo.DeleteByNoteID(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+func (s *tagService) syncTags(ctx context.Context, note *model.Note) error {
+	extracted := markdown.ExtractTags([]byte(note.Content))
+	tags := make([]*model.NoteTag, 0, len(extracted))
+	for _, t := range extracted {
+		tags = append(tags, &model.NoteTag{NoteID: note.ID, Tag: t})
+	}
+
+	return s.tagRepo.Save(ctx, note.ID, tags)
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ray-d-song/yan/internal/markdown"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrTaskNotFound     = errors.New("task not found")
+	ErrTaskUnauthorized = errors.New("unauthorized to access this task")
+	ErrTaskOutOfDate    = errors.New("task no longer matches the note content")
+)
+
+type TaskService interface {
+	GetByID(ctx context.Context, id int64, userID int64) (*model.Task, error)
+	List(ctx context.Context, filter repo.TaskFilter) ([]*model.Task, error)
+	SetDone(ctx context.Context, id int64, userID int64, done bool) (*model.Task, error)
+}
+
+type taskService struct {
+	taskRepo    repo.TaskRepo
+	noteService NoteService
+}
+
+func NewTaskService(taskRepo repo.TaskRepo, noteService NoteService, events *NoteEvents) TaskService {
+	s := &taskService{
+		taskRepo:    taskRepo,
+		noteService: noteService,
+	}
+	events.Subscribe("tasks", s.onNoteEvent)
+	return s
+}
+
+// GetByID returns the task if the user may read its note
+func (s *taskService) GetByID(ctx context.Context, id int64, userID int64) (*model.Task, error) {
+	task, _, err := s.authorize(ctx, id, userID, model.NoteRoleViewer)
+	return task, err
+}
+
+// authorize returns the task and its note if the user's role on the note is
+// at least role
+func (s *taskService) authorize(ctx context.Context, id int64, userID int64, role string) (*model.Task, *model.Note, error) {
+	task, err := s.taskRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, ErrTaskNotFound
+		}
+		return nil, nil, err
+	}
+
+	note, err := s.noteService.Authorize(ctx, task.NoteID, userID, role)
+	if err != nil {
+		if err == ErrNoteNotFound {
+			return nil, nil, ErrTaskNotFound
+		}
+		if err == ErrNoteUnauthorized {
+			return nil, nil, ErrTaskUnauthorized
+		}
+		return nil, nil, err
+	}
+
+	return task, note, nil
+}
+
+func (s *taskService) List(ctx context.Context, filter repo.TaskFilter) ([]*model.Task, error) {
+	// If parent_id is provided, the user must be able to read it, and with
+	// it the tasks of every note below it, whoever wrote them
+	if filter.ParentID.Valid {
+		if _, err := s.noteService.GetByID(ctx, filter.ParentID.Int64, filter.UserID); err != nil {
+			if err == ErrNoteNotFound {
+				return nil, ErrInvalidParentNote
+			}
+			return nil, err
+		}
+		filter.UserID = 0
+	}
+
+	return s.taskRepo.List(ctx, filter)
+}
+
+// SetDone checks or unchecks the task by rewriting its checkbox in the note
+// content. The task list is then updated from the saved note.
+func (s *taskService) SetDone(ctx context.Context, id int64, userID int64, done bool) (*model.Task, error) {
+	task, note, err := s.authorize(ctx, id, userID, model.NoteRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stored line must still hold this task, or we would toggle another
+	current := false
+	for _, t := range markdown.ExtractTasks([]byte(note.Content)) {
+		if t.Line == task.Line && t.Text == task.Text {
+			current = true
+			break
+		}
+	}
+	if !current {
+		return nil, ErrTaskOutOfDate
+	}
+
+	content, err := markdown.SetTaskDone([]byte(note.Content), task.Line, done)
+	if err != nil {
+		return nil, ErrTaskOutOfDate
+	}
+	note.Content = string(content)
+	if err := s.noteService.Update(ctx, note, userID); err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(ctx, id, userID)
+}
+
+// onNoteEvent keeps the tasks table in sync with note content
+func (s *taskService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	switch e.Type {
+	case NoteCreated, NoteUpdated:
+		if e.Previous != nil && e.Previous.Content == e.Note.Content {
+			return nil
+		}
+		return s.syncTasks(ctx, e.Note)
+	case NoteDeleted:
+		return s.taskRepo.DeleteByNoteID(ctx, e.Note.ID)
+	}
+	return nil
+}
+
+func (s *taskService) syncTasks(ctx context.Context, note *model.Note) error {
+	// Tasks cannot be read out of an encrypted note
+	if note.IsEncryptedNote() {
+		return s.taskRepo.DeleteByNoteID(ctx, note.ID)
+	}
+
+	existing, err := s.taskRepo.GetByNoteID(ctx, note.ID)
+	if err != nil {
+		return err
+	}
+
+	extracted := markdown.ExtractTasks([]byte(note.Content))
+	tasks := make([]*model.Task, 0, len(extracted))
+	for _, e := range extracted {
+		t := &model.Task{
+			NoteID: note.ID,
+			UserID: note.UserID,
+			Line:   e.Line,
+			Text:   e.Text,
+			Done:   model.TaskOpen,
+		}
+		if e.Done {
+			t.Done = model.TaskDone
+		}
+		if e.Due != "" {
+			t.DueDate = model.NullString{NullString: sql.NullString{String: e.Due, Valid: true}}
+		}
+		tasks = append(tasks, t)
+	}
+
+	matchTaskIDs(existing, tasks)
+	return s.taskRepo.Save(ctx, note.ID, tasks)
+}
+
+// matchTaskIDs gives re-extracted tasks the IDs of the stored tasks they
+// continue, so task IDs survive edits of the note. A task continues the
+// nearest stored task with the same text, or else the one on the same line.
+func matchTaskIDs(existing []*model.Task, tasks []*model.Task) {
+	used := make(map[int64]bool, len(existing))
+
+	for _, t := range tasks {
+		var best *model.Task
+		for _, old := range existing {
+			if used[old.ID] || old.Text != t.Text {
+				continue
+			}
+			if best == nil || absInt(old.Line-t.Line) < absInt(best.Line-t.Line) {
+				best = old
+			}
+		}
+		if best != nil {
+			t.ID = best.ID
+			used[best.ID] = true
+		}
+	}
+
+	for _, t := range tasks {
+		if t.ID > 0 {
+			continue
+		}
+		for _, old := range existing {
+			if !used[old.ID] && old.Line == t.Line {
+				t.ID = old.ID
+				used[old.ID] = true
+				break
+			}
+		}
+	}
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -18,6 +18,7 @@ var (
 	ErrUsernameExists     = errors.New("username already exists")
 	ErrInvalidCredentials = errors.New("invalid username or password")
 	ErrUserDisabled       = errors.New("user is disabled")
+	ErrUsernameAmbiguous  = errors.New("several users have this username, use the email instead")
 )
 
 //
@@ -26,6 +27,7 @@ var (
 
 type UserService interface {
 	GetByID(ctx context.Context, id int64) (*model.User, error)
+	FindByIdentity(ctx context.Context, identity string) (*model.User, error)
 	Register(ctx context.Context, username, password, email string) (*model.User, error)
 	Login(ctx context.Context, email, password string) (*model.User, error)
 	UpdateProfile(ctx context.Context, u *model.User) error
@@ -65,6 +67,44 @@ func (s *userService) GetByID(ctx context.Context, id int64) (*model.User, error
 	return u, nil
 }
 
+// FindByIdentity looks up an active user by email, or by username when
+// identity has no @
+func (s *userService) FindByIdentity(ctx context.Context, identity string) (*model.User, error) {
+	identity = strings.TrimSpace(identity)
+	if identity == "" {
+		return nil, ErrUserNotFound
+	}
+
+	var u *model.User
+	if strings.Contains(identity, "@") {
+		var err error
+		u, err = s.userRepo.GetByEmail(ctx, identity)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrUserNotFound
+			}
+			return nil, err
+		}
+	} else {
+		users, err := s.userRepo.GetByUsername(ctx, identity)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			return nil, ErrUserNotFound
+		}
+		// Usernames are not unique
+		if len(users) > 1 {
+			return nil, ErrUsernameAmbiguous
+		}
+		u = users[0]
+	}
+	if !u.IsActive() {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
 //
 // write / business logic
 //
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/infra"
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/ray-d-song/yan/internal/safehttp"
+)
+
+const (
+	webhookPollInterval = 15 * time.Second
+	webhookLease        = 2 * time.Minute
+	webhookSendTimeout  = 10 * time.Second
+	webhookBatchSize    = 50
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = time.Hour
+	// webhookDisableAfter is how long an endpoint may fail every attempt
+	// before it is disabled
+	webhookDisableAfter = 24 * time.Hour
+	// webhookRetention is how long finished deliveries stay in the log
+	webhookRetention      = 30 * 24 * time.Hour
+	webhookPruneInterval  = time.Hour
+	webhookMaxResponseLog = 1024
+)
+
+// WebhookDispatcher sends queued webhook deliveries. Deliveries are queued in
+// the database by the webhook service; the dispatcher is woken up right away
+// and otherwise polls for retries that have become due.
+type WebhookDispatcher struct {
+	webhookRepo  repo.WebhookRepo
+	deliveryRepo repo.WebhookDeliveryRepo
+	client       *http.Client
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewWebhookDispatcher(webhookRepo repo.WebhookRepo, deliveryRepo repo.WebhookDeliveryRepo) *WebhookDispatcher {
+	// Webhook URLs are user-supplied, so deliveries only go to public
+	// addresses
+	client := safehttp.NewClient(webhookSendTimeout)
+	// A redirect would resend the payload somewhere the user did not
+	// configure
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &WebhookDispatcher{
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		client:       client,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Start begins sending deliveries in the background
+func (d *WebhookDispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.done = make(chan struct{})
+	go d.run(ctx)
+}
+
+// Stop ends sending and waits for the delivery in progress
+func (d *WebhookDispatcher) Stop(ctx context.Context) error {
+	if d.cancel == nil {
+		return nil
+	}
+	d.cancel()
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wake makes the dispatcher look for due deliveries now
+func (d *WebhookDispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *WebhookDispatcher) run(ctx context.Context) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	var pruned time.Time
+	for {
+		d.sendDue(ctx)
+		if time.Since(pruned) >= webhookPruneInterval {
+			d.prune(ctx)
+			pruned = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) sendDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		deliveries, err := d.deliveryRepo.ClaimDue(ctx, now, webhookLease, webhookBatchSize)
+		if err != nil {
+			infra.Errorf("failed to claim due webhook deliveries: %v", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			d.deliver(ctx, delivery)
+		}
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func (d *WebhookDispatcher) prune(ctx context.Context) {
+	n, err := d.deliveryRepo.DeleteBefore(ctx, time.Now().UTC().Add(-webhookRetention))
+	if err != nil {
+		infra.Errorf("failed to prune webhook deliveries: %v", err)
+		return
+	}
+	if n > 0 {
+		infra.Infof("pruned %d old webhook deliveries", n)
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	// Record the outcome even when shutdown interrupts the delivery
+	recordCtx := context.WithoutCancel(ctx)
+
+	hook, err := d.webhookRepo.GetByID(ctx, delivery.WebhookID)
+	if err != nil {
+		infra.Errorf("failed to load webhook %d: %v", delivery.WebhookID, err)
+		return
+	}
+
+	start := time.Now()
+	code, body, retry, err := d.send(ctx, hook, delivery)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	delivery.Attempts++
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.ResponseCode = model.NullInt64{}
+	if code != 0 {
+		delivery.ResponseCode = model.NullInt64{NullInt64: sql.NullInt64{Int64: int64(code), Valid: true}}
+	}
+	delivery.ResponseBody = model.NullString{NullString: sql.NullString{String: body, Valid: body != ""}}
+	if err == nil {
+		delivery.Status = model.DeliveryStatusSucceeded
+		delivery.LastError = model.NullString{}
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = model.NullString{NullString: sql.NullString{String: err.Error(), Valid: true}}
+		if !retry || delivery.Attempts >= webhookMaxAttempts {
+			delivery.Status = model.DeliveryStatusFailed
+		} else {
+			delivery.Status = model.DeliveryStatusPending
+			delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+		}
+		infra.Warnf("webhook %d delivery %d attempt %d failed: %v", hook.ID, delivery.ID, delivery.Attempts, err)
+	}
+
+	if err := d.deliveryRepo.UpdateDelivery(recordCtx, delivery); err != nil {
+		infra.Errorf("failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+
+	if err == nil {
+		if err := d.webhookRepo.RecordSuccess(recordCtx, hook.ID); err != nil {
+			infra.Errorf("failed to record success of webhook %d: %v", hook.ID, err)
+		}
+		return
+	}
+	d.recordFailure(recordCtx, hook.ID, now)
+}
+
+// recordFailure counts a failed attempt and disables the webhook once every
+// attempt has failed for webhookDisableAfter
+func (d *WebhookDispatcher) recordFailure(ctx context.Context, id int64, at time.Time) {
+	hook, err := d.webhookRepo.RecordFailure(ctx, id, at)
+	if err != nil {
+		infra.Errorf("failed to record failure of webhook %d: %v", id, err)
+		return
+	}
+	if hook.FailingSince == nil || at.Sub(*hook.FailingSince) < webhookDisableAfter {
+		return
+	}
+
+	reason := fmt.Sprintf("%d failed attempts since %s", hook.FailureCount, hook.FailingSince.UTC().Format(time.RFC3339))
+	if err := d.webhookRepo.Disable(ctx, id, reason); err != nil {
+		infra.Errorf("failed to disable webhook %d: %v", id, err)
+		return
+	}
+	infra.Warnf("disabled webhook %d after %s", id, reason)
+}
+
+// send posts the delivery payload signed with the webhook secret. It returns
+// the response status and the start of the response body, and whether a
+// failure is worth retrying.
+func (d *WebhookDispatcher) send(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery) (code int, body string, retry bool, err error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, "", false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Yan-Webhooks")
+	req.Header.Set("X-Yan-Event", delivery.Event)
+	req.Header.Set("X-Yan-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Yan-Timestamp", timestamp)
+	req.Header.Set("X-Yan-Signature", SignWebhookPayload(hook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", !errors.Is(err, safehttp.ErrPrivateAddress), err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponseLog))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, string(respBody), false, nil
+	}
+	err = fmt.Errorf("webhook responded with %s", resp.Status)
+	// Client errors will not change on retry, except for timeouts and rate limits
+	retry = resp.StatusCode >= 500 ||
+		resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return resp.StatusCode, string(respBody), retry, err
+}
+
+// SignWebhookPayload returns the X-Yan-Signature header of a payload: the hex
+// HMAC-SHA256 of "<timestamp>.<payload>" keyed with the webhook secret.
+// Receivers recompute it to authenticate the payload, and reject stale
+// timestamps to defeat replays.
+func SignWebhookPayload(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff doubles the wait after every failed attempt
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, webhookMaxBackoff)
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+	"github.com/ray-d-song/yan/internal/safehttp"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookUnauthorized     = errors.New("unauthorized to access this webhook")
+	ErrInvalidWebhook          = errors.New("invalid webhook")
+	ErrWebhookDisabled         = errors.New("webhook is disabled")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// webhookEvents are the note events webhooks can subscribe to
+var webhookEvents = []NoteEventType{
+	NoteCreated, NoteUpdated, NoteTrashed, NoteRestored, NoteDeleted, NoteMoved, NoteFavorited,
+}
+
+const maxWebhookDeliveries = 100
+
+// NewWebhook is a freshly created webhook. The signing secret is only
+// returned at creation and when it is rotated.
+type NewWebhook struct {
+	*model.Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookPayload is the JSON body posted to webhooks
+type WebhookPayload struct {
+	Event      NoteEventType       `json:"event"`
+	OccurredAt time.Time           `json:"occurredAt"`
+	Note       *WebhookPayloadNote `json:"note"`
+	Previous   *WebhookPayloadNote `json:"previous,omitempty"`
+}
+
+// WebhookPayloadNote is a note as sent to webhooks
+type WebhookPayloadNote struct {
+	ID         int64           `json:"id"`
+	ParentID   model.NullInt64 `json:"parentId"`
+	Title      string          `json:"title"`
+	Content    string          `json:"content"`
+	IsFavorite bool            `json:"isFavorite"`
+	Trashed    bool            `json:"trashed"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+}
+
+type WebhookService interface {
+	List(ctx context.Context, userID int64) ([]*model.Webhook, error)
+	GetByID(ctx context.Context, id int64, userID int64) (*model.Webhook, error)
+	Create(ctx context.Context, w *model.Webhook) (*NewWebhook, error)
+	Update(ctx context.Context, w *model.Webhook, userID int64) error
+	SetActive(ctx context.Context, id int64, userID int64, active bool) error
+	RotateSecret(ctx context.Context, id int64, userID int64) (*NewWebhook, error)
+	Delete(ctx context.Context, id int64, userID int64) error
+	ListDeliveries(ctx context.Context, id int64, userID int64) ([]*model.WebhookDelivery, error)
+	Redeliver(ctx context.Context, id int64, deliveryID int64, userID int64) (*model.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo  repo.WebhookRepo
+	deliveryRepo repo.WebhookDeliveryRepo
+	dispatcher   *WebhookDispatcher
+}
+
+func NewWebhookService(
+	webhookRepo repo.WebhookRepo,
+	deliveryRepo repo.WebhookDeliveryRepo,
+	dispatcher *WebhookDispatcher,
+	events *NoteEvents,
+) WebhookService {
+	s := &webhookService{
+		webhookRepo:  webhookRepo,
+		deliveryRepo: deliveryRepo,
+		dispatcher:   dispatcher,
+	}
+	events.Subscribe("webhooks", s.onNoteEvent)
+	return s
+}
+
+func (s *webhookService) List(ctx context.Context, userID int64) ([]*model.Webhook, error) {
+	return s.webhookRepo.GetByUserID(ctx, userID)
+}
+
+func (s *webhookService) GetByID(ctx context.Context, id int64, userID int64) (*model.Webhook, error) {
+	w, err := s.webhookRepo.GetByID(ctx, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	// Check if the user owns this webhook
+	if w.UserID != userID {
+		return nil, ErrWebhookUnauthorized
+	}
+
+	return w, nil
+}
+
+func (s *webhookService) Create(ctx context.Context, w *model.Webhook) (*NewWebhook, error) {
+	if err := validateWebhook(w); err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	w.Secret = secret
+	w.IsActive = model.WebhookActive
+	if err := s.webhookRepo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+
+	created, err := s.webhookRepo.GetByID(ctx, w.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &NewWebhook{Webhook: created, Secret: secret}, nil
+}
+
+// Update changes the URL and events of the webhook
+func (s *webhookService) Update(ctx context.Context, w *model.Webhook, userID int64) error {
+	existing, err := s.GetByID(ctx, w.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := validateWebhook(w); err != nil {
+		return err
+	}
+	existing.URL = w.URL
+	existing.Events = w.Events
+	return s.webhookRepo.Update(ctx, existing)
+}
+
+// SetActive disables the webhook, or enables it again with a clean failure
+// record. Disabling fails the deliveries still queued.
+func (s *webhookService) SetActive(ctx context.Context, id int64, userID int64, active bool) error {
+	w, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if active {
+		if w.IsEnabled() {
+			return nil
+		}
+		return s.webhookRepo.Enable(ctx, id)
+	}
+	if !w.IsEnabled() {
+		return nil
+	}
+	return s.webhookRepo.Disable(ctx, id, "disabled by user")
+}
+
+// RotateSecret replaces the signing secret of the webhook
+func (s *webhookService) RotateSecret(ctx context.Context, id int64, userID int64) (*NewWebhook, error) {
+	w, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	w.Secret = secret
+	if err := s.webhookRepo.Update(ctx, w); err != nil {
+		return nil, err
+	}
+	return &NewWebhook{Webhook: w, Secret: secret}, nil
+}
+
+func (s *webhookService) Delete(ctx context.Context, id int64, userID int64) error {
+	if _, err := s.GetByID(ctx, id, userID); err != nil {
+		return err
+	}
+
+	return s.webhookRepo.Delete(ctx, id)
+}
+
+// ListDeliveries returns the delivery log of the webhook, newest first
+func (s *webhookService) ListDeliveries(ctx context.Context, id int64, userID int64) ([]*model.WebhookDelivery, error) {
+	if _, err := s.GetByID(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	return s.deliveryRepo.GetByWebhookID(ctx, id, maxWebhookDeliveries)
+}
+
+// Redeliver queues the payload of a past delivery again as a new delivery
+func (s *webhookService) Redeliver(ctx context.Context, id int64, deliveryID int64, userID int64) (*model.WebhookDelivery, error) {
+	w, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !w.IsEnabled() {
+		return nil, ErrWebhookDisabled
+	}
+
+	original, err := s.deliveryRepo.GetByID(ctx, deliveryID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	if original.WebhookID != w.ID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	d := &model.WebhookDelivery{
+		WebhookID:     w.ID,
+		Event:         original.Event,
+		NoteID:        original.NoteID,
+		Payload:       original.Payload,
+		Status:        model.DeliveryStatusPending,
+		NextAttemptAt: time.Now().UTC().Truncate(time.Second),
+		RedeliveryOf:  model.NullInt64{NullInt64: sql.NullInt64{Int64: original.ID, Valid: true}},
+	}
+	if err := s.deliveryRepo.Create(ctx, d); err != nil {
+		return nil, err
+	}
+	s.dispatcher.Wake()
+
+	return s.deliveryRepo.GetByID(ctx, d.ID)
+}
+
+// onNoteEvent queues a delivery for every active webhook of the note owner
+// that subscribed to the event
+func (s *webhookService) onNoteEvent(ctx context.Context, e NoteEvent) error {
+	hooks, err := s.webhookRepo.GetActiveByUserID(ctx, e.Note.UserID)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	queued := false
+	for _, w := range hooks {
+		if !w.Events.Has(string(e.Type)) {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = json.Marshal(WebhookPayload{
+				Event:      e.Type,
+				OccurredAt: e.At.UTC(),
+				Note:       webhookPayloadNote(e.Note),
+				Previous:   webhookPayloadNote(e.Previous),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		d := &model.WebhookDelivery{
+			WebhookID:     w.ID,
+			Event:         string(e.Type),
+			NoteID:        e.Note.ID,
+			Payload:       string(payload),
+			Status:        model.DeliveryStatusPending,
+			NextAttemptAt: time.Now().UTC().Truncate(time.Second),
+		}
+		if err := s.deliveryRepo.Create(ctx, d); err != nil {
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		s.dispatcher.Wake()
+	}
+	return nil
+}
+
+func webhookPayloadNote(n *model.Note) *WebhookPayloadNote {
+	if n == nil {
+		return nil
+	}
+	return &WebhookPayloadNote{
+		ID:         n.ID,
+		ParentID:   n.ParentID,
+		Title:      n.Title,
+		Content:    n.Content,
+		IsFavorite: n.IsFavorited(),
+		Trashed:    n.IsTrashed(),
+		CreatedAt:  n.CreatedAt.UTC(),
+		UpdatedAt:  n.UpdatedAt.UTC(),
+	}
+}
+
+// validateWebhook checks the URL and events of w
+func validateWebhook(w *model.Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an http(s) URL", ErrInvalidWebhook)
+	}
+	if err := safehttp.CheckURL(u); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+
+	seen := make(map[string]bool, len(w.Events))
+	events := make(model.EventList, 0, len(w.Events))
+	for _, e := range w.Events {
+		known := false
+		for _, t := range webhookEvents {
+			if e == string(t) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+		if !seen[e] {
+			seen[e] = true
+			events = append(events, e)
+		}
+	}
+	w.Events = events
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
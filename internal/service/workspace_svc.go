@@ -0,0 +1,486 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ray-d-song/yan/internal/model"
+	"github.com/ray-d-song/yan/internal/repo"
+)
+
+var (
+	ErrWorkspaceNotFound     = errors.New("workspace not found")
+	ErrWorkspaceUnauthorized = errors.New("unauthorized to access this workspace")
+	ErrInvalidWorkspace      = errors.New("invalid workspace")
+	ErrWorkspaceNotEmpty     = errors.New("workspace still has notes")
+	ErrMemberNotFound        = errors.New("workspace member not found")
+	ErrAlreadyMember         = errors.New("user is already a member of this workspace")
+	ErrInvitationNotFound    = errors.New("invitation not found")
+)
+
+const (
+	maxWorkspaceNameLength = 100
+	personalWorkspaceName  = "Personal"
+)
+
+// WorkspaceService manages workspaces, which own note trees. Every user has
+// a personal workspace, created on first use, and works in one workspace at
+// a time: new top-level notes go there and note listings show it.
+type WorkspaceService interface {
+	List(ctx context.Context, userID int64) ([]*model.Workspace, error)
+	GetByID(ctx context.Context, id int64, userID int64) (*model.Workspace, error)
+	Current(ctx context.Context, userID int64) (*model.Workspace, error)
+	Switch(ctx context.Context, id int64, userID int64) (*model.Workspace, error)
+	Create(ctx context.Context, userID int64, name string) (*model.Workspace, error)
+	Rename(ctx context.Context, id int64, userID int64, name string) error
+	Delete(ctx context.Context, id int64, userID int64) error
+	MemberRole(ctx context.Context, id int64, userID int64) (string, error)
+
+	ListMembers(ctx context.Context, id int64, userID int64) ([]*model.WorkspaceMember, error)
+	UpdateMemberRole(ctx context.Context, id int64, userID int64, memberID int64, role string) error
+	RemoveMember(ctx context.Context, id int64, userID int64, memberID int64) error
+
+	Invite(ctx context.Context, id int64, userID int64, identity string, role string) (*model.WorkspaceInvitation, error)
+	ListInvitations(ctx context.Context, id int64, userID int64) ([]*model.WorkspaceInvitation, error)
+	CancelInvitation(ctx context.Context, id int64, userID int64, invitationID int64) error
+	ListMyInvitations(ctx context.Context, userID int64) ([]*model.WorkspaceInvitation, error)
+	AcceptInvitation(ctx context.Context, invitationID int64, userID int64) (*model.Workspace, error)
+	DeclineInvitation(ctx context.Context, invitationID int64, userID int64) error
+}
+
+type workspaceService struct {
+	workspaceRepo  repo.WorkspaceRepo
+	invitationRepo repo.WorkspaceInvitationRepo
+	noteRepo       repo.NoteRepo
+	userService    UserService
+}
+
+func NewWorkspaceService(
+	workspaceRepo repo.WorkspaceRepo,
+	invitationRepo repo.WorkspaceInvitationRepo,
+	noteRepo repo.NoteRepo,
+	userService UserService,
+) WorkspaceService {
+	return &workspaceService{
+		workspaceRepo:  workspaceRepo,
+		invitationRepo: invitationRepo,
+		noteRepo:       noteRepo,
+		userService:    userService,
+	}
+}
+
+// List returns the user's workspaces, marking the current one
+func (s *workspaceService) List(ctx context.Context, userID int64) ([]*model.Workspace, error) {
+	current, err := s.Current(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	workspaces, err := s.workspaceRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range workspaces {
+		w.IsCurrent = w.ID == current.ID
+	}
+
+	return workspaces, nil
+}
+
+// GetByID returns the workspace with the user's role in it
+func (s *workspaceService) GetByID(ctx context.Context, id int64, userID int64) (*model.Workspace, error) {
+	w, err := s.workspaceRepo.GetForUser(ctx, id, userID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		if _, err := s.workspaceRepo.GetByID(ctx, id); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrWorkspaceNotFound
+			}
+			return nil, err
+		}
+		return nil, ErrWorkspaceUnauthorized
+	}
+
+	return w, nil
+}
+
+// Current returns the workspace the user works in. Users who never switched,
+// or who left the workspace they were in, are back in their personal one.
+func (s *workspaceService) Current(ctx context.Context, userID int64) (*model.Workspace, error) {
+	currentID, err := s.workspaceRepo.GetCurrentID(ctx, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if currentID.Valid {
+		w, err := s.workspaceRepo.GetForUser(ctx, currentID.Int64, userID)
+		if err == nil {
+			w.IsCurrent = true
+			return w, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	w, err := s.ensurePersonal(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.workspaceRepo.SetCurrent(ctx, userID, w.ID); err != nil {
+		return nil, err
+	}
+	w.IsCurrent = true
+	return w, nil
+}
+
+// ensurePersonal returns the user's personal workspace, creating it for
+// users registered after workspaces were introduced
+func (s *workspaceService) ensurePersonal(ctx context.Context, userID int64) (*model.Workspace, error) {
+	w, err := s.workspaceRepo.GetPersonal(ctx, userID)
+	if err == nil {
+		return w, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	w = &model.Workspace{
+		Name:       personalWorkspaceName,
+		OwnerID:    userID,
+		IsPersonal: model.WorkspacePersonal,
+	}
+	if err := s.workspaceRepo.Create(ctx, w); err != nil {
+		// A concurrent request may have created it first
+		if existing, getErr := s.workspaceRepo.GetPersonal(ctx, userID); getErr == nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+// Switch makes the workspace the user's current one
+func (s *workspaceService) Switch(ctx context.Context, id int64, userID int64) (*model.Workspace, error) {
+	w, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.workspaceRepo.SetCurrent(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	w.IsCurrent = true
+	return w, nil
+}
+
+func (s *workspaceService) Create(ctx context.Context, userID int64, name string) (*model.Workspace, error) {
+	name, err := normalizeWorkspaceName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &model.Workspace{
+		Name:       name,
+		OwnerID:    userID,
+		IsPersonal: model.WorkspaceShared,
+	}
+	if err := s.workspaceRepo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (s *workspaceService) Rename(ctx context.Context, id int64, userID int64, name string) error {
+	if _, err := s.authorize(ctx, id, userID, model.WorkspaceRoleAdmin); err != nil {
+		return err
+	}
+
+	name, err := normalizeWorkspaceName(name)
+	if err != nil {
+		return err
+	}
+
+	return s.workspaceRepo.UpdateName(ctx, id, name)
+}
+
+// Delete removes a workspace of the owner. Its notes must be deleted or
+// moved out first, and personal workspaces cannot be deleted.
+func (s *workspaceService) Delete(ctx context.Context, id int64, userID int64) error {
+	w, err := s.authorize(ctx, id, userID, model.WorkspaceRoleOwner)
+	if err != nil {
+		return err
+	}
+	if w.IsPersonalWorkspace() {
+		return fmt.Errorf("%w: a personal workspace cannot be deleted", ErrInvalidWorkspace)
+	}
+
+	count, err := s.noteRepo.CountByWorkspaceID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrWorkspaceNotEmpty
+	}
+
+	return s.workspaceRepo.Delete(ctx, id)
+}
+
+// MemberRole returns the user's role in the workspace, or "" when they are
+// not a member
+func (s *workspaceService) MemberRole(ctx context.Context, id int64, userID int64) (string, error) {
+	m, err := s.workspaceRepo.GetMember(ctx, id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return m.Role, nil
+}
+
+func (s *workspaceService) ListMembers(ctx context.Context, id int64, userID int64) ([]*model.WorkspaceMember, error) {
+	if _, err := s.GetByID(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	return s.workspaceRepo.GetMembers(ctx, id)
+}
+
+// UpdateMemberRole changes the role of a member. Admins manage members and
+// guests; only the owner changes the role of admins.
+func (s *workspaceService) UpdateMemberRole(ctx context.Context, id int64, userID int64, memberID int64, role string) error {
+	if !model.IsAssignableWorkspaceRole(role) {
+		return fmt.Errorf("%w: role must be admin, member or guest", ErrInvalidWorkspace)
+	}
+	w, err := s.authorize(ctx, id, userID, model.WorkspaceRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	member, err := s.getMember(ctx, id, memberID)
+	if err != nil {
+		return err
+	}
+	if member.Role == model.WorkspaceRoleOwner {
+		return fmt.Errorf("%w: the owner's role cannot change", ErrInvalidWorkspace)
+	}
+	if member.Role == model.WorkspaceRoleAdmin && w.Role != model.WorkspaceRoleOwner {
+		return ErrWorkspaceUnauthorized
+	}
+
+	return s.workspaceRepo.UpdateMemberRole(ctx, id, memberID, role)
+}
+
+// RemoveMember removes a member from the workspace. Members may leave on
+// their own, except the owner; removing others follows the rules of
+// UpdateMemberRole.
+func (s *workspaceService) RemoveMember(ctx context.Context, id int64, userID int64, memberID int64) error {
+	w, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	member, err := s.getMember(ctx, id, memberID)
+	if err != nil {
+		return err
+	}
+	if member.Role == model.WorkspaceRoleOwner {
+		return fmt.Errorf("%w: the owner cannot leave the workspace", ErrInvalidWorkspace)
+	}
+	if memberID != userID {
+		if !model.WorkspaceRoleAllows(w.Role, model.WorkspaceRoleAdmin) {
+			return ErrWorkspaceUnauthorized
+		}
+		if member.Role == model.WorkspaceRoleAdmin && w.Role != model.WorkspaceRoleOwner {
+			return ErrWorkspaceUnauthorized
+		}
+	}
+
+	return s.workspaceRepo.RemoveMember(ctx, id, memberID)
+}
+
+// Invite invites the user identified by email or username to join the
+// workspace with the role. Inviting someone with a pending invitation
+// updates its role.
+func (s *workspaceService) Invite(ctx context.Context, id int64, userID int64, identity string, role string) (*model.WorkspaceInvitation, error) {
+	if !model.IsAssignableWorkspaceRole(role) {
+		return nil, fmt.Errorf("%w: role must be admin, member or guest", ErrInvalidWorkspace)
+	}
+	w, err := s.authorize(ctx, id, userID, model.WorkspaceRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if role == model.WorkspaceRoleAdmin && w.Role != model.WorkspaceRoleOwner {
+		return nil, ErrWorkspaceUnauthorized
+	}
+
+	invitee, err := s.userService.FindByIdentity(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	memberRole, err := s.MemberRole(ctx, id, invitee.ID)
+	if err != nil {
+		return nil, err
+	}
+	if memberRole != "" {
+		return nil, ErrAlreadyMember
+	}
+
+	existing, err := s.invitationRepo.GetPending(ctx, id, invitee.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if existing != nil {
+		if err := s.invitationRepo.UpdateRole(ctx, existing.ID, role, userID); err != nil {
+			return nil, err
+		}
+		return s.invitationRepo.GetByID(ctx, existing.ID)
+	}
+
+	i := &model.WorkspaceInvitation{
+		WorkspaceID: id,
+		UserID:      invitee.ID,
+		Role:        role,
+		InvitedBy:   userID,
+	}
+	if err := s.invitationRepo.Create(ctx, i); err != nil {
+		return nil, err
+	}
+
+	return s.invitationRepo.GetByID(ctx, i.ID)
+}
+
+func (s *workspaceService) ListInvitations(ctx context.Context, id int64, userID int64) ([]*model.WorkspaceInvitation, error) {
+	if _, err := s.authorize(ctx, id, userID, model.WorkspaceRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return s.invitationRepo.GetPendingByWorkspaceID(ctx, id)
+}
+
+func (s *workspaceService) CancelInvitation(ctx context.Context, id int64, userID int64, invitationID int64) error {
+	if _, err := s.authorize(ctx, id, userID, model.WorkspaceRoleAdmin); err != nil {
+		return err
+	}
+
+	i, err := s.invitationRepo.GetByID(ctx, invitationID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrInvitationNotFound
+		}
+		return err
+	}
+	if i.WorkspaceID != id {
+		return ErrInvitationNotFound
+	}
+
+	return s.resolveInvitation(ctx, i.ID, model.InvitationCanceled)
+}
+
+func (s *workspaceService) ListMyInvitations(ctx context.Context, userID int64) ([]*model.WorkspaceInvitation, error) {
+	return s.invitationRepo.GetPendingByUserID(ctx, userID)
+}
+
+// AcceptInvitation adds the user to the workspace of the invitation and
+// returns the workspace
+func (s *workspaceService) AcceptInvitation(ctx context.Context, invitationID int64, userID int64) (*model.Workspace, error) {
+	i, err := s.getInvitation(ctx, invitationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := s.invitationRepo.Accept(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvitationNotFound
+	}
+
+	return s.GetByID(ctx, i.WorkspaceID, userID)
+}
+
+func (s *workspaceService) DeclineInvitation(ctx context.Context, invitationID int64, userID int64) error {
+	i, err := s.getInvitation(ctx, invitationID, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.resolveInvitation(ctx, i.ID, model.InvitationDeclined)
+}
+
+// authorize returns the workspace if the user's role in it is at least role
+func (s *workspaceService) authorize(ctx context.Context, id int64, userID int64, role string) (*model.Workspace, error) {
+	w, err := s.GetByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !model.WorkspaceRoleAllows(w.Role, role) {
+		return nil, ErrWorkspaceUnauthorized
+	}
+
+	return w, nil
+}
+
+func (s *workspaceService) getMember(ctx context.Context, id int64, userID int64) (*model.WorkspaceMember, error) {
+	m, err := s.workspaceRepo.GetMember(ctx, id, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// getInvitation returns a pending invitation of the user
+func (s *workspaceService) getInvitation(ctx context.Context, invitationID int64, userID int64) (*model.WorkspaceInvitation, error) {
+	i, err := s.invitationRepo.GetByID(ctx, invitationID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	if i.UserID != userID || !i.IsPending() {
+		return nil, ErrInvitationNotFound
+	}
+
+	return i, nil
+}
+
+func (s *workspaceService) resolveInvitation(ctx context.Context, invitationID int64, status string) error {
+	resolved, err := s.invitationRepo.Resolve(ctx, invitationID, status)
+	if err != nil {
+		return err
+	}
+	if !resolved {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+func normalizeWorkspaceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidWorkspace)
+	}
+	if utf8.RuneCountInString(name) > maxWorkspaceNameLength {
+		return "", fmt.Errorf("%w: name is longer than %d characters", ErrInvalidWorkspace, maxWorkspaceNameLength)
+	}
+
+	return name, nil
+}